@@ -12,6 +12,9 @@ import (
 	"xiaozhi-esp32-server-golang/internal/app/server/auth"
 	redisdb "xiaozhi-esp32-server-golang/internal/db/redis"
 	user_config "xiaozhi-esp32-server-golang/internal/domain/config"
+	llm_memory "xiaozhi-esp32-server-golang/internal/domain/llm/memory"
+	"xiaozhi-esp32-server-golang/internal/domain/recording"
+	tts_cache "xiaozhi-esp32-server-golang/internal/domain/tts/cache"
 
 	log "xiaozhi-esp32-server-golang/logger"
 
@@ -60,6 +63,21 @@ func Init(configFile string) error {
 	//init redis
 	initRedis()
 
+	//init memory
+	if err := llm_memory.Init(); err != nil {
+		fmt.Printf("init memory error: %v\n", err)
+	}
+
+	//init recording
+	if err := recording.Init(); err != nil {
+		fmt.Printf("init recording error: %v\n", err)
+	}
+
+	//init tts cache
+	if err := tts_cache.Init(); err != nil {
+		fmt.Printf("init tts cache error: %v\n", err)
+	}
+
 	//init auth
 	err = initAuthManager()
 	if err != nil {
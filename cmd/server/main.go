@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net/http"
@@ -8,7 +9,9 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 	"xiaozhi-esp32-server-golang/internal/app/server"
+	"xiaozhi-esp32-server-golang/internal/domain/tracing"
 	log "xiaozhi-esp32-server-golang/logger"
 
 	"github.com/spf13/viper"
@@ -43,6 +46,12 @@ func main() {
 		log.Info("pprof服务已禁用")
 	}
 
+	// 初始化链路追踪
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.LoadConfig())
+	if err != nil {
+		log.Errorf("初始化链路追踪失败: %v", err)
+	}
+
 	// 创建服务器
 	appInstance := server.NewApp()
 	appInstance.Run()
@@ -59,5 +68,14 @@ func main() {
 	// 停止周期性配置更新服务
 	StopPeriodicConfigUpdate()
 
+	// 上报剩余的链路数据
+	if shutdownTracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := shutdownTracing(ctx); err != nil {
+			log.Warnf("关闭链路追踪失败: %v", err)
+		}
+		cancel()
+	}
+
 	log.Info("服务器已关闭")
 }
@@ -6,15 +6,18 @@ const (
 )
 
 const (
-	AsrTypeFunAsr = "funasr"
-	AsrTypeDoubao = "doubao"
+	AsrTypeFunAsr  = "funasr"
+	AsrTypeDoubao  = "doubao"
+	AsrTypeWhisper = "whisper"
 )
 
 const (
-	LlmTypeOpenai  = "openai"
-	LlmTypeOllama  = "ollama"
-	LlmTypeEinoLLM = "eino_llm"
-	LlmTypeEino    = "eino"
+	LlmTypeOpenai   = "openai"
+	LlmTypeOllama   = "ollama"
+	LlmTypeEinoLLM  = "eino_llm"
+	LlmTypeEino     = "eino"
+	LlmTypeRealtime = "realtime"
+	LlmTypeRouter   = "router"
 )
 
 const (
@@ -24,4 +27,5 @@ const (
 	TtsTypeEdge        = "edge"
 	TtsTypeEdgeOffline = "edge_offline"
 	TtsTypeXiaozhi     = "xiaozhi"
+	TtsTypeChain       = "chain"
 )
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"xiaozhi-esp32-server-golang/internal/app/server/chat"
+	llm_memory "xiaozhi-esp32-server-golang/internal/domain/llm/memory"
+	"xiaozhi-esp32-server-golang/internal/domain/recording"
+	log "xiaozhi-esp32-server-golang/logger"
+
+	"github.com/spf13/viper"
+)
+
+// registerAdminAPI 注册会话管理接口, 需配置 admin_api.token, 请求时通过 Authorization: Bearer <token> 认证
+func (a *App) registerAdminAPI(mux *http.ServeMux) {
+	if !viper.GetBool("admin_api.enable") {
+		return
+	}
+	token := viper.GetString("admin_api.token")
+	if token == "" {
+		log.Warn("admin_api.token 未配置, 会话管理接口未启用")
+		return
+	}
+
+	mux.Handle("GET /admin/sessions", adminAuth(token, a.handleListSessions))
+	mux.Handle("GET /admin/sessions/{device_id}", adminAuth(token, a.handleGetSession))
+	mux.Handle("GET /admin/sessions/{device_id}/dialogue", adminAuth(token, a.handleGetDialogue))
+	mux.Handle("DELETE /admin/sessions/{device_id}", adminAuth(token, a.handleCloseSession))
+	mux.Handle("POST /admin/sessions/{device_id}/reload_config", adminAuth(token, a.handleReloadConfig))
+
+	// 对话记录, 设备无需在线
+	mux.Handle("GET /admin/devices/{device_id}/conversations", adminAuth(token, a.handleListConversations))
+	mux.Handle("GET /admin/devices/{device_id}/conversations/export", adminAuth(token, a.handleExportConversations))
+	mux.Handle("GET /admin/devices/{device_id}/conversations/{conversation_id}", adminAuth(token, a.handleGetConversation))
+	mux.Handle("DELETE /admin/devices/{device_id}/conversations/{conversation_id}", adminAuth(token, a.handleDeleteConversation))
+
+	// 对话录音, file 为 input.wav 或 output.ogg
+	mux.Handle("GET /admin/devices/{device_id}/recordings/{turn_id}/{file}", adminAuth(token, a.handleDownloadRecording))
+
+	log.Info("会话管理接口已启用: /admin/sessions, /admin/devices")
+}
+
+// adminAuth 校验管理接口的 Bearer token
+func adminAuth(token string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(authToken), []byte(token)) != 1 {
+			writeAdminError(w, http.StatusUnauthorized, "无效的token")
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (a *App) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions := make([]chat.SessionInfo, 0, a.GetChatManagerCount())
+	for _, manager := range a.GetAllChatManagers() {
+		sessions = append(sessions, manager.GetSessionInfo())
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].DeviceID < sessions[j].DeviceID
+	})
+	writeAdminJSON(w, map[string]interface{}{
+		"total":    len(sessions),
+		"sessions": sessions,
+	})
+}
+
+func (a *App) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	manager, ok := a.lookupChatManager(w, r)
+	if !ok {
+		return
+	}
+	writeAdminJSON(w, manager.GetSessionInfo())
+}
+
+func (a *App) handleGetDialogue(w http.ResponseWriter, r *http.Request) {
+	manager, ok := a.lookupChatManager(w, r)
+	if !ok {
+		return
+	}
+	writeAdminJSON(w, map[string]interface{}{
+		"device_id": manager.DeviceID,
+		"messages":  manager.GetDialogue(),
+	})
+}
+
+func (a *App) handleCloseSession(w http.ResponseWriter, r *http.Request) {
+	deviceID := r.PathValue("device_id")
+	if !a.CloseChatManager(deviceID) {
+		writeAdminError(w, http.StatusNotFound, "设备不在线")
+		return
+	}
+	log.Infof("管理接口关闭设备 %s 的会话", deviceID)
+	writeAdminJSON(w, map[string]interface{}{"device_id": deviceID, "closed": true})
+}
+
+func (a *App) handleReloadConfig(w http.ResponseWriter, r *http.Request) {
+	manager, ok := a.lookupChatManager(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	if err := manager.ReloadConfig(ctx); err != nil {
+		log.Errorf("设备 %s 重新加载配置失败: %v", manager.DeviceID, err)
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAdminJSON(w, manager.GetSessionInfo())
+}
+
+func (a *App) handleListConversations(w http.ResponseWriter, r *http.Request) {
+	deviceID := r.PathValue("device_id")
+	conversations, err := llm_memory.Get().ListConversations(r.Context(), deviceID)
+	if err != nil {
+		log.Errorf("获取设备 %s 会话列表失败: %v", deviceID, err)
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAdminJSON(w, map[string]interface{}{
+		"device_id":     deviceID,
+		"total":         len(conversations),
+		"conversations": conversations,
+	})
+}
+
+func (a *App) handleGetConversation(w http.ResponseWriter, r *http.Request) {
+	deviceID := r.PathValue("device_id")
+	conversationID := r.PathValue("conversation_id")
+	messages, err := llm_memory.Get().GetConversation(r.Context(), deviceID, conversationID)
+	if err != nil {
+		log.Errorf("获取设备 %s 会话 %s 失败: %v", deviceID, conversationID, err)
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(messages) == 0 {
+		writeAdminError(w, http.StatusNotFound, "会话不存在")
+		return
+	}
+	writeAdminJSON(w, map[string]interface{}{
+		"device_id":       deviceID,
+		"conversation_id": conversationID,
+		"messages":        messages,
+	})
+}
+
+func (a *App) handleDeleteConversation(w http.ResponseWriter, r *http.Request) {
+	deviceID := r.PathValue("device_id")
+	conversationID := r.PathValue("conversation_id")
+	if err := llm_memory.Get().DeleteConversation(r.Context(), deviceID, conversationID); err != nil {
+		log.Errorf("删除设备 %s 会话 %s 失败: %v", deviceID, conversationID, err)
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Infof("管理接口删除设备 %s 的会话 %s", deviceID, conversationID)
+	writeAdminJSON(w, map[string]interface{}{"device_id": deviceID, "conversation_id": conversationID, "deleted": true})
+}
+
+func (a *App) handleExportConversations(w http.ResponseWriter, r *http.Request) {
+	deviceID := r.PathValue("device_id")
+	exports, err := llm_memory.Get().Export(r.Context(), deviceID)
+	if err != nil {
+		log.Errorf("导出设备 %s 对话记录失败: %v", deviceID, err)
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", deviceID+"_conversations.json"))
+	writeAdminJSON(w, map[string]interface{}{
+		"device_id":     deviceID,
+		"exported_at":   time.Now(),
+		"conversations": exports,
+	})
+}
+
+func (a *App) handleDownloadRecording(w http.ResponseWriter, r *http.Request) {
+	recorder := recording.Get()
+	if recorder == nil {
+		writeAdminError(w, http.StatusNotFound, "未启用对话录音")
+		return
+	}
+	deviceID := r.PathValue("device_id")
+	turnID := r.PathValue("turn_id")
+	file := r.PathValue("file")
+	reader, err := recorder.Open(r.Context(), deviceID, turnID, file)
+	if errors.Is(err, recording.ErrNotFound) {
+		writeAdminError(w, http.StatusNotFound, "录音不存在")
+		return
+	}
+	if err != nil {
+		log.Errorf("读取设备 %s 第 %s 轮录音失败: %v", deviceID, turnID, err)
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer reader.Close()
+
+	contentType := "audio/wav"
+	if file == recording.OutputFileName {
+		contentType = "audio/ogg"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", turnID+"_"+file))
+	if _, err := io.Copy(w, reader); err != nil {
+		log.Errorf("下载设备 %s 第 %s 轮录音失败: %v", deviceID, turnID, err)
+	}
+}
+
+func (a *App) lookupChatManager(w http.ResponseWriter, r *http.Request) (*chat.ChatManager, bool) {
+	manager, ok := a.GetChatManager(r.PathValue("device_id"))
+	if !ok {
+		writeAdminError(w, http.StatusNotFound, "设备不在线")
+	}
+	return manager, ok
+}
+
+func writeAdminJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Errorf("写入管理接口响应失败: %v", err)
+	}
+}
+
+func writeAdminError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
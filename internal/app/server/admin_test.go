@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"xiaozhi-esp32-server-golang/internal/app/server/chat"
+
+	cmap "github.com/orcaman/concurrent-map/v2"
+	"github.com/spf13/viper"
+)
+
+func TestAdminAPI(t *testing.T) {
+	viper.Set("admin_api.enable", true)
+	viper.Set("admin_api.token", "secret")
+	defer viper.Set("admin_api.enable", false)
+
+	app := &App{chatManagers: cmap.New[*chat.ChatManager]()}
+	mux := http.NewServeMux()
+	app.registerAdminAPI(mux)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"缺少token", http.MethodGet, "/admin/sessions", "", http.StatusUnauthorized},
+		{"错误token", http.MethodGet, "/admin/sessions", "wrong", http.StatusUnauthorized},
+		{"会话列表", http.MethodGet, "/admin/sessions", "secret", http.StatusOK},
+		{"设备不在线", http.MethodGet, "/admin/sessions/dev-1/dialogue", "secret", http.StatusNotFound},
+		{"关闭不在线设备", http.MethodDelete, "/admin/sessions/dev-1", "secret", http.StatusNotFound},
+		{"重载不在线设备", http.MethodPost, "/admin/sessions/dev-1/reload_config", "secret", http.StatusNotFound},
+		{"方法不支持", http.MethodPost, "/admin/sessions", "secret", http.StatusMethodNotAllowed},
+		{"对话记录列表", http.MethodGet, "/admin/devices/dev-1/conversations", "secret", http.StatusOK},
+		{"导出对话记录", http.MethodGet, "/admin/devices/dev-1/conversations/export", "secret", http.StatusOK},
+		{"会话不存在", http.MethodGet, "/admin/devices/dev-1/conversations/s1", "secret", http.StatusNotFound},
+		{"删除会话", http.MethodDelete, "/admin/devices/dev-1/conversations/s1", "secret", http.StatusOK},
+		{"未启用录音", http.MethodGet, "/admin/devices/dev-1/recordings/turn-1/input.wav", "secret", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, 期望 %d, body: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/sessions", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	var resp struct {
+		Total    int                `json:"total"`
+		Sessions []chat.SessionInfo `json:"sessions"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	if resp.Total != 0 || resp.Sessions == nil {
+		t.Fatalf("响应错误: %s", rec.Body.String())
+	}
+}
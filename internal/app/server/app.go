@@ -4,6 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 	"xiaozhi-esp32-server-golang/internal/app/mqtt_server"
 	"xiaozhi-esp32-server-golang/internal/app/server/chat"
@@ -33,6 +36,8 @@ func NewApp() *App {
 	app := &App{
 		chatManagers: cmap.New[*chat.ChatManager](),
 	}
+	app.registerMetrics()
+	app.registerAdminAPI(http.DefaultServeMux)
 	app.wsServer = app.newWebSocketServer()
 	app.mqttUdpAdapter, err = app.newMqttUdpAdapter()
 	if err != nil {
@@ -242,6 +247,7 @@ func (a *App) registerHandler() {
 		return
 	}
 	provider.RegisterMessageEventHandler(context.Background(), config_types.EventHandleMessageInject, a.HandleInjectMsg)
+	provider.RegisterMessageEventHandler(context.Background(), config_types.EventHandleConfigChanged, a.HandleConfigChanged)
 }
 
 // 向客户端注入消息
@@ -288,3 +294,63 @@ func (a *App) HandleInjectMsg(ctx context.Context, eventType string, eventData m
 
 	return "message injected successfully", nil
 }
+
+const (
+	configReloadConcurrency = 16               // 配置变更时同时重新加载的设备数
+	configReloadTimeout     = 10 * time.Second // 单个设备重新加载配置的超时时间
+)
+
+// HandleConfigChanged 管理后台修改了设备、智能体或提供者配置, 重新加载受影响的在线设备配置
+// 按 device_ids / agent_ids 匹配, all 为 true 时重新加载全部在线设备; 新配置在下一轮对话开始前生效, 无需设备重连
+func (a *App) HandleConfigChanged(ctx context.Context, eventType string, eventData map[string]interface{}) (string, error) {
+	type ConfigChanged struct {
+		All       bool     `json:"all"`
+		AgentIds  []string `json:"agent_ids"`
+		DeviceIds []string `json:"device_ids"`
+	}
+	bodyBytes, _ := json.Marshal(eventData)
+	var msg ConfigChanged
+	if err := json.Unmarshal(bodyBytes, &msg); err != nil {
+		log.Errorf("HandleConfigChanged error: %+v", err)
+		return "", fmt.Errorf("HandleConfigChanged error")
+	}
+	if !msg.All && len(msg.AgentIds) == 0 && len(msg.DeviceIds) == 0 {
+		return "", fmt.Errorf("all, agent_ids or device_ids is required")
+	}
+
+	agentIds := make(map[string]bool, len(msg.AgentIds))
+	for _, agentId := range msg.AgentIds {
+		agentIds[agentId] = true
+	}
+	deviceIds := make(map[string]bool, len(msg.DeviceIds))
+	for _, deviceId := range msg.DeviceIds {
+		deviceIds[deviceId] = true
+	}
+
+	// 各设备并发加载, 总耗时不随在线设备数增长, 同时限制并发数避免压垮配置服务和提供者
+	var reloaded atomic.Int32
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, configReloadConcurrency)
+	for deviceId, chatManager := range a.GetAllChatManagers() {
+		if !msg.All && !deviceIds[deviceId] && !agentIds[chatManager.GetAgentID()] {
+			continue
+		}
+		wg.Add(1)
+		go func(deviceId string, chatManager *chat.ChatManager) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			reloadCtx, cancel := context.WithTimeout(ctx, configReloadTimeout)
+			defer cancel()
+			if err := chatManager.ReloadConfig(reloadCtx); err != nil {
+				log.Errorf("HandleConfigChanged: 设备 %s 重新加载配置失败: %v", deviceId, err)
+				return
+			}
+			reloaded.Add(1)
+		}(deviceId, chatManager)
+	}
+	wg.Wait()
+
+	log.Infof("HandleConfigChanged: all: %v, agent_ids: %v, device_ids: %v, 已重新加载 %d 个在线设备", msg.All, msg.AgentIds, msg.DeviceIds, reloaded.Load())
+	return fmt.Sprintf("%d online devices reloaded", reloaded.Load()), nil
+}
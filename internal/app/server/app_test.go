@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"xiaozhi-esp32-server-golang/internal/app/server/chat"
+	config_types "xiaozhi-esp32-server-golang/internal/domain/config/types"
+
+	cmap "github.com/orcaman/concurrent-map/v2"
+)
+
+func TestHandleConfigChanged(t *testing.T) {
+	app := &App{chatManagers: cmap.New[*chat.ChatManager]()}
+
+	tests := []struct {
+		name      string
+		eventData map[string]interface{}
+		wantErr   bool
+	}{
+		{"缺少参数", map[string]interface{}{}, true},
+		{"参数类型错误", map[string]interface{}{"agent_ids": "1"}, true},
+		{"按智能体", map[string]interface{}{"agent_ids": []string{"1"}}, false},
+		{"按设备", map[string]interface{}{"device_ids": []string{"dev-1"}}, false},
+		{"全部设备", map[string]interface{}{"all": true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := app.HandleConfigChanged(context.Background(), config_types.EventHandleConfigChanged, tt.eventData)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && result != "0 online devices reloaded" {
+				t.Fatalf("result = %s", result)
+			}
+		})
+	}
+}
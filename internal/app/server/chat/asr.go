@@ -6,6 +6,7 @@ import (
 	"time"
 	. "xiaozhi-esp32-server-golang/internal/data/client"
 	"xiaozhi-esp32-server-golang/internal/domain/audio"
+	"xiaozhi-esp32-server-golang/internal/domain/metrics"
 	log "xiaozhi-esp32-server-golang/logger"
 )
 
@@ -14,6 +15,29 @@ type ASRManagerOption func(*ASRManager)
 type ASRManager struct {
 	clientState     *ClientState
 	serverTransport *ServerTransport
+
+	bargeIn   *bargeInDetector
+	onBargeIn func() error
+
+	audioRecorder *turnAudioRecorder
+}
+
+// WithBargeIn 启用服务端插话检测，播放期间检测到持续语音时调用 onBargeIn
+func WithBargeIn(config BargeInConfig, onBargeIn func() error) ASRManagerOption {
+	return func(a *ASRManager) {
+		if !config.Enable {
+			return
+		}
+		a.bargeIn = newBargeInDetector(config)
+		a.onBargeIn = onBargeIn
+	}
+}
+
+// WithASRAudioRecorder 记录送入ASR的用户语音
+func WithASRAudioRecorder(recorder *turnAudioRecorder) ASRManagerOption {
+	return func(a *ASRManager) {
+		a.audioRecorder = recorder
+	}
 }
 
 func NewASRManager(clientState *ClientState, serverTransport *ServerTransport, opts ...ASRManagerOption) *ASRManager {
@@ -31,6 +55,9 @@ func NewASRManager(clientState *ClientState, serverTransport *ServerTransport, o
 func (a *ASRManager) ProcessVadAudio(ctx context.Context, onClose func()) {
 	state := a.clientState
 	go func() {
+		// 插话检测保留的vad实例在音频处理结束时释放, 避免与检测并发
+		defer state.Vad.Release()
+
 		audioFormat := state.InputAudioFormat
 		audioProcesser, err := audio.GetAudioProcesser(audioFormat.SampleRate, audioFormat.Channels, audioFormat.FrameDuration)
 		if err != nil {
@@ -57,19 +84,27 @@ func (a *ASRManager) ProcessVadAudio(ctx context.Context, onClose func()) {
 
 				var skipVad bool
 				var haveVoice bool
-				clientHaveVoice := state.GetClientHaveVoice()
+				clientHaveVoice := state.StateMachine.HaveVoice()
 				if state.Asr.AutoEnd || state.ListenMode == "manual" {
 					skipVad = true         //跳过vad
 					clientHaveVoice = true //之前有声音
 					haveVoice = true       //本次有声音
 				}
 
-				if state.GetClientVoiceStop() { //已停止 说话 则不接收音频数据
+				if state.StateMachine.VoiceStopped() { //已停止 说话 则不接收音频数据
 					//log.Infof("客户端停止说话, 跳过音频数据")
+					if a.IsBargeInListening() {
+						// 播放期间仍检测用户是否插话
+						if n, err := audioProcesser.DecoderFloat32(opusFrame, pcmFrame); err == nil {
+							a.detectBargeIn(pcmFrame[:n], audioFormat.SampleRate, frameSize, audioFormat.FrameDuration)
+						}
+					} else if a.bargeIn != nil {
+						a.bargeIn.Reset()
+					}
 					continue
 				}
 
-				//log.Debugf("clientVoiceStop: %+v, asrDataSize: %d, listenMode: %s, isSkipVad: %v\n", state.GetClientVoiceStop(), state.AsrAudioBuffer.GetAsrDataSize(), state.ListenMode, skipVad)
+				//log.Debugf("clientVoiceStop: %+v, asrDataSize: %d, listenMode: %s, isSkipVad: %v\n", state.StateMachine.VoiceStopped(), state.AsrAudioBuffer.GetAsrDataSize(), state.ListenMode, skipVad)
 
 				n, err := audioProcesser.DecoderFloat32(opusFrame, pcmFrame)
 				if err != nil {
@@ -81,13 +116,16 @@ func (a *ASRManager) ProcessVadAudio(ctx context.Context, onClose func()) {
 				pcmData := pcmFrame[:n]
 				if !skipVad {
 					//如果已经检测到语音, 则不进行vad检测, 直接将pcmData传给asr
-					if state.VadProvider == nil {
+					vadProvider := state.Vad.Provider()
+					if vadProvider == nil {
 						// 初始化vad
 						err = state.Vad.Init(state.DeviceConfig.Vad.Provider, state.DeviceConfig.Vad.Config)
 						if err != nil {
 							log.Errorf("初始化vad失败: %v", err)
+							metrics.ObserveProviderError(metrics.KindVad, state.DeviceConfig.Vad.Provider)
 							continue
 						}
+						vadProvider = state.Vad.Provider()
 					}
 					//decode opus to pcm
 					state.AsrAudioBuffer.AddAsrAudioData(pcmData)
@@ -95,11 +133,12 @@ func (a *ASRManager) ProcessVadAudio(ctx context.Context, onClose func()) {
 					if state.AsrAudioBuffer.GetAsrDataSize() >= vadNeedGetCount*state.AsrAudioBuffer.PcmFrameSize {
 						//如果要进行vad, 至少要取60ms的音频数据
 						vadPcmData = state.AsrAudioBuffer.GetAsrData(vadNeedGetCount)
-						state.VadProvider.Reset()
-						haveVoice, err = state.VadProvider.IsVADExt(vadPcmData, audioFormat.SampleRate, frameSize)
+						vadProvider.Reset()
+						haveVoice, err = vadProvider.IsVADExt(vadPcmData, audioFormat.SampleRate, frameSize)
 
 						if err != nil {
 							log.Errorf("processAsrAudio VAD检测失败: %v", err)
+							metrics.ObserveProviderError(metrics.KindVad, state.DeviceConfig.Vad.Provider)
 							//删除
 							continue
 						}
@@ -126,7 +165,9 @@ func (a *ASRManager) ProcessVadAudio(ctx context.Context, onClose func()) {
 
 				if haveVoice {
 					//log.Infof("检测到语音, len: %d", len(pcmData))
-					state.SetClientHaveVoice(true)
+					if !clientHaveVoice {
+						state.StateMachine.Fire(EventVoiceStart)
+					}
 					state.SetClientHaveVoiceLastTime(time.Now().UnixMilli())
 					if !state.Asr.AutoEnd {
 						state.Vad.ResetIdleDuration()
@@ -146,6 +187,7 @@ func (a *ASRManager) ProcessVadAudio(ctx context.Context, onClose func()) {
 					//vad识别成功, 往asr音频通道里发送数据
 					//log.Infof("vad识别成功, 往asr音频通道里发送数据, len: %d", len(pcmData))
 					state.Asr.AddAudioData(pcmData)
+					a.audioRecorder.AddInput(pcmData)
 				}
 
 				//已经有语音了, 但本次没有检测到语音, 则需要判断是否已经停止说话
@@ -178,6 +220,7 @@ func (a *ASRManager) RestartAsrRecognition(ctx context.Context) error {
 
 	state.VoiceStatus.Reset()
 	state.AsrAudioBuffer.ClearAsrAudioData()
+	a.audioRecorder.DiscardInput()
 
 	// 等待一小段时间让资源清理
 	select {
@@ -194,6 +237,9 @@ func (a *ASRManager) RestartAsrRecognition(ctx context.Context) error {
 	asrResultChannel, err := state.AsrProvider.StreamingRecognize(state.Asr.Ctx, state.Asr.AsrAudioChannel)
 	if err != nil {
 		log.Errorf("重启ASR流式识别失败: %v", err)
+		if ctx.Err() == nil {
+			metrics.ObserveProviderError(metrics.KindAsr, state.DeviceConfig.Asr.Provider)
+		}
 		return fmt.Errorf("重启ASR流式识别失败: %v", err)
 	}
 
@@ -0,0 +1,165 @@
+package chat
+
+import (
+	"math"
+	"time"
+
+	. "xiaozhi-esp32-server-golang/internal/data/client"
+	"xiaozhi-esp32-server-golang/internal/domain/metrics"
+	log "xiaozhi-esp32-server-golang/logger"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultBargeInMinSpeechMs = 300  // 默认持续语音时长阈值（毫秒）
+	defaultBargeInMinEnergy   = 0.02 // 默认能量(RMS)阈值，过滤设备扬声器的回声
+	maxBargeInPrerollMs       = 2000 // 插话触发前缓存的最大音频时长（毫秒）
+
+	// 插话后该时长内设备发来的 listen start 视为设备对 tts stop 的响应
+	bargeInListenStartWindow = 3 * time.Second
+)
+
+// BargeInConfig 服务端插话检测配置
+type BargeInConfig struct {
+	Enable      bool    // 是否启用
+	MinSpeechMs int64   // 持续语音达到该时长才触发打断
+	MinEnergy   float64 // 帧能量(RMS)低于该值视为回声或噪声
+}
+
+// LoadBargeInConfig 从配置文件 chat.barge_in 读取插话检测配置
+func LoadBargeInConfig() BargeInConfig {
+	config := BargeInConfig{
+		Enable:      viper.GetBool("chat.barge_in.enable"),
+		MinSpeechMs: viper.GetInt64("chat.barge_in.min_speech_duration"),
+		MinEnergy:   viper.GetFloat64("chat.barge_in.min_energy"),
+	}
+	if config.MinSpeechMs <= 0 {
+		config.MinSpeechMs = defaultBargeInMinSpeechMs
+	}
+	if config.MinEnergy <= 0 {
+		config.MinEnergy = defaultBargeInMinEnergy
+	}
+	return config
+}
+
+// bargeInDetector 在播放期间检测用户是否持续说话
+// 只有连续的有声帧（VAD判定为语音且能量超过阈值）累计达到 MinSpeechMs 才触发，中间出现静音帧则重新计数
+type bargeInDetector struct {
+	config   BargeInConfig
+	speechMs int64
+	preroll  []float32 // 本段语音的pcm数据，触发后送入新一轮asr，避免丢失开头
+}
+
+func newBargeInDetector(config BargeInConfig) *bargeInDetector {
+	return &bargeInDetector{config: config}
+}
+
+// Feed 输入一帧检测结果，返回是否触发插话
+func (b *bargeInDetector) Feed(pcmData []float32, haveVoice bool, frameDuration int, sampleRate int) bool {
+	if !haveVoice || frameEnergy(pcmData) < b.config.MinEnergy {
+		b.Reset()
+		return false
+	}
+
+	b.speechMs += int64(frameDuration)
+	b.preroll = append(b.preroll, pcmData...)
+	if maxSamples := sampleRate * maxBargeInPrerollMs / 1000; len(b.preroll) > maxSamples {
+		b.preroll = b.preroll[len(b.preroll)-maxSamples:]
+	}
+	return b.speechMs >= b.config.MinSpeechMs
+}
+
+// TakePreroll 取出并清空缓存的语音数据
+func (b *bargeInDetector) TakePreroll() []float32 {
+	preroll := b.preroll
+	b.Reset()
+	return preroll
+}
+
+func (b *bargeInDetector) Reset() {
+	b.speechMs = 0
+	b.preroll = nil
+}
+
+// bargeInListen 插话开始的拾音
+// 设备收到插话下发的 tts stop 后会按自身的拾音模式发来 listen start, 此时拾音已经开始, 不应再重启识别
+type bargeInListen struct {
+	turn uint64    //插话开始的拾音轮次, 为 0 时正在开始拾音
+	at   time.Time //插话时间
+}
+
+// isBargeInListenStart 设备的 listen start 是否是对刚发生的插话的响应, 只对插话后的第一次 listen start 生效
+func (s *ChatSession) isBargeInListenStart() bool {
+	listen := s.bargeInListen.Swap(nil)
+	if listen == nil || time.Since(listen.at) > bargeInListenStartWindow {
+		return false
+	}
+	if listen.turn == 0 {
+		return true
+	}
+	stateMachine := s.clientState.StateMachine
+	return stateMachine.Turn() == listen.turn && stateMachine.Is(StateListening)
+}
+
+// frameEnergy 计算一帧pcm的均方根能量
+func frameEnergy(pcmData []float32) float64 {
+	if len(pcmData) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, sample := range pcmData {
+		sum += float64(sample) * float64(sample)
+	}
+	return math.Sqrt(sum / float64(len(pcmData)))
+}
+
+// IsBargeInListening 是否处于播放期间的插话检测阶段
+func (a *ASRManager) IsBargeInListening() bool {
+	return a.bargeIn != nil && a.onBargeIn != nil && a.clientState.StateMachine.Is(StateSpeaking)
+}
+
+// prepareBargeIn 开始拾音时创建插话检测使用的vad, 拾音结束后保留到下一轮拾音
+// 在设置拾音轮次时创建, 避免在音频处理协程中与拾音结束时的vad释放并发
+func (a *ASRManager) prepareBargeIn() {
+	if a.bargeIn == nil || a.onBargeIn == nil {
+		return
+	}
+	state := a.clientState
+	if err := state.Vad.InitRetained(state.DeviceConfig.Vad.Provider, state.DeviceConfig.Vad.Config); err != nil {
+		log.Errorf("插话检测初始化vad失败: %v", err)
+		metrics.ObserveProviderError(metrics.KindVad, state.DeviceConfig.Vad.Provider)
+	}
+}
+
+// detectBargeIn 播放期间对设备上行音频做VAD，持续语音时回调 onBargeIn 开始新一轮拾音
+func (a *ASRManager) detectBargeIn(pcmData []float32, sampleRate int, frameSize int, frameDuration int) {
+	state := a.clientState
+	vadProvider := state.Vad.Provider()
+	if vadProvider == nil {
+		// 拾音开始时未能创建vad
+		return
+	}
+
+	haveVoice, err := vadProvider.IsVADExt(pcmData, sampleRate, frameSize)
+	if err != nil {
+		log.Errorf("插话检测VAD失败: %v", err)
+		return
+	}
+
+	if !a.bargeIn.Feed(pcmData, haveVoice, frameDuration, sampleRate) {
+		return
+	}
+
+	preroll := a.bargeIn.TakePreroll()
+	log.Infof("设备 %s 播放期间检测到持续语音, 触发插话, 缓存音频: %dms", state.DeviceID, len(preroll)*1000/sampleRate)
+	if err := a.onBargeIn(); err != nil {
+		log.Errorf("处理插话失败: %v", err)
+		return
+	}
+
+	// 新一轮识别已启动，补上触发前的语音
+	state.StateMachine.Fire(EventVoiceStart)
+	state.SetClientHaveVoiceLastTime(time.Now().UnixMilli())
+	state.Asr.AddAudioData(preroll)
+}
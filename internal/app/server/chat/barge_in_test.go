@@ -0,0 +1,91 @@
+package chat
+
+import (
+	"testing"
+)
+
+func newTestFrame(amplitude float32, size int) []float32 {
+	frame := make([]float32, size)
+	for i := range frame {
+		if i%2 == 0 {
+			frame[i] = amplitude
+		} else {
+			frame[i] = -amplitude
+		}
+	}
+	return frame
+}
+
+func TestBargeInDetectorFeed(t *testing.T) {
+	const frameDuration = 20
+	const sampleRate = 16000
+	frameSize := sampleRate * frameDuration / 1000
+
+	loud := newTestFrame(0.2, frameSize)
+	echo := newTestFrame(0.005, frameSize)
+
+	type frame struct {
+		pcm       []float32
+		haveVoice bool
+	}
+	tests := []struct {
+		name        string
+		frames      []frame
+		wantTrigger int // 期望触发的帧序号，-1 表示不触发
+	}{
+		{
+			name:        "持续语音达到阈值触发",
+			frames:      []frame{{loud, true}, {loud, true}, {loud, true}, {loud, true}, {loud, true}},
+			wantTrigger: 4,
+		},
+		{
+			name:        "低能量回声不触发",
+			frames:      []frame{{echo, true}, {echo, true}, {echo, true}, {echo, true}, {echo, true}, {echo, true}},
+			wantTrigger: -1,
+		},
+		{
+			name:        "中间出现静音重新计数",
+			frames:      []frame{{loud, true}, {loud, true}, {loud, true}, {loud, false}, {loud, true}, {loud, true}, {loud, true}, {loud, true}, {loud, true}},
+			wantTrigger: 8,
+		},
+		{
+			name:        "VAD未检测到语音不触发",
+			frames:      []frame{{loud, false}, {loud, false}, {loud, false}, {loud, false}, {loud, false}},
+			wantTrigger: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector := newBargeInDetector(BargeInConfig{Enable: true, MinSpeechMs: 100, MinEnergy: 0.02})
+			triggered := -1
+			for i, f := range tt.frames {
+				if detector.Feed(f.pcm, f.haveVoice, frameDuration, sampleRate) {
+					triggered = i
+					break
+				}
+			}
+			if triggered != tt.wantTrigger {
+				t.Fatalf("触发帧 = %d, 期望 %d", triggered, tt.wantTrigger)
+			}
+			if triggered >= 0 {
+				preroll := detector.TakePreroll()
+				if len(preroll) != 5*frameSize {
+					t.Fatalf("缓存音频长度 = %d, 期望 %d", len(preroll), 5*frameSize)
+				}
+				if len(detector.TakePreroll()) != 0 {
+					t.Fatalf("取出后缓存应被清空")
+				}
+			}
+		})
+	}
+}
+
+func TestFrameEnergy(t *testing.T) {
+	if energy := frameEnergy(nil); energy != 0 {
+		t.Fatalf("空帧能量 = %f", energy)
+	}
+	if energy := frameEnergy(newTestFrame(0.5, 320)); energy < 0.499 || energy > 0.501 {
+		t.Fatalf("能量 = %f, 期望 0.5", energy)
+	}
+}
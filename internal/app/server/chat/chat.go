@@ -3,10 +3,11 @@ package chat
 import (
 	"context"
 	"sync"
+	"time"
 
+	"github.com/cloudwego/eino/schema"
 	"github.com/spf13/viper"
 
-	"xiaozhi-esp32-server-golang/constants"
 	types_conn "xiaozhi-esp32-server-golang/internal/app/server/types"
 	types_audio "xiaozhi-esp32-server-golang/internal/data/audio"
 	. "xiaozhi-esp32-server-golang/internal/data/client"
@@ -17,8 +18,9 @@ import (
 )
 
 type ChatManager struct {
-	DeviceID  string
-	transport types_conn.IConn
+	DeviceID    string
+	transport   types_conn.IConn
+	ConnectedAt time.Time
 
 	clientState *ClientState
 	session     *ChatSession
@@ -30,8 +32,9 @@ type ChatManagerOption func(*ChatManager)
 
 func NewChatManager(deviceID string, transport types_conn.IConn, options ...ChatManagerOption) (*ChatManager, error) {
 	cm := &ChatManager{
-		DeviceID:  deviceID,
-		transport: transport,
+		DeviceID:    deviceID,
+		transport:   transport,
+		ConnectedAt: time.Now(),
 	}
 
 	for _, option := range options {
@@ -94,7 +97,6 @@ func GenClientState(pctx context.Context, deviceID string) (*ClientState, error)
 	clientState := &ClientState{
 		IsActivated:  isDeviceActivated,
 		Dialogue:     &Dialogue{},
-		Abort:        false,
 		ListenMode:   "auto",
 		DeviceID:     deviceID,
 		AgentID:      deviceConfig.AgentId,
@@ -115,12 +117,11 @@ func GenClientState(pctx context.Context, deviceID string) (*ClientState, error)
 			PcmFrameSize:     0,
 		},
 		VoiceStatus: VoiceStatus{
-			HaveVoice:            false,
 			HaveVoiceLastTime:    0,
-			VoiceStop:            false,
 			SilenceThresholdTime: maxSilenceDuration,
 		},
-		SessionCtx: Ctx{},
+		SessionCtx:   Ctx{},
+		StateMachine: NewStateMachine(deviceID),
 	}
 
 	historyMessages, err := llm_memory.Get().GetMessages(ctx, deviceID, 15)
@@ -129,13 +130,6 @@ func GenClientState(pctx context.Context, deviceID string) (*ClientState, error)
 	}
 	clientState.InitMessages(historyMessages)
 
-	ttsType := clientState.DeviceConfig.Tts.Provider
-	//如果使用 xiaozhi tts，则固定使用24000hz, 20ms帧长
-	if ttsType == constants.TtsTypeXiaozhi || ttsType == constants.TtsTypeEdgeOffline {
-		clientState.OutputAudioFormat.SampleRate = 24000
-		clientState.OutputAudioFormat.FrameDuration = 20
-	}
-
 	return clientState, nil
 }
 
@@ -181,6 +175,16 @@ func (c *ChatManager) GetDeviceId() string {
 	return c.clientState.DeviceID
 }
 
+// GetTransportType 获取设备连接使用的传输类型
+func (c *ChatManager) GetTransportType() string {
+	return c.transport.GetTransportType()
+}
+
+// OnStateTransition 注册会话状态迁移的回调, 用于观测会话状态变化
+func (c *ChatManager) OnStateTransition(hook TransitionHook) {
+	c.clientState.StateMachine.OnTransition(hook)
+}
+
 // InjectMessage 注入消息到设备
 func (c *ChatManager) InjectMessage(message string, skipLlm bool) error {
 	if skipLlm {
@@ -188,6 +192,64 @@ func (c *ChatManager) InjectMessage(message string, skipLlm bool) error {
 		return c.session.AddTextToTTSQueue(message)
 	} else {
 		// 通过LLM处理消息
-		return c.session.AddAsrResultToQueue(message)
+		return c.session.InjectLlmMessage(message)
+	}
+}
+
+// SessionInfo 在线会话信息, 供管理接口展示
+type SessionInfo struct {
+	DeviceID      string            `json:"device_id"`
+	AgentID       string            `json:"agent_id"`
+	SessionID     string            `json:"session_id"`
+	Transport     string            `json:"transport"`
+	Status        string            `json:"status"`
+	ListenMode    string            `json:"listen_mode"`
+	IsActivated   bool              `json:"is_activated"`
+	Providers     map[string]string `json:"providers"`
+	ConnectedAt   int64             `json:"connected_at"`   //连接建立时间(毫秒)
+	LastCmdAt     int64             `json:"last_cmd_at"`    //最后收到信令消息的时间(毫秒)
+	LastAudioAt   int64             `json:"last_audio_at"`  //最后收到音频数据的时间(毫秒)
+	DialogueCount int               `json:"dialogue_count"` //内存中的对话消息数
+	ConfigPending bool              `json:"config_pending"` //是否有等待下一轮对话生效的新配置
+}
+
+// GetSessionInfo 获取当前会话信息
+func (c *ChatManager) GetSessionInfo() SessionInfo {
+	state := c.clientState
+	deviceConfig := state.GetDeviceConfig()
+	return SessionInfo{
+		DeviceID:    state.DeviceID,
+		AgentID:     deviceConfig.AgentId,
+		SessionID:   state.SessionID,
+		Transport:   c.transport.GetTransportType(),
+		Status:      state.GetStatus(),
+		ListenMode:  state.ListenMode,
+		IsActivated: state.IsActivated,
+		Providers: map[string]string{
+			"vad": deviceConfig.Vad.Provider,
+			"asr": deviceConfig.Asr.Provider,
+			"llm": deviceConfig.Llm.Provider,
+			"tts": deviceConfig.Tts.Provider,
+		},
+		ConnectedAt:   c.ConnectedAt.UnixMilli(),
+		LastCmdAt:     state.LastCmdTs.Load(),
+		LastAudioAt:   state.LastAudioTs.Load(),
+		DialogueCount: state.DialogueCount(),
+		ConfigPending: c.session.IsConfigPending(),
 	}
 }
+
+// GetDialogue 获取内存中的对话历史副本
+func (c *ChatManager) GetDialogue() []*schema.Message {
+	return c.clientState.GetDialogue()
+}
+
+// GetAgentID 获取设备当前绑定的智能体ID
+func (c *ChatManager) GetAgentID() string {
+	return c.clientState.GetAgentID()
+}
+
+// ReloadConfig 重新加载设备配置, 新配置从下一轮对话开始生效
+func (c *ChatManager) ReloadConfig(ctx context.Context) error {
+	return c.session.ReloadConfig(ctx)
+}
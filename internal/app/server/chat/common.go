@@ -1,14 +1,19 @@
 package chat
 
+import (
+	. "xiaozhi-esp32-server-golang/internal/data/client"
+)
+
 func (s *ChatSession) StopSpeaking(isSendTtsStop bool) {
 	s.ClearChatTextQueue()
 	s.llmManager.ClearLLMResponseQueue()
 	s.ttsManager.ClearTTSQueue()
 
 	s.clientState.CancelSessionCtx()
+	s.clientState.StateMachine.Fire(EventAbort)
 
 	if isSendTtsStop {
-		s.serverTransport.SendTtsStop()
+		s.serverTransport.SendTtsStop(s.clientState.Ctx)
 	}
 
 }
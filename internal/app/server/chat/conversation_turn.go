@@ -0,0 +1,182 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+
+	userconfig "xiaozhi-esp32-server-golang/internal/domain/config"
+	config_types "xiaozhi-esp32-server-golang/internal/domain/config/types"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+// 上报单轮对话的超时时间
+const conversationReportTimeout = 5 * time.Second
+
+// ConversationToolCall 一轮对话中的工具调用
+type ConversationToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Result    string `json:"result"`
+}
+
+// ConversationTurn 一轮完整的对话: 用户输入、工具调用及助手的最终回复
+type ConversationTurn struct {
+	TurnID        string                 `json:"turn_id"`
+	DeviceID      string                 `json:"device_id"`
+	AgentID       string                 `json:"agent_id"`
+	SessionID     string                 `json:"session_id"`
+	UserText      string                 `json:"user_text"`
+	AssistantText string                 `json:"assistant_text"`
+	ToolCalls     []ConversationToolCall `json:"tool_calls"`
+	LatencyMs     int64                  `json:"latency_ms"`  //发起请求到首个响应的耗时
+	DurationMs    int64                  `json:"duration_ms"` //发起请求到本轮结束的耗时
+	StartedAt     int64                  `json:"started_at"`  //毫秒时间戳
+	Recorded      bool                   `json:"recorded"`    //是否保存了本轮录音
+
+	startTs    time.Time
+	firstToken bool
+}
+
+// turnRecorder 根据写入对话历史的消息顺序聚合每轮对话, 一轮结束后上报
+// 消息顺序为 user, [assistant(tool_calls), tool...], assistant
+type turnRecorder struct {
+	sync.Mutex
+	current *ConversationTurn
+	newTurn func() *ConversationTurn
+	report  func(turn ConversationTurn)
+	audio   *turnAudioRecorder //为空时不录音
+}
+
+func newTurnRecorder(newTurn func() *ConversationTurn, report func(turn ConversationTurn), audio *turnAudioRecorder) *turnRecorder {
+	return &turnRecorder{
+		newTurn: newTurn,
+		report:  report,
+		audio:   audio,
+	}
+}
+
+// Begin 用户发起新一轮对话, 未结束的上一轮直接上报
+func (r *turnRecorder) Begin(userText string) {
+	r.Lock()
+	defer r.Unlock()
+	r.begin(userText)
+}
+
+func (r *turnRecorder) begin(userText string) {
+	r.flush()
+	turn := r.newTurn()
+	turn.TurnID = uuid.New().String()
+	turn.UserText = userText
+	turn.startTs = time.Now()
+	turn.StartedAt = turn.startTs.UnixMilli()
+	r.current = turn
+	r.audio.StartTurn()
+}
+
+// FirstToken 记录本轮首个响应的耗时, 工具调用后的再次请求不重复记录
+func (r *turnRecorder) FirstToken() {
+	r.Lock()
+	defer r.Unlock()
+	if r.current == nil || r.current.firstToken {
+		return
+	}
+	r.current.firstToken = true
+	r.current.LatencyMs = time.Since(r.current.startTs).Milliseconds()
+}
+
+// OnMessage 写入对话历史的消息
+func (r *turnRecorder) OnMessage(msg *schema.Message) {
+	r.Lock()
+	defer r.Unlock()
+
+	if msg.Role == schema.User {
+		// 没有经过 Begin 的用户消息(如实时语音模式)开启新一轮
+		if r.current == nil || r.current.AssistantText != "" || len(r.current.ToolCalls) > 0 {
+			r.begin(msg.Content)
+		}
+		return
+	}
+	if r.current == nil {
+		return
+	}
+
+	switch msg.Role {
+	case schema.Assistant:
+		for _, toolCall := range msg.ToolCalls {
+			r.current.ToolCalls = append(r.current.ToolCalls, ConversationToolCall{
+				ID:        toolCall.ID,
+				Name:      toolCall.Function.Name,
+				Arguments: toolCall.Function.Arguments,
+			})
+		}
+		r.current.AssistantText += msg.Content
+		if len(msg.ToolCalls) == 0 {
+			r.flush()
+		}
+	case schema.Tool:
+		for i := range r.current.ToolCalls {
+			if r.current.ToolCalls[i].ID == msg.ToolCallID {
+				r.current.ToolCalls[i].Result = msg.Content
+				break
+			}
+		}
+	}
+}
+
+// Flush 上报未结束的一轮, 用于会话关闭或工具直接播放音频后不再请求LLM的情况
+func (r *turnRecorder) Flush() {
+	r.Lock()
+	defer r.Unlock()
+	r.flush()
+}
+
+func (r *turnRecorder) flush() {
+	turn := r.current
+	r.current = nil
+	if turn == nil {
+		return
+	}
+	completed := strings.TrimSpace(turn.AssistantText) != "" || len(turn.ToolCalls) > 0
+	turn.Recorded = r.audio.FinishTurn(turn.DeviceID, turn.TurnID, completed)
+	if !completed {
+		return
+	}
+	turn.DurationMs = time.Since(turn.startTs).Milliseconds()
+	if turn.ToolCalls == nil {
+		turn.ToolCalls = []ConversationToolCall{}
+	}
+	r.report(*turn)
+}
+
+// reportConversationTurn 异步上报一轮对话到配置提供者, 非 manager 提供者忽略
+func reportConversationTurn(turn ConversationTurn) {
+	data, err := json.Marshal(turn)
+	if err != nil {
+		log.Errorf("序列化对话记录失败: %v", err)
+		return
+	}
+	eventData := make(map[string]interface{})
+	if err := json.Unmarshal(data, &eventData); err != nil {
+		log.Errorf("序列化对话记录失败: %v", err)
+		return
+	}
+
+	go func() {
+		provider, err := userconfig.GetProvider(viper.GetString("config_provider.type"))
+		if err != nil {
+			log.Errorf("GetProvider err: %+v", err)
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), conversationReportTimeout)
+		defer cancel()
+		provider.NotifyDeviceEvent(ctx, config_types.EventConversation, eventData)
+	}()
+}
@@ -0,0 +1,51 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestTurnRecorder(t *testing.T) {
+	var reported []ConversationTurn
+	recorder := newTurnRecorder(func() *ConversationTurn {
+		return &ConversationTurn{DeviceID: "dev-1", AgentID: "1", SessionID: "s1"}
+	}, func(turn ConversationTurn) {
+		reported = append(reported, turn)
+	}, nil)
+
+	toolCall := schema.ToolCall{ID: "call-1", Function: schema.FunctionCall{Name: "get_weather", Arguments: `{"city":"北京"}`}}
+
+	// 带工具调用的一轮
+	recorder.Begin("北京天气怎么样")
+	recorder.FirstToken()
+	recorder.OnMessage(schema.UserMessage("北京天气怎么样"))
+	recorder.OnMessage(schema.AssistantMessage("", []schema.ToolCall{toolCall}))
+	recorder.OnMessage(schema.ToolMessage("晴, 25度", "call-1"))
+	if len(reported) != 0 {
+		t.Fatalf("最终回复前不应上报: %+v", reported)
+	}
+	recorder.OnMessage(schema.AssistantMessage("北京今天晴天, 25度", nil))
+
+	// 实时语音模式没有 Begin, 由用户消息开启新一轮
+	recorder.OnMessage(schema.UserMessage("谢谢"))
+	recorder.OnMessage(schema.AssistantMessage("不客气", nil))
+
+	// 没有回复的一轮不上报
+	recorder.Begin("你好")
+	recorder.Flush()
+
+	if len(reported) != 2 {
+		t.Fatalf("reported = %+v", reported)
+	}
+	turn := reported[0]
+	if turn.UserText != "北京天气怎么样" || turn.AssistantText != "北京今天晴天, 25度" || turn.DeviceID != "dev-1" || turn.StartedAt == 0 || turn.TurnID == "" || turn.Recorded {
+		t.Fatalf("turn = %+v", turn)
+	}
+	if len(turn.ToolCalls) != 1 || turn.ToolCalls[0].Name != "get_weather" || turn.ToolCalls[0].Result != "晴, 25度" {
+		t.Fatalf("tool calls = %+v", turn.ToolCalls)
+	}
+	if reported[1].UserText != "谢谢" || reported[1].AssistantText != "不客气" || reported[1].ToolCalls == nil {
+		t.Fatalf("turn = %+v", reported[1])
+	}
+}
@@ -9,24 +9,34 @@ import (
 	"io"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	. "xiaozhi-esp32-server-golang/internal/data/client"
 	"xiaozhi-esp32-server-golang/internal/domain/llm"
 	llm_common "xiaozhi-esp32-server-golang/internal/domain/llm/common"
 	llm_memory "xiaozhi-esp32-server-golang/internal/domain/llm/memory"
+	"xiaozhi-esp32-server-golang/internal/domain/llm/realtime"
 	"xiaozhi-esp32-server-golang/internal/domain/mcp"
+	"xiaozhi-esp32-server-golang/internal/domain/metrics"
 	"xiaozhi-esp32-server-golang/internal/domain/play_music"
+	"xiaozhi-esp32-server-golang/internal/domain/tracing"
+	"xiaozhi-esp32-server-golang/internal/domain/tts"
 	"xiaozhi-esp32-server-golang/internal/util"
 	log "xiaozhi-esp32-server-golang/logger"
 
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
 	mcp_go "github.com/mark3labs/mcp-go/mcp"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	MaxMessageCount = 10
+	// 按token预算裁剪时最多参与计算的历史消息条数
+	MaxWindowMessageCount = 200
 
 	McpReadResourcePageSize       = 100 * 1024
 	McpReadResourceStreamDoneFlag = "[DONE]"
@@ -48,15 +58,40 @@ type LLMManager struct {
 	einoTools []*schema.ToolInfo
 
 	llmResponseQueue *util.Queue[LLMResponseChannelItem]
+
+	turnRecorder  *turnRecorder
+	audioRecorder *turnAudioRecorder
+
+	memoryPrompt atomic.Pointer[string] //本轮检索到的长期记忆, 工具调用后的后续请求复用
+}
+
+type LLMManagerOption func(*LLMManager)
+
+// WithLLMAudioRecorder 按对话轮次保存录音
+func WithLLMAudioRecorder(recorder *turnAudioRecorder) LLMManagerOption {
+	return func(l *LLMManager) {
+		l.audioRecorder = recorder
+	}
 }
 
-func NewLLMManager(clientState *ClientState, serverTransport *ServerTransport, ttsManager *TTSManager) *LLMManager {
-	return &LLMManager{
+func NewLLMManager(clientState *ClientState, serverTransport *ServerTransport, ttsManager *TTSManager, opts ...LLMManagerOption) *LLMManager {
+	l := &LLMManager{
 		clientState:      clientState,
 		serverTransport:  serverTransport,
 		ttsManager:       ttsManager,
 		llmResponseQueue: util.NewQueue[LLMResponseChannelItem](10),
 	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.turnRecorder = newTurnRecorder(func() *ConversationTurn {
+		return &ConversationTurn{
+			DeviceID:  clientState.DeviceID,
+			AgentID:   clientState.AgentID,
+			SessionID: clientState.SessionID,
+		}
+	}, reportConversationTurn, l.audioRecorder)
+	return l
 }
 
 func (l *LLMManager) Start(ctx context.Context) {
@@ -122,10 +157,10 @@ func (l *LLMManager) HandleLLMResponseChannelAsync(ctx context.Context, userMess
 
 	if needSendTtsCmd {
 		onStartFunc = func(...any) {
-			l.serverTransport.SendTtsStart()
+			l.serverTransport.SendTtsStart(ctx)
 		}
 		onEndFunc = func(err error, args ...any) {
-			l.serverTransport.SendTtsStop()
+			l.serverTransport.SendTtsStop(ctx)
 		}
 	}
 
@@ -154,11 +189,11 @@ func (l *LLMManager) HandleLLMResponseChannelSync(ctx context.Context, userMessa
 		}
 	}
 	if needSendTtsCmd {
-		l.serverTransport.SendTtsStart()
+		l.serverTransport.SendTtsStart(ctx)
 	}
 	ok, err := l.handleLLMResponse(ctx, userMessage, llmResponseChannel)
 	if needSendTtsCmd {
-		l.serverTransport.SendTtsStop()
+		l.serverTransport.SendTtsStop(ctx)
 	}
 
 	return ok, err
@@ -178,6 +213,7 @@ func (l *LLMManager) handleLLMResponse(ctx context.Context, userMessage *schema.
 	state := l.clientState
 	var toolCalls []schema.ToolCall
 	var fullText bytes.Buffer
+	isFirstResponse := true
 
 	//var hasTextResponse bool
 	for {
@@ -199,12 +235,20 @@ func (l *LLMManager) handleLLMResponse(ctx context.Context, userMessage *schema.
 
 				log.Debugf("LLM 响应: %+v", llmResponse)
 
+				if isFirstResponse {
+					isFirstResponse = false
+					metrics.LlmLatency.WithLabelValues(state.DeviceConfig.Llm.Provider).Observe(float64(state.GetLlmDuration()) / 1000)
+					trace.SpanFromContext(ctx).AddEvent("first_token")
+					tracing.FromContext(ctx).LlmFirstToken()
+					l.turnRecorder.FirstToken()
+				}
+
 				if len(llmResponse.ToolCalls) > 0 {
 					log.Debugf("获取到工具: %+v", llmResponse.ToolCalls)
 					toolCalls = append(toolCalls, llmResponse.ToolCalls...)
 				}
 
-				if llmResponse.Text != "" {
+				if llmResponse.Text != "" || llmResponse.Emotion != "" {
 					//hasTextResponse = true
 					// 处理文本内容响应
 					if err := l.ttsManager.handleTextResponse(ctx, llmResponse, true); err != nil {
@@ -272,6 +316,10 @@ func (l *LLMManager) handleToolCallResponse(ctx context.Context, userMessage *sc
 	}
 
 	state := l.clientState
+	// 回复已被打断或开始新一轮拾音时不再执行工具
+	if err := state.StateMachine.FireContext(ctx, EventToolCall); err != nil {
+		return false, err
+	}
 
 	log.Infof("处理 %d 个工具调用", len(tools))
 
@@ -307,19 +355,24 @@ func (l *LLMManager) handleToolCallResponse(ctx context.Context, userMessage *sc
 		tool, ok := mcp.GetToolByName(state.DeviceID, toolName)
 		if !ok || tool == nil {
 			log.Errorf("未找到工具: %s", toolName)
+			metrics.ToolCalls.WithLabelValues(toolName, metrics.ToolResultNotFound).Inc()
 			addMessageFunc(toolCall, fmt.Sprintf("未找到工具: %s", toolName))
 			continue
 		}
 		log.Infof("进行工具调用请求: %s, 参数: %+v", toolName, toolCall.Function.Arguments)
 		startTs := time.Now().UnixMilli()
+		_, toolSpan := tracing.StartSpan(ctx, "tool_call", attribute.String("tool.name", toolName))
 		fcResult, err := tool.InvokableRun(toolCtx, toolCall.Function.Arguments)
+		tracing.EndSpan(toolSpan, err)
 		if err != nil {
 			log.Errorf("工具调用失败: %v", err)
+			metrics.ToolCalls.WithLabelValues(toolName, metrics.ToolResultError).Inc()
 			addMessageFunc(toolCall, fmt.Sprintf("工具 %s 调用失败: %v", toolName, err))
 			continue
 		}
 		costTs := time.Now().UnixMilli() - startTs
 		invokeToolSuccess = true
+		metrics.ToolCalls.WithLabelValues(toolName, metrics.ToolResultSuccess).Inc()
 		if len(fcResult) > 2048 {
 			log.Infof("工具调用结果 len: %d, 耗时: %dms", len(fcResult), costTs)
 		} else {
@@ -336,7 +389,7 @@ func (l *LLMManager) handleToolCallResponse(ctx context.Context, userMessage *sc
 			contentList = mcpResp.GetContent()
 		} else if toolCallResult, ok := l.handleToolResult(fcResult); ok {
 			if toolCallResult.IsError {
-				log.Errorf("工具调用失败: %s, 错误: %s", fcResult, toolCallResult.IsError)
+				log.Errorf("工具调用失败: %s, 错误: %v", fcResult, toolCallResult.IsError)
 			}
 			contentList = toolCallResult.Content
 		}
@@ -531,7 +584,7 @@ func (l *LLMManager) handleResourceLink(ctx context.Context, resourceLink mcp_go
 	}
 
 	playText := fmt.Sprintf("正在播放音乐: %s", resourceLink.Name)
-	l.serverTransport.SendSentenceStart(playText)
+	l.serverTransport.SendSentenceStart(ctx, playText)
 
 	go func() {
 		defer func() {
@@ -562,7 +615,7 @@ func (l *LLMManager) handleAudioContent(ctx context.Context, realMusicName strin
 	}
 
 	playText := fmt.Sprintf("正在播放音乐: %s", realMusicName)
-	l.serverTransport.SendSentenceStart(playText)
+	l.serverTransport.SendSentenceStart(ctx, playText)
 
 	go func() {
 		defer func() {
@@ -596,23 +649,39 @@ func (l *LLMManager) handleToolResult(toolResultStr string) (mcp_go.CallToolResu
 	return toolResult, true
 }
 
-func (l *LLMManager) DoLLmRequest(ctx context.Context, userMessage *schema.Message, einoTools []*schema.ToolInfo, isSync bool) error {
+func (l *LLMManager) DoLLmRequest(ctx context.Context, userMessage *schema.Message, einoTools []*schema.ToolInfo, isSync bool) (err error) {
 	log.Debugf("发送带工具的 LLM 请求, seesionID: %s, requestEinoMessages: %+v", l.clientState.SessionID, userMessage)
 	clientState := l.clientState
 
+	// 工具调用和tts的span挂在本次llm请求下
+	ctx, span := tracing.StartSpan(ctx, "llm.request", attribute.Int("llm.tools", len(einoTools)))
+	defer func() {
+		tracing.EndSpan(span, err)
+	}()
+
+	// 状态不允许时本轮不会请求大模型, 也不记录对话
+	if err := clientState.StateMachine.FireContext(ctx, EventLlmStart); err != nil {
+		return fmt.Errorf("发送带工具的 LLM 请求失败: %v", err)
+	}
 	l.einoTools = einoTools
+	if userMessage != nil && userMessage.Role == schema.User {
+		l.turnRecorder.Begin(userMessage.Content)
+	}
 	//组装历史消息和当前用户的消息
 	requestMessages := l.GetMessages(ctx, userMessage, MaxMessageCount)
-	clientState.SetStatus(ClientStatusLLMStart)
+	clientState.SetStartLlmTs()
 	responseSentences, err := llm.HandleLLMWithContextAndTools(
 		ctx,
 		clientState.LLMProvider,
 		requestMessages,
 		einoTools,
 		l.clientState.SessionID,
+		llm.WithEmotionParser(l.emotionParser()),
+		llm.WithSpeakerParser(l.speakerParser()),
 	)
 	if err != nil {
 		log.Errorf("发送带工具的 LLM 请求失败, seesionID: %s, error: %v", l.clientState.SessionID, err)
+		metrics.ObserveProviderError(metrics.KindLlm, clientState.DeviceConfig.Llm.Provider)
 		return fmt.Errorf("发送带工具的 LLM 请求失败: %v", err)
 	}
 
@@ -636,24 +705,103 @@ func (l *LLMManager) DoLLmRequest(ctx context.Context, userMessage *schema.Messa
 	return nil
 }
 
+// emotionParser 按全局配置和智能体的表情映射创建表情解析, 未开启时返回 nil
+func (l *LLMManager) emotionParser() *llm.EmotionParser {
+	if !viper.GetBool("emotion.enable") {
+		return nil
+	}
+	mapping := viper.GetStringMapString("emotion.map")
+	for marker, emotion := range l.clientState.DeviceConfig.EmotionMap {
+		mapping[marker] = emotion
+	}
+	return llm.NewEmotionParser(mapping, viper.GetBool("emotion.classify"))
+}
+
+// speakerParser TTS配置了多角色音色时创建角色解析, 否则返回 nil
+func (l *LLMManager) speakerParser() *llm.SpeakerParser {
+	ttsConfig := l.clientState.DeviceConfig.Tts
+	roles := tts.SpeakerRoles(ttsConfig.Provider, ttsConfig.Config)
+	if len(roles) == 0 {
+		return nil
+	}
+	return llm.NewSpeakerParser(roles)
+}
+
 func (l *LLMManager) AddLlmMessage(ctx context.Context, msg *schema.Message) error {
 	if msg == nil {
 		log.Warnf("尝试添加 nil 消息到 LLM 对话历史")
 		return fmt.Errorf("消息不能为 nil")
 	}
 	l.clientState.AddMessage(msg)
-	llm_memory.Get().AddMessage(ctx, l.clientState.DeviceID, *msg)
+	l.turnRecorder.OnMessage(msg)
+	llm_memory.Get().AddMessage(ctx, l.clientState.DeviceID, l.clientState.SessionID, *msg)
+	llm_memory.GetLongTerm().AddMessage(ctx, l.clientState.DeviceID, msg, l.summarize)
 	return nil
 }
 
+// summarize 使用智能体的LLM进行长期记忆总结
+func (l *LLMManager) summarize(ctx context.Context, messages []*schema.Message) (string, error) {
+	llmProvider := l.clientState.LLMProvider
+	if llmProvider == nil {
+		return "", fmt.Errorf("LLM提供者未初始化")
+	}
+	if _, ok := llmProvider.(*realtime.Provider); ok {
+		return "", realtime.ErrTextUnsupported
+	}
+	// 提供者出错时只记录日志并关闭通道, 超时、取消或没有输出都视为总结失败
+	var content strings.Builder
+	msgChan := llmProvider.ResponseWithContext(ctx, l.clientState.SessionID, messages, nil)
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("LLM总结未完成: %v", ctx.Err())
+		case msg, ok := <-msgChan:
+			if !ok {
+				if ctx.Err() != nil {
+					return "", fmt.Errorf("LLM总结未完成: %v", ctx.Err())
+				}
+				if strings.TrimSpace(content.String()) == "" {
+					return "", fmt.Errorf("LLM未返回总结内容")
+				}
+				return content.String(), nil
+			}
+			if msg != nil {
+				content.WriteString(msg.Content)
+			}
+		}
+	}
+}
+
 func (l *LLMManager) GetMessages(ctx context.Context, userMessage *schema.Message, count int) []*schema.Message {
+	// 配置了 context_length 时按token预算选取历史消息, 否则按条数
+	contextWindow, err := llm.NewContextWindow(l.clientState.DeviceConfig.Llm.Config)
+	if err != nil {
+		log.Errorf("LLM上下文窗口配置错误, 按消息条数裁剪: %v", err)
+	}
+	if contextWindow != nil {
+		count = MaxWindowMessageCount
+	}
+
 	//从dialogue中获取
 	messageList := l.clientState.GetMessages(count)
 
+	// 每轮按用户的问题检索一次长期记忆注入系统提示词, 工具调用后的后续请求没有新问题, 复用本轮的检索结果
+	memoryPrompt := ""
+	if userMessage != nil && userMessage.Role == schema.User {
+		memoryPrompt = llm_memory.GetLongTerm().BuildPrompt(ctx, l.clientState.DeviceID, userMessage.Content)
+		l.memoryPrompt.Store(&memoryPrompt)
+	} else if prompt := l.memoryPrompt.Load(); prompt != nil {
+		memoryPrompt = *prompt
+	}
+	systemPrompt := l.clientState.SystemPrompt + memoryPrompt
+	if contextWindow != nil {
+		messageList = AlignToolMessages(contextWindow.Fit(systemPrompt, messageList, userMessage, l.einoTools))
+	}
+
 	retMessage := make([]*schema.Message, 0)
 	retMessage = append(retMessage, &schema.Message{
 		Role:    schema.System,
-		Content: l.clientState.SystemPrompt,
+		Content: systemPrompt,
 	})
 	retMessage = append(retMessage, messageList...)
 	if userMessage != nil {
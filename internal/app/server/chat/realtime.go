@@ -0,0 +1,531 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/cloudwego/eino/schema"
+	mcp_go "github.com/mark3labs/mcp-go/mcp"
+
+	. "xiaozhi-esp32-server-golang/internal/data/client"
+	"xiaozhi-esp32-server-golang/internal/domain/audio"
+	llm_memory "xiaozhi-esp32-server-golang/internal/domain/llm/memory"
+	"xiaozhi-esp32-server-golang/internal/domain/llm/realtime"
+	"xiaozhi-esp32-server-golang/internal/domain/mcp"
+	"xiaozhi-esp32-server-golang/internal/domain/metrics"
+	"xiaozhi-esp32-server-golang/internal/util"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+// 回复音频先编码进缓冲通道再按播放速度下发, 模型生成音频通常快于实时, 缓冲约一分钟
+const realtimeOutputFrames = 1000
+
+// RealtimeSession 实时语音模式, 设备 opus 音频直接桥接到实时语音模型, 替代 vad→asr→llm→tts 链路
+// 轮次检测由模型的服务端vad完成, 回复音频编码为 opus 后复用 tts 的下发流程, 工具调用复用 mcp 工具
+type RealtimeSession struct {
+	session *ChatSession
+	conn    *realtime.Conn
+
+	toolNames  string          //已下发给模型的工具列表, 变化时更新会话
+	output     *realtimeOutput //当前回复的下发状态, 播放完成前一直保留以便打断, 只在事件循环中访问
+	toolCalled bool            //本次回复中有工具调用, 回复结束后需要让模型继续
+	abortCh    chan struct{}   //设备主动打断, 在事件循环中处理
+
+	toolCtx      context.Context //本轮工具调用的ctx, 打断时取消
+	toolCancel   context.CancelFunc
+	pendingTools int                     //执行中的工具调用数, 全部返回且回复结束后才让模型继续
+	responseDone bool                    //模型已返回 response.done, 等待执行中的工具调用
+	toolResultCh chan realtimeToolResult //工具在独立协程中执行, 结果回到事件循环中返回给模型
+}
+
+// realtimeToolResult 一次工具调用的结果
+type realtimeToolResult struct {
+	ctx    context.Context
+	callID string
+	result string
+}
+
+// realtimeOutput 一次回复的音频下发
+type realtimeOutput struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	frames   chan []byte
+	encoder  *audio.AudioProcesser
+	pcm      []float32 //不足一帧待编码的音频
+	text     string
+	finished bool            //模型已返回 response.done, 剩余的缓冲帧仍在播放
+	prev     *realtimeOutput //工具调用后的续接回复, 等上一次回复播放完再下发
+	done     chan struct{}
+
+	continued atomic.Bool //有续接的回复, 播放完后不下发 tts stop
+	stopped   bool        //已下发 tts stop, 在 done 关闭前写入
+}
+
+// isRealtimeMode 智能体的LLM配置为实时语音模型时, 会话使用实时语音模式
+func (s *ChatSession) isRealtimeMode() (*realtime.Provider, bool) {
+	provider, ok := s.clientState.LLMProvider.(*realtime.Provider)
+	return provider, ok
+}
+
+// checkTextChat 实时语音模式下模型只接收语音, 文本对话和注入的消息无法得到回复
+func (s *ChatSession) checkTextChat() error {
+	if _, ok := s.isRealtimeMode(); ok {
+		return realtime.ErrTextUnsupported
+	}
+	return nil
+}
+
+// startRealtime 建立实时语音会话并开始桥接设备音频
+func (s *ChatSession) startRealtime(provider *realtime.Provider) error {
+	ctx := s.clientState.Ctx
+	einoTools := s.getEinoTools(ctx)
+	conn, err := provider.Dial(ctx, realtime.Session{
+		Instructions: s.clientState.SystemPrompt + llm_memory.GetLongTerm().BuildPrompt(ctx, s.clientState.DeviceID, ""),
+		Tools:        realtime.ToolsFromEino(einoTools),
+	})
+	if err != nil {
+		metrics.ObserveProviderError(metrics.KindLlm, s.clientState.DeviceConfig.Llm.Provider)
+		return fmt.Errorf("建立实时语音会话失败: %v", err)
+	}
+	log.Infof("设备 %s 使用实时语音模式, 工具数: %d", s.clientState.DeviceID, len(einoTools))
+
+	r := &RealtimeSession{
+		session:      s,
+		conn:         conn,
+		toolNames:    toolNameKey(einoTools),
+		abortCh:      make(chan struct{}, 1),
+		toolResultCh: make(chan realtimeToolResult),
+	}
+	s.realtime = r
+	go r.pumpAudio(ctx)
+	go r.run(ctx)
+	return nil
+}
+
+// pumpAudio 解码设备上行 opus 音频, 重采样为 24kHz pcm16 后发送给模型
+func (r *RealtimeSession) pumpAudio(ctx context.Context) {
+	state := r.session.clientState
+	format := state.InputAudioFormat
+	processer, err := audio.GetAudioProcesser(format.SampleRate, format.Channels, format.FrameDuration)
+	if err != nil {
+		log.Errorf("获取解码器失败: %v", err)
+		return
+	}
+	frameSize := state.AsrAudioBuffer.PcmFrameSize
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case opusFrame, ok := <-state.OpusAudioBuffer:
+			if !ok {
+				return
+			}
+			pcmFrame := make([]float32, frameSize)
+			n, err := processer.DecoderFloat32(opusFrame, pcmFrame)
+			if err != nil {
+				log.Errorf("解码失败: %v", err)
+				continue
+			}
+			pcm := pcmFrame[:n]
+			if format.SampleRate != realtime.SampleRate {
+				pcm = util.ResampleLinearFloat32(pcm, format.SampleRate, realtime.SampleRate)
+			}
+			pcmBytes := make([]byte, len(pcm)*2)
+			util.Float32ToPCMBytes(pcm, pcmBytes)
+			if err := r.conn.AppendAudio(pcmBytes); err != nil {
+				log.Errorf("发送音频到实时语音模型失败: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// run 处理模型事件, 连接断开时关闭会话
+func (r *RealtimeSession) run(ctx context.Context) {
+	defer func() {
+		r.cancelTools()
+		r.stopOutput()
+		if r.session.ctx.Err() == nil {
+			r.session.Close()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.abortCh:
+			r.interrupt()
+		case result := <-r.toolResultCh:
+			r.handleToolResult(result)
+		case event, ok := <-r.conn.Events():
+			if !ok {
+				log.Infof("设备 %s 实时语音连接已断开", r.session.clientState.DeviceID)
+				return
+			}
+			r.handleEvent(ctx, event)
+		}
+	}
+}
+
+func (r *RealtimeSession) handleEvent(ctx context.Context, event realtime.ServerEvent) {
+	s := r.session
+	switch event.Type {
+	case realtime.EventSpeechStarted:
+		// 用户开始说话, 打断正在播放的回复和执行中的工具调用
+		if r.playing() != nil || r.pendingTools > 0 {
+			log.Infof("设备 %s 插话, 打断当前回复", s.clientState.DeviceID)
+			r.interrupt()
+		}
+		s.clientState.StateMachine.Fire(EventListenStart)
+	case realtime.EventSpeechStopped:
+		s.clientState.StateMachine.Fire(EventVoiceStop)
+		r.refreshTools(ctx)
+	case realtime.EventInputTranscriptionDone:
+		text := strings.TrimSpace(event.Transcript)
+		if text == "" {
+			return
+		}
+		if err := s.serverTransport.SendAsrResult(text); err != nil {
+			log.Errorf("发送asr结果失败: %v", err)
+		}
+		r.addMessage(ctx, &schema.Message{Role: schema.User, Content: text})
+	case realtime.EventResponseCreated:
+		r.startOutput(ctx)
+	case realtime.EventResponseAudioDelta:
+		pcm, err := event.Audio()
+		if err != nil {
+			log.Errorf("解码实时语音音频失败: %v", err)
+			return
+		}
+		r.writeOutput(pcm)
+	case realtime.EventResponseTranscriptDone:
+		if output := r.output; output != nil && !output.finished && event.Transcript != "" {
+			output.text = event.Transcript
+			if err := s.serverTransport.SendSentenceStart(ctx, event.Transcript); err != nil {
+				log.Errorf("发送 TTS 文本失败: %v", err)
+			}
+		}
+	case realtime.EventFunctionCallArgsDone:
+		r.callTool(ctx, event)
+	case realtime.EventResponseDone:
+		r.finishOutput(ctx)
+		r.responseDone = true
+		r.continueResponse()
+	case realtime.EventError:
+		if event.Error != nil {
+			log.Errorf("实时语音模型返回错误: %s, %s", event.Error.Code, event.Error.Message)
+		}
+		metrics.ObserveProviderError(metrics.KindLlm, s.clientState.DeviceConfig.Llm.Provider)
+	}
+}
+
+// Abort 设备发送 abort 时打断当前回复
+func (r *RealtimeSession) Abort() {
+	select {
+	case r.abortCh <- struct{}{}:
+	default:
+	}
+}
+
+// interrupt 停止下发并取消执行中的工具调用, 模型仍在生成回复时一并取消
+func (r *RealtimeSession) interrupt() {
+	r.cancelTools()
+	r.toolCalled = false
+	output := r.playing()
+	if output == nil {
+		return
+	}
+	r.stopOutput()
+	if output.finished {
+		return
+	}
+	if err := r.conn.CancelResponse(); err != nil {
+		log.Errorf("取消实时语音回复失败: %v", err)
+	}
+}
+
+// playing 获取仍在下发的回复, 播放完成后清除
+func (r *RealtimeSession) playing() *realtimeOutput {
+	if r.output == nil {
+		return nil
+	}
+	select {
+	case <-r.output.done:
+		r.output = nil
+	default:
+	}
+	return r.output
+}
+
+// startOutput 开始下发一次回复, 音频帧按 tts 的流控发送
+// 上一次回复已生成完但仍在播放时(如工具调用后的续接回复), 等其播放完再下发
+func (r *RealtimeSession) startOutput(ctx context.Context) {
+	s := r.session
+	prev := r.playing()
+	if prev != nil && !prev.finished {
+		r.stopOutput()
+		prev = nil
+	}
+	if prev != nil {
+		prev.continued.Store(true)
+	}
+
+	format := s.clientState.OutputAudioFormat
+	encoder, err := audio.GetAudioProcesser(format.SampleRate, format.Channels, format.FrameDuration)
+	if err != nil {
+		log.Errorf("获取编码器失败: %v", err)
+		return
+	}
+
+	outputCtx, cancel := context.WithCancel(ctx)
+	output := &realtimeOutput{
+		ctx:     outputCtx,
+		cancel:  cancel,
+		frames:  make(chan []byte, realtimeOutputFrames),
+		encoder: encoder,
+		prev:    prev,
+		done:    make(chan struct{}),
+	}
+	r.output = output
+
+	if prev == nil {
+		s.serverTransport.SendTtsStart(ctx)
+	}
+	go func() {
+		defer close(output.done)
+		if prev != nil {
+			select {
+			case <-prev.done:
+			case <-outputCtx.Done():
+				return
+			}
+			// 上一次回复在续接前已下发 tts stop
+			if prev.stopped {
+				s.serverTransport.SendTtsStart(ctx)
+			}
+		}
+		if err := s.ttsManager.SendTTSAudio(outputCtx, output.frames, true); err != nil {
+			log.Errorf("发送实时语音音频失败: %v", err)
+		}
+		if outputCtx.Err() != nil {
+			return
+		}
+		if output.text != "" {
+			s.serverTransport.SendSentenceEnd(output.text)
+		}
+		if !output.continued.Load() {
+			s.serverTransport.SendTtsStop(ctx)
+			output.stopped = true
+		}
+	}()
+}
+
+// writeOutput 将模型返回的 24kHz pcm16 音频按输出格式编码为 opus 帧
+func (r *RealtimeSession) writeOutput(pcmBytes []byte) {
+	output := r.output
+	if output == nil || output.finished {
+		return
+	}
+	format := r.session.clientState.OutputAudioFormat
+	pcm := util.PCM16BytesToFloat32(pcmBytes)
+	if format.SampleRate != realtime.SampleRate {
+		pcm = util.ResampleLinearFloat32(pcm, realtime.SampleRate, format.SampleRate)
+	}
+	output.pcm = append(output.pcm, pcm...)
+
+	frameSize := format.SampleRate * format.Channels * format.FrameDuration / 1000
+	for len(output.pcm) >= frameSize {
+		r.encodeFrame(output, output.pcm[:frameSize])
+		output.pcm = output.pcm[frameSize:]
+	}
+}
+
+func (r *RealtimeSession) encodeFrame(output *realtimeOutput, pcm []float32) {
+	opusFrame := make([]byte, 1500)
+	n, err := output.encoder.Encoder(util.Float32SliceToInt16Slice(pcm), opusFrame)
+	if err != nil {
+		log.Errorf("编码实时语音音频失败: %v", err)
+		return
+	}
+	select {
+	case output.frames <- opusFrame[:n]:
+	default:
+		log.Warnf("实时语音音频缓冲已满, 丢弃音频帧")
+	}
+}
+
+// finishOutput 模型回复结束, 补齐最后一帧后等待播放完成, 播放完成前仍可被打断
+func (r *RealtimeSession) finishOutput(ctx context.Context) {
+	output := r.output
+	if output == nil || output.finished {
+		return
+	}
+	output.finished = true
+
+	if len(output.pcm) > 0 {
+		format := r.session.clientState.OutputAudioFormat
+		frame := make([]float32, format.SampleRate*format.Channels*format.FrameDuration/1000)
+		copy(frame, output.pcm)
+		r.encodeFrame(output, frame)
+	}
+	close(output.frames)
+
+	if output.text != "" {
+		r.addMessage(ctx, &schema.Message{Role: schema.Assistant, Content: output.text})
+	}
+}
+
+// stopOutput 立即停止当前回复及其等待续接的上一次回复的下发
+func (r *RealtimeSession) stopOutput() {
+	output := r.playing()
+	if output == nil {
+		return
+	}
+	r.output = nil
+	if !output.finished {
+		close(output.frames)
+	}
+	for o := output; o != nil; o = o.prev {
+		o.cancel()
+	}
+	for o := output; o != nil; o = o.prev {
+		<-o.done
+	}
+	r.session.serverTransport.SendTtsStop(output.ctx)
+}
+
+// callTool 在独立协程中调用 mcp 工具, 不阻塞事件循环, 执行期间仍可处理插话、打断和断开
+func (r *RealtimeSession) callTool(ctx context.Context, event realtime.ServerEvent) {
+	if r.toolCtx == nil {
+		r.toolCtx, r.toolCancel = context.WithCancel(ctx)
+	}
+	toolCtx := r.toolCtx
+	r.pendingTools++
+
+	go func() {
+		result := r.invokeTool(toolCtx, event)
+		select {
+		case r.toolResultCh <- realtimeToolResult{ctx: toolCtx, callID: event.CallID, result: result}:
+		case <-toolCtx.Done():
+		}
+	}()
+}
+
+func (r *RealtimeSession) invokeTool(ctx context.Context, event realtime.ServerEvent) string {
+	deviceID := r.session.clientState.DeviceID
+	tool, ok := mcp.GetToolByName(deviceID, event.Name)
+	if !ok || tool == nil {
+		log.Errorf("未找到工具: %s", event.Name)
+		metrics.ToolCalls.WithLabelValues(event.Name, metrics.ToolResultNotFound).Inc()
+		return fmt.Sprintf("未找到工具: %s", event.Name)
+	}
+	log.Infof("进行工具调用请求: %s, 参数: %s", event.Name, event.Arguments)
+	fcResult, err := tool.InvokableRun(ctx, event.Arguments)
+	if err != nil {
+		log.Errorf("工具调用失败: %v", err)
+		metrics.ToolCalls.WithLabelValues(event.Name, metrics.ToolResultError).Inc()
+		return fmt.Sprintf("工具 %s 调用失败: %v", event.Name, err)
+	}
+	metrics.ToolCalls.WithLabelValues(event.Name, metrics.ToolResultSuccess).Inc()
+	return r.toolResultText(fcResult)
+}
+
+// handleToolResult 将工具结果返回给模型, 已被打断的调用结果直接丢弃
+func (r *RealtimeSession) handleToolResult(result realtimeToolResult) {
+	if result.ctx != r.toolCtx || result.ctx.Err() != nil {
+		return
+	}
+	r.pendingTools--
+	if err := r.conn.SendToolResult(result.callID, result.result); err != nil {
+		log.Errorf("返回工具调用结果失败: %v", err)
+	} else {
+		r.toolCalled = true
+	}
+	r.continueResponse()
+}
+
+// continueResponse 回复结束且工具调用全部返回后, 有工具结果时请求模型继续回复
+func (r *RealtimeSession) continueResponse() {
+	if !r.responseDone || r.pendingTools > 0 {
+		return
+	}
+	r.responseDone = false
+	r.cancelTools()
+	if !r.toolCalled {
+		return
+	}
+	r.toolCalled = false
+	if err := r.conn.CreateResponse(); err != nil {
+		log.Errorf("请求实时语音回复失败: %v", err)
+	}
+}
+
+// cancelTools 取消本轮执行中的工具调用
+func (r *RealtimeSession) cancelTools() {
+	if r.toolCancel != nil {
+		r.toolCancel()
+	}
+	r.toolCtx, r.toolCancel = nil, nil
+	r.pendingTools = 0
+	r.responseDone = false
+}
+
+// refreshTools 设备 mcp 工具在 hello 之后异步初始化, 工具列表变化时更新会话
+func (r *RealtimeSession) refreshTools(ctx context.Context) {
+	einoTools := r.session.getEinoTools(ctx)
+	names := toolNameKey(einoTools)
+	if names == r.toolNames {
+		return
+	}
+	if err := r.conn.UpdateSession(realtime.Session{Tools: realtime.ToolsFromEino(einoTools)}); err != nil {
+		log.Errorf("更新实时语音工具失败: %v", err)
+		return
+	}
+	r.toolNames = names
+}
+
+// toolResultText 提取工具结果中的文本内容, 实时语音模式下不播放工具返回的音频资源
+func (r *RealtimeSession) toolResultText(fcResult string) string {
+	l := r.session.llmManager
+	var contentList []mcp_go.Content
+	if mcpResp, ok := l.handleLocalToolResult(fcResult); ok {
+		contentList = mcpResp.GetContent()
+	} else if toolCallResult, ok := l.handleToolResult(fcResult); ok {
+		contentList = toolCallResult.Content
+	}
+
+	var text string
+	for _, content := range contentList {
+		if textContent, ok := content.(mcp_go.TextContent); ok {
+			text += textContent.Text
+		}
+	}
+	if text == "" {
+		return fcResult
+	}
+	return text
+}
+
+func (r *RealtimeSession) addMessage(ctx context.Context, msg *schema.Message) {
+	if err := r.session.llmManager.AddLlmMessage(ctx, msg); err != nil {
+		log.Errorf("写入对话历史失败: %v", err)
+	}
+}
+
+func (r *RealtimeSession) Close() error {
+	return r.conn.Close()
+}
+
+func toolNameKey(einoTools []*schema.ToolInfo) string {
+	names := make([]string, 0, len(einoTools))
+	for _, tool := range einoTools {
+		names = append(names, tool.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,223 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	types_conn "xiaozhi-esp32-server-golang/internal/app/server/types"
+	. "xiaozhi-esp32-server-golang/internal/data/msg"
+)
+
+// Event 服务端下发给设备的一条数据, 信令消息和音频帧二选一
+type Event struct {
+	Index int //下发顺序
+	Time  time.Time
+	Cmd   *ServerMessage
+	Raw   []byte //信令消息原文
+	Audio []byte
+}
+
+// IsCmd 是否为指定类型和状态的信令消息, state 为空时不比较状态
+func (e Event) IsCmd(msgType string, state string) bool {
+	return e.Cmd != nil && e.Cmd.Type == msgType && (state == "" || e.Cmd.State == state)
+}
+
+// Conn 进程内的设备连接, 实现 types.IConn
+// 设备上行的信令和音频通过 PushCmd/PushAudio 写入, 服务端下发的数据按时间顺序记录为 Event
+type Conn struct {
+	sync.Mutex
+	deviceID      string
+	transportType string
+	data          map[string]interface{}
+
+	recvCmdChan   chan []byte
+	recvAudioChan chan []byte
+	done          chan struct{}
+	closed        bool
+	onCloseCbList []func(deviceId string)
+
+	events  []Event
+	updated chan struct{} //有新事件时关闭并替换, 用于唤醒等待者
+}
+
+func NewConn(deviceID string, transportType string) *Conn {
+	return &Conn{
+		deviceID:      deviceID,
+		transportType: transportType,
+		data:          make(map[string]interface{}),
+		recvCmdChan:   make(chan []byte, 100),
+		recvAudioChan: make(chan []byte, 100),
+		done:          make(chan struct{}),
+		updated:       make(chan struct{}),
+	}
+}
+
+// PushCmd 模拟设备上行一条信令消息
+func (c *Conn) PushCmd(msg []byte) error {
+	select {
+	case <-c.done:
+		return errors.New("connection is closed")
+	case c.recvCmdChan <- msg:
+		return nil
+	}
+}
+
+// PushAudio 模拟设备上行一帧音频
+func (c *Conn) PushAudio(frame []byte) error {
+	select {
+	case <-c.done:
+		return errors.New("connection is closed")
+	case c.recvAudioChan <- frame:
+		return nil
+	}
+}
+
+// SetData 设置 GetData 返回的私有数据, 如 mqtt 的 aes_key
+func (c *Conn) SetData(key string, value interface{}) {
+	c.Lock()
+	defer c.Unlock()
+	c.data[key] = value
+}
+
+// Events 获取目前为止下发的全部数据
+func (c *Conn) Events() []Event {
+	c.Lock()
+	defer c.Unlock()
+	events := make([]Event, len(c.events))
+	copy(events, c.events)
+	return events
+}
+
+// WaitFor 等待从第 from 个事件开始第一个满足条件的事件, 返回该事件的序号
+func (c *Conn) WaitFor(ctx context.Context, from int, match func(e Event) bool) (int, Event, error) {
+	for {
+		c.Lock()
+		for i := from; i < len(c.events); i++ {
+			if match(c.events[i]) {
+				event := c.events[i]
+				c.Unlock()
+				return i, event, nil
+			}
+		}
+		from = len(c.events)
+		updated := c.updated
+		c.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return -1, Event{}, ctx.Err()
+		case <-updated:
+		}
+	}
+}
+
+func (c *Conn) addEvent(event Event) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return errors.New("connection is closed")
+	}
+	event.Index = len(c.events)
+	c.events = append(c.events, event)
+	close(c.updated)
+	c.updated = make(chan struct{})
+	return nil
+}
+
+func (c *Conn) SendCmd(msg []byte) error {
+	var serverMsg ServerMessage
+	if err := json.Unmarshal(msg, &serverMsg); err != nil {
+		return err
+	}
+	return c.addEvent(Event{Time: time.Now(), Cmd: &serverMsg, Raw: append([]byte(nil), msg...)})
+}
+
+func (c *Conn) SendAudio(audio []byte) error {
+	return c.addEvent(Event{Time: time.Now(), Audio: append([]byte(nil), audio...)})
+}
+
+func (c *Conn) RecvCmd(ctx context.Context, timeout int) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, errors.New("connection is closed")
+	case msg := <-c.recvCmdChan:
+		return msg, nil
+	case <-time.After(time.Duration(timeout) * time.Second):
+		return nil, errors.New("timeout")
+	}
+}
+
+func (c *Conn) RecvAudio(ctx context.Context, timeout int) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, errors.New("connection is closed")
+	case audio := <-c.recvAudioChan:
+		return audio, nil
+	case <-time.After(time.Duration(timeout) * time.Second):
+		return nil, errors.New("timeout")
+	}
+}
+
+func (c *Conn) GetDeviceID() string {
+	return c.deviceID
+}
+
+func (c *Conn) Close() error {
+	c.Lock()
+	if c.closed {
+		c.Unlock()
+		return nil
+	}
+	c.closed = true
+	close(c.done)
+	close(c.updated)
+	c.updated = make(chan struct{})
+	cbList := c.onCloseCbList
+	c.Unlock()
+
+	for _, cb := range cbList {
+		cb(c.deviceID)
+	}
+	return nil
+}
+
+// IsClosed 连接是否已被服务端关闭
+func (c *Conn) IsClosed() bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.closed
+}
+
+func (c *Conn) OnClose(cb func(deviceId string)) {
+	c.Lock()
+	defer c.Unlock()
+	c.onCloseCbList = append(c.onCloseCbList, cb)
+}
+
+func (c *Conn) CloseAudioChannel() error {
+	return nil
+}
+
+func (c *Conn) GetTransportType() string {
+	if c.transportType == "" {
+		return types_conn.TransportTypeWebsocket
+	}
+	return c.transportType
+}
+
+func (c *Conn) GetData(key string) (interface{}, error) {
+	c.Lock()
+	defer c.Unlock()
+	value, ok := c.data[key]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return value, nil
+}
@@ -0,0 +1,337 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"xiaozhi-esp32-server-golang/internal/app/server/auth"
+	"xiaozhi-esp32-server-golang/internal/app/server/chat"
+	types_conn "xiaozhi-esp32-server-golang/internal/app/server/types"
+	types_audio "xiaozhi-esp32-server-golang/internal/data/audio"
+	. "xiaozhi-esp32-server-golang/internal/data/client"
+	. "xiaozhi-esp32-server-golang/internal/data/msg"
+	config_types "xiaozhi-esp32-server-golang/internal/domain/config/types"
+	"xiaozhi-esp32-server-golang/internal/domain/mcp"
+	"xiaozhi-esp32-server-golang/internal/util"
+)
+
+// 回放的默认参数
+const (
+	DefaultWaitTimeout = 5 * time.Second
+	// WavToOpus 固定按20ms一帧编码
+	inputFrameDuration = 20
+)
+
+// Harness 在进程内驱动 ChatManager: 通过 Conn 模拟设备上行信令和录音, 使用脚本化的 ASR/LLM/TTS
+// 记录服务端下发的协议消息和音频帧, 用于对会话状态机做回归测试, 不依赖真实的服务和厂商接口
+type Harness struct {
+	DeviceID string
+	Conn     *Conn
+	Script   *Script
+
+	transportType string
+	inputFormat   types_audio.AudioFormat
+	frameInterval time.Duration //上行音频帧的发送间隔
+	waitTimeout   time.Duration
+	configure     []func(config *config_types.UConfig)
+	capabilities  *types_audio.OutputCapabilities
+
+	manager *chat.ChatManager
+	done    chan struct{}
+	runErr  error
+	hello   bool
+	cursor  int //Expect 的断言位置
+
+	transitionLock sync.Mutex
+	transitions    []Transition
+}
+
+type Option func(*Harness)
+
+// WithTransport 设备的传输类型, 默认为 websocket
+func WithTransport(transportType string) Option {
+	return func(h *Harness) {
+		h.transportType = transportType
+	}
+}
+
+// WithFrameInterval 上行音频帧的发送间隔, 默认按帧时长实时发送
+func WithFrameInterval(interval time.Duration) Option {
+	return func(h *Harness) {
+		h.frameInterval = interval
+	}
+}
+
+// WithWaitTimeout Expect 等待消息的超时时间
+func WithWaitTimeout(timeout time.Duration) Option {
+	return func(h *Harness) {
+		h.waitTimeout = timeout
+	}
+}
+
+// WithDeviceConfig 修改设备配置, 如系统提示词或VAD阈值
+func WithDeviceConfig(configure func(config *config_types.UConfig)) Option {
+	return func(h *Harness) {
+		h.configure = append(h.configure, configure)
+	}
+}
+
+// WithOutputCapabilities hello 中携带设备支持的下行音频格式
+func WithOutputCapabilities(capabilities *types_audio.OutputCapabilities) Option {
+	return func(h *Harness) {
+		h.capabilities = capabilities
+	}
+}
+
+// New 为设备创建回放会话, 设备配置指向脚本化的提供者
+// 会将 config_provider.type 设置为 replay, 同一进程内的多个 Harness 需使用不同的设备ID
+func New(deviceID string, script *Script, opts ...Option) (*Harness, error) {
+	h := &Harness{
+		DeviceID:      deviceID,
+		Script:        script,
+		transportType: types_conn.TransportTypeWebsocket,
+		inputFormat: types_audio.AudioFormat{
+			Format:        types_audio.Format,
+			SampleRate:    types_audio.SampleRate,
+			Channels:      types_audio.Channels,
+			FrameDuration: inputFrameDuration,
+		},
+		frameInterval: inputFrameDuration * time.Millisecond,
+		waitTimeout:   DefaultWaitTimeout,
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	deviceConfig := config_types.UConfig{
+		SystemPrompt: "你是一个回放测试助手",
+		Asr:          config_types.AsrConfig{Provider: ProviderName, Config: map[string]interface{}{"script": script}},
+		Llm:          config_types.LlmConfig{Provider: ProviderName, Config: map[string]interface{}{"type": ProviderName, "script": script}},
+		Tts:          config_types.TtsConfig{Provider: ProviderName, Config: map[string]interface{}{"script": script}},
+		Vad:          config_types.VadConfig{Provider: ProviderName, Config: map[string]interface{}{"threshold": DefaultVadThreshold}},
+	}
+	for _, configure := range h.configure {
+		configure(&deviceConfig)
+	}
+	configProvider.setUserConfig(deviceID, deviceConfig)
+
+	viper.Set("config_provider.type", ProviderName)
+	if auth.A() == nil {
+		auth.Init()
+	}
+	// 未启动mcp时全局管理器为空, 获取工具列表前先初始化
+	mcp.GetGlobalMCPManager()
+
+	h.Conn = NewConn(deviceID, h.transportType)
+	manager, err := chat.NewChatManager(deviceID, h.Conn)
+	if err != nil {
+		return nil, fmt.Errorf("创建会话失败: %v", err)
+	}
+	h.manager = manager
+	manager.OnStateTransition(func(transition Transition) {
+		h.transitionLock.Lock()
+		defer h.transitionLock.Unlock()
+		h.transitions = append(h.transitions, transition)
+	})
+
+	go func() {
+		defer close(h.done)
+		h.runErr = manager.Start()
+	}()
+	return h, nil
+}
+
+// Manager 获取被驱动的 ChatManager
+func (h *Harness) Manager() *chat.ChatManager {
+	return h.manager
+}
+
+func (h *Harness) pushCmd(msg ClientMessage) error {
+	msg.DeviceID = h.DeviceID
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return h.Conn.PushCmd(data)
+}
+
+// Hello 发送 hello 并等待服务端的 hello 响应
+func (h *Harness) Hello(features map[string]bool) (Event, error) {
+	inputFormat := h.inputFormat
+	err := h.pushCmd(ClientMessage{
+		Type:              MessageTypeHello,
+		Version:           1,
+		Transport:         h.transportType,
+		Features:          features,
+		AudioParams:       &inputFormat,
+		OutputAudioParams: h.capabilities,
+	})
+	if err != nil {
+		return Event{}, err
+	}
+	event, err := h.Expect(ServerMessageTypeHello, "")
+	if err != nil {
+		return event, err
+	}
+	h.hello = true
+	return event, nil
+}
+
+// ListenStart 设备开始拾音, mode 为 auto/manual/realtime
+func (h *Harness) ListenStart(mode string) error {
+	return h.pushCmd(ClientMessage{Type: MessageTypeListen, State: MessageStateStart, Mode: mode})
+}
+
+// ListenStop 设备停止拾音(manual 模式)
+func (h *Harness) ListenStop() error {
+	return h.pushCmd(ClientMessage{Type: MessageTypeListen, State: MessageStateStop})
+}
+
+// Abort 设备打断当前回复
+func (h *Harness) Abort() error {
+	return h.pushCmd(ClientMessage{Type: MessageTypeAbort})
+}
+
+// Chat 发送文本对话消息
+func (h *Harness) Chat(text string) error {
+	return h.pushCmd(ClientMessage{Type: MessageTypeChat, Text: text})
+}
+
+// PlayWav 按帧间隔上行 WAV 文件中的音频, 文件需为16k单声道16bit
+func (h *Harness) PlayWav(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取录音文件失败: %v", err)
+	}
+	return h.PlayWavData(data)
+}
+
+// PlayWavData 将 WAV 数据编码为 opus 帧后按帧间隔上行
+func (h *Harness) PlayWavData(data []byte) error {
+	frames, err := util.WavToOpus(data, h.inputFormat.SampleRate, h.inputFormat.Channels, 0)
+	if err != nil {
+		return fmt.Errorf("录音编码失败: %v", err)
+	}
+	return h.PlayOpus(frames)
+}
+
+// PlayOpus 按帧间隔上行 opus 帧
+func (h *Harness) PlayOpus(frames [][]byte) error {
+	for _, frame := range frames {
+		if err := h.Conn.PushAudio(frame); err != nil {
+			return err
+		}
+		if h.frameInterval > 0 {
+			time.Sleep(h.frameInterval)
+		}
+	}
+	return nil
+}
+
+// Expect 从上次匹配的位置开始等待指定类型和状态的信令消息, state 为空时不比较状态
+// 按顺序调用即可断言消息的先后顺序
+func (h *Harness) Expect(msgType string, state string) (Event, error) {
+	return h.ExpectMatch(fmt.Sprintf("%s/%s", msgType, state), func(e Event) bool {
+		return e.IsCmd(msgType, state)
+	})
+}
+
+// ExpectMatch 从上次匹配的位置开始等待满足条件的事件
+func (h *Harness) ExpectMatch(desc string, match func(e Event) bool) (Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.waitTimeout)
+	defer cancel()
+	index, event, err := h.Conn.WaitFor(ctx, h.cursor, match)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return event, fmt.Errorf("等待 %s 超时, 已下发: %s", desc, h.Summary())
+		}
+		return event, err
+	}
+	h.cursor = index + 1
+	return event, nil
+}
+
+// Summary 已下发数据的简要描述, 连续的音频帧合并显示, 用于断言失败时输出
+func (h *Harness) Summary() string {
+	summary := ""
+	audioFrames := 0
+	for _, event := range h.Conn.Events() {
+		if event.Cmd == nil {
+			audioFrames++
+			continue
+		}
+		if audioFrames > 0 {
+			summary += fmt.Sprintf(" [audio x%d]", audioFrames)
+			audioFrames = 0
+		}
+		summary += fmt.Sprintf(" %s/%s", event.Cmd.Type, event.Cmd.State)
+		if event.Cmd.Text != "" {
+			summary += fmt.Sprintf("(%s)", event.Cmd.Text)
+		}
+	}
+	if audioFrames > 0 {
+		summary += fmt.Sprintf(" [audio x%d]", audioFrames)
+	}
+	return summary
+}
+
+// AudioFrames 获取两个信令消息之间下发的音频帧, 用于断言帧数和发送节奏
+func AudioFrames(events []Event, start Event, end Event) []Event {
+	frames := make([]Event, 0)
+	for _, event := range events {
+		if event.Cmd != nil || event.Index < start.Index || event.Index > end.Index {
+			continue
+		}
+		frames = append(frames, event)
+	}
+	return frames
+}
+
+// Transitions 获取会话状态机目前为止的状态迁移
+func (h *Harness) Transitions() []Transition {
+	h.transitionLock.Lock()
+	defer h.transitionLock.Unlock()
+	return append([]Transition(nil), h.transitions...)
+}
+
+// States 状态迁移经过的状态, 连续相同的状态合并
+func (h *Harness) States() []SessionState {
+	var states []SessionState
+	for _, transition := range h.Transitions() {
+		if len(states) == 0 {
+			states = append(states, transition.From)
+		}
+		if states[len(states)-1] != transition.To {
+			states = append(states, transition.To)
+		}
+	}
+	return states
+}
+
+// ConversationTurns 获取会话上报的对话记录
+func (h *Harness) ConversationTurns() []map[string]interface{} {
+	return configProvider.deviceEvents(h.DeviceID, config_types.EventConversation)
+}
+
+// Close 关闭会话并等待 ChatManager 退出
+func (h *Harness) Close() error {
+	if h.hello {
+		h.manager.Close()
+	}
+	h.Conn.Close()
+
+	select {
+	case <-h.done:
+		return h.runErr
+	case <-time.After(h.waitTimeout):
+		return fmt.Errorf("等待会话退出超时")
+	}
+}
@@ -0,0 +1,366 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/cloudwego/eino/schema"
+
+	"xiaozhi-esp32-server-golang/internal/domain/asr"
+	asr_types "xiaozhi-esp32-server-golang/internal/domain/asr/types"
+	user_config "xiaozhi-esp32-server-golang/internal/domain/config"
+	config_types "xiaozhi-esp32-server-golang/internal/domain/config/types"
+	"xiaozhi-esp32-server-golang/internal/domain/llm"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+	"xiaozhi-esp32-server-golang/internal/domain/tts"
+	"xiaozhi-esp32-server-golang/internal/domain/vad"
+	"xiaozhi-esp32-server-golang/internal/domain/vad/inter"
+)
+
+// ProviderName 回放用的 ASR/LLM/TTS/VAD 提供者及用户配置提供者的名称
+const ProviderName = "replay"
+
+// 默认的回放参数
+const (
+	DefaultVadThreshold = 0.02 //能量VAD的RMS阈值
+	DefaultTtsFrames    = 3    //每句话合成的音频帧数
+	DefaultLlmChunkSize = 4    //LLM流式返回时每个分片的字数
+)
+
+func init() {
+	asr.Register(ProviderName, func(config map[string]interface{}) (asr.AsrProvider, error) {
+		script, err := scriptFromConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		return &scriptedAsr{script: script}, nil
+	}, registry.ConfigSchema{Description: "回放测试用的脚本化语音识别"})
+
+	llm.Register(ProviderName, func(config map[string]interface{}) (llm.LLMProvider, error) {
+		script, err := scriptFromConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		return &scriptedLlm{script: script}, nil
+	}, registry.ConfigSchema{Description: "回放测试用的脚本化大模型"})
+
+	tts.Register(ProviderName, func(config map[string]interface{}) (tts.BaseTTSProvider, error) {
+		script, err := scriptFromConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		return &scriptedTts{script: script}, nil
+	}, registry.ConfigSchema{Description: "回放测试用的脚本化语音合成"})
+
+	vad.Register(ProviderName, vad.Factory{
+		Acquire: func(config map[string]interface{}) (inter.VAD, error) {
+			threshold := DefaultVadThreshold
+			if value, ok := config["threshold"].(float64); ok && value > 0 {
+				threshold = value
+			}
+			return &energyVad{threshold: threshold}, nil
+		},
+		Release: func(vad inter.VAD) error { return nil },
+	}, registry.ConfigSchema{
+		Description: "回放测试用的能量VAD",
+		Fields: []registry.ConfigField{
+			{Name: "threshold", Type: registry.FieldTypeNumber, Default: DefaultVadThreshold, Description: "RMS阈值"},
+		},
+	})
+
+	user_config.RegisterProvider(ProviderName, func(config map[string]interface{}) (user_config.UserConfigProvider, error) {
+		return configProvider, nil
+	})
+}
+
+// Script 回放脚本, 按调用顺序依次返回识别结果和模型回复, 并记录收到的请求
+type Script struct {
+	sync.Mutex
+
+	AsrResults []string //每次识别结束时依次返回, 用完后返回空结果
+	LlmReplies []string //每次请求依次返回, 用完后返回空回复
+	TtsFrames  int      //每句话合成的音频帧数, 为0时使用 DefaultTtsFrames
+	LlmChunk   int      //流式返回时每个分片的字数, 为0时使用 DefaultLlmChunkSize
+
+	asrIndex    int
+	llmIndex    int
+	asrSamples  []int
+	llmRequests [][]*schema.Message
+	ttsTexts    []string
+}
+
+func scriptFromConfig(config map[string]interface{}) (*Script, error) {
+	script, ok := config["script"].(*Script)
+	if !ok || script == nil {
+		return nil, errors.New("回放提供者缺少 script 配置")
+	}
+	return script, nil
+}
+
+// AsrSamples 每次识别收到的音频采样数
+func (s *Script) AsrSamples() []int {
+	s.Lock()
+	defer s.Unlock()
+	return append([]int(nil), s.asrSamples...)
+}
+
+// LlmRequests 每次请求大模型时的完整对话
+func (s *Script) LlmRequests() [][]*schema.Message {
+	s.Lock()
+	defer s.Unlock()
+	return append([][]*schema.Message(nil), s.llmRequests...)
+}
+
+// TtsTexts 依次合成的句子
+func (s *Script) TtsTexts() []string {
+	s.Lock()
+	defer s.Unlock()
+	return append([]string(nil), s.ttsTexts...)
+}
+
+func (s *Script) nextAsr(samples int) string {
+	s.Lock()
+	defer s.Unlock()
+	s.asrSamples = append(s.asrSamples, samples)
+	if s.asrIndex >= len(s.AsrResults) {
+		return ""
+	}
+	text := s.AsrResults[s.asrIndex]
+	s.asrIndex++
+	return text
+}
+
+func (s *Script) nextLlm(dialogue []*schema.Message) (string, int) {
+	s.Lock()
+	defer s.Unlock()
+	s.llmRequests = append(s.llmRequests, append([]*schema.Message(nil), dialogue...))
+	chunk := s.LlmChunk
+	if chunk <= 0 {
+		chunk = DefaultLlmChunkSize
+	}
+	if s.llmIndex >= len(s.LlmReplies) {
+		return "", chunk
+	}
+	reply := s.LlmReplies[s.llmIndex]
+	s.llmIndex++
+	return reply, chunk
+}
+
+func (s *Script) addTts(text string) int {
+	s.Lock()
+	defer s.Unlock()
+	s.ttsTexts = append(s.ttsTexts, text)
+	if s.TtsFrames <= 0 {
+		return DefaultTtsFrames
+	}
+	return s.TtsFrames
+}
+
+// scriptedAsr 收完一轮音频后返回脚本中的下一条识别结果
+type scriptedAsr struct {
+	script *Script
+}
+
+func (a *scriptedAsr) Process(pcmData []float32) (string, error) {
+	return a.script.nextAsr(len(pcmData)), nil
+}
+
+func (a *scriptedAsr) StreamingRecognize(ctx context.Context, audioStream <-chan []float32) (chan asr_types.StreamingResult, error) {
+	resultChan := make(chan asr_types.StreamingResult, 1)
+	go func() {
+		defer close(resultChan)
+		samples := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case pcm, ok := <-audioStream:
+				if ok {
+					samples += len(pcm)
+					continue
+				}
+				select {
+				case resultChan <- asr_types.StreamingResult{Text: a.script.nextAsr(samples), IsFinal: true}:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+	return resultChan, nil
+}
+
+// scriptedLlm 按分片流式返回脚本中的下一条回复
+type scriptedLlm struct {
+	script *Script
+}
+
+func (l *scriptedLlm) ResponseWithContext(ctx context.Context, sessionID string, dialogue []*schema.Message, functions []*schema.ToolInfo) chan *schema.Message {
+	reply, chunk := l.script.nextLlm(dialogue)
+	msgChan := make(chan *schema.Message, 10)
+	go func() {
+		defer close(msgChan)
+		runes := []rune(reply)
+		for start := 0; start < len(runes); start += chunk {
+			end := start + chunk
+			if end > len(runes) {
+				end = len(runes)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case msgChan <- schema.AssistantMessage(string(runes[start:end]), nil):
+			}
+		}
+	}()
+	return msgChan
+}
+
+func (l *scriptedLlm) ResponseWithVllm(ctx context.Context, file []byte, text string, mimeType string) (string, error) {
+	return "", errors.New("回放大模型不支持图片识别")
+}
+
+func (l *scriptedLlm) GetModelInfo() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       ProviderName,
+		"model_name": ProviderName,
+	}
+}
+
+// scriptedTts 每句话合成固定帧数的音频, 帧内容为帧序号
+type scriptedTts struct {
+	script *Script
+}
+
+func (t *scriptedTts) frames(text string) ([][]byte, error) {
+	count := t.script.addTts(text)
+	frames := make([][]byte, count)
+	for i := range frames {
+		frames[i] = []byte(fmt.Sprintf("frame-%d", i))
+	}
+	return frames, nil
+}
+
+func (t *scriptedTts) TextToSpeech(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) ([][]byte, error) {
+	return t.frames(text)
+}
+
+func (t *scriptedTts) TextToSpeechStream(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (chan []byte, error) {
+	frames, err := t.frames(text)
+	if err != nil {
+		return nil, err
+	}
+	outputChan := make(chan []byte, len(frames))
+	for _, frame := range frames {
+		outputChan <- frame
+	}
+	close(outputChan)
+	return outputChan, nil
+}
+
+// energyVad 按RMS能量判断是否有语音
+type energyVad struct {
+	threshold float64
+}
+
+func (v *energyVad) IsVAD(pcmData []float32) (bool, error) {
+	if len(pcmData) == 0 {
+		return false, nil
+	}
+	var sum float64
+	for _, sample := range pcmData {
+		sum += float64(sample) * float64(sample)
+	}
+	return math.Sqrt(sum/float64(len(pcmData))) >= v.threshold, nil
+}
+
+func (v *energyVad) IsVADExt(pcmData []float32, sampleRate int, frameSize int) (bool, error) {
+	return v.IsVAD(pcmData)
+}
+
+func (v *energyVad) Reset() error {
+	return nil
+}
+
+func (v *energyVad) Close() error {
+	return nil
+}
+
+// memoryConfigProvider 进程内的用户配置提供者, 设备配置由 Harness 写入
+type memoryConfigProvider struct {
+	sync.RWMutex
+	configs map[string]config_types.UConfig
+	events  map[string][]map[string]interface{} //按事件类型记录上报的设备事件
+}
+
+var configProvider = &memoryConfigProvider{
+	configs: make(map[string]config_types.UConfig),
+	events:  make(map[string][]map[string]interface{}),
+}
+
+// setUserConfig 设置设备配置, 并清空该设备之前上报的事件
+func (p *memoryConfigProvider) setUserConfig(deviceID string, config config_types.UConfig) {
+	p.Lock()
+	defer p.Unlock()
+	p.configs[deviceID] = config
+	for eventType, events := range p.events {
+		kept := events[:0]
+		for _, event := range events {
+			if event["device_id"] != deviceID {
+				kept = append(kept, event)
+			}
+		}
+		p.events[eventType] = kept
+	}
+}
+
+// deviceEvents 获取设备上报的指定类型事件
+func (p *memoryConfigProvider) deviceEvents(deviceID string, eventType string) []map[string]interface{} {
+	p.RLock()
+	defer p.RUnlock()
+	var events []map[string]interface{}
+	for _, event := range p.events[eventType] {
+		if event["device_id"] == deviceID {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func (p *memoryConfigProvider) IsDeviceActivated(ctx context.Context, deviceId string, clientId string) (bool, error) {
+	return true, nil
+}
+
+func (p *memoryConfigProvider) GetActivationInfo(ctx context.Context, deviceId string, clientId string) (int, string, string, int) {
+	return 0, "", "", 0
+}
+
+func (p *memoryConfigProvider) VerifyChallenge(ctx context.Context, deviceId string, clientId string, activationPayload config_types.ActivationPayload) (bool, error) {
+	return true, nil
+}
+
+func (p *memoryConfigProvider) GetUserConfig(ctx context.Context, deviceID string) (config_types.UConfig, error) {
+	p.RLock()
+	defer p.RUnlock()
+	config, ok := p.configs[deviceID]
+	if !ok {
+		return config_types.UConfig{}, fmt.Errorf("设备 %s 未配置回放脚本", deviceID)
+	}
+	return config, nil
+}
+
+func (p *memoryConfigProvider) GetSystemConfig(ctx context.Context) (string, error) {
+	return "", nil
+}
+
+func (p *memoryConfigProvider) NotifyDeviceEvent(ctx context.Context, eventType string, eventData map[string]interface{}) {
+	p.Lock()
+	defer p.Unlock()
+	p.events[eventType] = append(p.events[eventType], eventData)
+}
+
+func (p *memoryConfigProvider) RegisterMessageEventHandler(ctx context.Context, eventType string, eventHandler config_types.EventHandler) {
+}
@@ -0,0 +1,112 @@
+package replay
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	types_audio "xiaozhi-esp32-server-golang/internal/data/audio"
+	. "xiaozhi-esp32-server-golang/internal/data/msg"
+	config_types "xiaozhi-esp32-server-golang/internal/domain/config/types"
+)
+
+// fakeRealtimeServer 模拟实时语音模型, 下发 send 中的事件, 记录收到的客户端事件类型
+func fakeRealtimeServer(t *testing.T, send chan string, received chan string) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer ws.Close()
+		go func() {
+			for event := range send {
+				ws.WriteMessage(websocket.TextMessage, []byte(event))
+			}
+		}()
+		for {
+			var event struct {
+				Type string `json:"type"`
+			}
+			if err := ws.ReadJSON(&event); err != nil {
+				return
+			}
+			select {
+			case received <- event.Type:
+			default:
+			}
+		}
+	}))
+}
+
+func TestReplayRealtimeInterruptAfterResponseDone(t *testing.T) {
+	send := make(chan string, 10)
+	received := make(chan string, 1000)
+	server := fakeRealtimeServer(t, send, received)
+	defer server.Close()
+	defer close(send)
+
+	configure := func(config *config_types.UConfig) {
+		config.Llm = config_types.LlmConfig{Provider: "realtime", Config: map[string]interface{}{
+			"type":       "realtime",
+			"base_url":   "ws" + strings.TrimPrefix(server.URL, "http"),
+			"model_name": "test-model",
+		}}
+	}
+	capabilities := &types_audio.OutputCapabilities{FrameDurations: []int{20}}
+	h, err := New("replay-realtime", &Script{}, WithDeviceConfig(configure), WithOutputCapabilities(capabilities))
+	if err != nil {
+		t.Fatalf("创建回放会话失败: %v", err)
+	}
+	t.Cleanup(func() {
+		h.Close()
+	})
+	if _, err := h.Hello(map[string]bool{"mcp": false}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模型一次性返回3秒的回复音频, 之后设备按实时速度播放
+	pcm := make([]byte, 24000*2*3)
+	delta, _ := json.Marshal(map[string]string{"type": "response.audio.delta", "delta": base64.StdEncoding.EncodeToString(pcm)})
+	send <- `{"type":"response.created"}`
+	send <- string(delta)
+	send <- `{"type":"response.audio_transcript.done","transcript":"好的"}`
+	send <- `{"type":"response.done"}`
+
+	expect(t, h, ServerMessageTypeTts, MessageStateStart)
+	expect(t, h, ServerMessageTypeTts, MessageStateSentenceStart)
+	if _, err := h.ExpectMatch("音频帧", func(e Event) bool { return e.Audio != nil }); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	// response.done 之后打断, 剩余的缓冲音频不再下发
+	abortAt := time.Now()
+	if err := h.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	stop := expect(t, h, ServerMessageTypeTts, MessageStateStop)
+	if elapsed := stop.Time.Sub(abortAt); elapsed > time.Second {
+		t.Fatalf("打断后 %v 才下发 tts stop, 已下发: %s", elapsed, h.Summary())
+	}
+	time.Sleep(200 * time.Millisecond)
+	for _, event := range h.Conn.Events() {
+		if event.Audio != nil && event.Index > stop.Index {
+			t.Fatalf("tts stop 之后仍在下发音频: %s", h.Summary())
+		}
+	}
+
+	// 回复已生成完, 不需要再取消模型的回复
+	for len(received) > 0 {
+		if eventType := <-received; eventType == "response.cancel" {
+			t.Fatal("response.done 之后不应发送 response.cancel")
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package replay
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/spf13/viper"
+
+	types_audio "xiaozhi-esp32-server-golang/internal/data/audio"
+	. "xiaozhi-esp32-server-golang/internal/data/client"
+	. "xiaozhi-esp32-server-golang/internal/data/msg"
+	"xiaozhi-esp32-server-golang/internal/util"
+)
+
+// writeWav 生成一段录音: 静音、440Hz 的"语音"、静音, 单位毫秒
+func writeWav(t *testing.T, silenceMs int, voiceMs int, tailMs int) string {
+	const sampleRate = 16000
+	pcm := make([]float32, (silenceMs+voiceMs+tailMs)*sampleRate/1000)
+	voiceStart := silenceMs * sampleRate / 1000
+	voiceEnd := voiceStart + voiceMs*sampleRate/1000
+	for i := voiceStart; i < voiceEnd; i++ {
+		pcm[i] = float32(0.3 * math.Sin(2*math.Pi*440*float64(i)/sampleRate))
+	}
+	path := filepath.Join(t.TempDir(), "input.wav")
+	if err := os.WriteFile(path, util.PcmToWav(pcm, sampleRate), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newHarness(t *testing.T, deviceID string, script *Script) *Harness {
+	h, err := New(deviceID, script, WithFrameInterval(2*time.Millisecond))
+	if err != nil {
+		t.Fatalf("创建回放会话失败: %v", err)
+	}
+	t.Cleanup(func() {
+		h.Close()
+	})
+	hello, err := h.Hello(map[string]bool{"mcp": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hello.Cmd.SessionID == "" || hello.Cmd.Transport != "websocket" || hello.Cmd.AudioFormat == nil {
+		t.Fatalf("hello = %s", hello.Raw)
+	}
+	return h
+}
+
+func expect(t *testing.T, h *Harness, msgType string, state string) Event {
+	t.Helper()
+	event, err := h.Expect(msgType, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestReplayVoiceTurn(t *testing.T) {
+	reply := "今天是晴天，气温二十五度。适合出门散步！"
+	script := &Script{
+		AsrResults: []string{"今天天气怎么样"},
+		LlmReplies: []string{reply},
+		TtsFrames:  2,
+	}
+	h := newHarness(t, "replay-voice-turn", script)
+
+	if err := h.ListenStart("auto"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.PlayWav(writeWav(t, 200, 600, 400)); err != nil {
+		t.Fatal(err)
+	}
+
+	stt := expect(t, h, ServerMessageTypeStt, "")
+	if stt.Cmd.Text != "今天天气怎么样" {
+		t.Fatalf("stt = %s", stt.Raw)
+	}
+	expect(t, h, ServerMessageTypeTts, MessageStateStart)
+
+	var sentences []string
+	for {
+		event, err := h.ExpectMatch("tts sentence_start/stop", func(e Event) bool {
+			return e.IsCmd(ServerMessageTypeTts, MessageStateSentenceStart) || e.IsCmd(ServerMessageTypeTts, MessageStateStop)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Cmd.State == MessageStateStop {
+			break
+		}
+		end := expect(t, h, ServerMessageTypeTts, MessageStateSentenceEnd)
+		if end.Cmd.Text != event.Cmd.Text {
+			t.Fatalf("sentence_end = %s, sentence_start = %s", end.Raw, event.Raw)
+		}
+		if frames := AudioFrames(h.Conn.Events(), event, end); len(frames) != script.TtsFrames {
+			t.Fatalf("句子 %s 下发 %d 帧, 期望 %d 帧", event.Cmd.Text, len(frames), script.TtsFrames)
+		}
+		sentences = append(sentences, event.Cmd.Text)
+	}
+
+	if strings.Join(sentences, "") != reply || strings.Join(script.TtsTexts(), "") != reply {
+		t.Fatalf("sentences = %v, tts = %v", sentences, script.TtsTexts())
+	}
+	if samples := script.AsrSamples(); len(samples) != 1 || samples[0] < 16000*600/1000 {
+		t.Fatalf("asr samples = %v", samples)
+	}
+	requests := script.LlmRequests()
+	if len(requests) != 1 {
+		t.Fatalf("llm requests = %d", len(requests))
+	}
+	last := requests[0][len(requests[0])-1]
+	if last.Role != schema.User || last.Content != "今天天气怎么样" {
+		t.Fatalf("last message = %+v", last)
+	}
+	wantStates := []SessionState{StateIdle, StateListening, StateRecognizing, StateThinking, StateSpeaking, StateIdle}
+	if states := h.States(); !reflect.DeepEqual(states, wantStates) {
+		t.Fatalf("states = %v, want %v", states, wantStates)
+	}
+
+	// 一轮对话结束后异步上报
+	deadline := time.Now().Add(time.Second)
+	for len(h.ConversationTurns()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	turns := h.ConversationTurns()
+	if len(turns) != 1 || turns[0]["user_text"] != "今天天气怎么样" || turns[0]["assistant_text"] != reply {
+		t.Fatalf("turns = %+v", turns)
+	}
+}
+
+func TestReplaySilenceNotRecognized(t *testing.T) {
+	script := &Script{AsrResults: []string{"不应被识别"}}
+	h := newHarness(t, "replay-silence", script)
+
+	if err := h.ListenStart("auto"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.PlayWav(writeWav(t, 600, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if samples := script.AsrSamples(); len(samples) != 0 {
+		t.Fatalf("静音不应送入asr: %v", samples)
+	}
+	if events := h.Conn.Events(); len(events) != 1 {
+		t.Fatalf("静音不应有下发: %s", h.Summary())
+	}
+	if state := h.Manager().GetClientState().StateMachine.State(); state != StateListening {
+		t.Fatalf("静音时应保持拾音, state = %s", state)
+	}
+}
+
+func TestReplayTtsFramePacing(t *testing.T) {
+	script := &Script{
+		LlmReplies: []string{"我给你讲个故事吧。"},
+		TtsFrames:  8,
+	}
+	h := newHarness(t, "replay-pacing", script)
+
+	if err := h.Chat("讲个故事"); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, h, ServerMessageTypeStt, "")
+	start := expect(t, h, ServerMessageTypeTts, MessageStateSentenceStart)
+	end := expect(t, h, ServerMessageTypeTts, MessageStateSentenceEnd)
+	expect(t, h, ServerMessageTypeTts, MessageStateStop)
+
+	frames := AudioFrames(h.Conn.Events(), start, end)
+	if len(frames) != script.TtsFrames {
+		t.Fatalf("下发 %d 帧, 期望 %d 帧", len(frames), script.TtsFrames)
+	}
+
+	// 先突发发送120ms的缓冲帧, 之后按帧时长匀速发送, 发完后等待设备播放完剩余缓冲
+	frameDuration := time.Duration(h.Manager().GetClientState().OutputAudioFormat.FrameDuration) * time.Millisecond
+	cacheFrames := int(120 * time.Millisecond / frameDuration)
+	tolerance := 15 * time.Millisecond
+	first := frames[0].Time
+	for i, frame := range frames {
+		if earliest := first.Add(time.Duration(i-cacheFrames)*frameDuration - tolerance); frame.Time.Before(earliest) {
+			t.Fatalf("第 %d 帧发送过早: 距首帧 %v", i, frame.Time.Sub(first))
+		}
+	}
+	if played := end.Time.Sub(first); played < time.Duration(len(frames))*frameDuration-tolerance {
+		t.Fatalf("sentence_end 应在音频播放完后下发, 距首帧 %v", played)
+	}
+}
+
+func TestReplayNegotiatedOutputFormat(t *testing.T) {
+	capabilities := &types_audio.OutputCapabilities{SampleRates: []int{24000}, FrameDurations: []int{20, 40}}
+	h, err := New("replay-negotiate", &Script{}, WithOutputCapabilities(capabilities))
+	if err != nil {
+		t.Fatalf("创建回放会话失败: %v", err)
+	}
+	t.Cleanup(func() {
+		h.Close()
+	})
+	hello, err := h.Hello(map[string]bool{"mcp": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 回放TTS没有原生格式, 按默认的 16000Hz/60ms 从设备支持的格式中选择
+	format := hello.Cmd.AudioFormat
+	if format == nil || format.SampleRate != 24000 || format.FrameDuration != 40 {
+		t.Fatalf("hello = %s", hello.Raw)
+	}
+	if state := h.Manager().GetClientState().OutputAudioFormat; state != *format {
+		t.Fatalf("OutputAudioFormat = %+v, hello audio_params = %+v", state, *format)
+	}
+}
+
+func TestReplayListenStartInterruptsReply(t *testing.T) {
+	script := &Script{
+		LlmReplies: []string{"从前有座山，山里有座庙。"},
+		TtsFrames:  50,
+	}
+	h := newHarness(t, "replay-interrupt", script)
+
+	if err := h.Chat("讲个故事"); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, h, ServerMessageTypeTts, MessageStateSentenceStart)
+	if err := h.ListenStart("auto"); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !h.Manager().GetClientState().StateMachine.Is(StateListening) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if state := h.Manager().GetClientState().StateMachine.State(); state != StateListening {
+		t.Fatalf("state = %s, 已下发: %s", state, h.Summary())
+	}
+	// 被打断的回复不再下发 tts stop, 避免设备退出拾音
+	for _, event := range h.Conn.Events() {
+		if event.IsCmd(ServerMessageTypeTts, MessageStateStop) {
+			t.Fatalf("打断后不应下发 tts stop: %s", h.Summary())
+		}
+	}
+}
+
+func TestReplayBargeInThenListenStart(t *testing.T) {
+	viper.Set("chat.barge_in.enable", true)
+	t.Cleanup(func() {
+		viper.Set("chat.barge_in.enable", false)
+	})
+	script := &Script{
+		AsrResults: []string{"讲个故事", "换个故事"},
+		LlmReplies: []string{"从前有座山，山里有座庙。", "好的。"},
+		TtsFrames:  200,
+	}
+	h := newHarness(t, "replay-barge-in", script)
+
+	if err := h.ListenStart("auto"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.PlayWav(writeWav(t, 200, 600, 400)); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, h, ServerMessageTypeTts, MessageStateSentenceStart)
+
+	// 播放期间用户持续说话, 服务端检测到插话, 打断回复并开始新一轮拾音
+	if err := h.PlayWav(writeWav(t, 0, 600, 0)); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, h, ServerMessageTypeTts, MessageStateStop)
+
+	// 设备收到 tts stop 后发来 listen start, 不应重启识别丢弃插话的语音
+	if err := h.ListenStart("auto"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.PlayWav(writeWav(t, 0, 400, 600)); err != nil {
+		t.Fatal(err)
+	}
+	stt, err := h.ExpectMatch("stt 换个故事", func(e Event) bool {
+		return e.IsCmd(ServerMessageTypeStt, "") && e.Cmd.Text == "换个故事"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if samples := script.AsrSamples(); len(samples) != 2 || samples[1] < 16000*900/1000 {
+		t.Fatalf("asr samples = %v, 插话的语音应与之后的语音在同一次识别中, stt = %s", samples, stt.Raw)
+	}
+}
+
+func TestReplayInjectMessageWhileSpeaking(t *testing.T) {
+	script := &Script{
+		LlmReplies: []string{"从前有座山，山里有座庙。", "该睡觉了。"},
+		TtsFrames:  200,
+	}
+	h := newHarness(t, "replay-inject", script)
+
+	if err := h.Chat("讲个故事"); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, h, ServerMessageTypeTts, MessageStateSentenceStart)
+
+	// 播放期间管理后台注入需要大模型回复的消息, 打断当前回复并回复注入的消息
+	if err := h.Manager().InjectMessage("提醒我睡觉", false); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, h, ServerMessageTypeTts, MessageStateStop)
+	if _, err := h.ExpectMatch("注入消息的回复", func(e Event) bool {
+		return e.IsCmd(ServerMessageTypeTts, MessageStateSentenceStart) && e.Cmd.Text == "该睡觉了。"
+	}); err != nil {
+		t.Fatal(err)
+	}
+	requests := script.LlmRequests()
+	if len(requests) != 2 {
+		t.Fatalf("llm requests = %d", len(requests))
+	}
+	if last := requests[1][len(requests[1])-1]; last.Role != schema.User || last.Content != "提醒我睡觉" {
+		t.Fatalf("last message = %+v", last)
+	}
+}
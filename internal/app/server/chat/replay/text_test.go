@@ -0,0 +1,137 @@
+package replay
+
+import (
+	"strings"
+	"testing"
+
+	types_conn "xiaozhi-esp32-server-golang/internal/app/server/types"
+	. "xiaozhi-esp32-server-golang/internal/data/msg"
+)
+
+func newTextHarness(t *testing.T, deviceID string, script *Script, features map[string]bool) (*Harness, Event) {
+	h, err := New(deviceID, script, WithTransport(types_conn.TransportTypeText))
+	if err != nil {
+		t.Fatalf("创建回放会话失败: %v", err)
+	}
+	t.Cleanup(func() {
+		h.Close()
+	})
+	hello, err := h.Hello(features)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hello.Cmd.SessionID == "" || hello.Cmd.Transport != types_conn.TransportTypeText {
+		t.Fatalf("hello = %s", hello.Raw)
+	}
+	return h, hello
+}
+
+func TestReplayTextChatWithoutTts(t *testing.T) {
+	reply := "你好呀。有什么可以帮你？"
+	script := &Script{LlmReplies: []string{reply}}
+	h, hello := newTextHarness(t, "replay-text-chat", script, nil)
+
+	// 未声明 tts 时不下发音频参数
+	if hello.Cmd.AudioFormat != nil {
+		t.Fatalf("hello = %s", hello.Raw)
+	}
+
+	if err := h.Chat("你好"); err != nil {
+		t.Fatal(err)
+	}
+	stt := expect(t, h, ServerMessageTypeStt, "")
+	if stt.Cmd.Text != "你好" {
+		t.Fatalf("stt = %s", stt.Raw)
+	}
+	expect(t, h, ServerMessageTypeTts, MessageStateStart)
+
+	// 回复只下发句子文本, 不合成语音
+	var sentences []string
+	for {
+		event, err := h.ExpectMatch("tts sentence_start/stop", func(e Event) bool {
+			return e.IsCmd(ServerMessageTypeTts, MessageStateSentenceStart) || e.IsCmd(ServerMessageTypeTts, MessageStateStop)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Cmd.State == MessageStateStop {
+			break
+		}
+		end := expect(t, h, ServerMessageTypeTts, MessageStateSentenceEnd)
+		if end.Cmd.Text != event.Cmd.Text {
+			t.Fatalf("sentence_end = %s, sentence_start = %s", end.Raw, event.Raw)
+		}
+		sentences = append(sentences, event.Cmd.Text)
+	}
+	if strings.Join(sentences, "") != reply {
+		t.Fatalf("sentences = %v", sentences)
+	}
+	if texts := script.TtsTexts(); len(texts) != 0 {
+		t.Fatalf("未开启语音回复时不应合成语音: %v", texts)
+	}
+	for _, event := range h.Conn.Events() {
+		if event.Cmd == nil {
+			t.Fatalf("未开启语音回复时不应下发音频: %s", h.Summary())
+		}
+	}
+	requests := script.LlmRequests()
+	if len(requests) != 1 {
+		t.Fatalf("llm requests = %d", len(requests))
+	}
+	if last := requests[0][len(requests[0])-1]; last.Content != "你好" {
+		t.Fatalf("last message = %+v", last)
+	}
+}
+
+func TestReplayTextChatWhileSpeaking(t *testing.T) {
+	script := &Script{
+		LlmReplies: []string{"从前有座山，山里有座庙。", "好的。"},
+		TtsFrames:  200,
+	}
+	h, hello := newTextHarness(t, "replay-text-interrupt", script, map[string]bool{"tts": true})
+	if hello.Cmd.AudioFormat == nil {
+		t.Fatalf("开启语音回复时应下发音频参数: %s", hello.Raw)
+	}
+
+	if err := h.Chat("讲个故事"); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, h, ServerMessageTypeTts, MessageStateSentenceStart)
+	if _, err := h.ExpectMatch("音频帧", func(e Event) bool { return e.Cmd == nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	// 播放期间发来新的文本, 打断当前回复并回复新的文本
+	if err := h.Chat("换个话题"); err != nil {
+		t.Fatal(err)
+	}
+	stt, err := h.ExpectMatch("stt 换个话题", func(e Event) bool {
+		return e.IsCmd(ServerMessageTypeStt, "") && e.Cmd.Text == "换个话题"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.ExpectMatch("新文本的回复", func(e Event) bool {
+		return e.IsCmd(ServerMessageTypeTts, MessageStateSentenceStart) && e.Cmd.Text == "好的。"
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 被打断的回复不再继续下发音频
+	frames := 0
+	for _, event := range h.Conn.Events() {
+		if event.Index >= stt.Index {
+			break
+		}
+		if event.Cmd == nil {
+			frames++
+		}
+	}
+	if frames >= script.TtsFrames {
+		t.Fatalf("被打断的回复下发了 %d 帧", frames)
+	}
+	requests := script.LlmRequests()
+	if len(requests) != 2 || requests[1][len(requests[1])-1].Content != "换个话题" {
+		t.Fatalf("llm requests = %v", requests)
+	}
+}
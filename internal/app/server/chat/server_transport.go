@@ -10,6 +10,7 @@ import (
 	types_audio "xiaozhi-esp32-server-golang/internal/data/audio"
 	. "xiaozhi-esp32-server-golang/internal/data/client"
 	. "xiaozhi-esp32-server-golang/internal/data/msg"
+	"xiaozhi-esp32-server-golang/internal/domain/llm"
 )
 
 // ServerTransport handles sending messages to the client via the transport layer
@@ -30,7 +31,11 @@ func NewServerTransport(transport types_conn.IConn, clientState *ClientState) *S
 	}
 }
 
-func (s *ServerTransport) SendTtsStart() error {
+// SendTtsStart ctx 所属的轮次已结束时不再下发
+func (s *ServerTransport) SendTtsStart(ctx context.Context) error {
+	if err := s.clientState.StateMachine.FireContext(ctx, EventSpeakStart); err != nil {
+		return err
+	}
 	msg := ServerMessage{
 		Type:      ServerMessageTypeTts,
 		State:     MessageStateStart,
@@ -40,15 +45,14 @@ func (s *ServerTransport) SendTtsStart() error {
 	if err != nil {
 		return err
 	}
-	err = s.transport.SendCmd(bytes)
-	if err != nil {
-		return err
-	}
-	s.clientState.SetTtsStart(true)
-	return nil
+	return s.transport.SendCmd(bytes)
 }
 
-func (s *ServerTransport) SendTtsStop() error {
+// SendTtsStop 本轮回复结束, 已被打断或开始新一轮拾音时不再下发
+func (s *ServerTransport) SendTtsStop(ctx context.Context) error {
+	if err := s.clientState.StateMachine.FireContext(ctx, EventTurnEnd); err != nil {
+		return err
+	}
 	msg := ServerMessage{
 		Type:      ServerMessageTypeTts,
 		State:     MessageStateStop,
@@ -137,29 +141,43 @@ func (s *ServerTransport) SendAsrResult(text string) error {
 	return nil
 }
 
-func (s *ServerTransport) SendSentenceStart(text string) error {
-	response := ServerMessage{
-		Type:      ServerMessageTypeTts,
-		State:     MessageStateSentenceStart,
+// SendAsrPartialResult 发送中间识别结果，用于设备实时显示字幕
+func (s *ServerTransport) SendAsrPartialResult(text string) error {
+	resp := ServerMessage{
+		Type:      ServerMessageTypeStt,
 		Text:      text,
+		IsPartial: true,
 		SessionID: s.clientState.SessionID,
 	}
-	bytes, err := json.Marshal(response)
+	bytes, err := json.Marshal(resp)
 	if err != nil {
 		return err
 	}
-	err = s.transport.SendCmd(bytes)
+	return s.transport.SendCmd(bytes)
+}
+
+// SendEmotion 发送表情, text 为表情对应的 emoji
+func (s *ServerTransport) SendEmotion(emotion string) error {
+	resp := ServerMessage{
+		Type:      ServerMessageTypeLlm,
+		Text:      llm.DeviceEmotionEmoji[emotion],
+		Emotion:   emotion,
+		SessionID: s.clientState.SessionID,
+	}
+	bytes, err := json.Marshal(resp)
 	if err != nil {
 		return err
 	}
-	s.clientState.SetStatus(ClientStatusTTSStart)
-	return nil
+	return s.transport.SendCmd(bytes)
 }
 
-func (s *ServerTransport) SendSentenceEnd(text string) error {
+func (s *ServerTransport) SendSentenceStart(ctx context.Context, text string) error {
+	if err := s.clientState.StateMachine.FireContext(ctx, EventSpeakStart); err != nil {
+		return err
+	}
 	response := ServerMessage{
 		Type:      ServerMessageTypeTts,
-		State:     MessageStateSentenceEnd,
+		State:     MessageStateSentenceStart,
 		Text:      text,
 		SessionID: s.clientState.SessionID,
 	}
@@ -167,12 +185,21 @@ func (s *ServerTransport) SendSentenceEnd(text string) error {
 	if err != nil {
 		return err
 	}
-	err = s.transport.SendCmd(bytes)
+	return s.transport.SendCmd(bytes)
+}
+
+func (s *ServerTransport) SendSentenceEnd(text string) error {
+	response := ServerMessage{
+		Type:      ServerMessageTypeTts,
+		State:     MessageStateSentenceEnd,
+		Text:      text,
+		SessionID: s.clientState.SessionID,
+	}
+	bytes, err := json.Marshal(response)
 	if err != nil {
 		return err
 	}
-	s.clientState.SetStatus(ClientStatusTTSStart)
-	return nil
+	return s.transport.SendCmd(bytes)
 }
 
 func (s *ServerTransport) SendCmd(cmdBytes []byte) error {
@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"runtime/debug"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/cloudwego/eino/components/tool"
@@ -15,12 +16,17 @@ import (
 
 	"xiaozhi-esp32-server-golang/internal/app/server/auth"
 	types_conn "xiaozhi-esp32-server-golang/internal/app/server/types"
+	types_audio "xiaozhi-esp32-server-golang/internal/data/audio"
 	. "xiaozhi-esp32-server-golang/internal/data/client"
 	. "xiaozhi-esp32-server-golang/internal/data/msg"
 	user_config "xiaozhi-esp32-server-golang/internal/domain/config"
 	"xiaozhi-esp32-server-golang/internal/domain/llm"
 	llm_common "xiaozhi-esp32-server-golang/internal/domain/llm/common"
+	"xiaozhi-esp32-server-golang/internal/domain/llm/realtime"
 	"xiaozhi-esp32-server-golang/internal/domain/mcp"
+	"xiaozhi-esp32-server-golang/internal/domain/metrics"
+	"xiaozhi-esp32-server-golang/internal/domain/recording"
+	"xiaozhi-esp32-server-golang/internal/domain/tracing"
 	"xiaozhi-esp32-server-golang/internal/domain/tts"
 	"xiaozhi-esp32-server-golang/internal/util"
 	log "xiaozhi-esp32-server-golang/logger"
@@ -42,6 +48,11 @@ type ChatSession struct {
 	cancel context.CancelFunc
 
 	chatTextQueue *util.Queue[AsrResponseChannelItem]
+
+	pendingProviders atomic.Pointer[DeviceProviders] //待生效的设备配置, 在对话间隙替换
+	bargeInListen    atomic.Pointer[bargeInListen]   //插话开始的拾音, 用于识别设备随后发来的 listen start
+
+	realtime *RealtimeSession //实时语音模式, 为空时使用 vad→asr→llm→tts 链路
 }
 
 type ChatSessionOption func(*ChatSession)
@@ -56,9 +67,14 @@ func NewChatSession(clientState *ClientState, serverTransport *ServerTransport,
 		opt(s)
 	}
 
-	s.asrManager = NewASRManager(clientState, serverTransport)
-	s.ttsManager = NewTTSManager(clientState, serverTransport)
-	s.llmManager = NewLLMManager(clientState, serverTransport, s.ttsManager)
+	var audioRecorder *turnAudioRecorder
+	if recorder := recording.Get(); recorder != nil {
+		audioRecorder = newTurnAudioRecorder(clientState, recorder)
+	}
+
+	s.asrManager = NewASRManager(clientState, serverTransport, WithBargeIn(LoadBargeInConfig(), s.HandleBargeIn), WithASRAudioRecorder(audioRecorder))
+	s.ttsManager = NewTTSManager(clientState, serverTransport, WithTTSAudioRecorder(audioRecorder))
+	s.llmManager = NewLLMManager(clientState, serverTransport, s.ttsManager, WithLLMAudioRecorder(audioRecorder))
 
 	return s
 }
@@ -81,6 +97,48 @@ func (s *ChatSession) Start(pctx context.Context) error {
 	return nil
 }
 
+// ReloadConfig 重新获取设备配置并创建 ASR/LLM/TTS 提供者
+// 为避免一轮回复中途切换音色或模型, 新配置暂存起来, 在下一次拾音或下一轮对话开始前替换
+func (s *ChatSession) ReloadConfig(ctx context.Context) error {
+	configProvider, err := user_config.GetProvider(viper.GetString("config_provider.type"))
+	if err != nil {
+		return fmt.Errorf("获取用户配置提供者失败: %v", err)
+	}
+	deviceConfig, err := configProvider.GetUserConfig(ctx, s.clientState.DeviceID)
+	if err != nil {
+		return fmt.Errorf("获取设备 %s 配置失败: %v", s.clientState.DeviceID, err)
+	}
+	providers, err := NewDeviceProviders(deviceConfig)
+	if err != nil {
+		return err
+	}
+	if _, ok := providers.LLMProvider.(*realtime.Provider); ok || s.realtime != nil {
+		providers.Close()
+		return fmt.Errorf("设备 %s 实时语音模式的配置需设备重连后生效", s.clientState.DeviceID)
+	}
+	// 尚未生效就被新配置取代的提供者不会再用到
+	if superseded := s.pendingProviders.Swap(providers); superseded != nil {
+		superseded.Close()
+	}
+	log.Infof("设备 %s 新配置已加载, 将在下一轮对话生效, asr: %s, llm: %s, tts: %s", s.clientState.DeviceID, deviceConfig.Asr.Provider, deviceConfig.Llm.Provider, deviceConfig.Tts.Provider)
+	return nil
+}
+
+// IsConfigPending 是否有尚未生效的新配置
+func (s *ChatSession) IsConfigPending() bool {
+	return s.pendingProviders.Load() != nil
+}
+
+// applyPendingConfig 在对话间隙替换为最新加载的配置
+func (s *ChatSession) applyPendingConfig() {
+	providers := s.pendingProviders.Swap(nil)
+	if providers == nil {
+		return
+	}
+	s.clientState.ApplyDeviceProviders(providers)
+	log.Infof("设备 %s 新配置已生效", s.clientState.DeviceID)
+}
+
 // 在mqtt 收到type: listen, state: start后进行
 func (c *ChatSession) InitAsrLlmTts() error {
 	ttsConfig := c.clientState.DeviceConfig.Tts
@@ -123,6 +181,7 @@ func (c *ChatSession) CmdMessageLoop(ctx context.Context) {
 			continue
 		}
 		recvFailCount = 0
+		c.clientState.LastCmdTs.Store(time.Now().UnixMilli())
 		log.Infof("收到文本消息: %s", string(message))
 		if err := c.HandleTextMessage(message); err != nil {
 			log.Errorf("处理文本消息失败: %v", err)
@@ -145,6 +204,7 @@ func (c *ChatSession) AudioMessageLoop(ctx context.Context) {
 			return
 		}
 		log.Debugf("收到音频数据，大小: %d 字节", len(message))
+		c.clientState.LastAudioTs.Store(time.Now().UnixMilli())
 		isAuth := viper.GetBool("auth.enable")
 		if isAuth {
 			if !c.clientState.IsActivated {
@@ -152,7 +212,8 @@ func (c *ChatSession) AudioMessageLoop(ctx context.Context) {
 				continue
 			}
 		}
-		if c.clientState.GetClientVoiceStop() {
+		// 实时语音模式由模型的服务端vad判断轮次, 音频持续转发
+		if c.realtime == nil && c.clientState.StateMachine.VoiceStopped() && !c.asrManager.IsBargeInListening() {
 			//log.Debug("客户端停止说话, 跳过音频数据")
 			continue
 		}
@@ -185,6 +246,8 @@ func (c *ChatSession) HandleTextMessage(message []byte) error {
 		return c.HandleMcpMessage(&clientMsg)
 	case MessageTypeGoodBye:
 		return c.HandleGoodByeMessage(&clientMsg)
+	case MessageTypeChat:
+		return c.HandleChatMessage(&clientMsg)
 	default:
 		// 未知消息类型，直接回显
 		return fmt.Errorf("未知消息类型: %s", clientMsg.Type)
@@ -198,6 +261,7 @@ func (c *ChatSession) HandleAudioMessage(data []byte) bool {
 		return true
 	default:
 		log.Warnf("音频缓冲区已满, 丢弃音频数据")
+		metrics.DroppedAudioFrames.WithLabelValues(c.serverTransport.GetTransportType()).Inc()
 	}
 	return false
 }
@@ -208,6 +272,8 @@ func (s *ChatSession) HandleHelloMessage(msg *ClientMessage) error {
 		return s.HandleWebsocketHelloMessage(msg)
 	} else if msg.Transport == types_conn.TransportTypeMqttUdp {
 		return s.HandleMqttHelloMessage(msg)
+	} else if msg.Transport == types_conn.TransportTypeText {
+		return s.HandleTextHelloMessage(msg)
 	}
 	return fmt.Errorf("不支持的传输类型: %s", msg.Transport)
 }
@@ -263,10 +329,21 @@ func (s *ChatSession) HandleCommonHelloMessage(msg *ClientMessage) error {
 		go initMcp(s.clientState, s.serverTransport)
 	}
 
+	// 设备声明支持时才下发stt中间结果
+	s.clientState.EnablePartialStt = msg.Features["partial_stt"]
+
 	clientState := s.clientState
 
 	clientState.InputAudioFormat = *msg.AudioParams
 	clientState.SetAsrPcmFrameSize(clientState.InputAudioFormat.SampleRate, clientState.InputAudioFormat.Channels, clientState.InputAudioFormat.FrameDuration)
+	s.negotiateOutputFormat(msg.OutputAudioParams)
+
+	if provider, ok := s.isRealtimeMode(); ok {
+		if s.realtime != nil {
+			return nil
+		}
+		return s.startRealtime(provider)
+	}
 
 	s.asrManager.ProcessVadAudio(clientState.Ctx, s.Close)
 
@@ -282,6 +359,76 @@ func (s *ChatSession) HandleWebsocketHelloMessage(msg *ClientMessage) error {
 	return s.serverTransport.SendHello("websocket", &s.clientState.OutputAudioFormat, nil)
 }
 
+// HandleTextHelloMessage 处理纯文本对话的 hello 消息
+// 文本对话没有上行音频, 不启动vad; features 中声明 tts 时才合成语音, 按 hello 返回的 audio_params 下发 opus 音频
+func (s *ChatSession) HandleTextHelloMessage(msg *ClientMessage) error {
+	session, err := auth.A().CreateSession(msg.DeviceID)
+	if err != nil {
+		return fmt.Errorf("创建会话失败: %v", err)
+	}
+	s.clientState.SessionID = session.ID
+
+	if isMcp, ok := msg.Features["mcp"]; ok && isMcp {
+		go initMcp(s.clientState, s.serverTransport)
+	}
+
+	s.clientState.SkipTts = !msg.Features["tts"]
+	if s.clientState.SkipTts {
+		return s.serverTransport.SendHello(types_conn.TransportTypeText, nil, nil)
+	}
+	s.negotiateOutputFormat(msg.OutputAudioParams)
+	return s.serverTransport.SendHello(types_conn.TransportTypeText, &s.clientState.OutputAudioFormat, nil)
+}
+
+// negotiateOutputFormat 按TTS提供者的原生格式从设备支持的格式中选择下行音频格式
+// 与原生格式一致时TTS音频直接转发, 否则在下发前转码; hello 之后切换的TTS同样按协商的格式转码
+func (s *ChatSession) negotiateOutputFormat(capabilities *types_audio.OutputCapabilities) {
+	preferred := types_audio.AudioFormat{
+		Format:        types_audio.Format,
+		SampleRate:    types_audio.SampleRate,
+		Channels:      types_audio.Channels,
+		FrameDuration: types_audio.FrameDuration,
+	}
+	native := tts.NativeFormat(s.clientState.TTSProvider)
+	if native.SampleRate > 0 {
+		preferred.SampleRate = native.SampleRate
+	}
+	if native.FrameDuration > 0 {
+		preferred.FrameDuration = native.FrameDuration
+	}
+	s.clientState.OutputAudioFormat = types_audio.NegotiateOutput(capabilities, preferred)
+	log.Infof("设备 %s 下行音频格式: %d Hz, %d ms", s.clientState.DeviceID, s.clientState.OutputAudioFormat.SampleRate, s.clientState.OutputAudioFormat.FrameDuration)
+}
+
+// HandleChatMessage 处理文本对话消息, 与语音识别结果走同一条对话链路
+func (s *ChatSession) HandleChatMessage(msg *ClientMessage) error {
+	text := strings.TrimSpace(msg.Text)
+	if text == "" {
+		return fmt.Errorf("对话内容为空")
+	}
+	if err := s.checkTextChat(); err != nil {
+		return err
+	}
+
+	isActivated, err := s.CheckDeviceActivated()
+	if err != nil {
+		log.Errorf("检查设备激活状态失败: %v", err)
+		return err
+	}
+	if !isActivated {
+		return nil
+	}
+
+	// 打断正在进行的回复
+	s.StopSpeaking(false)
+
+	if err := s.serverTransport.SendAsrResult(text); err != nil {
+		log.Errorf("发送对话文本失败: %v", err)
+		return err
+	}
+	return s.AddAsrResultToQueue(text)
+}
+
 // handleListenMessage 处理监听消息
 func (s *ChatSession) HandleListenMessage(msg *ClientMessage) error {
 	// 根据状态处理
@@ -330,7 +477,7 @@ func (s *ChatSession) HandleListenDetect(msg *ClientMessage) error {
 			// 否则开始对话
 			if enableGreeting && isWakeupWord {
 				//进行tts欢迎语
-				if !s.clientState.IsWelcomeSpeaking {
+				if !s.clientState.StateMachine.Welcomed() {
 					s.HandleWelcome()
 				}
 			} else {
@@ -359,25 +506,29 @@ func (s *ChatSession) HandleNotActivated() {
 
 	log.Infof("激活码: %d, 挑战码: %s, 消息: %s, 超时时间: %d", code, challenge, message, timeoutMs)
 
-	s.serverTransport.SendTtsStart()
-	defer s.serverTransport.SendTtsStop()
+	ctx := s.clientState.GetSessionCtx()
+	s.serverTransport.SendTtsStart(ctx)
+	defer s.serverTransport.SendTtsStop(ctx)
 
-	s.ttsManager.handleTts(s.clientState.GetSessionCtx(), llm_common.LLMResponseStruct{
+	s.ttsManager.handleTts(ctx, llm_common.LLMResponseStruct{
 		Text: fmt.Sprintf("请在后台添加设备，激活码: %d", code),
 	})
 
 }
 
+// HandleWelcome 唤醒后下发欢迎语, 每个会话只下发一次
 func (s *ChatSession) HandleWelcome() {
+	ctx := s.clientState.GetSessionCtx()
+	if err := s.clientState.StateMachine.FireContext(ctx, EventWelcome); err != nil {
+		return
+	}
 	greetingText := s.GetRandomGreeting()
-	s.serverTransport.SendTtsStart()
-	defer s.serverTransport.SendTtsStop()
+	s.serverTransport.SendTtsStart(ctx)
+	defer s.serverTransport.SendTtsStop(ctx)
 
-	s.ttsManager.handleTts(s.clientState.GetSessionCtx(), llm_common.LLMResponseStruct{
+	s.ttsManager.handleTts(ctx, llm_common.LLMResponseStruct{
 		Text: greetingText,
 	})
-
-	s.clientState.IsWelcomeSpeaking = true
 }
 
 func (s *ChatSession) GetRandomGreeting() string {
@@ -396,8 +547,11 @@ func (s *ChatSession) AddTextToTTSQueue(text string) error {
 
 // handleAbortMessage 处理中止消息
 func (s *ChatSession) HandleAbortMessage(msg *ClientMessage) error {
-	// 设置打断状态
-	s.clientState.Abort = true
+	if s.realtime != nil {
+		s.clientState.StateMachine.Fire(EventAbort)
+		s.realtime.Abort()
+		return nil
+	}
 
 	s.StopSpeaking(true)
 
@@ -406,6 +560,23 @@ func (s *ChatSession) HandleAbortMessage(msg *ClientMessage) error {
 	return nil
 }
 
+// HandleBargeIn 服务端检测到用户在播放期间插话
+// 取消当前会话上下文并停止播放，然后开始新一轮拾音
+func (s *ChatSession) HandleBargeIn() error {
+	log.Infof("设备 %s 插话, 打断当前回复", s.clientState.DeviceID)
+
+	// 下发 tts stop 前标记, 设备随后发来的 listen start 不再重启识别
+	s.bargeInListen.Store(&bargeInListen{at: time.Now()})
+	s.StopSpeaking(true)
+
+	if err := s.OnListenStart(); err != nil {
+		s.bargeInListen.Store(nil)
+		return err
+	}
+	s.bargeInListen.Store(&bargeInListen{turn: s.clientState.StateMachine.Turn(), at: time.Now()})
+	return nil
+}
+
 // handleIoTMessage 处理物联网消息
 func (s *ChatSession) HandleIoTMessage(msg *ClientMessage) error {
 	// 获取客户端状态
@@ -486,10 +657,20 @@ func (s *ChatSession) HandleListenStart(msg *ClientMessage) error {
 		s.clientState.ListenMode = msg.Mode
 		log.Infof("设备 %s 拾音模式: %s", msg.DeviceID, msg.Mode)
 	}
+
+	// 实时语音模式由模型的服务端vad判断轮次, 设备音频持续转发即可
+	if s.realtime != nil {
+		s.clientState.StateMachine.Fire(EventListenStart)
+		return nil
+	}
+	// 插话已开始新一轮拾音, 重启识别会丢弃插话时缓存的语音
+	if s.isBargeInListenStart() {
+		log.Infof("设备 %s 插话后的 listen start, 继续当前拾音", msg.DeviceID)
+		return nil
+	}
 	//if s.clientState.ListenMode == "manual" {
 	s.StopSpeaking(false)
 	//}
-	s.clientState.SetStatus(ClientStatusListening)
 
 	return s.OnListenStart()
 }
@@ -517,12 +698,15 @@ func (s *ChatSession) OnListenStart() error {
 	}
 
 	s.clientState.Destroy()
+	s.clientState.StateMachine.Fire(EventListenStart)
+	s.applyPendingConfig()
+	s.asrManager.prepareBargeIn()
 
 	ctx := s.clientState.GetSessionCtx()
 
 	//初始化asr相关
 	if s.clientState.ListenMode == "manual" {
-		s.clientState.VoiceStatus.SetClientHaveVoice(true)
+		s.clientState.StateMachine.Fire(EventVoiceStart)
 	}
 
 	// 启动asr流式识别，复用 restartAsrRecognition 函数
@@ -555,48 +739,64 @@ func (s *ChatSession) OnListenStart() error {
 			default:
 			}
 
-			text, err := s.clientState.RetireAsrResult(ctx)
+			text, err := s.clientState.RetireAsrResult(ctx, s.newPartialSttSender())
 			if err != nil {
+				// 打断或会话结束取消识别不计为asr错误
+				if ctx.Err() != nil {
+					log.Debugf("asr ctx done: %v", err)
+					return
+				}
 				log.Errorf("处理asr结果失败: %v", err)
+				metrics.ObserveProviderError(metrics.KindAsr, s.clientState.DeviceConfig.Asr.Provider)
 				return
 			}
 
 			//统计asr耗时
-			log.Debugf("处理asr结果: %s, 耗时: %d ms", text, s.clientState.GetAsrDuration())
+			asrDuration := s.clientState.GetAsrDuration()
+			log.Debugf("处理asr结果: %s, 耗时: %d ms", text, asrDuration)
 
 			if text != "" {
+				metrics.AsrLatency.WithLabelValues(s.clientState.DeviceConfig.Asr.Provider).Observe(float64(asrDuration) / 1000)
+
 				// 重置重试计数器
 				startIdleTime = 0
 
 				//当获取到asr结果时, 结束语音输入
 				s.clientState.OnVoiceSilence()
+				turn := s.clientState.TakeTurnTrace()
+				turn.AsrFinal(text)
 
 				//发送asr消息
 				err = s.serverTransport.SendAsrResult(text)
 				if err != nil {
 					log.Errorf("发送asr消息失败: %v", err)
+					turn.End(err)
 					return
 				}
 
-				err = s.AddAsrResultToQueue(text)
+				err = s.addAsrResultToQueue(turn, text)
 				if err != nil {
 					log.Errorf("开始对话失败: %v", err)
 					return
 				}
 				return
 			} else {
+				// 识别结果为空, 本轮不会进入对话, 这段语音也不再录音
+				s.clientState.TakeTurnTrace().End(nil)
+				s.asrManager.audioRecorder.DiscardInput()
+
 				select {
 				case <-ctx.Done():
 					log.Debugf("asr ctx done")
 					return
 				default:
 				}
-				log.Debugf("ready Restart Asr, s.clientState.Status: %s", s.clientState.Status)
-				if s.clientState.Status == ClientStatusListening || s.clientState.Status == ClientStatusListenStop {
+				log.Debugf("ready Restart Asr, state: %s", s.clientState.StateMachine.State())
+				if s.clientState.StateMachine.Fire(EventAsrEmpty) == nil {
 					// text 为空，检查是否需要重新启动ASR
 					diffTs := time.Now().Unix() - startIdleTime
 					if startIdleTime > 0 && diffTs <= maxIdleTime {
-						log.Warnf("ASR识别结果为空，尝试重启ASR识别, diff ts: %s", diffTs)
+						log.Warnf("ASR识别结果为空，尝试重启ASR识别, diff ts: %d", diffTs)
 						if restartErr := s.asrManager.RestartAsrRecognition(ctx); restartErr != nil {
 							log.Errorf("重启ASR识别失败: %v", restartErr)
 							return
@@ -615,16 +815,63 @@ func (s *ChatSession) OnListenStart() error {
 	return nil
 }
 
+// newPartialSttSender 创建stt中间结果的发送函数，设备未声明支持时返回nil
+// 按 chat.partial_stt_interval 限制发送频率，间隔内的更新直接丢弃，最终结果仍由 SendAsrResult 发送
+func (s *ChatSession) newPartialSttSender() func(text string) {
+	if !s.clientState.EnablePartialStt {
+		return nil
+	}
+
+	interval := viper.GetInt64("chat.partial_stt_interval")
+	if interval <= 0 {
+		interval = 300
+	}
+
+	var lastSendTs int64
+	var lastText string
+	return func(text string) {
+		now := time.Now().UnixMilli()
+		if text == lastText || now-lastSendTs < interval {
+			return
+		}
+		lastSendTs, lastText = now, text
+		if err := s.serverTransport.SendAsrPartialResult(text); err != nil {
+			log.Warnf("发送asr中间结果失败: %v", err)
+		}
+	}
+}
+
+// InjectLlmMessage 注入需要大模型回复的消息, 正在回复时先打断, 与设备发起的新一轮对话一致
+func (s *ChatSession) InjectLlmMessage(text string) error {
+	if err := s.checkTextChat(); err != nil {
+		return err
+	}
+	if s.clientState.StateMachine.Is(StateThinking, StateSpeaking, StateToolRunning) {
+		s.StopSpeaking(true)
+	}
+	return s.AddAsrResultToQueue(text)
+}
+
 // startChat 开始对话
 func (s *ChatSession) AddAsrResultToQueue(text string) error {
+	return s.addAsrResultToQueue(nil, text)
+}
+
+// addAsrResultToQueue 将本轮追踪挂到对话的ctx上, 由 actionDoChat 结束追踪
+func (s *ChatSession) addAsrResultToQueue(turn *tracing.Turn, text string) error {
 	log.Debugf("AddAsrResultToQueue text: %s", text)
+	if err := s.checkTextChat(); err != nil {
+		turn.End(err)
+		return err
+	}
 	item := AsrResponseChannelItem{
-		ctx:  s.clientState.GetSessionCtx(),
+		ctx:  turn.Context(s.clientState.GetSessionCtx()),
 		text: text,
 	}
 	err := s.chatTextQueue.Push(item)
 	if err != nil {
 		log.Warnf("chatTextQueue 已满或已关闭, 丢弃消息")
+		turn.End(err)
 	}
 	return nil
 }
@@ -659,6 +906,7 @@ func (s *ChatSession) Close() {
 
 	// 停止说话和清理音频相关资源
 	s.StopSpeaking(true)
+	s.clientState.StateMachine.Fire(EventClose)
 
 	// 清理聊天文本队列
 	s.ClearChatTextQueue()
@@ -671,10 +919,28 @@ func (s *ChatSession) Close() {
 	// 取消会话级别的上下文
 	s.cancel()
 
+	if s.realtime != nil {
+		s.realtime.Close()
+	}
+
+	// 未生效的新配置不会再用到
+	if providers := s.pendingProviders.Swap(nil); providers != nil {
+		providers.Close()
+	}
+	s.ttsManager.Close()
+
+	// 上报未结束的一轮对话
+	s.llmManager.turnRecorder.Flush()
+
 	log.Debugf("ChatSession.Close() 会话资源清理完成, 设备 %s", s.clientState.DeviceID)
 }
 
-func (s *ChatSession) actionDoChat(ctx context.Context, text string) error {
+func (s *ChatSession) actionDoChat(ctx context.Context, text string) (err error) {
+	turn := tracing.FromContext(ctx)
+	defer func() {
+		turn.End(err)
+	}()
+
 	select {
 	case <-ctx.Done():
 		log.Debugf("actionDoChat ctx done, return")
@@ -692,6 +958,8 @@ func (s *ChatSession) actionDoChat(ctx context.Context, text string) error {
 		}
 	}
 
+	s.applyPendingConfig()
+
 	clientState := s.clientState
 
 	sessionID := clientState.SessionID
@@ -702,6 +970,28 @@ func (s *ChatSession) actionDoChat(ctx context.Context, text string) error {
 		Content: text,
 	}
 
+	einoTools := s.getEinoTools(ctx)
+
+	toolNameList := make([]string, 0)
+	for _, tool := range einoTools {
+		toolNameList = append(toolNameList, tool.Name)
+	}
+
+	// 发送带工具的LLM请求
+	log.Infof("使用 %d 个MCP工具发送LLM请求, tools: %+v", len(einoTools), toolNameList)
+
+	err = s.llmManager.DoLLmRequest(ctx, userMessage, einoTools, true)
+	if err != nil {
+		log.Errorf("发送带工具的 LLM 请求失败, seesionID: %s, error: %v", sessionID, err)
+		return fmt.Errorf("发送带工具的 LLM 请求失败: %v", err)
+	}
+	return nil
+}
+
+// getEinoTools 获取设备可用的MCP工具并转换为Eino ToolInfo格式
+func (s *ChatSession) getEinoTools(ctx context.Context) []*schema.ToolInfo {
+	clientState := s.clientState
+
 	// 获取全局MCP工具列表
 	mcpTools, err := mcp.GetToolsByDeviceId(clientState.DeviceID, clientState.AgentID)
 	if err != nil {
@@ -719,21 +1009,7 @@ func (s *ChatSession) actionDoChat(ctx context.Context, text string) error {
 	einoTools, err := llm.ConvertMCPToolsToEinoTools(ctx, mcpToolsInterface)
 	if err != nil {
 		log.Errorf("转换MCP工具失败: %v", err)
-		einoTools = nil
-	}
-
-	toolNameList := make([]string, 0)
-	for _, tool := range einoTools {
-		toolNameList = append(toolNameList, tool.Name)
-	}
-
-	// 发送带工具的LLM请求
-	log.Infof("使用 %d 个MCP工具发送LLM请求, tools: %+v", len(einoTools), toolNameList)
-
-	err = s.llmManager.DoLLmRequest(ctx, userMessage, einoTools, true)
-	if err != nil {
-		log.Errorf("发送带工具的 LLM 请求失败, seesionID: %s, error: %v", sessionID, err)
-		return fmt.Errorf("发送带工具的 LLM 请求失败: %v", err)
+		return nil
 	}
-	return nil
+	return einoTools
 }
@@ -3,13 +3,25 @@ package chat
 import (
 	"context"
 	"fmt"
+	"io"
+	"strings"
+	"sync"
 	"time"
 	. "xiaozhi-esp32-server-golang/internal/data/client"
+	"xiaozhi-esp32-server-golang/internal/domain/audio"
 	llm_common "xiaozhi-esp32-server-golang/internal/domain/llm/common"
+	"xiaozhi-esp32-server-golang/internal/domain/metrics"
+	"xiaozhi-esp32-server-golang/internal/domain/tracing"
+	"xiaozhi-esp32-server-golang/internal/domain/tts"
 	"xiaozhi-esp32-server-golang/internal/util"
 	log "xiaozhi-esp32-server-golang/logger"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// ttsStartCtxKey 记录请求tts的时间, 用于统计首帧耗时
+type ttsStartCtxKey struct{}
+
 type TTSQueueItem struct {
 	ctx         context.Context
 	llmResponse llm_common.LLMResponseStruct
@@ -27,6 +39,20 @@ type TTSManager struct {
 	clientState     *ClientState
 	serverTransport *ServerTransport
 	ttsQueue        *util.Queue[TTSQueueItem]
+
+	audioRecorder *turnAudioRecorder
+
+	// 多角色朗读时各角色的TTS提供者, 智能体的TTS变更后重新创建
+	speakerLock      sync.Mutex
+	speakerBase      tts.TTSProvider
+	speakerProviders map[string]tts.TTSProvider
+}
+
+// WithTTSAudioRecorder 记录下发给设备的TTS音频
+func WithTTSAudioRecorder(recorder *turnAudioRecorder) TTSManagerOption {
+	return func(t *TTSManager) {
+		t.audioRecorder = recorder
+	}
 }
 
 // NewTTSManager 只接受WithClientState
@@ -72,7 +98,7 @@ func (t *TTSManager) ClearTTSQueue() {
 
 // 处理文本内容响应（异步 TTS 入队）
 func (t *TTSManager) handleTextResponse(ctx context.Context, llmResponse llm_common.LLMResponseStruct, isSync bool) error {
-	if llmResponse.Text == "" {
+	if llmResponse.Text == "" && llmResponse.Emotion == "" {
 		return nil
 	}
 
@@ -104,20 +130,52 @@ func (t *TTSManager) handleTextResponse(ctx context.Context, llmResponse llm_com
 }
 
 // 同步 TTS 处理
-func (t *TTSManager) handleTts(ctx context.Context, llmResponse llm_common.LLMResponseStruct) error {
+func (t *TTSManager) handleTts(ctx context.Context, llmResponse llm_common.LLMResponseStruct) (err error) {
 	log.Debugf("handleTts start, text: %s", llmResponse.Text)
+	// 表情在对应的句子之前下发, 设备据此切换屏幕上的表情
+	if llmResponse.Emotion != "" {
+		if err := t.serverTransport.SendEmotion(llmResponse.Emotion); err != nil {
+			log.Warnf("发送表情失败: %s, %v", llmResponse.Emotion, err)
+		}
+	}
 	if llmResponse.Text == "" {
 		return nil
 	}
 
+	// 下发给设备显示的文本去除语音标记
+	displayText := llmResponse.Text
+	if strings.Contains(displayText, "<") {
+		displayText = tts.ParseSpeech(displayText, tts.Prosody{}).PlainText()
+	}
+
+	tracing.FromContext(ctx).FirstSentence()
+	ctx, span := tracing.StartSpan(ctx, "tts.sentence", attribute.Int("tts.text_len", len([]rune(llmResponse.Text))))
+	defer func() {
+		tracing.EndSpan(span, err)
+	}()
+
+	// 纯文本对话未开启语音回复, 只下发句子文本
+	if t.clientState.SkipTts {
+		if err := t.serverTransport.SendSentenceStart(ctx, displayText); err != nil {
+			return fmt.Errorf("发送 TTS 文本失败: %s, %v", llmResponse.Text, err)
+		}
+		return t.serverTransport.SendSentenceEnd(displayText)
+	}
+
 	// 使用带上下文的TTS处理
-	outputChan, err := t.clientState.TTSProvider.TextToSpeechStream(ctx, llmResponse.Text, t.clientState.OutputAudioFormat.SampleRate, t.clientState.OutputAudioFormat.Channels, t.clientState.OutputAudioFormat.FrameDuration)
+	ctx = context.WithValue(ctx, ttsStartCtxKey{}, time.Now())
+	provider := t.speakerProvider(llmResponse.Speaker)
+	outputChan, err := provider.TextToSpeechStream(ctx, llmResponse.Text, t.clientState.OutputAudioFormat.SampleRate, t.clientState.OutputAudioFormat.Channels, t.clientState.OutputAudioFormat.FrameDuration)
+	if err == nil {
+		outputChan, err = t.toOutputFormat(ctx, provider, outputChan)
+	}
 	if err != nil {
 		log.Errorf("生成 TTS 音频失败: %v", err)
+		metrics.ObserveProviderError(metrics.KindTts, t.clientState.DeviceConfig.Tts.Provider)
 		return fmt.Errorf("生成 TTS 音频失败: %v", err)
 	}
 
-	if err := t.serverTransport.SendSentenceStart(llmResponse.Text); err != nil {
+	if err := t.serverTransport.SendSentenceStart(ctx, displayText); err != nil {
 		log.Errorf("发送 TTS 文本失败: %s, %v", llmResponse.Text, err)
 		return fmt.Errorf("发送 TTS 文本失败: %s, %v", llmResponse.Text, err)
 	}
@@ -128,7 +186,7 @@ func (t *TTSManager) handleTts(ctx context.Context, llmResponse llm_common.LLMRe
 		return fmt.Errorf("发送 TTS 音频失败: %s, %v", llmResponse.Text, err)
 	}
 
-	if err := t.serverTransport.SendSentenceEnd(llmResponse.Text); err != nil {
+	if err := t.serverTransport.SendSentenceEnd(displayText); err != nil {
 		log.Errorf("发送 TTS 文本失败: %s, %v", llmResponse.Text, err)
 		return fmt.Errorf("发送 TTS 文本失败: %s, %v", llmResponse.Text, err)
 	}
@@ -136,6 +194,73 @@ func (t *TTSManager) handleTts(ctx context.Context, llmResponse llm_common.LLMRe
 	return nil
 }
 
+// toOutputFormat 提供者的原生格式与设备的输出格式一致时直接转发, 否则转码
+func (t *TTSManager) toOutputFormat(ctx context.Context, provider tts.TTSProvider, outputChan chan []byte) (chan []byte, error) {
+	outputFormat := audio.Format{
+		SampleRate:    t.clientState.OutputAudioFormat.SampleRate,
+		Channels:      t.clientState.OutputAudioFormat.Channels,
+		FrameDuration: t.clientState.OutputAudioFormat.FrameDuration,
+	}
+	native := tts.NativeFormat(provider).Resolve(outputFormat)
+	if !native.NeedTranscode(outputFormat) {
+		return outputChan, nil
+	}
+	log.Debugf("TTS 原生格式 %+v 与输出格式 %+v 不一致, 转码后下发", native, outputFormat)
+	return audio.TranscodeStream(ctx, outputChan, native, outputFormat)
+}
+
+// speakerProvider 返回角色使用的TTS提供者, 角色没有配置音色时使用智能体的TTS
+// 所有句子仍按顺序经过TTS队列, 不同角色的音频帧和句子文本保持对应
+func (t *TTSManager) speakerProvider(speaker string) tts.TTSProvider {
+	base := t.clientState.TTSProvider
+	ttsConfig := t.clientState.DeviceConfig.Tts
+	config, ok := tts.SpeakerConfig(ttsConfig.Provider, ttsConfig.Config, speaker)
+	if !ok {
+		return base
+	}
+
+	t.speakerLock.Lock()
+	defer t.speakerLock.Unlock()
+	if t.speakerBase != base || t.speakerProviders == nil {
+		// 句子按顺序合成, 旧的角色提供者此时已不再使用
+		t.closeSpeakerProviders()
+		t.speakerBase = base
+		t.speakerProviders = make(map[string]tts.TTSProvider)
+	}
+	if provider, ok := t.speakerProviders[speaker]; ok {
+		return provider
+	}
+	provider, err := tts.GetTTSProvider(ttsConfig.Provider, config)
+	if err != nil {
+		log.Warnf("创建角色 %s 的TTS提供者失败, 使用默认音色: %v", speaker, err)
+		return base
+	}
+	t.speakerProviders[speaker] = provider
+	return provider
+}
+
+// Close 释放多角色朗读创建的TTS提供者, 智能体的TTS由 ClientState 管理
+func (t *TTSManager) Close() {
+	t.speakerLock.Lock()
+	defer t.speakerLock.Unlock()
+	t.closeSpeakerProviders()
+	t.speakerBase = nil
+	t.speakerProviders = nil
+}
+
+// closeSpeakerProviders 调用方需持有 speakerLock
+func (t *TTSManager) closeSpeakerProviders() {
+	for speaker, provider := range t.speakerProviders {
+		closer, ok := provider.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil {
+			log.Warnf("关闭角色 %s 的TTS提供者失败: %v", speaker, err)
+		}
+	}
+}
+
 // getAlignedDuration 计算当前时间与开始时间的差值，向上对齐到frameDuration
 func getAlignedDuration(startTime time.Time, frameDuration time.Duration) time.Duration {
 	elapsed := time.Since(startTime)
@@ -183,6 +308,9 @@ func (t *TTSManager) SendTTSAudio(ctx context.Context, audioChan chan []byte, is
 		case frame, ok := <-audioChan:
 			if !ok {
 				// 通道已关闭，所有帧已处理完毕
+				if totalFrames > 0 {
+					tracing.FromContext(ctx).TtsLastFrame()
+				}
 				// 为确保终端播放完成：等待已发送帧的总时长与从开始发送以来的实际耗时之间的差值
 				elapsed := time.Since(startTime)
 				totalDuration := time.Duration(totalFrames) * frameDuration
@@ -200,7 +328,15 @@ func (t *TTSManager) SendTTSAudio(ctx context.Context, audioChan chan []byte, is
 				return fmt.Errorf("发送 TTS 音频 len: %d 失败: %v", len(frame), err)
 			}
 
+			t.audioRecorder.AddOutput(frame)
+
 			totalFrames++
+			if totalFrames == 1 {
+				tracing.FromContext(ctx).TtsFirstFrame()
+				if ttsStart, ok := ctx.Value(ttsStartCtxKey{}).(time.Time); ok {
+					metrics.TtsLatency.WithLabelValues(t.clientState.DeviceConfig.Tts.Provider).Observe(time.Since(ttsStart).Seconds())
+				}
+			}
 			if totalFrames%100 == 0 {
 				log.Debugf("SendTTSAudio 已发送 %d 帧", totalFrames)
 			}
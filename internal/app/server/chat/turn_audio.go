@@ -0,0 +1,138 @@
+package chat
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	. "xiaozhi-esp32-server-golang/internal/data/client"
+	"xiaozhi-esp32-server-golang/internal/domain/recording"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+// 保存单轮录音的超时时间
+const turnAudioSaveTimeout = 30 * time.Second
+
+// 单轮录音的上限, 避免长时间录音占用过多内存
+const (
+	maxTurnInputSeconds  = 120
+	maxTurnOutputSeconds = 300
+)
+
+// turnAudioRecorder 收集一轮对话的用户语音和TTS音频, 按智能体配置决定是否录音
+// 用户语音在识别完成、本轮开始时归入本轮, 之后的语音属于下一轮; 识别结果为空或重新识别时丢弃
+type turnAudioRecorder struct {
+	sync.Mutex
+	clientState *ClientState
+	recorder    *recording.Recorder
+
+	pendingInput []float32 //尚未归入任何一轮的用户语音
+	current      *recording.TurnAudio
+}
+
+func newTurnAudioRecorder(clientState *ClientState, recorder *recording.Recorder) *turnAudioRecorder {
+	return &turnAudioRecorder{
+		clientState: clientState,
+		recorder:    recorder,
+	}
+}
+
+func (r *turnAudioRecorder) enabled() bool {
+	return r != nil && r.recorder.Enabled(r.clientState.DeviceConfig.RecordAudio)
+}
+
+// AddInput 记录送入ASR的用户语音
+func (r *turnAudioRecorder) AddInput(pcm []float32) {
+	if !r.enabled() {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.pendingInput = append(r.pendingInput, pcm...)
+	// 超出上限时保留最新的语音; 超出四分之一后再整体前移, 避免每帧都搬移整段录音
+	maxSamples := maxTurnInputSeconds * r.clientState.InputAudioFormat.SampleRate
+	if len(r.pendingInput) > maxSamples+maxSamples/4 {
+		n := copy(r.pendingInput, r.pendingInput[len(r.pendingInput)-maxSamples:])
+		r.pendingInput = r.pendingInput[:n]
+	}
+}
+
+// DiscardInput 丢弃尚未归入任何一轮的用户语音, 在识别结果为空或重新开始识别时调用
+func (r *turnAudioRecorder) DiscardInput() {
+	if r == nil {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.pendingInput = nil
+}
+
+// AddOutput 记录下发给设备的TTS opus帧
+func (r *turnAudioRecorder) AddOutput(frame []byte) {
+	if !r.enabled() {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	if r.current == nil {
+		return
+	}
+	format := r.clientState.OutputAudioFormat
+	if format.FrameDuration > 0 && len(r.current.Output)*format.FrameDuration >= maxTurnOutputSeconds*1000 {
+		return
+	}
+	r.current.Output = append(r.current.Output, append([]byte(nil), frame...))
+}
+
+// StartTurn 开始新一轮, 之前收集的用户语音归入本轮
+func (r *turnAudioRecorder) StartTurn() {
+	if r == nil {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.current = nil
+	if !r.enabled() {
+		r.pendingInput = nil
+		return
+	}
+	input := r.pendingInput
+	if maxSamples := maxTurnInputSeconds * r.clientState.InputAudioFormat.SampleRate; len(input) > maxSamples {
+		input = input[len(input)-maxSamples:]
+	}
+	format := r.clientState.OutputAudioFormat
+	r.current = &recording.TurnAudio{
+		Input:               input,
+		InputSampleRate:     r.clientState.InputAudioFormat.SampleRate,
+		OutputSampleRate:    format.SampleRate,
+		OutputChannels:      format.Channels,
+		OutputFrameDuration: format.FrameDuration,
+	}
+	r.pendingInput = nil
+}
+
+// FinishTurn 结束本轮, 有录音时异步保存并返回 true
+func (r *turnAudioRecorder) FinishTurn(deviceID string, turnID string, save bool) bool {
+	if r == nil {
+		return false
+	}
+	r.Lock()
+	audio := r.current
+	r.current = nil
+	r.Unlock()
+
+	if !save || audio == nil || (len(audio.Input) == 0 && len(audio.Output) == 0) {
+		return false
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), turnAudioSaveTimeout)
+		defer cancel()
+		files, err := r.recorder.Save(ctx, deviceID, turnID, *audio)
+		if err != nil {
+			log.Errorf("保存设备 %s 第 %s 轮录音失败: %v", deviceID, turnID, err)
+			return
+		}
+		log.Debugf("已保存设备 %s 第 %s 轮录音: %v", deviceID, turnID, files)
+	}()
+	return true
+}
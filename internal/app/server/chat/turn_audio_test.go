@@ -0,0 +1,43 @@
+package chat
+
+import (
+	"testing"
+
+	types_audio "xiaozhi-esp32-server-golang/internal/data/audio"
+	. "xiaozhi-esp32-server-golang/internal/data/client"
+	"xiaozhi-esp32-server-golang/internal/domain/recording"
+)
+
+func TestTurnAudioRecorderInput(t *testing.T) {
+	// 采样率取 10, 上限为 1200 个采样点
+	clientState := &ClientState{InputAudioFormat: types_audio.AudioFormat{SampleRate: 10}}
+	r := newTurnAudioRecorder(clientState, recording.NewRecorder(nil, true, 0))
+	maxSamples := maxTurnInputSeconds * 10
+
+	samples := func(from, n int) []float32 {
+		pcm := make([]float32, n)
+		for i := range pcm {
+			pcm[i] = float32(from + i)
+		}
+		return pcm
+	}
+
+	// 识别结果为空的语音不归入下一轮
+	r.AddInput(samples(0, 500))
+	r.DiscardInput()
+	r.AddInput(samples(1000, 5))
+	r.StartTurn()
+	if input := r.current.Input; len(input) != 5 || input[0] != 1000 {
+		t.Fatalf("input = %v, want 本轮的 5 个采样点", input)
+	}
+
+	// 超出上限时保留最新的语音
+	for i := 0; i < 30; i++ {
+		r.AddInput(samples(i*100, 100))
+	}
+	r.StartTurn()
+	input := r.current.Input
+	if len(input) != maxSamples || input[0] != float32(3000-maxSamples) || input[len(input)-1] != 2999 {
+		t.Fatalf("len = %d, first = %v, last = %v, want %d, %d, 2999", len(input), input[0], input[len(input)-1], maxSamples, 3000-maxSamples)
+	}
+}
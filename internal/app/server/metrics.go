@@ -0,0 +1,53 @@
+package server
+
+import (
+	"xiaozhi-esp32-server-golang/internal/domain/mcp"
+	"xiaozhi-esp32-server-golang/internal/domain/metrics"
+	"xiaozhi-esp32-server-golang/internal/domain/vad"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+// vad资源池统计中作为指标上报的字段
+var vadPoolStatKeys = map[string]string{
+	"total_resources":     "total",
+	"available_resources": "available",
+	"in_use_resources":    "in_use",
+}
+
+// registerMetrics 注册抓取时计算的状态指标
+func (a *App) registerMetrics() {
+	err := metrics.RegisterGaugeVecFunc("active_chat_managers", "当前活跃的ChatManager数量", []string{"transport"}, func(report metrics.ReportFunc) {
+		counts := make(map[string]int)
+		for tuple := range a.chatManagers.IterBuffered() {
+			counts[tuple.Val.GetTransportType()]++
+		}
+		for transport, count := range counts {
+			report(float64(count), transport)
+		}
+	})
+	if err != nil {
+		log.Errorf("注册ChatManager指标失败: %v", err)
+	}
+
+	err = metrics.RegisterGaugeVecFunc("mcp_connections", "全局MCP服务器连接数", []string{"state"}, func(report metrics.ReportFunc) {
+		connected, total := mcp.GetGlobalMCPManager().GetConnectionStats()
+		report(float64(connected), "connected")
+		report(float64(total-connected), "disconnected")
+	})
+	if err != nil {
+		log.Errorf("注册MCP指标失败: %v", err)
+	}
+
+	err = metrics.RegisterGaugeVecFunc("vad_pool_resources", "VAD资源池实例数", []string{"provider", "state"}, func(report metrics.ReportFunc) {
+		for provider, stats := range vad.PoolStats() {
+			for key, state := range vadPoolStatKeys {
+				if value, ok := stats[key].(int); ok {
+					report(float64(value), provider, state)
+				}
+			}
+		}
+	})
+	if err != nil {
+		log.Errorf("注册VAD资源池指标失败: %v", err)
+	}
+}
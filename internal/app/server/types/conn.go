@@ -8,6 +8,7 @@ import "context"
 const (
 	TransportTypeWebsocket = "websocket"
 	TransportTypeMqttUdp   = "udp"
+	TransportTypeText      = "text" // 纯文本对话, 无上行音频
 )
 
 type IConn interface {
@@ -0,0 +1,24 @@
+package websocket
+
+import (
+	"xiaozhi-esp32-server-golang/internal/app/server/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// TextConn 纯文本对话连接, 复用 WebSocketConn 的收发逻辑
+// 客户端通过 chat 消息发送文本, 回复以 tts 句子消息下发, 开启语音回复时 opus 音频仍以二进制帧下发
+type TextConn struct {
+	*WebSocketConn
+}
+
+// NewTextConn 创建纯文本对话连接
+func NewTextConn(conn *websocket.Conn, deviceID string) *TextConn {
+	return &TextConn{
+		WebSocketConn: NewWebSocketConn(conn, deviceID, false),
+	}
+}
+
+func (c *TextConn) GetTransportType() string {
+	return types.TransportTypeText
+}
@@ -7,10 +7,12 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/spf13/viper"
 
 	"xiaozhi-esp32-server-golang/internal/app/server/auth"
 	"xiaozhi-esp32-server-golang/internal/app/server/types"
 	"xiaozhi-esp32-server-golang/internal/domain/mcp"
+	"xiaozhi-esp32-server-golang/internal/domain/metrics"
 	log "xiaozhi-esp32-server-golang/logger"
 )
 
@@ -89,6 +91,7 @@ func (s *WebSocketServer) Start() error {
 	// 注册路由处理器
 	http.HandleFunc("/xiaozhi/mqtt_udp/v1/", s.handleMqttUdpChat)
 	http.HandleFunc("/xiaozhi/v1/", s.handleChat)
+	http.HandleFunc("/xiaozhi/text/v1/", s.handleTextChat)
 	http.HandleFunc("/xiaozhi/ota/", s.handleOta)
 	http.HandleFunc("/xiaozhi/ota/activate", s.handleOtaActivate)
 	http.HandleFunc("/mcp", s.handleMCPWebSocket)
@@ -97,8 +100,13 @@ func (s *WebSocketServer) Start() error {
 
 	http.HandleFunc("/admin/inject_msg", s.handleInjectMsg)
 
+	if viper.GetBool("server.metrics.enable") {
+		http.Handle("/metrics", metrics.Handler())
+	}
+
 	listenAddr := fmt.Sprintf("0.0.0.0:%d", s.port)
 	log.Infof("WebSocket 服务器启动在 ws://%s/xiaozhi/v1/", listenAddr)
+	log.Infof("文本对话端点: ws://%s/xiaozhi/text/v1/?device_id=xxx", listenAddr)
 	log.Infof("MCP WebSocket 端点: ws://%s/mcp?token=xxx", listenAddr)
 	log.Infof("MCP API 端点: http://%s/xiaozhi/api/mcp/tools/{deviceId}", listenAddr)
 
@@ -174,6 +182,31 @@ func (s *WebSocketServer) internalHandleChat(w http.ResponseWriter, r *http.Requ
 
 }
 
+// handleTextChat 处理纯文本对话连接, 浏览器无法设置请求头时可通过 device_id 查询参数指定设备
+// 与设备共用同一个 device_id 会顶掉设备自身的连接, 配套应用应使用独立的 device_id 并在管理后台绑定智能体
+func (s *WebSocketServer) handleTextChat(w http.ResponseWriter, r *http.Request) {
+	deviceID := r.Header.Get("Device-Id")
+	if deviceID == "" {
+		deviceID = r.URL.Query().Get("device_id")
+	}
+	if deviceID == "" {
+		log.Warn("缺少 Device-Id 请求头或 device_id 参数")
+		http.Error(w, "缺少 Device-Id 请求头或 device_id 参数", http.StatusBadRequest)
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Errorf("WebSocket 升级失败: %v", err)
+		return
+	}
+
+	textConn := NewTextConn(conn, deviceID)
+	if s.onNewConnection != nil {
+		s.onNewConnection(textConn)
+	}
+}
+
 func (s *WebSocketServer) handleInjectMsg(w http.ResponseWriter, r *http.Request) {
 
 }
@@ -1,5 +1,7 @@
 package audio
 
+import "slices"
+
 const (
 	SampleRate    = 16000
 	Channels      = 1
@@ -13,3 +15,84 @@ type AudioFormat struct {
 	Channels      int    `json:"channels,omitempty"`
 	FrameDuration int    `json:"frame_duration,omitempty"`
 }
+
+// OutputCapabilities 设备在 hello 的 output_audio_params 中声明支持的下行音频格式
+type OutputCapabilities struct {
+	SampleRates    []int `json:"sample_rates,omitempty"`
+	FrameDurations []int `json:"frame_durations,omitempty"`
+}
+
+// opus 编码支持的采样率和帧长(毫秒), 设备声明的其他值无法编码, 协商时忽略
+var (
+	opusSampleRates    = []int{8000, 12000, 16000, 24000, 48000}
+	opusFrameDurations = []int{5, 10, 20, 40, 60, 80, 100, 120}
+)
+
+// NegotiateOutput 从设备支持的格式中选择与 preferred 最接近的下行格式, 设备未声明或没有 opus 可编码的项使用 preferred
+// 采样率优先选择不低于 preferred 的最小值, 避免降低音质; 帧长选择差值最小的, 相同时选较短的以降低延迟
+func NegotiateOutput(capabilities *OutputCapabilities, preferred AudioFormat) AudioFormat {
+	if capabilities == nil {
+		return preferred
+	}
+	result := preferred
+	if rate, ok := pickSampleRate(opusSupported(capabilities.SampleRates, opusSampleRates), preferred.SampleRate); ok {
+		result.SampleRate = rate
+	}
+	if duration, ok := pickFrameDuration(opusSupported(capabilities.FrameDurations, opusFrameDurations), preferred.FrameDuration); ok {
+		result.FrameDuration = duration
+	}
+	return result
+}
+
+// opusSupported 过滤出 opus 可编码的值
+func opusSupported(values []int, legal []int) []int {
+	result := make([]int, 0, len(values))
+	for _, v := range values {
+		if slices.Contains(legal, v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func pickSampleRate(supported []int, preferred int) (int, bool) {
+	best, bestAbove, found, foundAbove := 0, 0, false, false
+	for _, rate := range supported {
+		if rate <= 0 {
+			continue
+		}
+		if rate == preferred {
+			return rate, true
+		}
+		if rate > preferred && (!foundAbove || rate < bestAbove) {
+			bestAbove, foundAbove = rate, true
+		}
+		if !found || rate > best {
+			best, found = rate, true
+		}
+	}
+	if foundAbove {
+		return bestAbove, true
+	}
+	return best, found
+}
+
+func pickFrameDuration(supported []int, preferred int) (int, bool) {
+	best, found := 0, false
+	for _, duration := range supported {
+		if duration <= 0 {
+			continue
+		}
+		if !found || abs(duration-preferred) < abs(best-preferred) || (abs(duration-preferred) == abs(best-preferred) && duration < best) {
+			best, found = duration, true
+		}
+	}
+	return best, found
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
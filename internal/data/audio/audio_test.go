@@ -0,0 +1,28 @@
+package audio
+
+import "testing"
+
+func TestNegotiateOutput(t *testing.T) {
+	preferred := AudioFormat{Format: Format, SampleRate: 24000, Channels: Channels, FrameDuration: 20}
+	tests := []struct {
+		name         string
+		capabilities *OutputCapabilities
+		want         AudioFormat
+	}{
+		{name: "未声明", capabilities: nil, want: preferred},
+		{name: "完全支持", capabilities: &OutputCapabilities{SampleRates: []int{16000, 24000}, FrameDurations: []int{20, 60}}, want: preferred},
+		{name: "选择不低于首选的最小采样率", capabilities: &OutputCapabilities{SampleRates: []int{48000, 16000, 8000}}, want: AudioFormat{Format: Format, SampleRate: 48000, Channels: Channels, FrameDuration: 20}},
+		{name: "都低于首选时选最大采样率", capabilities: &OutputCapabilities{SampleRates: []int{8000, 16000}}, want: AudioFormat{Format: Format, SampleRate: 16000, Channels: Channels, FrameDuration: 20}},
+		{name: "选择最接近的帧长", capabilities: &OutputCapabilities{FrameDurations: []int{60, 40, 120}}, want: AudioFormat{Format: Format, SampleRate: 24000, Channels: Channels, FrameDuration: 40}},
+		{name: "忽略无效值", capabilities: &OutputCapabilities{SampleRates: []int{0}, FrameDurations: []int{-20}}, want: preferred},
+		{name: "忽略opus无法编码的格式", capabilities: &OutputCapabilities{SampleRates: []int{44100, 16000}, FrameDurations: []int{30, 200, 60}}, want: AudioFormat{Format: Format, SampleRate: 16000, Channels: Channels, FrameDuration: 60}},
+		{name: "全部无法编码时使用首选", capabilities: &OutputCapabilities{SampleRates: []int{44100}, FrameDurations: []int{30}}, want: preferred},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NegotiateOutput(tt.capabilities, preferred); got != tt.want {
+				t.Fatalf("NegotiateOutput = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
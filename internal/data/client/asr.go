@@ -28,7 +28,9 @@ func (a *Asr) Reset() {
 	a.AsrResult.Reset()
 }
 
-func (a *Asr) RetireAsrResult(ctx context.Context) (string, error) {
+// RetireAsrResult 等待本轮识别的最终结果
+// onPartial 不为 nil 时，收到中间结果会以截至当前的完整文本回调，用于实时字幕
+func (a *Asr) RetireAsrResult(ctx context.Context, onPartial func(text string)) (string, error) {
 	defer func() {
 		a.Reset()
 	}()
@@ -37,7 +39,16 @@ func (a *Asr) RetireAsrResult(ctx context.Context) (string, error) {
 		case <-ctx.Done():
 			return "", fmt.Errorf("RetireAsrResult ctx Done")
 		case result, ok := <-a.AsrResultChannel:
-			log.Debugf("asr result: %s, ok: %+v, isFinal: %+v", result.Text, ok, result.IsFinal)
+			log.Debugf("asr result: %s, ok: %+v, isFinal: %+v, isPartial: %+v", result.Text, ok, result.IsFinal, result.IsPartial)
+			if result.Error != nil {
+				return "", fmt.Errorf("asr识别失败: %v", result.Error)
+			}
+			if result.IsPartial {
+				if onPartial != nil && result.Text != "" {
+					onPartial(result.Text)
+				}
+				continue
+			}
 			a.AsrResult.WriteString(result.Text)
 			if a.AutoEnd || result.IsFinal {
 				text := a.AsrResult.String()
@@ -47,6 +58,9 @@ func (a *Asr) RetireAsrResult(ctx context.Context) (string, error) {
 				log.Debugf("asr result channel closed")
 				return "", nil
 			}
+			if onPartial != nil && result.Text != "" {
+				onPartial(a.AsrResult.String())
+			}
 		}
 	}
 }
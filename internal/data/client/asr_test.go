@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	asr_types "xiaozhi-esp32-server-golang/internal/domain/asr/types"
+)
+
+func TestRetireAsrResultPartial(t *testing.T) {
+	tests := []struct {
+		name        string
+		results     []asr_types.StreamingResult
+		wantText    string
+		wantPartial []string
+	}{
+		{
+			name: "增量片段拼接并回调累计文本",
+			results: []asr_types.StreamingResult{
+				{Text: "今天"},
+				{Text: "天气"},
+				{Text: "怎么样", IsFinal: true},
+			},
+			wantText:    "今天天气怎么样",
+			wantPartial: []string{"今天", "今天天气"},
+		},
+		{
+			name: "完整假设不参与拼接",
+			results: []asr_types.StreamingResult{
+				{Text: "今天", IsPartial: true},
+				{Text: "今天天气", IsPartial: true},
+				{Text: "今天天气怎么样", IsFinal: true},
+			},
+			wantText:    "今天天气怎么样",
+			wantPartial: []string{"今天", "今天天气"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Asr{AsrResultChannel: make(chan asr_types.StreamingResult, len(tt.results))}
+			for _, result := range tt.results {
+				a.AsrResultChannel <- result
+			}
+
+			var partials []string
+			text, err := a.RetireAsrResult(context.Background(), func(text string) {
+				partials = append(partials, text)
+			})
+			if err != nil {
+				t.Fatalf("RetireAsrResult 失败: %v", err)
+			}
+			if text != tt.wantText {
+				t.Fatalf("最终文本 = %q, 期望 %q", text, tt.wantText)
+			}
+			if len(partials) != len(tt.wantPartial) {
+				t.Fatalf("中间结果 = %v, 期望 %v", partials, tt.wantPartial)
+			}
+			for i := range partials {
+				if partials[i] != tt.wantPartial[i] {
+					t.Fatalf("中间结果 = %v, 期望 %v", partials, tt.wantPartial)
+				}
+			}
+		})
+	}
+}
+
+func TestRetireAsrResultWithoutPartialCallback(t *testing.T) {
+	a := &Asr{AsrResultChannel: make(chan asr_types.StreamingResult, 2)}
+	a.AsrResultChannel <- asr_types.StreamingResult{Text: "你好", IsPartial: true}
+	a.AsrResultChannel <- asr_types.StreamingResult{Text: "你好", IsFinal: true}
+
+	text, err := a.RetireAsrResult(context.Background(), nil)
+	if err != nil || text != "你好" {
+		t.Fatalf("text = %q, err = %v", text, err)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"time"
 
 	"sync"
+	"sync/atomic"
 
 	"xiaozhi-esp32-server-golang/internal/domain/asr"
 	utypes "xiaozhi-esp32-server-golang/internal/domain/config/types"
@@ -15,6 +17,9 @@ import (
 	llm_common "xiaozhi-esp32-server-golang/internal/domain/llm/common"
 	"xiaozhi-esp32-server-golang/internal/domain/tts"
 
+	// 注册内置的ASR/TTS/VAD/LLM提供者
+	_ "xiaozhi-esp32-server-golang/internal/domain/providers"
+
 	. "xiaozhi-esp32-server-golang/internal/data/audio"
 
 	log "xiaozhi-esp32-server-golang/logger"
@@ -23,19 +28,12 @@ import (
 	"github.com/spf13/viper"
 )
 
-// Dialogue 表示对话历史
+// Dialogue 表示对话历史, 管理接口会在其他协程读取, 通过 ClientState 的方法加锁访问
 type Dialogue struct {
+	sync.RWMutex
 	Messages []*schema.Message
 }
 
-const (
-	ClientStatusInit       = "init"
-	ClientStatusListening  = "listening"
-	ClientStatusListenStop = "listenStop"
-	ClientStatusLLMStart   = "llmStart"
-	ClientStatusTTSStart   = "ttsStart"
-)
-
 type SendAudioData func(audioData []byte) error
 
 // ClientState 表示客户端状态
@@ -43,8 +41,6 @@ type ClientState struct {
 	IsActivated bool
 	// 对话历史
 	Dialogue *Dialogue
-	// 打断状态
-	Abort bool
 	// 拾音模式
 	ListenMode string
 	// 设备ID
@@ -53,8 +49,9 @@ type ClientState struct {
 	// 会话ID
 	SessionID string
 
-	//设备配置
+	//设备配置, 在对话间隙整体替换, 其他协程通过 GetDeviceConfig 读取
 	DeviceConfig utypes.UConfig
+	configLock   sync.RWMutex
 
 	Vad
 	Asr
@@ -87,10 +84,13 @@ type ClientState struct {
 	MqttLastActiveTs int64         //最后活跃时间
 	VadLastActiveTs  int64         //vad最后活跃时间, 超过 60s && 没有在tts则断开连接
 
-	Status string //状态 listening, llmStart, ttsStart
+	StateMachine *StateMachine //会话状态机
+
+	EnablePartialStt bool //设备是否在hello的features中声明接收stt中间结果
+	SkipTts          bool //文本对话未开启语音回复时只下发文本, 不合成语音
 
-	IsTtsStart        bool //是否tts开始
-	IsWelcomeSpeaking bool //是否已经欢迎语
+	LastCmdTs   atomic.Int64 //最后收到信令消息的时间(毫秒)
+	LastAudioTs atomic.Int64 //最后收到音频数据的时间(毫秒)
 }
 
 // 历史消息相关的方法开始
@@ -99,10 +99,14 @@ func (c *ClientState) AddMessage(msg *schema.Message) {
 		log.Warnf("尝试添加 nil 消息到对话历史")
 		return
 	}
+	c.Dialogue.Lock()
+	defer c.Dialogue.Unlock()
 	c.Dialogue.Messages = append(c.Dialogue.Messages, msg)
 }
 
 func (c *ClientState) GetMessages(count int) []*schema.Message {
+	c.Dialogue.RLock()
+	defer c.Dialogue.RUnlock()
 	// 添加边界检查，防止数组越界
 	if len(c.Dialogue.Messages) == 0 {
 		return []*schema.Message{}
@@ -200,20 +204,30 @@ func AlignToolMessages(messages []*schema.Message) []*schema.Message {
 }
 
 func (c *ClientState) InitMessages(messages []*schema.Message) error {
+	c.Dialogue.Lock()
+	defer c.Dialogue.Unlock()
 	c.Dialogue.Messages = AlignToolMessages(messages)
 	return nil
 }
 
-//历史消息相关的方法结束
-
-func (c *ClientState) SetTtsStart(isStart bool) {
-	c.IsTtsStart = isStart
+// GetDialogue 返回对话历史的副本
+func (c *ClientState) GetDialogue() []*schema.Message {
+	c.Dialogue.RLock()
+	defer c.Dialogue.RUnlock()
+	dialogue := make([]*schema.Message, len(c.Dialogue.Messages))
+	copy(dialogue, c.Dialogue.Messages)
+	return dialogue
 }
 
-func (c *ClientState) GetTtsStart() bool {
-	return c.IsTtsStart
+// DialogueCount 对话历史中的消息数
+func (c *ClientState) DialogueCount() int {
+	c.Dialogue.RLock()
+	defer c.Dialogue.RUnlock()
+	return len(c.Dialogue.Messages)
 }
 
+//历史消息相关的方法结束
+
 func (c *ClientState) GetMaxIdleDuration() int64 {
 	maxIdleDuration := viper.GetInt64("chat.max_idle_duration")
 	if maxIdleDuration == 0 {
@@ -231,36 +245,32 @@ func (c *ClientState) IsActive() bool {
 	return c.MqttLastActiveTs > 0 && diff <= ClientActiveTs
 }
 
-func (c *ClientState) SetStatus(status string) {
-	c.Status = status
-}
-
 func (c *ClientState) GetStatus() string {
-	return c.Status
+	return string(c.StateMachine.State())
 }
 
 func (s *ClientState) ResetSessionCtx() {
-	s.SessionCtx.Lock()
-	defer s.SessionCtx.Unlock()
-	if s.SessionCtx.Ctx == nil {
-		s.SessionCtx.Ctx, s.SessionCtx.Cancel = context.WithCancel(s.Ctx)
-	}
+	s.GetSessionCtx()
 }
 
+// CancelSessionCtx 取消会话上下文并结束当前轮次, 被打断的回复之后上报的事件会被状态机丢弃
 func (s *ClientState) CancelSessionCtx() {
 	s.SessionCtx.Lock()
 	defer s.SessionCtx.Unlock()
 	if s.SessionCtx.Ctx != nil {
 		s.SessionCtx.Cancel()
 		s.SessionCtx.Ctx = nil
+		s.StateMachine.NextTurn()
 	}
 }
 
+// GetSessionCtx 获取会话上下文, 新建时开始新的轮次并挂到ctx上
 func (s *ClientState) GetSessionCtx() context.Context {
 	s.SessionCtx.Lock()
 	defer s.SessionCtx.Unlock()
 	if s.SessionCtx.Ctx == nil {
-		s.SessionCtx.Ctx, s.SessionCtx.Cancel = context.WithCancel(s.Ctx)
+		ctx := ContextWithTurn(s.Ctx, s.StateMachine.NextTurn())
+		s.SessionCtx.Ctx, s.SessionCtx.Cancel = context.WithCancel(ctx)
 	}
 	return s.SessionCtx.Ctx
 }
@@ -272,7 +282,10 @@ type Ctx struct {
 }
 
 func (s *ClientState) getLLMProvider() (llm.LLMProvider, error) {
-	llmConfig := s.DeviceConfig.Llm
+	return newLLMProvider(s.DeviceConfig.Llm)
+}
+
+func newLLMProvider(llmConfig utypes.LlmConfig) (llm.LLMProvider, error) {
 	llmType, ok := llmConfig.Config["type"]
 	if !ok {
 		log.Errorf("getLLMProvider err: not found llm type: %+v", llmConfig)
@@ -285,6 +298,82 @@ func (s *ClientState) getLLMProvider() (llm.LLMProvider, error) {
 	return llmProvider, nil
 }
 
+// DeviceProviders 按设备配置创建好的 ASR/LLM/TTS 提供者, 用于在对话间隙整体替换
+type DeviceProviders struct {
+	Config      utypes.UConfig
+	AsrProvider asr.AsrProvider
+	LLMProvider llm.LLMProvider
+	TTSProvider tts.TTSProvider
+}
+
+// NewDeviceProviders 按设备配置创建 ASR/LLM/TTS 提供者, 任一创建失败则返回错误
+func NewDeviceProviders(deviceConfig utypes.UConfig) (*DeviceProviders, error) {
+	asrProvider, err := asr.NewAsrProvider(deviceConfig.Asr.Provider, deviceConfig.Asr.Config)
+	if err != nil {
+		return nil, fmt.Errorf("创建asr提供者失败: %v", err)
+	}
+	llmProvider, err := newLLMProvider(deviceConfig.Llm)
+	if err != nil {
+		return nil, err
+	}
+	ttsProvider, err := tts.GetTTSProvider(deviceConfig.Tts.Provider, deviceConfig.Tts.Config)
+	if err != nil {
+		return nil, fmt.Errorf("创建 TTS 提供者失败: %v", err)
+	}
+	return &DeviceProviders{
+		Config:      deviceConfig,
+		AsrProvider: asrProvider,
+		LLMProvider: llmProvider,
+		TTSProvider: ttsProvider,
+	}, nil
+}
+
+// Close 释放提供者持有的连接等资源, 只处理实现了 io.Closer 的提供者
+func (p *DeviceProviders) Close() {
+	for name, provider := range map[string]interface{}{"asr": p.AsrProvider, "llm": p.LLMProvider, "tts": p.TTSProvider} {
+		closer, ok := provider.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil {
+			log.Warnf("关闭 %s 提供者失败: %v", name, err)
+		}
+	}
+}
+
+// ApplyDeviceProviders 替换设备配置及 ASR/LLM/TTS 提供者, 并关闭被替换的旧提供者
+// 在对话间隙调用, 上一轮的识别和回复已结束; 输出音频格式仍以 hello 协商的为准
+func (s *ClientState) ApplyDeviceProviders(providers *DeviceProviders) {
+	old := &DeviceProviders{
+		AsrProvider: s.AsrProvider,
+		LLMProvider: s.LLMProvider,
+		TTSProvider: s.TTSProvider,
+	}
+	s.configLock.Lock()
+	s.DeviceConfig = providers.Config
+	s.AgentID = providers.Config.AgentId
+	s.configLock.Unlock()
+	s.SystemPrompt = providers.Config.SystemPrompt
+	s.AsrProvider = providers.AsrProvider
+	s.LLMProvider = providers.LLMProvider
+	s.TTSProvider = providers.TTSProvider
+	old.Close()
+}
+
+// GetDeviceConfig 返回设备配置的副本, 供会话协程以外读取
+func (s *ClientState) GetDeviceConfig() utypes.UConfig {
+	s.configLock.RLock()
+	defer s.configLock.RUnlock()
+	return s.DeviceConfig
+}
+
+// GetAgentID 返回设备当前绑定的智能体ID, 供会话协程以外读取
+func (s *ClientState) GetAgentID() string {
+	s.configLock.RLock()
+	defer s.configLock.RUnlock()
+	return s.AgentID
+}
+
 func (s *ClientState) InitLlm() error {
 	ctx, cancel := context.WithCancel(s.Ctx)
 
@@ -333,15 +422,13 @@ func (s *ClientState) InitAsr() error {
 
 func (c *ClientState) Destroy() {
 	c.Asr.Stop()
-	c.Vad.Reset()
+	c.Vad.Release()
 
 	c.VoiceStatus.Reset()
 	c.AsrAudioBuffer.ClearAsrAudioData()
 
 	c.ResetSessionCtx()
 	c.Statistic.Reset()
-	c.SetStatus(ClientStatusInit)
-	c.SetTtsStart(false)
 }
 
 func (c *ClientState) SetAsrPcmFrameSize(sampleRate int, channels int, perFrameDuration int) {
@@ -353,15 +440,15 @@ func (state *ClientState) OnManualStop() {
 }
 
 func (state *ClientState) OnVoiceSilence() {
-	state.SetClientVoiceStop(true) //设置停止说话标志位, 此时收到的音频数据不会进vad
+	//停止说话, 此时收到的音频数据不会进vad
+	state.StateMachine.Fire(EventVoiceStop)
 	//客户端停止说话
 	state.Asr.Stop() //停止asr并获取结果，进行llm
 	//释放vad
 	state.Vad.Reset() //释放vad实例
 	//asr统计
 	state.SetStartAsrTs() //进行asr统计
-
-	state.SetStatus(ClientStatusListenStop)
+	state.StartTurnTrace()
 }
 
 type Llm struct {
@@ -387,5 +474,7 @@ type ClientMessage struct {
 	Transport   string          `json:"transport,omitempty"`
 	Features    map[string]bool `json:"features,omitempty"`
 	AudioParams *AudioFormat    `json:"audio_params,omitempty"`
-	PayLoad     json.RawMessage `json:"payload,omitempty"`
+	// OutputAudioParams 设备支持的下行音频格式, 服务端据此协商并在 hello 响应的 audio_params 中返回
+	OutputAudioParams *OutputCapabilities `json:"output_audio_params,omitempty"`
+	PayLoad           json.RawMessage     `json:"payload,omitempty"`
 }
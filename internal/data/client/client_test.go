@@ -0,0 +1,40 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	utypes "xiaozhi-esp32-server-golang/internal/domain/config/types"
+)
+
+// closableTTS 记录 Close 调用次数的 TTS 提供者
+type closableTTS struct {
+	closed int
+}
+
+func (p *closableTTS) TextToSpeech(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) ([][]byte, error) {
+	return nil, nil
+}
+
+func (p *closableTTS) TextToSpeechStream(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (chan []byte, error) {
+	return nil, nil
+}
+
+func (p *closableTTS) Close() error {
+	p.closed++
+	return nil
+}
+
+func TestApplyDeviceProvidersClosesOld(t *testing.T) {
+	old := &closableTTS{}
+	s := &ClientState{TTSProvider: old}
+
+	next := &closableTTS{}
+	s.ApplyDeviceProviders(&DeviceProviders{Config: utypes.UConfig{AgentId: "2"}, TTSProvider: next})
+	if old.closed != 1 || next.closed != 0 {
+		t.Fatalf("旧提供者关闭 %d 次, 新提供者关闭 %d 次, want 1, 0", old.closed, next.closed)
+	}
+	if s.TTSProvider != next || s.GetAgentID() != "2" {
+		t.Fatalf("配置未替换: agent %s", s.GetAgentID())
+	}
+}
@@ -0,0 +1,274 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+// SessionState 会话状态
+type SessionState string
+
+const (
+	StateIdle        SessionState = "idle"         //空闲, 等待设备拾音或服务端下发
+	StateListening   SessionState = "listening"    //设备拾音中
+	StateRecognizing SessionState = "recognizing"  //用户说完, 等待识别结果
+	StateThinking    SessionState = "thinking"     //请求大模型中
+	StateSpeaking    SessionState = "speaking"     //下发tts中
+	StateToolRunning SessionState = "tool_running" //执行工具调用中
+	StateClosing     SessionState = "closing"      //会话关闭, 终态
+)
+
+// SessionEvent 驱动会话状态变化的事件
+type SessionEvent string
+
+const (
+	EventListenStart SessionEvent = "listen_start" //设备开始拾音或插话
+	EventVoiceStart  SessionEvent = "voice_start"  //拾音中检测到用户说话
+	EventVoiceStop   SessionEvent = "voice_stop"   //检测到静音或设备停止拾音
+	EventAsrEmpty    SessionEvent = "asr_empty"    //识别结果为空, 继续拾音
+	EventLlmStart    SessionEvent = "llm_start"    //开始请求大模型
+	EventSpeakStart  SessionEvent = "speak_start"  //开始下发tts或句子
+	EventToolCall    SessionEvent = "tool_call"    //大模型返回工具调用
+	EventTurnEnd     SessionEvent = "turn_end"     //本轮回复下发完成
+	EventWelcome     SessionEvent = "welcome"      //唤醒后下发欢迎语, 每个会话只下发一次
+	EventAbort       SessionEvent = "abort"        //打断当前回复
+	EventClose       SessionEvent = "close"        //关闭会话
+)
+
+// sessionTransitions 状态迁移表: 当前状态 -> 事件 -> 目标状态, 表中没有的迁移均为非法
+var sessionTransitions = map[SessionState]map[SessionEvent]SessionState{
+	StateIdle: {
+		EventListenStart: StateListening,
+		EventLlmStart:    StateThinking,
+		EventSpeakStart:  StateSpeaking,
+		EventWelcome:     StateSpeaking,
+		EventTurnEnd:     StateIdle,
+		EventAbort:       StateIdle,
+		EventClose:       StateClosing,
+	},
+	StateListening: {
+		EventListenStart: StateListening,
+		EventVoiceStart:  StateListening,
+		EventVoiceStop:   StateRecognizing,
+		EventAsrEmpty:    StateListening,
+		// 设备持续拾音时服务端注入的消息, 上一轮的过期事件由轮次过滤
+		EventLlmStart:   StateThinking,
+		EventSpeakStart: StateSpeaking,
+		EventWelcome:    StateSpeaking,
+		EventAbort:      StateIdle,
+		EventClose:      StateClosing,
+	},
+	StateRecognizing: {
+		EventListenStart: StateListening,
+		EventVoiceStop:   StateRecognizing,
+		EventAsrEmpty:    StateListening,
+		EventLlmStart:    StateThinking,
+		// 实时语音模式由模型直接回复
+		EventSpeakStart: StateSpeaking,
+		EventWelcome:    StateSpeaking,
+		EventAbort:      StateIdle,
+		EventClose:      StateClosing,
+	},
+	StateThinking: {
+		EventListenStart: StateListening,
+		EventLlmStart:    StateThinking,
+		EventSpeakStart:  StateSpeaking,
+		EventToolCall:    StateToolRunning,
+		EventTurnEnd:     StateIdle,
+		EventAbort:       StateIdle,
+		EventClose:       StateClosing,
+	},
+	StateSpeaking: {
+		EventListenStart: StateListening,
+		EventSpeakStart:  StateSpeaking,
+		EventToolCall:    StateToolRunning,
+		EventTurnEnd:     StateIdle,
+		EventAbort:       StateIdle,
+		EventClose:       StateClosing,
+	},
+	StateToolRunning: {
+		EventListenStart: StateListening,
+		EventLlmStart:    StateThinking,
+		EventSpeakStart:  StateSpeaking,
+		EventTurnEnd:     StateIdle,
+		EventAbort:       StateIdle,
+		EventClose:       StateClosing,
+	},
+	StateClosing: {
+		EventAbort: StateClosing,
+		EventClose: StateClosing,
+	},
+}
+
+// Transition 一次状态迁移
+type Transition struct {
+	From  SessionState
+	To    SessionState
+	Event SessionEvent
+	Time  time.Time
+}
+
+// TransitionHook 状态迁移的回调, 在状态更新后调用
+type TransitionHook func(transition Transition)
+
+// StateMachine 会话状态机, 按迁移表处理事件, 非法迁移会被拒绝并保持当前状态
+// 每个会话上下文对应一个轮次, 带轮次的事件只在轮次仍为当前轮次时处理, 被打断的回复迟到的事件会被丢弃
+type StateMachine struct {
+	sync.RWMutex
+	deviceID string
+	state    SessionState
+	hooks    []TransitionHook
+
+	turn         uint64
+	haveVoice    bool //本轮拾音是否检测到用户说话
+	voiceStopped bool //本轮拾音已结束, 之后的音频不再送入vad
+	welcomed     bool //是否已下发欢迎语
+}
+
+func NewStateMachine(deviceID string) *StateMachine {
+	return &StateMachine{
+		deviceID: deviceID,
+		state:    StateIdle,
+	}
+}
+
+// State 当前状态
+func (m *StateMachine) State() SessionState {
+	m.RLock()
+	defer m.RUnlock()
+	return m.state
+}
+
+// Is 当前状态是否为其中之一
+func (m *StateMachine) Is(states ...SessionState) bool {
+	current := m.State()
+	for _, state := range states {
+		if current == state {
+			return true
+		}
+	}
+	return false
+}
+
+// HaveVoice 本轮拾音是否已检测到用户说话
+func (m *StateMachine) HaveVoice() bool {
+	m.RLock()
+	defer m.RUnlock()
+	return m.haveVoice
+}
+
+// VoiceStopped 本轮拾音是否已结束, 重新开始拾音前设备上行的音频不进入vad
+func (m *StateMachine) VoiceStopped() bool {
+	m.RLock()
+	defer m.RUnlock()
+	return m.voiceStopped
+}
+
+// Welcomed 是否已下发欢迎语
+func (m *StateMachine) Welcomed() bool {
+	m.RLock()
+	defer m.RUnlock()
+	return m.welcomed
+}
+
+// Turn 当前轮次
+func (m *StateMachine) Turn() uint64 {
+	m.RLock()
+	defer m.RUnlock()
+	return m.turn
+}
+
+// NextTurn 开始新的轮次, 之前轮次的事件将被丢弃
+func (m *StateMachine) NextTurn() uint64 {
+	m.Lock()
+	defer m.Unlock()
+	m.turn++
+	return m.turn
+}
+
+// Can 当前状态下事件是否合法
+func (m *StateMachine) Can(event SessionEvent) bool {
+	_, ok := sessionTransitions[m.State()][event]
+	return ok
+}
+
+// OnTransition 注册状态迁移的回调
+func (m *StateMachine) OnTransition(hook TransitionHook) {
+	m.Lock()
+	defer m.Unlock()
+	m.hooks = append(m.hooks, hook)
+}
+
+// Fire 处理事件并迁移状态, 非法迁移返回错误
+func (m *StateMachine) Fire(event SessionEvent) error {
+	return m.fire(0, false, event)
+}
+
+// FireTurn 处理轮次 turn 产生的事件, 轮次已结束时丢弃并返回错误
+func (m *StateMachine) FireTurn(turn uint64, event SessionEvent) error {
+	return m.fire(turn, true, event)
+}
+
+// FireContext ctx 带有轮次时按 FireTurn 处理, 否则按 Fire 处理
+func (m *StateMachine) FireContext(ctx context.Context, event SessionEvent) error {
+	if turn, ok := TurnFromContext(ctx); ok {
+		return m.FireTurn(turn, event)
+	}
+	return m.Fire(event)
+}
+
+func (m *StateMachine) fire(turn uint64, checkTurn bool, event SessionEvent) error {
+	m.Lock()
+	from := m.state
+	if checkTurn && turn != m.turn {
+		m.Unlock()
+		log.Warnf("设备 %s 丢弃过期轮次的事件: %s, 轮次 %d, 当前轮次 %d", m.deviceID, event, turn, m.turn)
+		return fmt.Errorf("轮次 %d 已结束, 丢弃事件 %s", turn, event)
+	}
+	to, ok := sessionTransitions[from][event]
+	if ok && event == EventWelcome && m.welcomed {
+		ok = false
+	}
+	if !ok {
+		m.Unlock()
+		log.Warnf("设备 %s 非法的状态迁移: %s --%s--> ?, 已忽略", m.deviceID, from, event)
+		return fmt.Errorf("状态 %s 下不允许事件 %s", from, event)
+	}
+	m.state = to
+	switch event {
+	case EventListenStart, EventAsrEmpty:
+		m.haveVoice, m.voiceStopped = false, false
+	case EventVoiceStart:
+		m.haveVoice = true
+	case EventVoiceStop:
+		m.voiceStopped = true
+	case EventWelcome:
+		m.welcomed = true
+	}
+	hooks := m.hooks
+	m.Unlock()
+
+	log.Debugf("设备 %s 状态迁移: %s --%s--> %s", m.deviceID, from, event, to)
+	transition := Transition{From: from, To: to, Event: event, Time: time.Now()}
+	for _, hook := range hooks {
+		hook(transition)
+	}
+	return nil
+}
+
+type turnCtxKey struct{}
+
+// ContextWithTurn 将轮次挂到ctx上, 该ctx下的回复通过 FireContext 上报事件
+func ContextWithTurn(ctx context.Context, turn uint64) context.Context {
+	return context.WithValue(ctx, turnCtxKey{}, turn)
+}
+
+// TurnFromContext 获取ctx所属的轮次
+func TurnFromContext(ctx context.Context) (uint64, bool) {
+	turn, ok := ctx.Value(turnCtxKey{}).(uint64)
+	return turn, ok
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStateMachineTransitions(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    SessionState
+		event   SessionEvent
+		want    SessionState
+		wantErr bool
+	}{
+		{name: "空闲时开始拾音", from: StateIdle, event: EventListenStart, want: StateListening},
+		{name: "拾音中检测到说话", from: StateListening, event: EventVoiceStart, want: StateListening},
+		{name: "唤醒后下发欢迎语", from: StateListening, event: EventWelcome, want: StateSpeaking},
+		{name: "检测到静音后等待识别", from: StateListening, event: EventVoiceStop, want: StateRecognizing},
+		{name: "识别结果到达时重复的静音事件", from: StateRecognizing, event: EventVoiceStop, want: StateRecognizing},
+		{name: "识别为空继续拾音", from: StateRecognizing, event: EventAsrEmpty, want: StateListening},
+		{name: "识别完成请求大模型", from: StateRecognizing, event: EventLlmStart, want: StateThinking},
+		{name: "文本对话直接请求大模型", from: StateIdle, event: EventLlmStart, want: StateThinking},
+		{name: "拾音时注入消息", from: StateListening, event: EventLlmStart, want: StateThinking},
+		{name: "大模型返回后开始播放", from: StateThinking, event: EventSpeakStart, want: StateSpeaking},
+		{name: "播放中的后续句子", from: StateSpeaking, event: EventSpeakStart, want: StateSpeaking},
+		{name: "播放中返回工具调用", from: StateSpeaking, event: EventToolCall, want: StateToolRunning},
+		{name: "工具调用后再次请求大模型", from: StateToolRunning, event: EventLlmStart, want: StateThinking},
+		{name: "工具直接播放音频", from: StateToolRunning, event: EventSpeakStart, want: StateSpeaking},
+		{name: "播放结束", from: StateSpeaking, event: EventTurnEnd, want: StateIdle},
+		{name: "播放中插话", from: StateSpeaking, event: EventListenStart, want: StateListening},
+		{name: "播放中打断", from: StateSpeaking, event: EventAbort, want: StateIdle},
+		{name: "工具执行中关闭", from: StateToolRunning, event: EventClose, want: StateClosing},
+		{name: "重复关闭", from: StateClosing, event: EventClose, want: StateClosing},
+		{name: "关闭时的打断", from: StateClosing, event: EventAbort, want: StateClosing},
+
+		{name: "空闲时不能停止拾音", from: StateIdle, event: EventVoiceStop, want: StateIdle, wantErr: true},
+		{name: "未拾音时识别为空", from: StateThinking, event: EventAsrEmpty, want: StateThinking, wantErr: true},
+		{name: "拾音时不能结束回复", from: StateListening, event: EventTurnEnd, want: StateListening, wantErr: true},
+		{name: "播放中不能再次请求大模型", from: StateSpeaking, event: EventLlmStart, want: StateSpeaking, wantErr: true},
+		{name: "识别中不能检测说话", from: StateRecognizing, event: EventVoiceStart, want: StateRecognizing, wantErr: true},
+		{name: "播放中不能下发欢迎语", from: StateSpeaking, event: EventWelcome, want: StateSpeaking, wantErr: true},
+		{name: "空闲时不能执行工具", from: StateIdle, event: EventToolCall, want: StateIdle, wantErr: true},
+		{name: "关闭后不能开始拾音", from: StateClosing, event: EventListenStart, want: StateClosing, wantErr: true},
+		{name: "关闭后不能播放", from: StateClosing, event: EventSpeakStart, want: StateClosing, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewStateMachine("test")
+			m.state = tt.from
+
+			var transitions []Transition
+			m.OnTransition(func(transition Transition) {
+				transitions = append(transitions, transition)
+			})
+
+			err := m.Fire(tt.event)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fire(%s) err = %v, wantErr %v", tt.event, err, tt.wantErr)
+			}
+			if got := m.State(); got != tt.want {
+				t.Fatalf("state = %s, want %s", got, tt.want)
+			}
+			if tt.wantErr {
+				if len(transitions) != 0 {
+					t.Fatalf("非法迁移不应触发回调: %+v", transitions)
+				}
+				return
+			}
+			if len(transitions) != 1 || transitions[0].From != tt.from || transitions[0].To != tt.want || transitions[0].Event != tt.event {
+				t.Fatalf("transitions = %+v", transitions)
+			}
+		})
+	}
+}
+
+func TestStateMachineTableComplete(t *testing.T) {
+	states := []SessionState{StateIdle, StateListening, StateRecognizing, StateThinking, StateSpeaking, StateToolRunning, StateClosing}
+	for _, state := range states {
+		events, ok := sessionTransitions[state]
+		if !ok {
+			t.Fatalf("状态 %s 未定义迁移", state)
+		}
+		// 任意状态都可以关闭会话
+		if events[EventClose] != StateClosing {
+			t.Fatalf("状态 %s 不能关闭会话", state)
+		}
+	}
+	// 关闭后只能停留在关闭状态
+	for event, to := range sessionTransitions[StateClosing] {
+		if to != StateClosing {
+			t.Fatalf("关闭后事件 %s 迁移到了 %s", event, to)
+		}
+	}
+}
+
+func TestStateMachineStaleTurn(t *testing.T) {
+	m := NewStateMachine("test")
+	stale := ContextWithTurn(context.Background(), m.NextTurn())
+	if err := m.FireContext(stale, EventLlmStart); err != nil {
+		t.Fatal(err)
+	}
+
+	// 设备在回复下发前开始新一轮拾音, 被打断的轮次结束
+	m.NextTurn()
+	current := ContextWithTurn(context.Background(), m.NextTurn())
+	if err := m.Fire(EventListenStart); err != nil {
+		t.Fatal(err)
+	}
+
+	// 被打断的回复迟到的句子不能把新一轮拾音切到播放
+	if err := m.FireContext(stale, EventSpeakStart); err == nil {
+		t.Fatal("过期轮次的事件应被丢弃")
+	}
+	if m.State() != StateListening {
+		t.Fatalf("state = %s, want %s", m.State(), StateListening)
+	}
+
+	// 新一轮不受影响
+	for _, event := range []SessionEvent{EventVoiceStop, EventLlmStart, EventSpeakStart, EventTurnEnd} {
+		if err := m.FireContext(current, event); err != nil {
+			t.Fatalf("Fire(%s) err = %v", event, err)
+		}
+	}
+	if m.State() != StateIdle {
+		t.Fatalf("state = %s, want %s", m.State(), StateIdle)
+	}
+}
+
+func TestStateMachineVoiceStatus(t *testing.T) {
+	m := NewStateMachine("test")
+	steps := []struct {
+		event        SessionEvent
+		haveVoice    bool
+		voiceStopped bool
+	}{
+		{event: EventListenStart},
+		{event: EventVoiceStart, haveVoice: true},
+		{event: EventVoiceStop, haveVoice: true, voiceStopped: true},
+		{event: EventAsrEmpty},
+		{event: EventVoiceStart, haveVoice: true},
+		{event: EventVoiceStop, haveVoice: true, voiceStopped: true},
+		{event: EventLlmStart, haveVoice: true, voiceStopped: true},
+		{event: EventListenStart},
+	}
+	for _, step := range steps {
+		if err := m.Fire(step.event); err != nil {
+			t.Fatal(err)
+		}
+		if m.HaveVoice() != step.haveVoice || m.VoiceStopped() != step.voiceStopped {
+			t.Fatalf("%s 后 haveVoice = %v, voiceStopped = %v", step.event, m.HaveVoice(), m.VoiceStopped())
+		}
+	}
+
+	if err := m.Fire(EventWelcome); err != nil || !m.Welcomed() {
+		t.Fatalf("欢迎语 err = %v, welcomed = %v", err, m.Welcomed())
+	}
+	m.Fire(EventTurnEnd)
+	if err := m.Fire(EventWelcome); err == nil {
+		t.Fatal("每个会话只下发一次欢迎语")
+	}
+}
@@ -1,17 +1,27 @@
 package client
 
-import "time"
+import (
+	"sync/atomic"
+	"time"
+
+	"xiaozhi-esp32-server-golang/internal/domain/tracing"
+)
 
 type Statistic struct {
 	AsrStartTs int64 //asr开始时间
 	LlmStartTs int64 //llm开始时间
 	TtsStartTs int64 //tts开始时间
+
+	turn atomic.Pointer[tracing.Turn] //说话结束后等待asr结果的本轮追踪
 }
 
 func (s *Statistic) Reset() {
 	s.AsrStartTs = 0
 	s.LlmStartTs = 0
 	s.TtsStartTs = 0
+	if turn := s.turn.Swap(nil); turn != nil {
+		turn.End(nil)
+	}
 }
 
 func (state *ClientState) SetStartAsrTs() {
@@ -41,3 +51,19 @@ func (state *ClientState) SetStartTtsTs() {
 func (state *ClientState) GetTtsDuration() int64 {
 	return time.Now().UnixMilli() - state.Statistic.TtsStartTs
 }
+
+// StartTurnTrace 说话结束时开始本轮追踪, 已存在未取走的追踪时不重复创建
+func (state *ClientState) StartTurnTrace() {
+	if state.Statistic.turn.Load() != nil {
+		return
+	}
+	turn := tracing.StartTurn(state.DeviceID, state.SessionID)
+	if !state.Statistic.turn.CompareAndSwap(nil, turn) {
+		turn.End(nil)
+	}
+}
+
+// TakeTurnTrace 取走本轮追踪, 由对话流程负责结束
+func (state *ClientState) TakeTurnTrace() *tracing.Turn {
+	return state.Statistic.turn.Swap(nil)
+}
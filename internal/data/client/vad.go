@@ -14,6 +14,8 @@ type Vad struct {
 	VadProvider vad_inter.VAD
 
 	IdleDuration int64 // 空闲时间, 单位: ms
+
+	retain bool // 本轮拾音结束时保留vad实例, 供播放期间的插话检测使用, 下一轮拾音或会话结束时释放
 }
 
 func (v *Vad) AddIdleDuration(idleDuration int64) int64 {
@@ -28,9 +30,35 @@ func (v *Vad) ResetIdleDuration() {
 	atomic.StoreInt64(&v.IdleDuration, 0)
 }
 
+// Provider 获取当前的vad实例, 未创建或已释放时返回 nil
+func (v *Vad) Provider() vad_inter.VAD {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	return v.VadProvider
+}
+
+// Init 创建vad实例, 已有实例时直接复用
 func (v *Vad) Init(provider string, config map[string]interface{}) error {
 	v.lock.Lock()
 	defer v.lock.Unlock()
+	return v.init(provider, config)
+}
+
+// InitRetained 创建vad实例并在本轮拾音结束后保留, 用于播放期间的插话检测
+func (v *Vad) InitRetained(provider string, config map[string]interface{}) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if err := v.init(provider, config); err != nil {
+		return err
+	}
+	v.retain = true
+	return nil
+}
+
+func (v *Vad) init(provider string, config map[string]interface{}) error {
+	if v.VadProvider != nil {
+		return nil
+	}
 	vadProvider, err := vad.AcquireVAD(provider, config)
 	if err != nil {
 		return fmt.Errorf("创建 VAD 提供者失败: %v", err)
@@ -41,13 +69,30 @@ func (v *Vad) Init(provider string, config map[string]interface{}) error {
 	return nil
 }
 
+// Reset 本轮拾音结束, 释放未被插话检测保留的vad实例
 func (v *Vad) Reset() error {
 	v.lock.Lock()
 	defer v.lock.Unlock()
+	if !v.retain {
+		v.release()
+	}
+	v.ResetIdleDuration()
+	return nil
+}
+
+// Release 释放vad实例, 包括插话检测保留的实例
+func (v *Vad) Release() error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.release()
+	v.retain = false
+	v.ResetIdleDuration()
+	return nil
+}
+
+func (v *Vad) release() {
 	if v.VadProvider != nil {
 		vad.ReleaseVAD(v.VadProvider) //释放vad实例资源
 		v.VadProvider = nil           //置nil
 	}
-	v.ResetIdleDuration()
-	return nil
 }
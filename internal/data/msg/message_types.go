@@ -23,6 +23,7 @@ const (
 	MessageTypeIot     = "iot"     // 物联网消息
 	MessageTypeMcp     = "mcp"     // MCP消息
 	MessageTypeGoodBye = "goodbye" // 再见消息
+	MessageTypeChat    = "chat"    // 文本对话消息
 )
 
 // 服务器消息类型常量
@@ -58,6 +59,7 @@ type UdpConfig struct {
 type ServerMessage struct {
 	Type        string                   `json:"type"`
 	Text        string                   `json:"text,omitempty"`
+	IsPartial   bool                     `json:"is_partial,omitempty"` // stt中间结果
 	SessionID   string                   `json:"session_id,omitempty"`
 	Version     int                      `json:"version"`
 	State       string                   `json:"state,omitempty"`
@@ -3,12 +3,31 @@ package asr
 import (
 	"context"
 	"strconv"
+	"xiaozhi-esp32-server-golang/constants"
 	"xiaozhi-esp32-server-golang/internal/data/audio"
 	"xiaozhi-esp32-server-golang/internal/domain/asr/funasr"
 	"xiaozhi-esp32-server-golang/internal/domain/asr/types"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
 	log "xiaozhi-esp32-server-golang/logger"
 )
 
+func init() {
+	Register(constants.AsrTypeFunAsr, NewFunasrAdapter, registry.ConfigSchema{
+		Description: "FunASR 语音识别",
+		Fields: []registry.ConfigField{
+			{Name: "host", Type: registry.FieldTypeString, Default: "localhost", Description: "FunASR服务器地址"},
+			{Name: "port", Type: registry.FieldTypeNumber, Default: "10095", Description: "FunASR服务器端口"},
+			{Name: "mode", Type: registry.FieldTypeString, Default: "online", Options: []string{"online", "offline", "2pass"}, Description: "识别模式"},
+			{Name: "sample_rate", Type: registry.FieldTypeNumber, Default: audio.SampleRate, Description: "采样率"},
+			{Name: "chunk_size", Type: registry.FieldTypeArray, Description: "分块大小配置"},
+			{Name: "chunk_interval", Type: registry.FieldTypeNumber, Default: audio.FrameDuration, Description: "分块间隔（毫秒）"},
+			{Name: "max_connections", Type: registry.FieldTypeNumber, Default: 5, Description: "最大连接数"},
+			{Name: "timeout", Type: registry.FieldTypeNumber, Default: 30, Description: "超时时间（秒）"},
+			{Name: "auto_end", Type: registry.FieldTypeBool, Default: false, Description: "是否自动结束"},
+		},
+	})
+}
+
 // FunasrAdapter 适配 funasr 包到 asr 接口
 type FunasrAdapter struct {
 	engine *funasr.Funasr
@@ -4,10 +4,7 @@ import (
 	"context"
 	"fmt"
 
-	"xiaozhi-esp32-server-golang/constants"
-	"xiaozhi-esp32-server-golang/internal/domain/asr/doubao"
 	"xiaozhi-esp32-server-golang/internal/domain/asr/types"
-	log "xiaozhi-esp32-server-golang/logger"
 )
 
 // Asr 语音识别接口
@@ -23,22 +20,15 @@ type AsrProvider interface {
 }
 
 // NewAsrProvider 创建一个新的ASR实例
-// asrType: ASR引擎类型，目前支持 "funasr"
+// asrType: ASR引擎类型，需已通过 Register 注册，如 "funasr"、"doubao"
 // config: ASR引擎配置，为 map[string]interface{} 类型
 func NewAsrProvider(asrType string, config map[string]interface{}) (AsrProvider, error) {
-	switch asrType {
-	case constants.AsrTypeFunAsr:
-		return NewFunasrAdapter(config)
-	case constants.AsrTypeDoubao:
-		log.Info("使用 豆包ASR 提供者")
-		provider, err := doubao.NewDoubaoV2Adapter(config)
-		if err != nil {
-			log.Errorf("豆包ASR适配器创建失败: %v", err)
-		} else {
-			log.Info("豆包ASR适配器创建成功")
-		}
-		return provider, err
-	default:
-		return nil, fmt.Errorf("不支持的ASR引擎类型: %s，目前仅支持 'funasr'", asrType)
+	factory, ok := providers.Lookup(asrType)
+	if !ok {
+		return nil, fmt.Errorf("不支持的ASR引擎类型: %s，目前支持: %v", asrType, providers.Names())
 	}
+	if err := providers.Validate(asrType, config); err != nil {
+		return nil, err
+	}
+	return factory(config)
 }
@@ -4,10 +4,33 @@ import (
 	"context"
 	"fmt"
 
+	"xiaozhi-esp32-server-golang/constants"
+	"xiaozhi-esp32-server-golang/internal/domain/asr"
 	"xiaozhi-esp32-server-golang/internal/domain/asr/types"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
 	log "xiaozhi-esp32-server-golang/logger"
 )
 
+func init() {
+	asr.Register(constants.AsrTypeDoubao, func(config map[string]interface{}) (asr.AsrProvider, error) {
+		return NewDoubaoV2Adapter(config)
+	}, registry.ConfigSchema{
+		Description: "豆包流式语音识别",
+		Fields: []registry.ConfigField{
+			{Name: "appid", Type: registry.FieldTypeString, Required: true, Description: "应用ID"},
+			{Name: "access_token", Type: registry.FieldTypeString, Required: true, Description: "访问令牌"},
+			{Name: "ws_url", Type: registry.FieldTypeString, Description: "WebSocket URL"},
+			{Name: "model_name", Type: registry.FieldTypeString, Description: "模型名称"},
+			{Name: "end_window_size", Type: registry.FieldTypeNumber, Description: "结束窗口大小"},
+			{Name: "enable_punc", Type: registry.FieldTypeBool, Description: "启用标点符号"},
+			{Name: "enable_itn", Type: registry.FieldTypeBool, Description: "启用反向文本标准化"},
+			{Name: "enable_ddc", Type: registry.FieldTypeBool, Description: "启用数字检测修正"},
+			{Name: "chunk_duration", Type: registry.FieldTypeNumber, Description: "分片时长（毫秒）"},
+			{Name: "timeout", Type: registry.FieldTypeNumber, Description: "超时时间（秒）"},
+		},
+	})
+}
+
 // DoubaoV2Adapter 适配器，实现现有的AsrProvider接口
 type DoubaoV2Adapter struct {
 	engine *DoubaoV2ASR
@@ -102,6 +102,7 @@ func (d *DoubaoV2ASR) receiveStreamResults(ctx context.Context, resultChan chan
 	defer func() {
 		close(resultChan)
 	}()
+	var lastPartial string
 	for {
 		select {
 		case <-ctx.Done():
@@ -119,6 +120,16 @@ func (d *DoubaoV2ASR) receiveStreamResults(ctx context.Context, resultChan chan
 				}
 				return
 			}
+			// 中间结果为截至当前的完整文本，文本有变化时才发送
+			if result.PayloadMsg == nil || result.PayloadMsg.Result.Text == "" || result.PayloadMsg.Result.Text == lastPartial {
+				continue
+			}
+			lastPartial = result.PayloadMsg.Result.Text
+			select {
+			case resultChan <- types.StreamingResult{Text: lastPartial, IsPartial: true}:
+			default:
+				// 中间结果仅用于展示，消费不及时直接丢弃
+			}
 		}
 	}
 }
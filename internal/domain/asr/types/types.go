@@ -4,4 +4,9 @@ package types
 type StreamingResult struct {
 	Text    string // 识别的文本
 	IsFinal bool   // 是否为最终结果
+	// IsPartial 为 true 时表示中间结果，Text 是截至当前的完整识别假设，仅用于实时展示，不参与最终文本拼接
+	// 为 false 且 IsFinal 为 false 时，Text 为增量片段（如 FunASR online 模式），会拼接到最终文本
+	IsPartial bool
+	// Error 不为 nil 时表示识别失败, 本轮识别结束, Text 无效
+	Error error
 }
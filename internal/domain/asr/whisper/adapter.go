@@ -0,0 +1,92 @@
+package whisper
+
+import (
+	"context"
+	"fmt"
+
+	"xiaozhi-esp32-server-golang/constants"
+	"xiaozhi-esp32-server-golang/internal/domain/asr"
+	"xiaozhi-esp32-server-golang/internal/domain/asr/types"
+	config_types "xiaozhi-esp32-server-golang/internal/domain/config/types"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+)
+
+func init() {
+	asr.Register(constants.AsrTypeWhisper, func(config map[string]interface{}) (asr.AsrProvider, error) {
+		return NewWhisperAdapter(config)
+	}, registry.ConfigSchema{
+		Description: "OpenAI兼容语音转写接口（如 whisper.cpp server）",
+		Fields: []registry.ConfigField{
+			{Name: "base_url", Type: registry.FieldTypeString, Default: DefaultConfig.BaseURL, Description: "服务地址，请求 {base_url}/audio/transcriptions"},
+			{Name: "api_key", Type: registry.FieldTypeString, Description: "API密钥"},
+			{Name: "model", Type: registry.FieldTypeString, Default: DefaultConfig.Model, Description: "模型名称"},
+			{Name: "language", Type: registry.FieldTypeString, Default: DefaultConfig.Language, Description: "识别语言"},
+			{Name: "prompt", Type: registry.FieldTypeString, Description: "提示词"},
+			{Name: "temperature", Type: registry.FieldTypeNumber, Description: "采样温度"},
+			{Name: "sample_rate", Type: registry.FieldTypeNumber, Default: DefaultConfig.SampleRate, Description: "输入采样率"},
+			{Name: "timeout", Type: registry.FieldTypeNumber, Default: DefaultConfig.Timeout, Description: "请求超时时间（秒）"},
+			{Name: "min_audio_ms", Type: registry.FieldTypeNumber, Default: DefaultConfig.MinAudioMs, Description: "最短识别音频时长（毫秒）"},
+			{Name: "response_format", Type: registry.FieldTypeString, Default: DefaultConfig.ResponseFormat, Options: []string{"json", "text"}, Description: "返回格式"},
+		},
+	})
+}
+
+// WhisperAdapter 适配器，实现AsrProvider接口
+type WhisperAdapter struct {
+	engine *WhisperASR
+}
+
+// NewWhisperAdapter 创建一个新的Whisper ASR适配器
+func NewWhisperAdapter(config map[string]interface{}) (*WhisperAdapter, error) {
+	whisperConfig := DefaultConfig
+
+	if baseURL, ok := config["base_url"].(string); ok && baseURL != "" {
+		whisperConfig.BaseURL = baseURL
+	}
+	if apiKey, ok := config["api_key"].(string); ok {
+		whisperConfig.APIKey = apiKey
+	}
+	if model, ok := config["model"].(string); ok && model != "" {
+		whisperConfig.Model = model
+	}
+	if language, ok := config["language"].(string); ok {
+		whisperConfig.Language = language
+	}
+	if prompt, ok := config["prompt"].(string); ok {
+		whisperConfig.Prompt = prompt
+	}
+	if temperature, ok := config["temperature"].(float64); ok {
+		whisperConfig.Temperature = temperature
+	}
+	if sampleRate := config_types.ConfigInt(config["sample_rate"]); sampleRate > 0 {
+		whisperConfig.SampleRate = sampleRate
+	}
+	if timeout := config_types.ConfigInt(config["timeout"]); timeout > 0 {
+		whisperConfig.Timeout = timeout
+	}
+	// 配置为 0 时不限制最短音频
+	if value, ok := config["min_audio_ms"]; ok {
+		if minAudioMs := config_types.ConfigInt(value); minAudioMs >= 0 {
+			whisperConfig.MinAudioMs = minAudioMs
+		}
+	}
+	if responseFormat, ok := config["response_format"].(string); ok && responseFormat != "" {
+		whisperConfig.ResponseFormat = responseFormat
+	}
+
+	engine, err := NewWhisperASR(whisperConfig)
+	if err != nil {
+		return nil, fmt.Errorf("创建Whisper ASR引擎失败: %v", err)
+	}
+	return &WhisperAdapter{engine: engine}, nil
+}
+
+// Process 实现一次性处理整段音频，返回完整识别结果
+func (w *WhisperAdapter) Process(pcmData []float32) (string, error) {
+	return w.engine.Process(pcmData)
+}
+
+// StreamingRecognize 实现流式识别接口
+func (w *WhisperAdapter) StreamingRecognize(ctx context.Context, audioStream <-chan []float32) (chan types.StreamingResult, error) {
+	return w.engine.StreamingRecognize(ctx, audioStream)
+}
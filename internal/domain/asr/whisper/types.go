@@ -0,0 +1,34 @@
+package whisper
+
+// WhisperConfig OpenAI兼容语音转写接口配置
+type WhisperConfig struct {
+	BaseURL        string  // 服务地址，如 http://127.0.0.1:8080/v1
+	APIKey         string  // API密钥，本地 whisper.cpp 服务可为空
+	Model          string  // 模型名称
+	Language       string  // 识别语言，为空时由服务端自动检测
+	Prompt         string  // 提示词，可用于专有名词纠正
+	Temperature    float64 // 采样温度
+	SampleRate     int     // 输入PCM采样率
+	Timeout        int     // 请求超时时间(秒)
+	MinAudioMs     int     // 小于该时长的音频不提交识别(毫秒)
+	ResponseFormat string  // 返回格式，json 或 text
+}
+
+// DefaultConfig 默认配置
+var DefaultConfig = WhisperConfig{
+	BaseURL:        "http://127.0.0.1:8080/v1",
+	Model:          "whisper-1",
+	Language:       "zh",
+	SampleRate:     16000,
+	Timeout:        30,
+	MinAudioMs:     200,
+	ResponseFormat: "json",
+}
+
+// transcriptionResponse /audio/transcriptions 接口 json 返回结构
+type transcriptionResponse struct {
+	Text  string `json:"text"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
@@ -0,0 +1,178 @@
+package whisper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"xiaozhi-esp32-server-golang/internal/domain/asr/types"
+	"xiaozhi-esp32-server-golang/internal/util"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+// WhisperASR 基于 OpenAI 兼容 /audio/transcriptions 接口的ASR实现
+// 接口本身不支持流式，这里缓存整段音频，在输入通道关闭后一次性提交识别
+type WhisperASR struct {
+	config     WhisperConfig
+	httpClient *http.Client
+}
+
+// NewWhisperASR 创建一个新的Whisper ASR实例
+func NewWhisperASR(config WhisperConfig) (*WhisperASR, error) {
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultConfig.BaseURL
+	}
+	if config.Model == "" {
+		config.Model = DefaultConfig.Model
+	}
+	if config.SampleRate == 0 {
+		config.SampleRate = DefaultConfig.SampleRate
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultConfig.Timeout
+	}
+	if config.ResponseFormat == "" {
+		config.ResponseFormat = DefaultConfig.ResponseFormat
+	}
+	if config.ResponseFormat != "json" && config.ResponseFormat != "text" {
+		return nil, fmt.Errorf("不支持的response_format: %s，仅支持 json 或 text", config.ResponseFormat)
+	}
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+
+	return &WhisperASR{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: time.Duration(config.Timeout) * time.Second,
+		},
+	}, nil
+}
+
+// Process 一次性识别整段音频
+func (w *WhisperASR) Process(pcmData []float32) (string, error) {
+	return w.transcribe(context.Background(), pcmData)
+}
+
+// StreamingRecognize 模拟流式识别
+// 持续缓存 audioStream 中的音频，audioStream 被关闭（Asr.Stop）后提交识别，返回一个最终结果并关闭结果通道
+// 识别失败时返回 Error 不为空的结果
+// ctx 被取消时（如 RestartAsrRecognition）直接丢弃缓存的音频
+func (w *WhisperASR) StreamingRecognize(ctx context.Context, audioStream <-chan []float32) (chan types.StreamingResult, error) {
+	resultChan := make(chan types.StreamingResult, 1)
+
+	go func() {
+		defer close(resultChan)
+
+		var pcmData []float32
+		for {
+			select {
+			case <-ctx.Done():
+				log.Debugf("whisper asr 上下文已取消, 丢弃 %d 个采样点", len(pcmData))
+				return
+			case data, ok := <-audioStream:
+				if ok {
+					pcmData = append(pcmData, data...)
+					continue
+				}
+
+				text, err := w.transcribe(ctx, pcmData)
+				if err != nil {
+					log.Errorf("whisper asr 识别失败: %v", err)
+				}
+				select {
+				case resultChan <- types.StreamingResult{Text: text, IsFinal: true, Error: err}:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+
+	return resultChan, nil
+}
+
+// transcribe 将PCM封装为wav后提交到转写接口
+func (w *WhisperASR) transcribe(ctx context.Context, pcmData []float32) (string, error) {
+	minSamples := w.config.SampleRate * w.config.MinAudioMs / 1000
+	if len(pcmData) == 0 || len(pcmData) < minSamples {
+		log.Debugf("whisper asr 音频过短(%d 个采样点)，跳过识别", len(pcmData))
+		return "", nil
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		return "", fmt.Errorf("创建表单文件失败: %v", err)
+	}
+	if _, err := part.Write(util.PcmToWav(pcmData, w.config.SampleRate)); err != nil {
+		return "", fmt.Errorf("写入音频数据失败: %v", err)
+	}
+
+	fields := map[string]string{
+		"model":           w.config.Model,
+		"response_format": w.config.ResponseFormat,
+		"language":        w.config.Language,
+		"prompt":          w.config.Prompt,
+	}
+	if w.config.Temperature > 0 {
+		fields["temperature"] = strconv.FormatFloat(w.config.Temperature, 'f', -1, 64)
+	}
+	for key, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err := writer.WriteField(key, value); err != nil {
+			return "", fmt.Errorf("写入表单字段 %s 失败: %v", key, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("关闭表单失败: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.BaseURL+"/audio/transcriptions", body)
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if w.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+w.config.APIKey)
+	}
+
+	startTs := time.Now()
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("请求转写接口失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("转写接口返回错误, status: %d, body: %s", resp.StatusCode, string(respBody))
+	}
+
+	text := strings.TrimSpace(string(respBody))
+	if w.config.ResponseFormat == "json" {
+		var result transcriptionResponse
+		if err := json.Unmarshal(respBody, &result); err != nil {
+			return "", fmt.Errorf("解析响应失败: %v, body: %s", err, string(respBody))
+		}
+		if result.Error != nil {
+			return "", fmt.Errorf("转写接口返回错误: %s", result.Error.Message)
+		}
+		text = strings.TrimSpace(result.Text)
+	}
+
+	log.Debugf("whisper asr 识别完成, 音频时长: %dms, 耗时: %dms, 结果: %s",
+		len(pcmData)*1000/w.config.SampleRate, time.Since(startTs).Milliseconds(), text)
+	return text, nil
+}
@@ -0,0 +1,194 @@
+package whisper
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestServer 模拟 /v1/audio/transcriptions 接口
+func newTestServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request)) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/audio/transcriptions", handler)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestAdapter(t *testing.T, baseURL string) *WhisperAdapter {
+	t.Helper()
+	adapter, err := NewWhisperAdapter(map[string]interface{}{
+		"base_url":     baseURL + "/v1",
+		"api_key":      "test-key",
+		"model":        "whisper-test",
+		"min_audio_ms": 0,
+	})
+	if err != nil {
+		t.Fatalf("创建适配器失败: %v", err)
+	}
+	return adapter
+}
+
+func TestStreamingRecognizeEmitsFinalResultOnClose(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := r.FormValue("model"); got != "whisper-test" {
+			t.Errorf("model = %q", got)
+		}
+		if got := r.FormValue("language"); got != "zh" {
+			t.Errorf("language = %q", got)
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("读取音频文件失败: %v", err)
+			return
+		}
+		wav, _ := io.ReadAll(file)
+		if string(wav[0:4]) != "RIFF" || string(wav[8:12]) != "WAVE" {
+			t.Errorf("音频不是wav格式")
+		}
+		if dataLen := binary.LittleEndian.Uint32(wav[40:44]); dataLen != 2*1600 {
+			t.Errorf("wav数据长度 = %d, 期望 %d", dataLen, 2*1600)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"text":" 你好小智 "}`))
+	})
+
+	adapter := newTestAdapter(t, server.URL)
+	audioChan := make(chan []float32, 10)
+	resultChan, err := adapter.StreamingRecognize(context.Background(), audioChan)
+	if err != nil {
+		t.Fatalf("StreamingRecognize 失败: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		audioChan <- make([]float32, 800)
+	}
+	close(audioChan)
+
+	select {
+	case result, ok := <-resultChan:
+		if !ok {
+			t.Fatalf("结果通道被提前关闭")
+		}
+		if !result.IsFinal || result.Text != "你好小智" {
+			t.Fatalf("识别结果不符合预期: %+v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("等待识别结果超时")
+	}
+
+	if _, ok := <-resultChan; ok {
+		t.Fatalf("最终结果后结果通道应被关闭")
+	}
+}
+
+func TestStreamingRecognizeCancelDiscardsAudio(t *testing.T) {
+	requested := make(chan struct{}, 1)
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requested <- struct{}{}
+		w.Write([]byte(`{"text":"不应被请求"}`))
+	})
+
+	adapter := newTestAdapter(t, server.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	audioChan := make(chan []float32, 10)
+	resultChan, err := adapter.StreamingRecognize(ctx, audioChan)
+	if err != nil {
+		t.Fatalf("StreamingRecognize 失败: %v", err)
+	}
+	audioChan <- make([]float32, 800)
+
+	// 与 RestartAsrRecognition 一致：先取消上下文，旧的输入通道不再使用
+	cancel()
+
+	select {
+	case result, ok := <-resultChan:
+		if ok {
+			t.Fatalf("取消后不应返回结果: %+v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("取消后结果通道未关闭")
+	}
+
+	select {
+	case <-requested:
+		t.Fatalf("取消后不应请求转写接口")
+	default:
+	}
+}
+
+func TestStreamingRecognizeServerError(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":{"message":"boom"}}`))
+	})
+
+	adapter := newTestAdapter(t, server.URL)
+	audioChan := make(chan []float32, 1)
+	resultChan, _ := adapter.StreamingRecognize(context.Background(), audioChan)
+	audioChan <- make([]float32, 800)
+	close(audioChan)
+
+	select {
+	case result, ok := <-resultChan:
+		if !ok || result.Error == nil || result.Text != "" {
+			t.Fatalf("接口错误时应返回错误结果: %+v, ok: %v", result, ok)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("接口错误时未返回结果")
+	}
+	if _, ok := <-resultChan; ok {
+		t.Fatalf("返回错误后结果通道未关闭")
+	}
+}
+
+func TestProcessTextResponseFormat(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.FormValue("response_format"); got != "text" {
+			t.Errorf("response_format = %q", got)
+		}
+		w.Write([]byte("今天天气怎么样\n"))
+	})
+
+	adapter, err := NewWhisperAdapter(map[string]interface{}{
+		"base_url":        server.URL + "/v1/",
+		"response_format": "text",
+		"min_audio_ms":    float64(0),
+	})
+	if err != nil {
+		t.Fatalf("创建适配器失败: %v", err)
+	}
+
+	text, err := adapter.Process(make([]float32, 1600))
+	if err != nil {
+		t.Fatalf("Process 失败: %v", err)
+	}
+	if text != "今天天气怎么样" {
+		t.Fatalf("识别结果 = %q", text)
+	}
+}
+
+func TestProcessSkipsShortAudio(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("短音频不应请求转写接口")
+	})
+
+	adapter, err := NewWhisperAdapter(map[string]interface{}{"base_url": server.URL + "/v1"})
+	if err != nil {
+		t.Fatalf("创建适配器失败: %v", err)
+	}
+
+	// 默认最短 200ms，16000 采样率下 100ms 为 1600 个采样点
+	text, err := adapter.Process(make([]float32, 1600))
+	if err != nil || text != "" {
+		t.Fatalf("短音频应返回空结果, text: %q, err: %v", text, err)
+	}
+}
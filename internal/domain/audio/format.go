@@ -0,0 +1,42 @@
+package audio
+
+// MaxOpusFrameDuration 单个 opus 包最长 120ms
+const MaxOpusFrameDuration = 120
+
+// Format opus 音频格式, 为零值的字段表示与请求的格式一致
+type Format struct {
+	SampleRate    int
+	Channels      int
+	FrameDuration int
+}
+
+// Resolve 用 target 补全为零值的字段
+func (f Format) Resolve(target Format) Format {
+	if f.SampleRate == 0 {
+		f.SampleRate = target.SampleRate
+	}
+	if f.Channels == 0 {
+		f.Channels = target.Channels
+	}
+	if f.FrameDuration == 0 {
+		f.FrameDuration = target.FrameDuration
+	}
+	return f
+}
+
+// NeedTranscode 从 f 转为 target 是否需要解码后重新编码
+// opus 码流与编码时的采样率无关, 解码端可按任意支持的采样率输出, 因此只有帧长或声道数不一致时需要转码
+func (f Format) NeedTranscode(target Format) bool {
+	return f.FrameDuration != target.FrameDuration || channels(f.Channels) != channels(target.Channels)
+}
+
+func (f Format) frameSize() int {
+	return f.SampleRate * f.FrameDuration / 1000
+}
+
+func channels(n int) int {
+	if n <= 0 {
+		return 1
+	}
+	return n
+}
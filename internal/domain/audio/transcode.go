@@ -0,0 +1,164 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+
+	"xiaozhi-esp32-server-golang/internal/util"
+	log "xiaozhi-esp32-server-golang/logger"
+
+	"gopkg.in/hraban/opus.v2"
+)
+
+// Transcoder 将 opus 帧转为目标采样率和帧长: 解码、重采样后按目标帧长重新编码, 仅支持单声道
+// 编码器取自编码器池, 用完后需调用 Close 归还
+type Transcoder struct {
+	from    Format
+	to      Format
+	decoder *opus.Decoder
+	encoder *opus.Encoder
+	decoded []float32
+	pcm     []float32 //未凑满一帧的目标采样率pcm
+	buf     []byte
+}
+
+func NewTranscoder(from Format, to Format) (*Transcoder, error) {
+	decoder, err := opus.NewDecoder(from.SampleRate, 1)
+	if err != nil {
+		return nil, fmt.Errorf("创建解码器失败: %v", err)
+	}
+	encoder, err := util.GetOpusEncoder(to.SampleRate, 1)
+	if err != nil {
+		return nil, fmt.Errorf("创建编码器失败: %v", err)
+	}
+	return &Transcoder{
+		from:    from,
+		to:      to,
+		decoder: decoder,
+		encoder: encoder,
+		decoded: make([]float32, from.SampleRate*MaxOpusFrameDuration/1000),
+		buf:     make([]byte, to.frameSize()*2),
+	}, nil
+}
+
+// Write 转换一帧, 返回凑满目标帧长的帧, 可能为空
+func (t *Transcoder) Write(frame []byte) ([][]byte, error) {
+	n, err := t.decoder.DecodeFloat32(frame, t.decoded)
+	if err != nil {
+		return nil, fmt.Errorf("解码音频失败: %v", err)
+	}
+	pcm := t.decoded[:n]
+	if t.from.SampleRate != t.to.SampleRate {
+		pcm = util.ResampleLinearFloat32(pcm, t.from.SampleRate, t.to.SampleRate)
+	}
+	t.pcm = append(t.pcm, pcm...)
+
+	var frames [][]byte
+	frameSize := t.to.frameSize()
+	for len(t.pcm) >= frameSize {
+		encoded, err := t.encode(t.pcm[:frameSize])
+		if err != nil {
+			return frames, err
+		}
+		frames = append(frames, encoded)
+		t.pcm = t.pcm[frameSize:]
+	}
+	return frames, nil
+}
+
+// Flush 剩余不足一帧的pcm补静音后编码
+func (t *Transcoder) Flush() ([]byte, error) {
+	if len(t.pcm) == 0 {
+		return nil, nil
+	}
+	pcm := make([]float32, t.to.frameSize())
+	copy(pcm, t.pcm)
+	t.pcm = nil
+	return t.encode(pcm)
+}
+
+// Close 归还编码器, 之后不能再使用
+func (t *Transcoder) Close() {
+	util.PutOpusEncoder(t.to.SampleRate, 1, t.encoder)
+	t.encoder = nil
+}
+
+func (t *Transcoder) encode(pcm []float32) ([]byte, error) {
+	n, err := t.encoder.Encode(util.Float32SliceToInt16Slice(pcm), t.buf)
+	if err != nil {
+		return nil, fmt.Errorf("编码音频失败: %v", err)
+	}
+	frame := make([]byte, n)
+	copy(frame, t.buf[:n])
+	return frame, nil
+}
+
+// TranscodeAll 转换全部帧
+func TranscodeAll(frames [][]byte, from Format, to Format) ([][]byte, error) {
+	t, err := NewTranscoder(from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+	var result [][]byte
+	for _, frame := range frames {
+		encoded, err := t.Write(frame)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, encoded...)
+	}
+	last, err := t.Flush()
+	if err != nil {
+		return nil, err
+	}
+	if last != nil {
+		result = append(result, last)
+	}
+	return result, nil
+}
+
+// TranscodeStream 转换音频帧流, 输入结束或 ctx 取消后关闭输出
+func TranscodeStream(ctx context.Context, input <-chan []byte, from Format, to Format) (chan []byte, error) {
+	t, err := NewTranscoder(from, to)
+	if err != nil {
+		return nil, err
+	}
+	outputChan := make(chan []byte, 100)
+	go func() {
+		defer close(outputChan)
+		defer t.Close()
+		send := func(frames ...[]byte) bool {
+			for _, frame := range frames {
+				select {
+				case <-ctx.Done():
+					return false
+				case outputChan <- frame:
+				}
+			}
+			return true
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case frame, ok := <-input:
+				if !ok {
+					if last, err := t.Flush(); err == nil && last != nil {
+						send(last)
+					}
+					return
+				}
+				frames, err := t.Write(frame)
+				if err != nil {
+					log.Errorf("音频转码失败: %v", err)
+					return
+				}
+				if !send(frames...) {
+					return
+				}
+			}
+		}
+	}()
+	return outputChan, nil
+}
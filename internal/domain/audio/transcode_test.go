@@ -0,0 +1,153 @@
+package audio
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"xiaozhi-esp32-server-golang/internal/util"
+
+	"gopkg.in/hraban/opus.v2"
+)
+
+// sentenceDuration 基准测试中一句TTS音频的时长(毫秒)
+const sentenceDuration = 3000
+
+// sinePCM 生成一帧 440Hz 正弦波
+func sinePCM(sampleRate int, frameDuration int) []int16 {
+	pcm := make([]int16, sampleRate*frameDuration/1000)
+	for i := range pcm {
+		pcm[i] = int16(math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate)) * 8000)
+	}
+	return pcm
+}
+
+// encodeSentence 按 format 编码一句TTS音频
+func encodeSentence(tb testing.TB, format Format, duration int) [][]byte {
+	enc, err := opus.NewEncoder(format.SampleRate, 1, opus.AppAudio)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	pcm := sinePCM(format.SampleRate, format.FrameDuration)
+	var frames [][]byte
+	for i := 0; i < duration/format.FrameDuration; i++ {
+		buf := make([]byte, len(pcm)*2)
+		n, err := enc.Encode(pcm, buf)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		frames = append(frames, buf[:n])
+	}
+	return frames
+}
+
+func TestFormatNeedTranscode(t *testing.T) {
+	device := Format{SampleRate: 16000, Channels: 1, FrameDuration: 60}
+	tests := []struct {
+		native Format
+		want   bool
+	}{
+		{native: Format{}, want: false},
+		{native: Format{SampleRate: 24000, Channels: 1, FrameDuration: 60}, want: false},
+		{native: Format{SampleRate: 24000}, want: false},
+		{native: Format{SampleRate: 24000, FrameDuration: 20}, want: true},
+		{native: Format{Channels: 2}, want: true},
+	}
+	for _, tt := range tests {
+		if got := tt.native.Resolve(device).NeedTranscode(device); got != tt.want {
+			t.Fatalf("%+v NeedTranscode = %v, want %v", tt.native, got, tt.want)
+		}
+	}
+}
+
+func TestTranscodeStream(t *testing.T) {
+	from := Format{SampleRate: 24000, Channels: 1, FrameDuration: 20}
+	to := Format{SampleRate: 16000, Channels: 1, FrameDuration: 60}
+	input := make(chan []byte, 10)
+	for _, frame := range encodeSentence(t, from, 200) {
+		input <- frame
+	}
+	close(input)
+
+	output, err := TranscodeStream(context.Background(), input, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := opus.NewDecoder(to.SampleRate, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcm := make([]int16, to.SampleRate*MaxOpusFrameDuration/1000)
+	count := 0
+	for frame := range output {
+		n, err := decoder.Decode(frame, pcm)
+		if err != nil || n != to.frameSize() {
+			t.Fatalf("decoded = %d, %v", n, err)
+		}
+		count++
+	}
+	// 200ms 转为60ms帧后为3个整帧加1个补静音的帧
+	if count != 4 {
+		t.Fatalf("frames = %d", count)
+	}
+}
+
+// 以下基准测试对比每路TTS流的CPU开销, 一次操作为编码或转码一句3秒的音频
+// RunParallel 模拟多路并发, 可用 -cpu 调整并发度, 需要链接真实的 libopus:
+// go test -run '^$' -bench Stream -benchmem -cpu 1,4,8 ./internal/domain/audio/
+
+// BenchmarkStreamEncodeNewEncoder 优化前: 每句TTS新建编码器
+func BenchmarkStreamEncodeNewEncoder(b *testing.B) {
+	benchmarkStreamEncode(b, func(sampleRate int) (*opus.Encoder, func(), error) {
+		enc, err := opus.NewEncoder(sampleRate, 1, opus.AppAudio)
+		return enc, func() {}, err
+	})
+}
+
+// BenchmarkStreamEncodePooledEncoder 优化后: 从编码器池获取
+func BenchmarkStreamEncodePooledEncoder(b *testing.B) {
+	benchmarkStreamEncode(b, func(sampleRate int) (*opus.Encoder, func(), error) {
+		enc, err := util.GetOpusEncoder(sampleRate, 1)
+		return enc, func() { util.PutOpusEncoder(sampleRate, 1, enc) }, err
+	})
+}
+
+func benchmarkStreamEncode(b *testing.B, getEncoder func(sampleRate int) (*opus.Encoder, func(), error)) {
+	format := Format{SampleRate: 16000, Channels: 1, FrameDuration: 60}
+	pcm := sinePCM(format.SampleRate, format.FrameDuration)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		buf := make([]byte, len(pcm)*2)
+		for pb.Next() {
+			enc, put, err := getEncoder(format.SampleRate)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			for i := 0; i < sentenceDuration/format.FrameDuration; i++ {
+				if _, err := enc.Encode(pcm, buf); err != nil {
+					b.Error(err)
+				}
+			}
+			put()
+		}
+	})
+}
+
+// BenchmarkStreamTranscodeMismatch 直接输出 opus 的提供者(如小智)原生格式与设备不一致时转码
+// 格式一致时直接转发, 没有编解码开销; 优化前小智的帧不论格式都直接转发, 格式不一致时设备无法正确播放
+func BenchmarkStreamTranscodeMismatch(b *testing.B) {
+	from := Format{SampleRate: 24000, Channels: 1, FrameDuration: 20}
+	to := Format{SampleRate: 16000, Channels: 1, FrameDuration: 60}
+	frames := encodeSentence(b, from, sentenceDuration)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := TranscodeAll(frames, from, to); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
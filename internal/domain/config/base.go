@@ -3,6 +3,7 @@ package user_config
 import (
 	"fmt"
 	"os"
+	"sync"
 
 	"xiaozhi-esp32-server-golang/internal/domain/config/manager"
 	userconfig_redis "xiaozhi-esp32-server-golang/internal/domain/config/redis"
@@ -16,6 +17,22 @@ type Config struct {
 	Parameters map[string]interface{} `json:"parameters"` // 存储相关配置参数
 }
 
+// ProviderFactory 自定义用户配置提供者的工厂函数
+type ProviderFactory func(config map[string]interface{}) (UserConfigProvider, error)
+
+var (
+	customProviders   = make(map[string]ProviderFactory)
+	customProvidersMu sync.RWMutex
+)
+
+// RegisterProvider 注册自定义用户配置提供者, 如测试用的进程内提供者
+// config_provider.type 为 providerType 时使用该工厂创建提供者, 不能覆盖内置的 redis/manager
+func RegisterProvider(providerType string, factory ProviderFactory) {
+	customProvidersMu.Lock()
+	defer customProvidersMu.Unlock()
+	customProviders[providerType] = factory
+}
+
 func GetProvider(sType string) (UserConfigProvider, error) {
 	config := make(map[string]interface{})
 	if sType == "manager" {
@@ -62,6 +79,12 @@ func GetUserConfigProvider(providerType string, config map[string]interface{}) (
 		}
 		return provider, nil
 	default:
+		customProvidersMu.RLock()
+		factory, ok := customProviders[providerType]
+		customProvidersMu.RUnlock()
+		if ok {
+			return factory(config)
+		}
 		return nil, fmt.Errorf("不支持的用户配置提供者: %s", providerType)
 	}
 }
@@ -88,8 +88,10 @@ func (c *ConfigManager) GetUserConfig(ctx context.Context, deviceID string) (typ
 				Provider string `json:"provider"`
 				JsonData string `json:"json_data"`
 			} `json:"tts"`
-			Prompt  string `json:"prompt"`
-			AgentId string `json:"agent_id"`
+			Prompt      string `json:"prompt"`
+			AgentId     string `json:"agent_id"`
+			RecordAudio bool   `json:"record_audio"`
+			EmotionMap  string `json:"emotion_map"`
 		} `json:"data"`
 	}
 
@@ -129,7 +131,13 @@ func (c *ConfigManager) GetUserConfig(ctx context.Context, deviceID string) (typ
 			Provider: response.Data.VAD.Provider,
 			Config:   parseJsonData(response.Data.VAD.JsonData),
 		},
-		AgentId: response.Data.AgentId,
+		AgentId:     response.Data.AgentId,
+		RecordAudio: response.Data.RecordAudio,
+	}
+	if response.Data.EmotionMap != "" {
+		if err := json.Unmarshal([]byte(response.Data.EmotionMap), &config.EmotionMap); err != nil {
+			log.Log().Warn("解析表情映射失败", "error", err, "json", response.Data.EmotionMap)
+		}
 	}
 
 	log.Log().Infof("成功获取设备配置: deviceId: %s, config: %+v", deviceID, config)
@@ -0,0 +1,63 @@
+package manager
+
+import (
+	"fmt"
+
+	"xiaozhi-esp32-server-golang/internal/domain/providers"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+// handleProviderListRequest 处理已注册提供者列表请求，body 中可选 type 指定类别(vad/asr/llm/tts)
+func (c *WebSocketClient) handleProviderListRequest(request *WebSocketRequest) {
+	kind := ""
+	if request.Body != nil {
+		kind, _ = request.Body["type"].(string)
+	}
+
+	list, err := providers.List(kind)
+	if err != nil {
+		if err := c.SendResponse(request.ID, 400, nil, err.Error()); err != nil {
+			log.Errorf("发送错误响应失败: %v", err)
+		}
+		return
+	}
+
+	response := map[string]interface{}{
+		"providers": list,
+	}
+	if err := c.SendResponse(request.ID, 200, response, ""); err != nil {
+		log.Errorf("发送提供者列表响应失败: %v", err)
+	}
+}
+
+// handleProviderValidateRequest 处理配置校验请求，body: {type, provider, config}
+func (c *WebSocketClient) handleProviderValidateRequest(request *WebSocketRequest) {
+	var req struct {
+		Type     string                 `json:"type"`
+		Provider string                 `json:"provider"`
+		Config   map[string]interface{} `json:"config"`
+	}
+	if err := mapToStruct(request.Body, &req); err != nil || req.Type == "" || req.Provider == "" {
+		if err := c.SendResponse(request.ID, 400, nil, "缺少type或provider参数"); err != nil {
+			log.Errorf("发送错误响应失败: %v", err)
+		}
+		return
+	}
+	if req.Config == nil {
+		req.Config = map[string]interface{}{}
+	}
+
+	response := map[string]interface{}{
+		"type":     req.Type,
+		"provider": req.Provider,
+		"valid":    true,
+	}
+	if err := providers.Validate(req.Type, req.Provider, req.Config); err != nil {
+		log.Infof("配置校验未通过, type: %s, provider: %s, err: %v", req.Type, req.Provider, err)
+		response["valid"] = false
+		response["message"] = fmt.Sprintf("%v", err)
+	}
+	if err := c.SendResponse(request.ID, 200, response, ""); err != nil {
+		log.Errorf("发送配置校验响应失败: %v", err)
+	}
+}
@@ -7,12 +7,14 @@ import (
 const (
 	EventDeviceOnlinePath  = "/api/device/active"
 	EventDeviceOfflinePath = "/api/device/inactive"
+	EventConversationPath  = "/api/device/conversation"
 	EventInjectMessagePath = "/api/device/message"
 )
 
 var event2Path = map[string]string{
 	types.EventDeviceOnline:  EventDeviceOnlinePath,
 	types.EventDeviceOffline: EventDeviceOfflinePath,
+	types.EventConversation:  EventConversationPath,
 }
 
 var path2Event = map[string]string{
@@ -491,6 +491,14 @@ func (c *WebSocketClient) handleDefaultRequest(request *WebSocketRequest) {
 		// 处理MCP工具列表请求
 		c.handleMcpToolListRequest(request)
 
+	case "/api/providers":
+		// 已注册的ASR/TTS/VAD/LLM提供者及配置描述
+		c.handleProviderListRequest(request)
+
+	case "/api/providers/validate":
+		// 按提供者配置描述校验配置
+		c.handleProviderValidateRequest(request)
+
 	case "/api/server/info":
 		// 返回服务器信息
 		response := map[string]interface{}{
@@ -6,11 +6,13 @@ type EventHandler func(ctx context.Context, eventType string, eventData map[stri
 
 // 上行push事件 主程序 => 管理内控
 const (
-	EventDeviceOnline  = "/api/device/active"   //设备上线
-	EventDeviceOffline = "/api/device/inactive" //设备下线
+	EventDeviceOnline  = "/api/device/active"       //设备上线
+	EventDeviceOffline = "/api/device/inactive"     //设备下线
+	EventConversation  = "/api/device/conversation" //上报一轮完整对话
 )
 
 // 下行pull事件 管理内控 => 主程序
 const (
-	EventHandleMessageInject = "/api/device/inject_msg" //处理消息注入
+	EventHandleMessageInject = "/api/device/inject_msg"     //处理消息注入
+	EventHandleConfigChanged = "/api/device/config_changed" //设备配置变更, 重新加载在线设备的配置
 )
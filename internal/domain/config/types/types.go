@@ -26,10 +26,12 @@ type ConfigItem struct {
 }
 
 type UConfig struct {
-	SystemPrompt string    `json:"system_prompt"`
-	Asr          AsrConfig `json:"asr"`
-	Tts          TtsConfig `json:"tts"`
-	Llm          LlmConfig `json:"llm"`
-	Vad          VadConfig `json:"vad"`
-	AgentId      string    `json:"agent_id"` //所属agent_id
+	SystemPrompt string            `json:"system_prompt"`
+	Asr          AsrConfig         `json:"asr"`
+	Tts          TtsConfig         `json:"tts"`
+	Llm          LlmConfig         `json:"llm"`
+	Vad          VadConfig         `json:"vad"`
+	AgentId      string            `json:"agent_id"`     //所属agent_id
+	RecordAudio  bool              `json:"record_audio"` //智能体是否开启对话录音
+	EmotionMap   map[string]string `json:"emotion_map"`  //智能体的表情映射, 标记(emoji或标签)到设备表情, 覆盖全局配置
 }
@@ -0,0 +1,40 @@
+package types
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// ConfigInt 读取提供者配置中的整数
+// 配置来自 yaml、json 或管理后台, 同一个值可能是 int、float64、json.Number 或数字字符串, 无法解析时返回 0
+func ConfigInt(value interface{}) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float32:
+		return int(v)
+	case float64:
+		return int(v)
+	case json.Number:
+		return parseInt(v.String())
+	case string:
+		return parseInt(v)
+	}
+	return 0
+}
+
+func parseInt(s string) int {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return int(f)
+	}
+	return 0
+}
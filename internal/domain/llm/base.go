@@ -5,9 +5,6 @@ import (
 	"fmt"
 
 	"github.com/cloudwego/eino/schema"
-
-	"xiaozhi-esp32-server-golang/constants"
-	"xiaozhi-esp32-server-golang/internal/domain/llm/eino_llm"
 )
 
 // LLMProvider 大语言模型提供者接口
@@ -34,19 +31,17 @@ type LLMFactory interface {
 }
 
 // GetLLMProvider 创建LLM提供者
-// 统一使用EinoLLMProvider处理所有类型
+// 根据配置中的type查找已注册的提供者，openai/ollama等类型统一由EinoLLMProvider处理
 func GetLLMProvider(providerName string, config map[string]interface{}) (LLMProvider, error) {
-	llmType := config["type"].(string)
-	switch llmType {
-	case constants.LlmTypeOpenai, constants.LlmTypeOllama, constants.LlmTypeEinoLLM, constants.LlmTypeEino:
-		// 统一使用 EinoLLMProvider 处理所有类型
-		provider, err := eino_llm.NewEinoLLMProvider(config)
-		if err != nil {
-			return nil, fmt.Errorf("创建Eino LLM提供者失败: %v", err)
-		}
-		return provider, nil
+	llmType, _ := config["type"].(string)
+	factory, ok := providers.Lookup(llmType)
+	if !ok {
+		return nil, fmt.Errorf("不支持的LLM提供者: %s", llmType)
+	}
+	if err := providers.Validate(llmType, config); err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("不支持的LLM提供者: %s", llmType)
+	return factory(config)
 }
 
 // Config LLM配置结构
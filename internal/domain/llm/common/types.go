@@ -18,4 +18,6 @@ type LLMResponseStruct struct {
 	IsStart   bool              `json:"is_start"`
 	IsEnd     bool              `json:"is_end"`
 	ToolCalls []schema.ToolCall `json:"tool_calls,omitempty"`
+	Emotion   string            `json:"emotion,omitempty"` //句子的表情, 在句子之前下发给设备
+	Speaker   string            `json:"speaker,omitempty"` //句子的说话角色, TTS按角色选择音色
 }
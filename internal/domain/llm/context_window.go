@@ -0,0 +1,199 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/cloudwego/eino/schema"
+
+	config_types "xiaozhi-esp32-server-golang/internal/domain/config/types"
+	"xiaozhi-esp32-server-golang/internal/domain/llm/tokenizer"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+const (
+	// 每条消息的角色、分隔符等固定开销
+	messageOverheadTokens = 4
+	// 未配置 max_tool_result_tokens 时, 单条工具结果最多占对话历史预算的比例
+	defaultToolResultRatio = 4
+)
+
+// ContextWindow 按模型的上下文长度裁剪对话历史
+// 预算 = context_length - max_tokens(为回复预留) - 系统提示词 - 工具描述 - 本轮用户消息
+type ContextWindow struct {
+	ContextLength       int //模型上下文长度
+	MaxTokens           int //为模型回复预留的token数
+	MaxToolResultTokens int //单条工具结果的token上限, 超出时截断
+	Tokenizer           tokenizer.Tokenizer
+}
+
+// NewContextWindow 根据LLM配置创建上下文窗口, 未配置 context_length 时返回 nil, 按消息条数裁剪
+func NewContextWindow(config map[string]interface{}) (*ContextWindow, error) {
+	contextLength := config_types.ConfigInt(config["context_length"])
+	if contextLength <= 0 {
+		return nil, nil
+	}
+	tokenizerName, _ := config["tokenizer"].(string)
+	t, err := tokenizer.Get(tokenizerName)
+	if err != nil {
+		return nil, err
+	}
+	w := &ContextWindow{
+		ContextLength:       contextLength,
+		MaxTokens:           config_types.ConfigInt(config["max_tokens"]),
+		MaxToolResultTokens: config_types.ConfigInt(config["max_tool_result_tokens"]),
+		Tokenizer:           t,
+	}
+	if w.MaxTokens >= w.ContextLength {
+		return nil, fmt.Errorf("max_tokens(%d) 需小于 context_length(%d)", w.MaxTokens, w.ContextLength)
+	}
+	return w, nil
+}
+
+// CountMessage 计算一条消息的token数
+func (w *ContextWindow) CountMessage(msg *schema.Message) int {
+	if msg == nil {
+		return 0
+	}
+	tokens := messageOverheadTokens + w.Tokenizer.CountTokens(msg.Content)
+	for _, toolCall := range msg.ToolCalls {
+		tokens += w.Tokenizer.CountTokens(toolCall.Function.Name) + w.Tokenizer.CountTokens(toolCall.Function.Arguments)
+	}
+	return tokens
+}
+
+// CountTools 计算工具描述的token数
+func (w *ContextWindow) CountTools(tools []*schema.ToolInfo) int {
+	tokens := 0
+	for _, tool := range tools {
+		if tool == nil {
+			continue
+		}
+		tokens += w.Tokenizer.CountTokens(tool.Name) + w.Tokenizer.CountTokens(tool.Desc)
+		if tool.ParamsOneOf != nil {
+			if params, err := tool.ParamsOneOf.ToOpenAPIV3(); err == nil {
+				if data, err := json.Marshal(params); err == nil {
+					tokens += w.Tokenizer.CountTokens(string(data))
+				}
+			}
+		}
+	}
+	return tokens
+}
+
+// Fit 从最新的消息往前选取不超过预算的对话历史
+// 带 tool_calls 的 assistant 消息与其后的 tool 消息作为整体保留或丢弃, 保证两者始终成对
+// 超过上限的工具结果先截断再计入预算, 最新的一组单独超出预算时截断内容后保留, 不修改原消息
+func (w *ContextWindow) Fit(systemPrompt string, history []*schema.Message, userMessage *schema.Message, tools []*schema.ToolInfo) []*schema.Message {
+	reserved := w.MaxTokens + messageOverheadTokens + w.Tokenizer.CountTokens(systemPrompt) + w.CountMessage(userMessage) + w.CountTools(tools)
+	budget := w.ContextLength - reserved
+	if budget <= 0 {
+		log.Warnf("上下文长度 %d 不足以容纳系统提示词与工具描述(%d tokens), 不携带对话历史", w.ContextLength, reserved)
+		return []*schema.Message{}
+	}
+
+	maxToolResultTokens := w.MaxToolResultTokens
+	if maxToolResultTokens <= 0 {
+		maxToolResultTokens = budget / defaultToolResultRatio
+	}
+
+	groups := groupMessages(history)
+	used := 0
+	start := len(groups)
+	for i := len(groups) - 1; i >= 0; i-- {
+		for j, msg := range groups[i] {
+			if msg.Role == schema.Tool {
+				groups[i][j] = w.truncateToolResult(msg, maxToolResultTokens)
+			}
+		}
+		tokens := 0
+		for _, msg := range groups[i] {
+			tokens += w.CountMessage(msg)
+		}
+		if used+tokens > budget {
+			// 最新的一组必须保留, 如工具调用后的后续请求需要带上本轮的工具结果
+			if i == len(groups)-1 {
+				log.Warnf("最新的对话消息约 %d tokens, 超出预算 %d, 截断后保留", tokens, budget)
+				groups[i] = w.truncateGroup(groups[i], budget)
+				start = i
+			}
+			break
+		}
+		used += tokens
+		start = i
+	}
+
+	result := make([]*schema.Message, 0, len(history))
+	for _, group := range groups[start:] {
+		result = append(result, group...)
+	}
+	if start > 0 {
+		log.Debugf("对话历史超出token预算 %d, 保留 %d/%d 条消息, 约 %d tokens", budget, len(result), len(history), used)
+	}
+	return result
+}
+
+// truncateGroup 按预算截断一组消息的内容, 返回副本
+// 角色、tool_calls 等固定开销不截断, 剩余预算优先满足较短的消息, 较长的消息平分余下的预算
+func (w *ContextWindow) truncateGroup(group []*schema.Message, budget int) []*schema.Message {
+	result := make([]*schema.Message, len(group))
+	copy(result, group)
+
+	available := budget
+	var indexes []int
+	for i, msg := range group {
+		available -= w.CountMessage(msg) - w.Tokenizer.CountTokens(msg.Content)
+		if msg.Content != "" {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Slice(indexes, func(a, b int) bool {
+		return w.Tokenizer.CountTokens(group[indexes[a]].Content) < w.Tokenizer.CountTokens(group[indexes[b]].Content)
+	})
+	for n, i := range indexes {
+		share := available / (len(indexes) - n)
+		if share < 0 {
+			share = 0
+		}
+		tokens := w.Tokenizer.CountTokens(group[i].Content)
+		if tokens > share {
+			truncated := *group[i]
+			truncated.Content = tokenizer.Truncate(w.Tokenizer, group[i].Content, share)
+			result[i] = &truncated
+			tokens = share
+		}
+		available -= tokens
+	}
+	return result
+}
+
+// groupMessages 将带 tool_calls 的 assistant 消息与紧随其后的 tool 消息分为一组, 其余消息各自一组
+func groupMessages(messages []*schema.Message) [][]*schema.Message {
+	groups := make([][]*schema.Message, 0, len(messages))
+	for _, msg := range messages {
+		if msg == nil {
+			continue
+		}
+		if msg.Role == schema.Tool && len(groups) > 0 {
+			last := groups[len(groups)-1]
+			if last[0].Role == schema.Assistant && len(last[0].ToolCalls) > 0 {
+				groups[len(groups)-1] = append(last, msg)
+				continue
+			}
+		}
+		groups = append(groups, []*schema.Message{msg})
+	}
+	return groups
+}
+
+// truncateToolResult 截断过长的工具结果, 返回副本
+func (w *ContextWindow) truncateToolResult(msg *schema.Message, maxTokens int) *schema.Message {
+	tokens := w.Tokenizer.CountTokens(msg.Content)
+	if tokens <= maxTokens {
+		return msg
+	}
+	truncated := *msg
+	truncated.Content = tokenizer.Truncate(w.Tokenizer, msg.Content, maxTokens) + fmt.Sprintf("\n...(工具结果过长已截断, 原长度约 %d tokens)", tokens)
+	return &truncated
+}
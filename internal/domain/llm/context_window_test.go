@@ -0,0 +1,115 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+
+	"xiaozhi-esp32-server-golang/internal/domain/llm/tokenizer"
+)
+
+func TestNewContextWindow(t *testing.T) {
+	w, err := NewContextWindow(map[string]interface{}{"max_tokens": 500})
+	if err != nil || w != nil {
+		t.Fatalf("未配置 context_length 时应按条数裁剪, w = %+v, err = %v", w, err)
+	}
+	if _, err := NewContextWindow(map[string]interface{}{"context_length": 500, "max_tokens": 500}); err == nil {
+		t.Fatal("max_tokens 不小于 context_length 时应返回错误")
+	}
+	if _, err := NewContextWindow(map[string]interface{}{"context_length": 4096, "tokenizer": "unknown"}); err == nil {
+		t.Fatal("未知分词器应返回错误")
+	}
+	w, err = NewContextWindow(map[string]interface{}{"context_length": float64(4096), "max_tokens": 500})
+	if err != nil || w.ContextLength != 4096 || w.MaxTokens != 500 {
+		t.Fatalf("w = %+v, err = %v", w, err)
+	}
+	// 管理后台下发的配置中数字可能是字符串
+	w, err = NewContextWindow(map[string]interface{}{"context_length": "4096", "max_tokens": " 500 "})
+	if err != nil || w == nil || w.ContextLength != 4096 || w.MaxTokens != 500 {
+		t.Fatalf("w = %+v, err = %v", w, err)
+	}
+}
+
+func TestContextWindowFit(t *testing.T) {
+	w := &ContextWindow{ContextLength: 100, Tokenizer: tokenizer.RuneTokenizer{}}
+
+	toolCall := &schema.Message{
+		Role:      schema.Assistant,
+		ToolCalls: []schema.ToolCall{{ID: "call_1", Function: schema.FunctionCall{Name: "read_file", Arguments: "{}"}}},
+	}
+	history := []*schema.Message{
+		{Role: schema.User, Content: strings.Repeat("早", 30)},
+		{Role: schema.Assistant, Content: strings.Repeat("早", 30)},
+		{Role: schema.User, Content: "读一下文件"},
+		toolCall,
+		{Role: schema.Tool, ToolCallID: "call_1", Content: strings.Repeat("字", 1000)},
+		{Role: schema.Assistant, Content: "文件内容很长"},
+	}
+
+	result := w.Fit("", history, nil, nil)
+	if len(result) != 4 || result[1] != toolCall {
+		t.Fatalf("应保留最近的工具调用及其结果, result = %d 条", len(result))
+	}
+	toolResult := result[2]
+	if toolResult.Role != schema.Tool || !strings.Contains(toolResult.Content, "已截断") {
+		t.Fatalf("过长的工具结果应截断, content 长度 = %d", len(toolResult.Content))
+	}
+	if len(history[4].Content) != len(strings.Repeat("字", 1000)) {
+		t.Fatal("截断不应修改原消息")
+	}
+
+	// 预算不足以容纳工具结果时, assistant 的 tool_calls 与 tool 消息一起丢弃
+	w.MaxToolResultTokens = 80
+	result = w.Fit("", history, nil, nil)
+	for _, msg := range result {
+		if msg.Role == schema.Tool || len(msg.ToolCalls) > 0 {
+			t.Fatalf("tool_calls 与 tool 消息应成对丢弃, result = %+v", result)
+		}
+	}
+	if len(result) != 1 || result[0].Content != "文件内容很长" {
+		t.Fatalf("result = %+v", result)
+	}
+}
+
+func TestContextWindowFitKeepsNewestGroup(t *testing.T) {
+	w := &ContextWindow{ContextLength: 100, MaxToolResultTokens: 1000, Tokenizer: tokenizer.RuneTokenizer{}}
+
+	// 单条消息超出预算时截断后保留
+	history := []*schema.Message{
+		{Role: schema.User, Content: "你好"},
+		{Role: schema.Assistant, Content: strings.Repeat("长", 500)},
+	}
+	result := w.Fit("", history, nil, nil)
+	if len(result) != 1 || result[0].Role != schema.Assistant {
+		t.Fatalf("应保留最新的消息, result = %d 条", len(result))
+	}
+	if tokens := w.CountMessage(result[0]); tokens > 96 || tokens < 90 {
+		t.Fatalf("截断后约 %d tokens, 应接近预算 96", tokens)
+	}
+	if len([]rune(history[1].Content)) != 500 {
+		t.Fatal("截断不应修改原消息")
+	}
+
+	// 工具调用后的后续请求: 最新的工具调用及结果超出预算时成对保留, 截断结果
+	toolCall := &schema.Message{
+		Role:      schema.Assistant,
+		ToolCalls: []schema.ToolCall{{ID: "call_1", Function: schema.FunctionCall{Name: "read_file", Arguments: "{}"}}},
+	}
+	history = []*schema.Message{
+		{Role: schema.User, Content: "读一下文件"},
+		toolCall,
+		{Role: schema.Tool, ToolCallID: "call_1", Content: strings.Repeat("字", 1000)},
+	}
+	result = w.Fit("系统提示词", history, nil, nil)
+	if len(result) != 2 || result[0] != toolCall || result[1].Role != schema.Tool {
+		t.Fatalf("应成对保留最新的工具调用及结果, result = %+v", result)
+	}
+	used := 0
+	for _, msg := range result {
+		used += w.CountMessage(msg)
+	}
+	if budget := 100 - messageOverheadTokens - 5; used > budget || result[1].Content == "" {
+		t.Fatalf("截断后 %d tokens, 预算 %d", used, budget)
+	}
+}
@@ -16,9 +16,39 @@ import (
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 
+	"xiaozhi-esp32-server-golang/constants"
+	"xiaozhi-esp32-server-golang/internal/domain/llm"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
 	log "xiaozhi-esp32-server-golang/logger"
 )
 
+func init() {
+	configSchema := registry.ConfigSchema{
+		Description: "基于Eino框架的LLM，支持OpenAI兼容接口与Ollama",
+		Fields: []registry.ConfigField{
+			{Name: "type", Type: registry.FieldTypeString, Required: true, Description: "接口类型"},
+			{Name: "model_name", Type: registry.FieldTypeString, Required: true, Description: "模型名称"},
+			{Name: "api_key", Type: registry.FieldTypeString, Description: "API密钥"},
+			{Name: "base_url", Type: registry.FieldTypeString, Description: "API基础地址"},
+			{Name: "max_tokens", Type: registry.FieldTypeNumber, Default: 500, Description: "最大生成token数"},
+			{Name: "streamable", Type: registry.FieldTypeBool, Default: true, Description: "是否流式输出"},
+			{Name: "context_length", Type: registry.FieldTypeNumber, Description: "模型上下文长度, 配置后按token预算裁剪对话历史, 否则按条数"},
+			{Name: "tokenizer", Type: registry.FieldTypeString, Default: "estimate", Description: "token计数方式, estimate 或 rune"},
+			{Name: "max_tool_result_tokens", Type: registry.FieldTypeNumber, Description: "单条工具结果的token上限, 超出时截断, 默认为对话历史预算的1/4"},
+		},
+	}
+	factory := func(config map[string]interface{}) (llm.LLMProvider, error) {
+		provider, err := NewEinoLLMProvider(config)
+		if err != nil {
+			return nil, fmt.Errorf("创建Eino LLM提供者失败: %v", err)
+		}
+		return provider, nil
+	}
+	for _, llmType := range []string{constants.LlmTypeOpenai, constants.LlmTypeOllama, constants.LlmTypeEinoLLM, constants.LlmTypeEino} {
+		llm.Register(llmType, factory, configSchema)
+	}
+}
+
 // EinoLLMProvider 基于Eino框架的LLM提供者
 // 直接使用Eino的ChatModel接口和类型，支持openai和ollama
 type EinoLLMProvider struct {
@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// DeviceEmotionEmoji 设备支持的表情及对应的 emoji, 与 ESP32 固件的表情名称一致
+var DeviceEmotionEmoji = map[string]string{
+	"neutral":     "😶",
+	"happy":       "🙂",
+	"laughing":    "😆",
+	"funny":       "😂",
+	"sad":         "😔",
+	"angry":       "😠",
+	"crying":      "😭",
+	"loving":      "😍",
+	"embarrassed": "😳",
+	"surprised":   "😯",
+	"shocked":     "😱",
+	"thinking":    "🤔",
+	"winking":     "😉",
+	"cool":        "😎",
+	"relaxed":     "😌",
+	"delicious":   "🤤",
+	"kissy":       "😘",
+	"confident":   "😏",
+	"sleepy":      "😴",
+	"silly":       "😜",
+	"confused":    "🙄",
+}
+
+// DefaultEmotionMap 默认的标记到设备表情的映射, 标记为 emoji 或 [开心]、【开心】 形式的标签内容
+// 设备表情名称本身也可作为标签, 如 [happy]
+var DefaultEmotionMap = map[string]string{
+	"😊": "happy", "😀": "happy", "🤗": "happy", "😄": "laughing", "😁": "laughing",
+	"🤣": "funny", "😢": "crying", "😞": "sad", "😡": "angry", "🥰": "loving",
+	"❤": "loving", "😮": "surprised", "😲": "shocked", "😋": "delicious", "😪": "sleepy",
+	"开心": "happy", "高兴": "happy", "大笑": "laughing", "搞笑": "funny", "难过": "sad",
+	"伤心": "sad", "生气": "angry", "哭": "crying", "喜欢": "loving", "害羞": "embarrassed",
+	"惊讶": "surprised", "震惊": "shocked", "思考": "thinking", "眨眼": "winking", "酷": "cool",
+	"放松": "relaxed", "好吃": "delicious", "亲亲": "kissy", "自信": "confident", "困": "sleepy",
+	"调皮": "silly", "疑惑": "confused", "平静": "neutral",
+}
+
+// emotionKeywords 没有表情标记时按关键词判断表情的轻量分类, 按顺序匹配
+var emotionKeywords = []struct {
+	emotion  string
+	keywords []string
+}{
+	{"laughing", []string{"哈哈", "嘿嘿"}},
+	{"crying", []string{"呜呜"}},
+	{"sad", []string{"抱歉", "对不起", "遗憾", "难过"}},
+	{"angry", []string{"生气", "气死"}},
+	{"surprised", []string{"哇", "天哪", "真的吗"}},
+	{"loving", []string{"爱你", "喜欢你"}},
+	{"thinking", []string{"让我想想", "我想想"}},
+	{"sleepy", []string{"晚安", "好困"}},
+}
+
+// EmotionParser 从LLM输出的句子中解析表情标记
+// 标记从文本中去除, 避免被TTS朗读; 没有映射的 emoji 同样去除
+type EmotionParser struct {
+	mapping  map[string]string
+	classify bool
+}
+
+// NewEmotionParser mapping 覆盖默认映射, classify 为 true 时没有标记的句子按关键词判断表情
+func NewEmotionParser(mapping map[string]string, classify bool) *EmotionParser {
+	merged := make(map[string]string, len(DefaultEmotionMap)+len(DeviceEmotionEmoji)+len(mapping))
+	for emotion := range DeviceEmotionEmoji {
+		merged[emotion] = emotion
+	}
+	for marker, emotion := range DefaultEmotionMap {
+		merged[marker] = emotion
+	}
+	for marker, emotion := range mapping {
+		merged[normalizeMarker(marker)] = emotion
+	}
+	return &EmotionParser{mapping: merged, classify: classify}
+}
+
+func normalizeMarker(marker string) string {
+	marker = strings.TrimSpace(strings.ReplaceAll(marker, "\uFE0F", ""))
+	return strings.ToLower(marker)
+}
+
+// Parse 返回去除标记后的文本和句子的表情, 有多个标记时取第一个, 没有表情时返回空字符串
+func (p *EmotionParser) Parse(sentence string) (string, string) {
+	var text strings.Builder
+	emotion := ""
+	for i := 0; i < len(sentence); {
+		r, size := utf8.DecodeRuneInString(sentence[i:])
+		if closing, ok := tagClosing[r]; ok {
+			if end := strings.IndexRune(sentence[i+size:], closing); end >= 0 {
+				tag := sentence[i+size : i+size+end]
+				if mapped, ok := p.mapping[normalizeMarker(tag)]; ok {
+					if emotion == "" {
+						emotion = mapped
+					}
+					i += size + end + utf8.RuneLen(closing)
+					continue
+				}
+			}
+		}
+		if isEmoji(r) {
+			if mapped, ok := p.mapping[string(r)]; ok && emotion == "" {
+				emotion = mapped
+			}
+			i += size
+			continue
+		}
+		text.WriteRune(r)
+		i += size
+	}
+
+	result := strings.TrimSpace(text.String())
+	if emotion == "" && p.classify {
+		emotion = classifyEmotion(result)
+	}
+	return result, emotion
+}
+
+var tagClosing = map[rune]rune{'[': ']', '【': '】'}
+
+func classifyEmotion(text string) string {
+	for _, item := range emotionKeywords {
+		for _, keyword := range item.keywords {
+			if strings.Contains(text, keyword) {
+				return item.emotion
+			}
+		}
+	}
+	return ""
+}
+
+// isEmoji 常见 emoji 及组合 emoji 使用的连接符、变体选择符
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF:
+		return true
+	case r >= 0x2600 && r <= 0x27BF:
+		return true
+	case r == 0x200D || r == 0xFE0F || r == 0x20E3:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package llm
+
+import "testing"
+
+func TestEmotionParserParse(t *testing.T) {
+	parser := NewEmotionParser(map[string]string{"🐶": "silly", "得意": "confident", "开心": "laughing"}, true)
+	tests := []struct {
+		name        string
+		sentence    string
+		wantText    string
+		wantEmotion string
+	}{
+		{name: "emoji", sentence: "今天天气真好😊", wantText: "今天天气真好", wantEmotion: "happy"},
+		{name: "多个emoji取第一个", sentence: "😢😂别难过", wantText: "别难过", wantEmotion: "crying"},
+		{name: "带变体选择符的emoji", sentence: "我也爱你❤️", wantText: "我也爱你", wantEmotion: "loving"},
+		{name: "未映射的emoji只去除", sentence: "出发吧🚀", wantText: "出发吧", wantEmotion: ""},
+		{name: "中文标签", sentence: "【惊讶】真的吗？", wantText: "真的吗？", wantEmotion: "surprised"},
+		{name: "设备表情名称标签", sentence: "[Thinking] 我查一下。", wantText: "我查一下。", wantEmotion: "thinking"},
+		{name: "智能体映射覆盖默认", sentence: "[开心]好呀", wantText: "好呀", wantEmotion: "laughing"},
+		{name: "智能体新增标签", sentence: "[得意]小菜一碟", wantText: "小菜一碟", wantEmotion: "confident"},
+		{name: "智能体新增emoji", sentence: "汪🐶", wantText: "汪", wantEmotion: "silly"},
+		{name: "未知标签保留", sentence: "见[1]所示", wantText: "见[1]所示", wantEmotion: ""},
+		{name: "只有标记", sentence: "😊", wantText: "", wantEmotion: "happy"},
+		{name: "关键词分类", sentence: "哈哈，你真逗", wantText: "哈哈，你真逗", wantEmotion: "laughing"},
+		{name: "没有表情", sentence: "现在是下午三点。", wantText: "现在是下午三点。", wantEmotion: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, emotion := parser.Parse(tt.sentence)
+			if text != tt.wantText || emotion != tt.wantEmotion {
+				t.Fatalf("Parse(%q) = %q, %q, want %q, %q", tt.sentence, text, emotion, tt.wantText, tt.wantEmotion)
+			}
+		})
+	}
+
+	if _, emotion := NewEmotionParser(nil, false).Parse("哈哈"); emotion != "" {
+		t.Fatalf("未开启分类时不应按关键词判断, emotion = %q", emotion)
+	}
+}
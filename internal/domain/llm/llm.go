@@ -40,11 +40,70 @@ func isSentencePausePunctuation(r rune) bool {
 	return false
 }
 
+// HandleOption HandleLLMWithContextAndTools 的可选参数
+type HandleOption func(*handleOptions)
+
+type handleOptions struct {
+	emotionParser *EmotionParser
+	speakerParser *SpeakerParser
+	jsonMode      bool
+}
+
+// WithEmotionParser 解析句子中的表情标记, 标记从文本中去除, 表情放在 Emotion 中
+func WithEmotionParser(parser *EmotionParser) HandleOption {
+	return func(o *handleOptions) {
+		o.emotionParser = parser
+	}
+}
+
+// WithSpeakerParser 解析句子的说话角色, 角色标记从文本中去除, 角色放在 Speaker 中
+// 开启后LLM的回复以 { 开头时按每行一个 {"speaker": "角色", "text": "内容"} 处理
+func WithSpeakerParser(parser *SpeakerParser) HandleOption {
+	return func(o *handleOptions) {
+		o.speakerParser = parser
+	}
+}
+
+// sentence 组装一句响应, 开启表情、角色解析时去除标记
+func (o *handleOptions) sentence(text string, isStart bool, isEnd bool) common.LLMResponseStruct {
+	response := common.LLMResponseStruct{Text: text, IsStart: isStart, IsEnd: isEnd}
+	if o.speakerParser != nil && text != "" {
+		response.Text, response.Speaker = o.speakerParser.Parse(text)
+	}
+	if o.emotionParser != nil && response.Text != "" {
+		response.Text, response.Emotion = o.emotionParser.Parse(response.Text)
+	}
+	return response
+}
+
+// lineSentences JSON 模式下将一行拆分为带角色的句子
+func (o *handleOptions) lineSentences(line string, isFirst bool) []common.LLMResponseStruct {
+	text, speaker, _ := o.speakerParser.ParseJSONLine(line)
+	sentences, remaining := extractSmartSentences(text, 2, 100, isFirst)
+	if remaining != "" {
+		sentences = append(sentences, remaining)
+	}
+	responses := make([]common.LLMResponseStruct, 0, len(sentences))
+	for _, sentence := range sentences {
+		response := common.LLMResponseStruct{Text: sentence, IsStart: isFirst, Speaker: speaker}
+		if o.emotionParser != nil {
+			response.Text, response.Emotion = o.emotionParser.Parse(sentence)
+		}
+		responses = append(responses, response)
+		isFirst = false
+	}
+	return responses
+}
+
 // HandleLLMWithContextAndTools 使用上下文控制来处理LLM响应（兼容带工具和不带工具）
-func HandleLLMWithContextAndTools(ctx context.Context, llmProvider LLMProvider, dialogue []*schema.Message, tools []*schema.ToolInfo, sessionID string) (chan common.LLMResponseStruct, error) {
+func HandleLLMWithContextAndTools(ctx context.Context, llmProvider LLMProvider, dialogue []*schema.Message, tools []*schema.ToolInfo, sessionID string, opts ...HandleOption) (chan common.LLMResponseStruct, error) {
 	var (
 		llmResponse interface{}
 	)
+	options := &handleOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
 	llmResponse = llmProvider.ResponseWithContext(ctx, sessionID, dialogue, tools)
 
 	sentenceChannel := make(chan common.LLMResponseStruct, 2)
@@ -72,17 +131,28 @@ func HandleLLMWithContextAndTools(ctx context.Context, llmProvider LLMProvider,
 			case message, ok := <-msgChan:
 				if !ok {
 					remaining := buffer.String()
-					if remaining != "" {
+					if options.jsonMode {
+						responses := options.lineSentences(remaining, isFirst)
+						if len(responses) == 0 {
+							responses = append(responses, common.LLMResponseStruct{})
+						}
+						responses[len(responses)-1].IsEnd = true
+						for _, response := range responses {
+							select {
+							case <-ctx.Done():
+								log.Infof("上下文已取消，停止LLM响应处理: %v, context done, exit", ctx.Err())
+								return
+							case sentenceChannel <- response:
+							}
+						}
+					} else if remaining != "" {
 						log.Infof("处理剩余内容: %s", remaining)
 						fullText += remaining
 						select {
 						case <-ctx.Done():
 							log.Infof("上下文已取消，停止LLM响应处理: %v, context done, exit", ctx.Err())
 							return
-						case sentenceChannel <- common.LLMResponseStruct{
-							Text:  remaining,
-							IsEnd: true,
-						}:
+						case sentenceChannel <- options.sentence(remaining, false, true):
 						}
 
 					} else {
@@ -104,9 +174,27 @@ func HandleLLMWithContextAndTools(ctx context.Context, llmProvider LLMProvider,
 				byteMessage, _ := json.Marshal(message)
 				log.Infof("收到message: %s", string(byteMessage))
 				if message.Content != "" {
+					if options.speakerParser != nil && strings.TrimSpace(fullText) == "" {
+						options.jsonMode = isJSONMode(message.Content)
+					}
 					fullText += message.Content
 					buffer.WriteString(message.Content)
-					if containsSentenceSeparator(message.Content, isFirst) {
+					if options.jsonMode {
+						lines, remaining := extractLines(buffer.String())
+						buffer.Reset()
+						buffer.WriteString(remaining)
+						for _, line := range lines {
+							for _, response := range options.lineSentences(line, isFirst) {
+								select {
+								case <-ctx.Done():
+									log.Infof("上下文已取消，停止LLM响应处理: %v, context done, exit", ctx.Err())
+									return
+								case sentenceChannel <- response:
+								}
+								isFirst = false
+							}
+						}
+					} else if containsSentenceSeparator(message.Content, isFirst) {
 						sentences, remaining := extractSmartSentences(buffer.String(), 2, 100, isFirst)
 						if len(sentences) > 0 {
 							for _, sentence := range sentences {
@@ -120,11 +208,7 @@ func HandleLLMWithContextAndTools(ctx context.Context, llmProvider LLMProvider,
 									case <-ctx.Done():
 										log.Infof("上下文已取消，停止LLM响应处理: %v, context done, exit", ctx.Err())
 										return
-									case sentenceChannel <- common.LLMResponseStruct{
-										Text:    sentence,
-										IsStart: isFirst,
-										IsEnd:   false,
-									}:
+									case sentenceChannel <- options.sentence(sentence, isFirst, false):
 									}
 
 									if isFirst {
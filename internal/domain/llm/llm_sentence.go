@@ -124,6 +124,53 @@ func findLastPunctuation(text []rune, separatorMap map[rune]bool) int {
 	return lastPos
 }
 
+// scopedMarkupTags 有作用范围的语音标记, 元素内不分句, 避免调整只作用到前半句
+var scopedMarkupTags = map[string]bool{"prosody": true, "voice": true}
+
+// maxMarkupTagLen 超过该长度仍未结束的 '<' 视为普通文本
+const maxMarkupTagLen = 200
+
+// scanMarkupTag text[pos] 为 '<' 且后面是标签名时返回标签结束的 '>' 位置和 depth 的变化
+// 标签还未输出完整时 end 为 -1
+func scanMarkupTag(text []rune, pos int) (end int, delta int, ok bool) {
+	i := pos + 1
+	closing := i < len(text) && text[i] == '/'
+	if closing {
+		i++
+	}
+	nameStart := i
+	for i < len(text) && (text[i] >= 'a' && text[i] <= 'z' || text[i] >= 'A' && text[i] <= 'Z' || text[i] == ':') {
+		i++
+	}
+	if i == nameStart {
+		if i >= len(text) {
+			// 流式输出时 '<' 可能是标签的开头
+			return -1, 0, true
+		}
+		return 0, 0, false
+	}
+	name := strings.ToLower(string(text[nameStart:i]))
+	for ; i < len(text); i++ {
+		if text[i] == '<' || text[i] == '\n' || i-pos > maxMarkupTagLen {
+			return 0, 0, false
+		}
+		if text[i] != '>' {
+			continue
+		}
+		if !scopedMarkupTags[name] {
+			return i, 0, true
+		}
+		if closing {
+			return i, -1, true
+		}
+		if text[i-1] == '/' {
+			return i, 0, true
+		}
+		return i, 1, true
+	}
+	return -1, 0, true
+}
+
 func findNextSplitPoint(text []rune, startPos int, maxLen int, separatorMap map[rune]bool) int {
 	// 计算查找的结束位置
 	endPos := startPos + maxLen
@@ -131,8 +178,36 @@ func findNextSplitPoint(text []rune, startPos int, maxLen int, separatorMap map[
 		endPos = len(text)
 	}
 
-	// 从前向后查找
-	for i := startPos; i < endPos; i++ {
+	// 从前向后查找, 跳过标签内的字符和语音标记元素内的标点
+	// 元素超过 maxLen 仍未闭合时视为在分句处结束, 标签超过 maxLen 仍未输出完整时 '<' 视为普通文本
+	depth := 0
+	for i := startPos; i < len(text); i++ {
+		if text[i] == '<' {
+			end, delta, ok := scanMarkupTag(text, i)
+			if ok && end < 0 && len(text)-startPos < maxLen {
+				return -1
+			}
+			if ok && end >= 0 {
+				depth += delta
+				if depth < 0 {
+					depth = 0
+				}
+				i = end
+				continue
+			}
+		}
+		if depth > 0 && i < endPos {
+			continue
+		}
+
+		// 如果在maxLen范围内没找到，尝试在更大范围内查找
+		if i >= endPos {
+			if text[i] == '\n' || separatorMap[text[i]] {
+				return i
+			}
+			continue
+		}
+
 		// 检查是否是换行符，同时检查下一行是否是序号
 		if text[i] == '\n' {
 			nextPos := i + 1
@@ -153,15 +228,6 @@ func findNextSplitPoint(text []rune, startPos int, maxLen int, separatorMap map[
 		}
 	}
 
-	// 如果在maxLen范围内没找到，尝试在更大范围内查找
-	if endPos < len(text) {
-		for i := endPos; i < len(text); i++ {
-			if text[i] == '\n' || separatorMap[text[i]] {
-				return i
-			}
-		}
-	}
-
 	return -1
 }
 
@@ -0,0 +1,77 @@
+package llm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractSmartSentencesMarkup(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		isFirst       bool
+		wantSentences []string
+		wantRemaining string
+	}{
+		{
+			name:          "属性中的小数点",
+			text:          `好的<prosody rate="1.2">我们现在就出发吧</prosody>。`,
+			wantSentences: []string{`好的<prosody rate="1.2">我们现在就出发吧</prosody>。`},
+		},
+		{
+			name:          "元素内的标点不分句",
+			text:          `<prosody pitch="0.9" volume="1.5">别急。慢慢来</prosody>！下一句。`,
+			wantSentences: []string{`<prosody pitch="0.9" volume="1.5">别急。慢慢来</prosody>！`, "下一句。"},
+		},
+		{
+			name:          "首句逗号不拆开元素",
+			text:          `<voice name="zh-CN-YunxiNeural">嗯，我想想</voice>，好的。`,
+			isFirst:       true,
+			wantSentences: []string{`<voice name="zh-CN-YunxiNeural">嗯，我想想</voice>，`, "好的。"},
+		},
+		{
+			name:          "停顿标签不影响分句",
+			text:          `稍等<break time="1.5s"/>马上就好。`,
+			wantSentences: []string{`稍等<break time="1.5s"/>马上就好。`},
+		},
+		{
+			name:          "未闭合的元素等待后续输出",
+			text:          `好的。<prosody rate="1.2">出发吧。`,
+			wantSentences: []string{"好的。"},
+			wantRemaining: `<prosody rate="1.2">出发吧。`,
+		},
+		{
+			name:          "未输出完整的标签",
+			text:          `好的。<prosody rate="1.`,
+			wantSentences: []string{"好的。"},
+			wantRemaining: `<prosody rate="1.`,
+		},
+		{
+			name:          "普通的小于号",
+			text:          "3<5。对吧？",
+			wantSentences: []string{"3<5。", "对吧？"},
+		},
+		{
+			name:          "小于号后是字母",
+			text:          "a<b" + strings.Repeat("长", 100) + "。对吧？",
+			wantSentences: []string{"a<b" + strings.Repeat("长", 100) + "。", "对吧？"},
+		},
+		{
+			name:          "超过最大长度仍未闭合的元素",
+			text:          `<prosody rate="1.2">` + strings.Repeat("长", 100) + "。后面的句子。",
+			wantSentences: []string{`<prosody rate="1.2">` + strings.Repeat("长", 100) + "。", "后面的句子。"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sentences, remaining := extractSmartSentences(tt.text, 2, 100, tt.isFirst)
+			if len(sentences) == 0 {
+				sentences = nil
+			}
+			if !reflect.DeepEqual(sentences, tt.wantSentences) || remaining != tt.wantRemaining {
+				t.Fatalf("extractSmartSentences(%q) = %q, %q, want %q, %q", tt.text, sentences, remaining, tt.wantSentences, tt.wantRemaining)
+			}
+		})
+	}
+}
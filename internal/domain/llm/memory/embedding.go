@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+
+	"github.com/cloudwego/eino-ext/libs/acl/openai"
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+const (
+	EmbeddingTypeHash   = "hash"
+	EmbeddingTypeOpenai = "openai"
+
+	defaultHashDimensions = 256
+)
+
+// EmbeddingConfig 长期记忆使用的向量模型配置
+type EmbeddingConfig struct {
+	Type       string
+	ModelName  string
+	APIKey     string
+	BaseURL    string
+	Dimensions int
+}
+
+// NewEmbedder 按类型创建向量模型, hash 为本地实现, 无需外部服务
+func NewEmbedder(ctx context.Context, config EmbeddingConfig) (embedding.Embedder, error) {
+	switch config.Type {
+	case "", EmbeddingTypeHash:
+		return NewHashEmbedder(config.Dimensions), nil
+	case EmbeddingTypeOpenai:
+		embeddingConfig := &openai.EmbeddingConfig{
+			APIKey:  config.APIKey,
+			BaseURL: config.BaseURL,
+			Model:   config.ModelName,
+		}
+		if config.Dimensions > 0 {
+			embeddingConfig.Dimensions = &config.Dimensions
+		}
+		return openai.NewEmbeddingClient(ctx, embeddingConfig)
+	default:
+		return nil, fmt.Errorf("不支持的向量模型类型: %s", config.Type)
+	}
+}
+
+// HashEmbedder 将文本的单字和相邻双字哈希到固定维度, 适合中文短句的字面相似度检索
+type HashEmbedder struct {
+	dimensions int
+}
+
+func NewHashEmbedder(dimensions int) *HashEmbedder {
+	if dimensions <= 0 {
+		dimensions = defaultHashDimensions
+	}
+	return &HashEmbedder{dimensions: dimensions}
+}
+
+func (e *HashEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	vectors := make([][]float64, 0, len(texts))
+	for _, text := range texts {
+		vectors = append(vectors, e.embed(text))
+	}
+	return vectors, nil
+}
+
+func (e *HashEmbedder) embed(text string) []float64 {
+	vector := make([]float64, e.dimensions)
+	runes := make([]rune, 0, len(text))
+	for _, r := range strings.ToLower(text) {
+		if isSeparator(r) {
+			continue
+		}
+		runes = append(runes, r)
+	}
+
+	add := func(token string, weight float64) {
+		h := fnv.New32a()
+		h.Write([]byte(token))
+		vector[h.Sum32()%uint32(e.dimensions)] += weight
+	}
+	for i, r := range runes {
+		add(string(r), 1)
+		if i+1 < len(runes) {
+			add(string(runes[i:i+2]), 2)
+		}
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vector {
+			vector[i] /= norm
+		}
+	}
+	return vector
+}
+
+func isSeparator(r rune) bool {
+	return strings.ContainsRune(" \t\r\n,.!?;:，。！？；：、\"'“”‘’()（）[]【】", r)
+}
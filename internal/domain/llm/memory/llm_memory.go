@@ -2,7 +2,6 @@ package memory
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
@@ -16,27 +15,47 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+const retentionCheckInterval = time.Hour
+
 var (
 	memoryInstance *Memory
 	once           sync.Once
 )
 
-// Memory 表示对话记忆体
+// Memory 表示对话记忆体, 具体存储由 memory.store.type 选择 redis、memory 或 sql
 type Memory struct {
-	redisClient *redis.Client
-	keyPrefix   string
-	sync.RWMutex
+	store     MemoryStore
+	retention time.Duration
 }
 
-// Init 初始化记忆体实例
+// LoadStoreConfig 从配置文件读取对话记忆存储配置
+func LoadStoreConfig() StoreConfig {
+	return StoreConfig{
+		Type:      viper.GetString("memory.store.type"),
+		KeyPrefix: viper.GetString("redis.key_prefix"),
+		Retention: viper.GetDuration("memory.store.retention"),
+		SqlDriver: viper.GetString("memory.store.sql.driver"),
+		SqlDsn:    viper.GetString("memory.store.sql.dsn"),
+	}
+}
+
+// Init 初始化记忆体实例, 存储创建失败时退化为进程内存储并返回错误
 func Init() error {
 	var initErr error
 	once.Do(func() {
-		redisInstance := i_redis.GetClient()
+		config := LoadStoreConfig()
+		store, err := NewStore(config, i_redis.GetClient())
+		if err != nil {
+			initErr = fmt.Errorf("创建对话记忆存储失败, 使用进程内存储, 每个设备只保留最近 %d 条消息: %v", maxInMemoryMessages, err)
+			store = NewInMemoryStore()
+		}
 
 		memoryInstance = &Memory{
-			redisClient: redisInstance,
-			keyPrefix:   viper.GetString("redis.key_prefix"),
+			store:     store,
+			retention: config.Retention,
+		}
+		if config.Retention > 0 {
+			go memoryInstance.retentionLoop()
 		}
 	})
 	return initErr
@@ -45,7 +64,9 @@ func Init() error {
 // Get 获取记忆体实例
 func Get() *Memory {
 	if memoryInstance == nil {
-		Init()
+		if err := Init(); err != nil {
+			log.Errorf("初始化对话记忆失败: %v", err)
+		}
 	}
 	return memoryInstance
 }
@@ -53,195 +74,136 @@ func Get() *Memory {
 // NewMemory 创建新的记忆体实例（仅用于测试）
 func NewMemory(redisClient *redis.Client) *Memory {
 	return &Memory{
-		redisClient: redisClient,
+		store: NewRedisStore(redisClient, "", 0),
 	}
 }
 
-// getMemoryKey 生成设备对应的 Redis key
-func (m *Memory) getMemoryKey(deviceID string) string {
-	return fmt.Sprintf("%s:llm:%s", m.keyPrefix, deviceID)
-}
-
-// getSystemPromptKey 生成设备对应的系统 prompt 的 Redis key
-func (m *Memory) getSystemPromptKey(deviceID string) string {
-	return fmt.Sprintf("%s:llm:system:%s", m.keyPrefix, deviceID)
-}
-
-// AddMessage 添加一条新的对话消息到记忆体
-func (m *Memory) AddMessage(ctx context.Context, deviceID string, msg schema.Message) error {
-	if m.redisClient == nil {
-		log.Log().Warn("redis client is nil")
-		return nil
+// NewMemoryWithStore 使用指定存储创建记忆体实例
+func NewMemoryWithStore(store MemoryStore, retention time.Duration) *Memory {
+	return &Memory{
+		store:     store,
+		retention: retention,
 	}
+}
 
-	msgBytes, err := json.Marshal(msg)
-	if err != nil {
-		return fmt.Errorf("marshal message failed: %w", err)
+// retentionLoop 定期删除超过保留时长的消息
+func (m *Memory) retentionLoop() {
+	ticker := time.NewTicker(retentionCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := m.RemoveExpired(context.Background()); err != nil {
+			log.Errorf("清理过期对话记忆失败: %v", err)
+		}
 	}
+}
 
-	key := m.getMemoryKey(deviceID)
-	// 使用纳秒时间戳作为分数
-	// ZREVRANGE 会返回分数从大到小的结果
-	score := float64(time.Now().UnixNano())
-
-	log.Debugf("添加消息到记忆体: %s, %s", key, string(msgBytes))
-
-	return m.redisClient.ZAdd(ctx, key, redis.Z{
-		Score:  score,
-		Member: string(msgBytes),
-	}).Err()
+// AddMessage 添加一条新的对话消息到记忆体, conversationID 为消息所属的会话
+func (m *Memory) AddMessage(ctx context.Context, deviceID string, conversationID string, msg schema.Message) error {
+	return m.store.AddMessage(ctx, deviceID, conversationID, msg)
 }
 
-// GetMessages 获取设备的所有对话记忆
+// GetMessages 获取设备最近的 count 条对话记忆, 按时间从旧到新
 func (m *Memory) GetMessages(ctx context.Context, deviceID string, count int) ([]*schema.Message, error) {
-	if m.redisClient == nil {
-		log.Log().Warn("redis client is nil")
-		return []*schema.Message{}, nil
-	}
-
-	key := m.getMemoryKey(deviceID)
-
 	if count == 0 {
 		count = 10
 	}
-
-	// 使用 ZREVRANGE 获取最新的 N 条消息
-	// 分数（时间戳）大的在前，所以需要反转顺序以保证旧消息在前
-	startIndex := int64(-(count))
-	results, err := m.redisClient.ZRange(ctx, key, startIndex, -1).Result()
-	if err != nil {
-		return nil, fmt.Errorf("get messages failed: %w", err)
-	}
-
-	// 预分配切片
-	messages := make([]*schema.Message, 0)
-
-	for i := 0; i < len(results); i++ {
-		msg := schema.Message{}
-		if err := json.Unmarshal([]byte(results[i]), &msg); err != nil {
-			return nil, fmt.Errorf("unmarshal message failed: %w", err)
-		}
-
-		messages = append(messages, &msg)
-	}
-
-	return messages, nil
+	return m.store.GetMessages(ctx, deviceID, count)
 }
 
 // GetMessagesForLLM 获取适用于 LLM 的消息格式
 func (m *Memory) GetMessagesForLLM(ctx context.Context, deviceID string, count int) ([]*schema.Message, error) {
-	if m.redisClient == nil {
-		log.Log().Warn("redis client is nil")
-		return []*schema.Message{}, nil
-	}
-
-	// 获取历史消息（已经是按时间顺序：旧->新）
-	memoryMessages, err := m.GetMessages(ctx, deviceID, count)
-	if err != nil {
-		return nil, err
-	}
-
-	return memoryMessages, nil
+	return m.GetMessages(ctx, deviceID, count)
 }
 
 // SetSystemPrompt 设置或更新设备的系统 prompt
 func (m *Memory) SetSystemPrompt(ctx context.Context, deviceID string, prompt string) error {
-	if m.redisClient == nil {
-		log.Log().Warn("redis client is nil")
-		return nil
-	}
-
-	key := m.getSystemPromptKey(deviceID)
-	return m.redisClient.Set(ctx, key, prompt, 0).Err()
+	return m.store.SetSystemPrompt(ctx, deviceID, prompt)
 }
 
-// GetSystemPrompt 获取设备的系统 prompt
+// GetSystemPrompt 获取设备的系统 prompt, 未设置时返回空消息
 func (m *Memory) GetSystemPrompt(ctx context.Context, deviceID string) (schema.Message, error) {
-	if m.redisClient == nil {
-		log.Log().Warn("redis client is nil")
-		return schema.Message{Role: schema.System, Content: viper.GetString("system_prompt")}, nil
-	}
-
-	key := m.getSystemPromptKey(deviceID)
-
-	result, err := m.redisClient.Get(ctx, key).Result()
-	if err == redis.Nil {
-		return schema.Message{}, nil // 返回空消息结构
-	}
+	prompt, err := m.store.GetSystemPrompt(ctx, deviceID)
 	if err != nil {
 		return schema.Message{}, fmt.Errorf("get system prompt failed: %w", err)
 	}
-
+	if prompt == "" {
+		return schema.Message{}, nil
+	}
 	return schema.Message{
 		Role:    schema.System,
-		Content: result,
+		Content: prompt,
 	}, nil
 }
 
-// ResetMemory 重置设备的对话记忆（包括系统 prompt）
+// ResetMemory 重置设备的对话记忆（包括对话摘要）
 func (m *Memory) ResetMemory(ctx context.Context, deviceID string) error {
-	if m.redisClient == nil {
-		log.Log().Warn("redis client is nil")
-		return nil
-	}
-
-	// 删除对话历史
-	historyKey := m.getMemoryKey(deviceID)
-	if err := m.redisClient.Del(ctx, historyKey).Err(); err != nil {
-		return fmt.Errorf("delete history failed: %w", err)
-	}
-
-	return nil
+	return m.store.DeleteMessages(ctx, deviceID)
 }
 
 // GetLastNMessages 获取最近的 N 条消息
 func (m *Memory) GetLastNMessages(ctx context.Context, deviceID string, n int64) ([]schema.Message, error) {
-	if m.redisClient == nil {
-		log.Log().Warn("redis client is nil")
-		return []schema.Message{}, nil
-	}
-
-	key := m.getMemoryKey(deviceID)
-
-	// 获取最后 N 条消息
-	results, err := m.redisClient.ZRevRange(ctx, key, 0, n-1).Result()
+	results, err := m.store.GetMessages(ctx, deviceID, int(n))
 	if err != nil {
-		return nil, fmt.Errorf("get last messages failed: %w", err)
+		return nil, err
 	}
-
 	messages := make([]schema.Message, 0, len(results))
-	for i := len(results) - 1; i >= 0; i-- { // 反转顺序以保持时间顺序
-		var msg schema.Message
-		if err := json.Unmarshal([]byte(results[i]), &msg); err != nil {
-			return nil, fmt.Errorf("unmarshal message failed: %w", err)
-		}
-		messages = append(messages, msg)
+	for _, msg := range results {
+		messages = append(messages, *msg)
 	}
-
 	return messages, nil
 }
 
-// RemoveOldMessages 删除指定时间之前的消息
-func (m *Memory) RemoveOldMessages(ctx context.Context, deviceID string, before time.Time) error {
-	if m.redisClient == nil {
-		log.Log().Warn("redis client is nil")
+// RemoveExpired 删除超过保留时长的消息, 未配置保留时长时不做处理
+func (m *Memory) RemoveExpired(ctx context.Context) error {
+	if m.retention <= 0 {
 		return nil
 	}
+	return m.store.RemoveExpired(ctx, time.Now().Add(-m.retention))
+}
+
+// ListConversations 列出设备的会话, 按开始时间从新到旧
+func (m *Memory) ListConversations(ctx context.Context, deviceID string) ([]Conversation, error) {
+	return m.store.ListConversations(ctx, deviceID)
+}
 
-	key := m.getMemoryKey(deviceID)
-	score := float64(before.UnixNano())
+// GetConversation 获取一个会话的全部消息
+func (m *Memory) GetConversation(ctx context.Context, deviceID string, conversationID string) ([]*schema.Message, error) {
+	return m.store.GetConversation(ctx, deviceID, conversationID)
+}
 
-	return m.redisClient.ZRemRangeByScore(ctx, key, "-inf", fmt.Sprintf("%f", score)).Err()
+// DeleteConversation 删除一个会话
+func (m *Memory) DeleteConversation(ctx context.Context, deviceID string, conversationID string) error {
+	return m.store.DeleteConversation(ctx, deviceID, conversationID)
 }
 
-// Summary 获取对话的摘要
+// Export 导出设备的全部会话及消息
+func (m *Memory) Export(ctx context.Context, deviceID string) ([]ConversationExport, error) {
+	conversations, err := m.store.ListConversations(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	exports := make([]ConversationExport, 0, len(conversations))
+	for _, conversation := range conversations {
+		messages, err := m.store.GetConversation(ctx, deviceID, conversation.ID)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, ConversationExport{
+			Conversation: conversation,
+			Messages:     messages,
+		})
+	}
+	return exports, nil
+}
+
+// GetSummary 获取对话的摘要
 func (m *Memory) GetSummary(ctx context.Context, deviceID string) (string, error) {
-	return "", nil
+	return m.store.GetSummary(ctx, deviceID)
 }
 
 // SetSummary 设置对话的摘要
 func (m *Memory) SetSummary(ctx context.Context, deviceID string, summary string) error {
-	return nil
+	return m.store.SetSummary(ctx, deviceID, summary)
 }
 
 // 进行总结
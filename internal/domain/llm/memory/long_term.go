@@ -0,0 +1,425 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+const (
+	defaultSummarizeThreshold = 20
+	defaultTopK               = 5
+	defaultMinScore           = 0.3
+	defaultMaxItems           = 200
+	defaultRetrieveTimeout    = time.Second
+
+	// 进程内最多缓存的设备摘要数, 超过后淘汰最早缓存的, 需要时重新从对话记忆体加载
+	maxCachedSummaries = 1000
+	// 总结失败时保留待总结的消息, 最多保留阈值的该倍数, 超过后丢弃最早的
+	maxPendingFactor = 5
+	// 总结失败后的重试间隔, 连续失败时翻倍, 不超过最大间隔
+	summarizeRetryDelay    = time.Minute
+	maxSummarizeRetryDelay = 30 * time.Minute
+
+	// 新事实与已有记忆相似度超过该值时视为同一条记忆的更新, 如名字变更
+	duplicateScore = 0.85
+)
+
+// Summarizer 使用智能体的LLM完成一次总结, 返回模型输出的文本
+type Summarizer func(ctx context.Context, messages []*schema.Message) (string, error)
+
+// LongTermConfig 长期记忆配置
+type LongTermConfig struct {
+	Enable             bool
+	SummarizeThreshold int     //累计多少条新对话消息后进行一次总结
+	TopK               int     //每轮注入提示词的记忆条数
+	MinScore           float64 //记忆与用户问题的最低相似度
+	MaxItems           int     //每个设备最多保留的记忆条数
+	VectorStore        string
+	Embedding          EmbeddingConfig
+}
+
+// LoadLongTermConfig 从配置文件读取长期记忆配置
+func LoadLongTermConfig() LongTermConfig {
+	config := LongTermConfig{
+		Enable:             viper.GetBool("memory.long_term.enable"),
+		SummarizeThreshold: viper.GetInt("memory.long_term.summarize_threshold"),
+		TopK:               viper.GetInt("memory.long_term.top_k"),
+		MinScore:           viper.GetFloat64("memory.long_term.min_score"),
+		MaxItems:           viper.GetInt("memory.long_term.max_items"),
+		VectorStore:        viper.GetString("memory.long_term.vector_store"),
+		Embedding: EmbeddingConfig{
+			Type:       viper.GetString("memory.long_term.embedding.type"),
+			ModelName:  viper.GetString("memory.long_term.embedding.model_name"),
+			APIKey:     viper.GetString("memory.long_term.embedding.api_key"),
+			BaseURL:    viper.GetString("memory.long_term.embedding.base_url"),
+			Dimensions: viper.GetInt("memory.long_term.embedding.dimensions"),
+		},
+	}
+	if config.SummarizeThreshold <= 0 {
+		config.SummarizeThreshold = defaultSummarizeThreshold
+	}
+	if config.TopK <= 0 {
+		config.TopK = defaultTopK
+	}
+	if config.MinScore <= 0 {
+		config.MinScore = defaultMinScore
+	}
+	if config.MaxItems <= 0 {
+		config.MaxItems = defaultMaxItems
+	}
+	return config
+}
+
+// LongTermMemory 长期记忆
+// 对话消息累计到一定条数后, 用智能体的LLM总结早期对话并提取用户的长期事实(名字、喜好、家庭成员等),
+// 事实向量化后存入向量存储, 每轮对话按用户问题检索相关记忆注入系统提示词
+type LongTermMemory struct {
+	config   LongTermConfig
+	embedder embedding.Embedder
+	store    VectorStore
+
+	pending      map[string][]*schema.Message //各设备尚未总结的对话消息
+	summarizing  map[string]bool
+	failures     map[string]summarizeFailure //各设备连续总结失败的次数和下次重试时间
+	summaries    map[string]string           //各设备早期对话的摘要
+	summaryOrder []string                    //摘要的缓存顺序, 用于淘汰
+	sync.Mutex
+}
+
+// summarizeFailure 设备连续总结失败的记录
+type summarizeFailure struct {
+	count   int
+	retryAt time.Time
+}
+
+var (
+	longTermInstance *LongTermMemory
+	longTermOnce     sync.Once
+)
+
+// GetLongTerm 获取长期记忆实例, 未开启或初始化失败时返回的实例不做任何处理
+func GetLongTerm() *LongTermMemory {
+	longTermOnce.Do(func() {
+		config := LoadLongTermConfig()
+		instance, err := NewLongTermMemory(context.Background(), config)
+		if err != nil {
+			log.Errorf("初始化长期记忆失败: %v", err)
+			instance = &LongTermMemory{}
+		}
+		longTermInstance = instance
+	})
+	return longTermInstance
+}
+
+// NewLongTermMemory 创建长期记忆
+func NewLongTermMemory(ctx context.Context, config LongTermConfig) (*LongTermMemory, error) {
+	m := &LongTermMemory{
+		config:      config,
+		pending:     make(map[string][]*schema.Message),
+		summarizing: make(map[string]bool),
+		failures:    make(map[string]summarizeFailure),
+		summaries:   make(map[string]string),
+	}
+	if !config.Enable {
+		return m, nil
+	}
+
+	embedder, err := NewEmbedder(ctx, config.Embedding)
+	if err != nil {
+		return nil, fmt.Errorf("创建向量模型失败: %v", err)
+	}
+	store, err := NewVectorStore(config.VectorStore, config.MaxItems)
+	if err != nil {
+		return nil, err
+	}
+	m.embedder = embedder
+	m.store = store
+	return m, nil
+}
+
+// Enabled 是否开启长期记忆
+func (m *LongTermMemory) Enabled() bool {
+	return m.config.Enable && m.embedder != nil && m.store != nil
+}
+
+// AddMessage 记录一条对话消息, 未总结的消息达到阈值时在后台进行总结
+// 只记录用户和助手的文本消息, 工具调用过程不参与总结
+func (m *LongTermMemory) AddMessage(ctx context.Context, deviceID string, msg *schema.Message, summarizer Summarizer) {
+	if !m.Enabled() || msg == nil || summarizer == nil {
+		return
+	}
+	if (msg.Role != schema.User && msg.Role != schema.Assistant) || msg.Content == "" || len(msg.ToolCalls) > 0 {
+		return
+	}
+
+	m.Lock()
+	m.pending[deviceID] = append(m.pending[deviceID], msg)
+	if len(m.pending[deviceID]) < m.config.SummarizeThreshold || m.summarizing[deviceID] {
+		m.Unlock()
+		return
+	}
+	if failure, ok := m.failures[deviceID]; ok && time.Now().Before(failure.retryAt) {
+		m.Unlock()
+		return
+	}
+	messages := m.pending[deviceID]
+	delete(m.pending, deviceID)
+	m.summarizing[deviceID] = true
+	m.Unlock()
+
+	go func() {
+		defer func() {
+			m.Lock()
+			delete(m.summarizing, deviceID)
+			m.Unlock()
+		}()
+		// 会话结束后也要完成总结, 不使用会话的ctx
+		summarizeCtx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+		if err := m.Summarize(summarizeCtx, deviceID, messages, summarizer); err != nil {
+			m.requeue(deviceID, messages, err)
+			return
+		}
+		m.Lock()
+		delete(m.failures, deviceID)
+		m.Unlock()
+	}()
+}
+
+// requeue 总结失败时把消息放回待总结队列的前面, 退避一段时间后由新消息触发重新总结
+func (m *LongTermMemory) requeue(deviceID string, messages []*schema.Message, err error) {
+	m.Lock()
+	defer m.Unlock()
+	failure := m.failures[deviceID]
+	failure.count++
+	delay := maxSummarizeRetryDelay
+	if failure.count <= 5 {
+		delay = min(summarizeRetryDelay<<(failure.count-1), maxSummarizeRetryDelay)
+	}
+	failure.retryAt = time.Now().Add(delay)
+	m.failures[deviceID] = failure
+	log.Errorf("设备 %s 长期记忆总结第 %d 次失败, %v 后重试, 消息留待下次总结: %v", deviceID, failure.count, delay, err)
+
+	pending := append(messages, m.pending[deviceID]...)
+	if limit := m.config.SummarizeThreshold * maxPendingFactor; len(pending) > limit {
+		pending = pending[len(pending)-limit:]
+	}
+	m.pending[deviceID] = pending
+}
+
+// longTermSummary 总结结果
+type longTermSummary struct {
+	Summary string   `json:"summary"`
+	Facts   []string `json:"facts"`
+}
+
+// Summarize 总结一批对话消息, 更新摘要并保存提取到的用户事实
+func (m *LongTermMemory) Summarize(ctx context.Context, deviceID string, messages []*schema.Message, summarizer Summarizer) error {
+	if !m.Enabled() || len(messages) == 0 {
+		return nil
+	}
+
+	var dialogue strings.Builder
+	if summary := m.GetSummary(ctx, deviceID); summary != "" {
+		dialogue.WriteString("已有摘要:\n")
+		dialogue.WriteString(summary)
+		dialogue.WriteString("\n\n")
+	}
+	dialogue.WriteString("新的对话记录:\n")
+	for _, msg := range messages {
+		fmt.Fprintf(&dialogue, "%s: %s\n", msg.Role, msg.Content)
+	}
+
+	output, err := summarizer(ctx, []*schema.Message{
+		{Role: schema.System, Content: LongTermMemoryPrompt},
+		{Role: schema.User, Content: dialogue.String()},
+	})
+	if err != nil {
+		return fmt.Errorf("调用LLM总结失败: %v", err)
+	}
+
+	result, err := parseLongTermSummary(output)
+	if err != nil {
+		return err
+	}
+	log.Infof("设备 %s 长期记忆总结完成, 摘要长度: %d, 提取事实: %d 条", deviceID, len(result.Summary), len(result.Facts))
+
+	if result.Summary != "" {
+		m.setSummary(ctx, deviceID, result.Summary)
+	}
+	return m.saveFacts(ctx, deviceID, result.Facts)
+}
+
+// parseLongTermSummary 解析模型输出, 兼容 ```json 代码块包裹
+func parseLongTermSummary(output string) (*longTermSummary, error) {
+	output = strings.TrimSpace(output)
+	if start := strings.Index(output, "{"); start >= 0 {
+		if end := strings.LastIndex(output, "}"); end > start {
+			output = output[start : end+1]
+		}
+	}
+	var result longTermSummary
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		return nil, fmt.Errorf("解析总结结果失败: %v, output: %s", err, output)
+	}
+	return &result, nil
+}
+
+// saveFacts 向量化并保存事实, 与已有记忆高度相似时覆盖旧记忆
+func (m *LongTermMemory) saveFacts(ctx context.Context, deviceID string, facts []string) error {
+	texts := make([]string, 0, len(facts))
+	for _, fact := range facts {
+		if fact = strings.TrimSpace(fact); fact != "" {
+			texts = append(texts, fact)
+		}
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+
+	vectors, err := m.embedder.EmbedStrings(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("向量化长期记忆失败: %v", err)
+	}
+	if len(vectors) != len(texts) {
+		return fmt.Errorf("向量数量 %d 与记忆数量 %d 不一致", len(vectors), len(texts))
+	}
+
+	now := time.Now()
+	items := make([]MemoryItem, 0, len(texts))
+	for i, text := range texts {
+		item := MemoryItem{
+			ID:        uuid.New().String(),
+			Text:      text,
+			Vector:    vectors[i],
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		similar, err := m.store.Search(ctx, deviceID, vectors[i], 1, duplicateScore)
+		if err != nil {
+			return fmt.Errorf("检索长期记忆失败: %v", err)
+		}
+		if len(similar) > 0 {
+			item.ID = similar[0].ID
+			item.CreatedAt = similar[0].CreatedAt
+		}
+		items = append(items, item)
+	}
+	return m.store.Upsert(ctx, deviceID, items)
+}
+
+// Retrieve 检索与 query 相关的长期记忆
+func (m *LongTermMemory) Retrieve(ctx context.Context, deviceID string, query string) ([]MemoryItem, error) {
+	if !m.Enabled() || strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+	vectors, err := m.embedder.EmbedStrings(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("向量化用户问题失败: %v", err)
+	}
+	if len(vectors) == 0 {
+		return nil, nil
+	}
+	return m.store.Search(ctx, deviceID, vectors[0], m.config.TopK, m.config.MinScore)
+}
+
+// BuildPrompt 生成注入系统提示词的记忆片段, 没有相关记忆时返回空字符串
+func (m *LongTermMemory) BuildPrompt(ctx context.Context, deviceID string, query string) string {
+	if !m.Enabled() {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, defaultRetrieveTimeout)
+	defer cancel()
+
+	var prompt strings.Builder
+	items, err := m.Retrieve(ctx, deviceID, query)
+	if err != nil {
+		log.Errorf("设备 %s 检索长期记忆失败: %v", deviceID, err)
+	}
+	if len(items) > 0 {
+		prompt.WriteString("\n\n# 关于用户的长期记忆\n")
+		for _, item := range items {
+			prompt.WriteString("- ")
+			prompt.WriteString(item.Text)
+			prompt.WriteString("\n")
+		}
+	}
+	if summary := m.GetSummary(ctx, deviceID); summary != "" {
+		prompt.WriteString("\n\n# 早期对话摘要\n")
+		prompt.WriteString(summary)
+	}
+	return prompt.String()
+}
+
+// GetSummary 获取设备早期对话的摘要, 进程内没有时从对话记忆体中加载
+func (m *LongTermMemory) GetSummary(ctx context.Context, deviceID string) string {
+	m.Lock()
+	summary, ok := m.summaries[deviceID]
+	m.Unlock()
+	if ok {
+		return summary
+	}
+
+	summary, err := Get().GetSummary(ctx, deviceID)
+	if err != nil {
+		log.Errorf("获取设备 %s 对话摘要失败: %v", deviceID, err)
+		return ""
+	}
+	m.Lock()
+	m.cacheSummary(deviceID, summary)
+	m.Unlock()
+	return summary
+}
+
+func (m *LongTermMemory) setSummary(ctx context.Context, deviceID string, summary string) {
+	m.Lock()
+	m.cacheSummary(deviceID, summary)
+	m.Unlock()
+	if err := Get().SetSummary(ctx, deviceID, summary); err != nil {
+		log.Errorf("保存设备 %s 对话摘要失败: %v", deviceID, err)
+	}
+}
+
+// cacheSummary 缓存设备摘要, 超过上限时淘汰最早缓存的, 调用方需持有锁
+func (m *LongTermMemory) cacheSummary(deviceID string, summary string) {
+	if _, ok := m.summaries[deviceID]; !ok {
+		m.summaryOrder = append(m.summaryOrder, deviceID)
+	}
+	m.summaries[deviceID] = summary
+	for len(m.summaries) > maxCachedSummaries && len(m.summaryOrder) > 0 {
+		delete(m.summaries, m.summaryOrder[0])
+		m.summaryOrder = m.summaryOrder[1:]
+	}
+}
+
+// Reset 清空设备的长期记忆和摘要
+func (m *LongTermMemory) Reset(ctx context.Context, deviceID string) error {
+	if !m.Enabled() {
+		return nil
+	}
+	m.Lock()
+	delete(m.pending, deviceID)
+	delete(m.failures, deviceID)
+	if _, ok := m.summaries[deviceID]; ok {
+		delete(m.summaries, deviceID)
+		for i, id := range m.summaryOrder {
+			if id == deviceID {
+				m.summaryOrder = append(m.summaryOrder[:i], m.summaryOrder[i+1:]...)
+				break
+			}
+		}
+	}
+	m.Unlock()
+	return m.store.Delete(ctx, deviceID)
+}
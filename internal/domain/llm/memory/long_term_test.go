@@ -0,0 +1,186 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func newTestLongTermMemory(t *testing.T) *LongTermMemory {
+	m, err := NewLongTermMemory(context.Background(), LongTermConfig{
+		Enable:             true,
+		SummarizeThreshold: 4,
+		TopK:               2,
+		MinScore:           0.2,
+		MaxItems:           10,
+	})
+	if err != nil {
+		t.Fatalf("NewLongTermMemory: %v", err)
+	}
+	// 避免测试依赖 redis
+	memoryInstance = NewMemoryWithStore(NewInMemoryStore(), 0)
+	return m
+}
+
+func TestLongTermMemorySummarize(t *testing.T) {
+	m := newTestLongTermMemory(t)
+	ctx := context.Background()
+
+	summarizer := func(ctx context.Context, messages []*schema.Message) (string, error) {
+		if !strings.Contains(messages[1].Content, "我叫张三") {
+			t.Errorf("对话记录缺少用户消息: %s", messages[1].Content)
+		}
+		return "```json\n{\"summary\": \"用户做了自我介绍\", \"facts\": [\"用户叫张三\", \"用户喜欢听周杰伦的歌\"]}\n```", nil
+	}
+	messages := []*schema.Message{
+		{Role: schema.User, Content: "我叫张三"},
+		{Role: schema.Assistant, Content: "你好张三"},
+		{Role: schema.User, Content: "我喜欢听周杰伦的歌"},
+	}
+	if err := m.Summarize(ctx, "dev-1", messages, summarizer); err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+
+	items, err := m.Retrieve(ctx, "dev-1", "我喜欢谁的歌")
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if len(items) == 0 || items[0].Text != "用户喜欢听周杰伦的歌" {
+		t.Fatalf("items = %+v", items)
+	}
+
+	prompt := m.BuildPrompt(ctx, "dev-1", "张三想听歌")
+	if !strings.Contains(prompt, "用户叫张三") || !strings.Contains(prompt, "用户做了自我介绍") {
+		t.Fatalf("prompt = %s", prompt)
+	}
+
+	// 名字更正时覆盖旧记忆, 而不是新增一条
+	rename := func(ctx context.Context, messages []*schema.Message) (string, error) {
+		return `{"summary": "用户改了名字", "facts": ["用户叫张三丰"]}`, nil
+	}
+	if err := m.Summarize(ctx, "dev-1", messages[:1], rename); err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	items, _ = m.Retrieve(ctx, "dev-1", "用户叫什么")
+	for _, item := range items {
+		if item.Text == "用户叫张三" {
+			t.Fatalf("旧记忆未被覆盖: %+v", items)
+		}
+	}
+
+	if items, _ := m.Retrieve(ctx, "dev-2", "用户叫什么"); len(items) != 0 {
+		t.Fatalf("不同设备的记忆应隔离: %+v", items)
+	}
+}
+
+func TestLongTermMemoryAddMessage(t *testing.T) {
+	m := newTestLongTermMemory(t)
+	done := make(chan []*schema.Message, 1)
+	summarizer := func(ctx context.Context, messages []*schema.Message) (string, error) {
+		done <- messages
+		return `{"summary": "", "facts": []}`, nil
+	}
+
+	ctx := context.Background()
+	m.AddMessage(ctx, "dev-1", &schema.Message{Role: schema.User, Content: "你好"}, summarizer)
+	m.AddMessage(ctx, "dev-1", &schema.Message{Role: schema.Tool, Content: "工具结果"}, summarizer)
+	m.AddMessage(ctx, "dev-1", &schema.Message{Role: schema.Assistant, Content: "你好呀"}, summarizer)
+	m.AddMessage(ctx, "dev-1", &schema.Message{Role: schema.User, Content: "今天天气怎么样"}, summarizer)
+	m.AddMessage(ctx, "dev-1", &schema.Message{Role: schema.Assistant, Content: "今天晴天"}, summarizer)
+
+	select {
+	case messages := <-done:
+		dialogue := messages[1].Content
+		if !strings.Contains(dialogue, "今天晴天") || strings.Contains(dialogue, "工具结果") {
+			t.Fatalf("对话记录 = %s, 工具消息不应参与总结", dialogue)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("达到阈值后未触发总结")
+	}
+}
+
+func TestLongTermMemoryRequeueOnFailure(t *testing.T) {
+	m := newTestLongTermMemory(t)
+	calls := make(chan string, 2)
+	failed := false
+	summarizer := func(ctx context.Context, messages []*schema.Message) (string, error) {
+		calls <- messages[1].Content
+		if !failed {
+			failed = true
+			return "", errors.New("模型超时")
+		}
+		return `{"summary": "", "facts": []}`, nil
+	}
+
+	ctx := context.Background()
+	add := func(content string) {
+		m.AddMessage(ctx, "dev-1", &schema.Message{Role: schema.User, Content: content}, summarizer)
+	}
+	for _, content := range []string{"一", "二", "三", "四"} {
+		add(content)
+	}
+	select {
+	case <-calls:
+	case <-time.After(2 * time.Second):
+		t.Fatal("达到阈值后未触发总结")
+	}
+	// 等待失败的总结结束, 消息放回待总结队列
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		m.Lock()
+		summarizing := m.summarizing["dev-1"]
+		m.Unlock()
+		if !summarizing {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("总结未结束")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 退避期间新消息不触发总结
+	add("五")
+	select {
+	case dialogue := <-calls:
+		t.Fatalf("退避期间重新总结: %s", dialogue)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	m.Lock()
+	failure := m.failures["dev-1"]
+	if failure.count != 1 {
+		t.Fatalf("失败次数 = %d, want 1", failure.count)
+	}
+	failure.retryAt = time.Now()
+	m.failures["dev-1"] = failure
+	m.Unlock()
+
+	add("六")
+	select {
+	case dialogue := <-calls:
+		if !strings.Contains(dialogue, "user: 一") || !strings.Contains(dialogue, "user: 六") {
+			t.Fatalf("对话记录 = %s, 总结失败的消息应在下次总结时保留", dialogue)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("退避结束后新消息未触发重新总结")
+	}
+}
+
+func TestLongTermMemorySummaryCacheLimit(t *testing.T) {
+	m := newTestLongTermMemory(t)
+	for i := 0; i < maxCachedSummaries+10; i++ {
+		m.cacheSummary(fmt.Sprintf("dev-%d", i), "摘要")
+	}
+	if len(m.summaries) != maxCachedSummaries || len(m.summaryOrder) != maxCachedSummaries {
+		t.Fatalf("缓存 %d 条摘要, want %d", len(m.summaries), maxCachedSummaries)
+	}
+	if _, ok := m.summaries["dev-0"]; ok {
+		t.Fatal("最早缓存的摘要应被淘汰")
+	}
+}
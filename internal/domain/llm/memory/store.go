@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/redis/go-redis/v9"
+
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+const (
+	StoreTypeRedis  = "redis"
+	StoreTypeMemory = "memory"
+	StoreTypeSql    = "sql"
+)
+
+// Conversation 一次会话的对话记录概要, 会话ID为设备 hello 时创建的 session id
+type Conversation struct {
+	ID           string    `json:"id"`
+	DeviceID     string    `json:"device_id"`
+	MessageCount int       `json:"message_count"`
+	StartedAt    time.Time `json:"started_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ConversationExport 导出的会话及其全部消息
+type ConversationExport struct {
+	Conversation
+	Messages []*schema.Message `json:"messages"`
+}
+
+// MemoryStore 对话记忆的存储后端
+type MemoryStore interface {
+	// AddMessage 添加一条消息, conversationID 为消息所属的会话
+	AddMessage(ctx context.Context, deviceID string, conversationID string, msg schema.Message) error
+	// GetMessages 获取设备最近的 count 条消息, 按时间从旧到新
+	GetMessages(ctx context.Context, deviceID string, count int) ([]*schema.Message, error)
+	// ListConversations 列出设备的会话, 按开始时间从新到旧
+	ListConversations(ctx context.Context, deviceID string) ([]Conversation, error)
+	// GetConversation 获取一个会话的全部消息, 按时间从旧到新
+	GetConversation(ctx context.Context, deviceID string, conversationID string) ([]*schema.Message, error)
+	// DeleteConversation 删除一个会话的全部消息
+	DeleteConversation(ctx context.Context, deviceID string, conversationID string) error
+	// DeleteMessages 删除设备的全部消息和摘要
+	DeleteMessages(ctx context.Context, deviceID string) error
+	// RemoveExpired 删除所有设备在 before 之前的消息
+	RemoveExpired(ctx context.Context, before time.Time) error
+
+	SetSystemPrompt(ctx context.Context, deviceID string, prompt string) error
+	// GetSystemPrompt 未设置时返回空字符串
+	GetSystemPrompt(ctx context.Context, deviceID string) (string, error)
+	SetSummary(ctx context.Context, deviceID string, summary string) error
+	// GetSummary 未设置时返回空字符串
+	GetSummary(ctx context.Context, deviceID string) (string, error)
+}
+
+// StoreConfig 对话记忆存储配置
+type StoreConfig struct {
+	Type      string
+	KeyPrefix string        //redis key 前缀
+	Retention time.Duration //消息保留时长, 0 为永久保留
+	SqlDriver string        //sqlite 或 mysql
+	SqlDsn    string
+}
+
+// NewStore 按类型创建存储后端, redis 未初始化时退化为进程内存储, 避免对话历史静默丢失
+func NewStore(config StoreConfig, redisClient *redis.Client) (MemoryStore, error) {
+	switch config.Type {
+	case "", StoreTypeRedis:
+		if redisClient == nil {
+			log.Warnf("redis 未初始化, 对话记忆退化为进程内存储, 重启后丢失, 每个设备只保留最近 %d 条消息", maxInMemoryMessages)
+			return NewInMemoryStore(), nil
+		}
+		return NewRedisStore(redisClient, config.KeyPrefix, config.Retention), nil
+	case StoreTypeMemory:
+		return NewInMemoryStore(), nil
+	case StoreTypeSql:
+		return NewSqlStore(config.SqlDriver, config.SqlDsn)
+	default:
+		return nil, fmt.Errorf("不支持的对话记忆存储类型: %s", config.Type)
+	}
+}
+
+// groupConversations 按会话聚合消息, 用于没有会话索引的后端
+func groupConversations(deviceID string, messages []storedMessage) []Conversation {
+	index := make(map[string]int)
+	conversations := make([]Conversation, 0)
+	for _, msg := range messages {
+		i, ok := index[msg.ConversationID]
+		if !ok {
+			i = len(conversations)
+			index[msg.ConversationID] = i
+			conversations = append(conversations, Conversation{
+				ID:        msg.ConversationID,
+				DeviceID:  deviceID,
+				StartedAt: msg.CreatedAt,
+			})
+		}
+		conversations[i].MessageCount++
+		if msg.CreatedAt.After(conversations[i].UpdatedAt) {
+			conversations[i].UpdatedAt = msg.CreatedAt
+		}
+		if msg.CreatedAt.Before(conversations[i].StartedAt) {
+			conversations[i].StartedAt = msg.CreatedAt
+		}
+	}
+	sortConversations(conversations)
+	return conversations
+}
+
+func sortConversations(conversations []Conversation) {
+	sort.SliceStable(conversations, func(i, j int) bool {
+		return conversations[i].StartedAt.After(conversations[j].StartedAt)
+	})
+}
+
+// storedMessage 带会话与时间信息的消息
+type storedMessage struct {
+	ConversationID string
+	CreatedAt      time.Time
+	Message        schema.Message
+}
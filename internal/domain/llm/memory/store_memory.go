@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 进程内存储每个设备最多保留的消息数, 不论是否配置保留时长, 超出后丢弃最早的消息
+const maxInMemoryMessages = 1000
+
+// InMemoryStore 进程内对话记忆存储, 无需外部服务, 重启后丢失
+type InMemoryStore struct {
+	messages      map[string][]storedMessage
+	systemPrompts map[string]string
+	summaries     map[string]string
+	sync.RWMutex
+}
+
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{
+		messages:      make(map[string][]storedMessage),
+		systemPrompts: make(map[string]string),
+		summaries:     make(map[string]string),
+	}
+}
+
+func (s *InMemoryStore) AddMessage(ctx context.Context, deviceID string, conversationID string, msg schema.Message) error {
+	s.Lock()
+	defer s.Unlock()
+	list := append(s.messages[deviceID], storedMessage{
+		ConversationID: conversationID,
+		CreatedAt:      time.Now(),
+		Message:        msg,
+	})
+	// 超出四分之一后再整体前移, 避免每条消息都搬移整个列表
+	if len(list) > maxInMemoryMessages+maxInMemoryMessages/4 {
+		n := copy(list, list[len(list)-maxInMemoryMessages:])
+		clear(list[n:])
+		list = list[:n]
+	}
+	s.messages[deviceID] = list
+	return nil
+}
+
+func (s *InMemoryStore) GetMessages(ctx context.Context, deviceID string, count int) ([]*schema.Message, error) {
+	s.RLock()
+	defer s.RUnlock()
+	list := s.messages[deviceID]
+	if count <= 0 || count > maxInMemoryMessages {
+		count = maxInMemoryMessages
+	}
+	if len(list) > count {
+		list = list[len(list)-count:]
+	}
+	messages := make([]*schema.Message, 0, len(list))
+	for _, msg := range list {
+		m := msg.Message
+		messages = append(messages, &m)
+	}
+	return messages, nil
+}
+
+func (s *InMemoryStore) ListConversations(ctx context.Context, deviceID string) ([]Conversation, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return groupConversations(deviceID, s.messages[deviceID]), nil
+}
+
+func (s *InMemoryStore) GetConversation(ctx context.Context, deviceID string, conversationID string) ([]*schema.Message, error) {
+	s.RLock()
+	defer s.RUnlock()
+	messages := make([]*schema.Message, 0)
+	for _, msg := range s.messages[deviceID] {
+		if msg.ConversationID == conversationID {
+			m := msg.Message
+			messages = append(messages, &m)
+		}
+	}
+	return messages, nil
+}
+
+func (s *InMemoryStore) DeleteConversation(ctx context.Context, deviceID string, conversationID string) error {
+	s.Lock()
+	defer s.Unlock()
+	s.filter(deviceID, func(msg storedMessage) bool {
+		return msg.ConversationID != conversationID
+	})
+	return nil
+}
+
+func (s *InMemoryStore) DeleteMessages(ctx context.Context, deviceID string) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.messages, deviceID)
+	delete(s.summaries, deviceID)
+	return nil
+}
+
+func (s *InMemoryStore) RemoveExpired(ctx context.Context, before time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+	for deviceID := range s.messages {
+		s.filter(deviceID, func(msg storedMessage) bool {
+			return !msg.CreatedAt.Before(before)
+		})
+	}
+	return nil
+}
+
+// filter 只保留 keep 返回 true 的消息, 调用方需持有写锁
+func (s *InMemoryStore) filter(deviceID string, keep func(msg storedMessage) bool) {
+	list := make([]storedMessage, 0, len(s.messages[deviceID]))
+	for _, msg := range s.messages[deviceID] {
+		if keep(msg) {
+			list = append(list, msg)
+		}
+	}
+	if len(list) == 0 {
+		delete(s.messages, deviceID)
+		return
+	}
+	s.messages[deviceID] = list
+}
+
+func (s *InMemoryStore) SetSystemPrompt(ctx context.Context, deviceID string, prompt string) error {
+	s.Lock()
+	defer s.Unlock()
+	s.systemPrompts[deviceID] = prompt
+	return nil
+}
+
+func (s *InMemoryStore) GetSystemPrompt(ctx context.Context, deviceID string) (string, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.systemPrompts[deviceID], nil
+}
+
+func (s *InMemoryStore) SetSummary(ctx context.Context, deviceID string, summary string) error {
+	s.Lock()
+	defer s.Unlock()
+	s.summaries[deviceID] = summary
+	return nil
+}
+
+func (s *InMemoryStore) GetSummary(ctx context.Context, deviceID string) (string, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.summaries[deviceID], nil
+}
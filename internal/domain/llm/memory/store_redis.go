@@ -0,0 +1,216 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/redis/go-redis/v9"
+
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+// RedisStore 对话记忆的 Redis 存储
+// 每个设备一个 sorted set, 分数为纳秒时间戳, 成员为消息的 json, 会话ID 作为附加字段写入成员
+type RedisStore struct {
+	redisClient *redis.Client
+	keyPrefix   string
+	retention   time.Duration
+}
+
+// redisMessage 兼容旧数据, 旧成员只有 schema.Message 的字段, 解析后会话ID为空
+type redisMessage struct {
+	schema.Message
+	ConversationID string `json:"conversation_id,omitempty"`
+}
+
+func NewRedisStore(redisClient *redis.Client, keyPrefix string, retention time.Duration) *RedisStore {
+	return &RedisStore{
+		redisClient: redisClient,
+		keyPrefix:   keyPrefix,
+		retention:   retention,
+	}
+}
+
+// getMemoryKey 生成设备对应的 Redis key
+func (s *RedisStore) getMemoryKey(deviceID string) string {
+	return fmt.Sprintf("%s:llm:%s", s.keyPrefix, deviceID)
+}
+
+// getSystemPromptKey 生成设备对应的系统 prompt 的 Redis key
+func (s *RedisStore) getSystemPromptKey(deviceID string) string {
+	return fmt.Sprintf("%s:llm:system:%s", s.keyPrefix, deviceID)
+}
+
+// getSummaryKey 生成设备对应的对话摘要的 Redis key
+func (s *RedisStore) getSummaryKey(deviceID string) string {
+	return fmt.Sprintf("%s:llm:summary:%s", s.keyPrefix, deviceID)
+}
+
+// getDevicesKey 记录有对话记忆的设备, 用于按保留时长清理
+func (s *RedisStore) getDevicesKey() string {
+	return fmt.Sprintf("%s:llm:devices", s.keyPrefix)
+}
+
+func (s *RedisStore) AddMessage(ctx context.Context, deviceID string, conversationID string, msg schema.Message) error {
+	msgBytes, err := json.Marshal(redisMessage{Message: msg, ConversationID: conversationID})
+	if err != nil {
+		return fmt.Errorf("marshal message failed: %w", err)
+	}
+
+	key := s.getMemoryKey(deviceID)
+	// 使用纳秒时间戳作为分数
+	score := float64(time.Now().UnixNano())
+
+	log.Debugf("添加消息到记忆体: %s, %s", key, string(msgBytes))
+
+	pipe := s.redisClient.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{
+		Score:  score,
+		Member: string(msgBytes),
+	})
+	pipe.SAdd(ctx, s.getDevicesKey(), deviceID)
+	if s.retention > 0 {
+		// 设备长期不活跃时整个 key 过期
+		pipe.Expire(ctx, key, s.retention)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisStore) GetMessages(ctx context.Context, deviceID string, count int) ([]*schema.Message, error) {
+	// 分数（时间戳）小的在前, 取最后 count 条即为最新的消息, 且旧消息在前
+	results, err := s.redisClient.ZRange(ctx, s.getMemoryKey(deviceID), int64(-count), -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get messages failed: %w", err)
+	}
+
+	messages := make([]*schema.Message, 0, len(results))
+	for _, result := range results {
+		var msg redisMessage
+		if err := json.Unmarshal([]byte(result), &msg); err != nil {
+			return nil, fmt.Errorf("unmarshal message failed: %w", err)
+		}
+		messages = append(messages, &msg.Message)
+	}
+	return messages, nil
+}
+
+// getAll 获取设备的全部消息及其成员原文
+func (s *RedisStore) getAll(ctx context.Context, deviceID string) ([]storedMessage, []string, error) {
+	results, err := s.redisClient.ZRangeWithScores(ctx, s.getMemoryKey(deviceID), 0, -1).Result()
+	if err != nil {
+		return nil, nil, fmt.Errorf("get messages failed: %w", err)
+	}
+
+	messages := make([]storedMessage, 0, len(results))
+	members := make([]string, 0, len(results))
+	for _, result := range results {
+		member, _ := result.Member.(string)
+		var msg redisMessage
+		if err := json.Unmarshal([]byte(member), &msg); err != nil {
+			return nil, nil, fmt.Errorf("unmarshal message failed: %w", err)
+		}
+		messages = append(messages, storedMessage{
+			ConversationID: msg.ConversationID,
+			CreatedAt:      time.Unix(0, int64(result.Score)),
+			Message:        msg.Message,
+		})
+		members = append(members, member)
+	}
+	return messages, members, nil
+}
+
+func (s *RedisStore) ListConversations(ctx context.Context, deviceID string) ([]Conversation, error) {
+	messages, _, err := s.getAll(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	return groupConversations(deviceID, messages), nil
+}
+
+func (s *RedisStore) GetConversation(ctx context.Context, deviceID string, conversationID string) ([]*schema.Message, error) {
+	messages, _, err := s.getAll(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*schema.Message, 0)
+	for i := range messages {
+		if messages[i].ConversationID == conversationID {
+			result = append(result, &messages[i].Message)
+		}
+	}
+	return result, nil
+}
+
+func (s *RedisStore) DeleteConversation(ctx context.Context, deviceID string, conversationID string) error {
+	messages, members, err := s.getAll(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	remove := make([]interface{}, 0)
+	for i := range messages {
+		if messages[i].ConversationID == conversationID {
+			remove = append(remove, members[i])
+		}
+	}
+	if len(remove) == 0 {
+		return nil
+	}
+	return s.redisClient.ZRem(ctx, s.getMemoryKey(deviceID), remove...).Err()
+}
+
+func (s *RedisStore) DeleteMessages(ctx context.Context, deviceID string) error {
+	if err := s.redisClient.Del(ctx, s.getMemoryKey(deviceID), s.getSummaryKey(deviceID)).Err(); err != nil {
+		return fmt.Errorf("delete history failed: %w", err)
+	}
+	return s.redisClient.SRem(ctx, s.getDevicesKey(), deviceID).Err()
+}
+
+func (s *RedisStore) RemoveExpired(ctx context.Context, before time.Time) error {
+	deviceIDs, err := s.redisClient.SMembers(ctx, s.getDevicesKey()).Result()
+	if err != nil {
+		return fmt.Errorf("get devices failed: %w", err)
+	}
+	score := fmt.Sprintf("%d", before.UnixNano())
+	for _, deviceID := range deviceIDs {
+		key := s.getMemoryKey(deviceID)
+		if err := s.redisClient.ZRemRangeByScore(ctx, key, "-inf", score).Err(); err != nil {
+			return fmt.Errorf("remove expired messages failed: %w", err)
+		}
+		count, err := s.redisClient.ZCard(ctx, key).Result()
+		if err == nil && count == 0 {
+			s.redisClient.SRem(ctx, s.getDevicesKey(), deviceID)
+		}
+	}
+	return nil
+}
+
+func (s *RedisStore) SetSystemPrompt(ctx context.Context, deviceID string, prompt string) error {
+	return s.redisClient.Set(ctx, s.getSystemPromptKey(deviceID), prompt, 0).Err()
+}
+
+func (s *RedisStore) GetSystemPrompt(ctx context.Context, deviceID string) (string, error) {
+	return s.getString(ctx, s.getSystemPromptKey(deviceID))
+}
+
+func (s *RedisStore) SetSummary(ctx context.Context, deviceID string, summary string) error {
+	return s.redisClient.Set(ctx, s.getSummaryKey(deviceID), summary, 0).Err()
+}
+
+func (s *RedisStore) GetSummary(ctx context.Context, deviceID string) (string, error) {
+	return s.getString(ctx, s.getSummaryKey(deviceID))
+}
+
+func (s *RedisStore) getString(ctx context.Context, key string) (string, error) {
+	result, err := s.redisClient.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("get %s failed: %w", key, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,222 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	SqlDriverSqlite = "sqlite"
+	SqlDriverMysql  = "mysql"
+)
+
+// ChatMessage 对话消息表, Data 为 schema.Message 的 json, Role/Content 冗余存储便于查询
+type ChatMessage struct {
+	ID             uint64    `gorm:"primaryKey;autoIncrement"`
+	DeviceID       string    `gorm:"size:128;index:idx_device_conversation"`
+	ConversationID string    `gorm:"size:128;index:idx_device_conversation"`
+	Role           string    `gorm:"size:32"`
+	Content        string    `gorm:"type:longtext"` // mysql 的 text 最长 64KB, 工具调用结果可能更长
+	Data           string    `gorm:"type:longtext"`
+	CreatedAt      time.Time `gorm:"index"`
+}
+
+// ChatMemoryMeta 设备的系统 prompt 与对话摘要
+type ChatMemoryMeta struct {
+	DeviceID     string `gorm:"primaryKey;size:128"`
+	SystemPrompt string `gorm:"type:text"`
+	Summary      string `gorm:"type:text"`
+	UpdatedAt    time.Time
+}
+
+// SqlStore 对话记忆的 SQL 存储, 支持 sqlite 和 mysql
+type SqlStore struct {
+	db *gorm.DB
+}
+
+// NewSqlStore 连接数据库并自动建表
+func NewSqlStore(driver string, dsn string) (*SqlStore, error) {
+	var dialector gorm.Dialector
+	switch driver {
+	case "", SqlDriverSqlite:
+		if dir := filepath.Dir(dsn); dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, fmt.Errorf("创建数据库目录失败: %v", err)
+			}
+		}
+		dialector = sqlite.Open(dsn)
+	case SqlDriverMysql:
+		dialector = mysql.Open(dsn)
+	default:
+		return nil, fmt.Errorf("不支持的数据库类型: %s", driver)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, fmt.Errorf("连接对话记忆数据库失败: %v", err)
+	}
+	return NewSqlStoreWithDB(db)
+}
+
+// NewSqlStoreWithDB 使用已有的数据库连接
+func NewSqlStoreWithDB(db *gorm.DB) (*SqlStore, error) {
+	if err := db.AutoMigrate(&ChatMessage{}, &ChatMemoryMeta{}); err != nil {
+		return nil, fmt.Errorf("创建对话记忆表失败: %v", err)
+	}
+	return &SqlStore{db: db}, nil
+}
+
+func (s *SqlStore) AddMessage(ctx context.Context, deviceID string, conversationID string, msg schema.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal message failed: %w", err)
+	}
+	return s.db.WithContext(ctx).Create(&ChatMessage{
+		DeviceID:       deviceID,
+		ConversationID: conversationID,
+		Role:           string(msg.Role),
+		Content:        msg.Content,
+		Data:           string(data),
+		CreatedAt:      time.Now(),
+	}).Error
+}
+
+func (s *SqlStore) GetMessages(ctx context.Context, deviceID string, count int) ([]*schema.Message, error) {
+	var rows []ChatMessage
+	err := s.db.WithContext(ctx).Where("device_id = ?", deviceID).Order("id DESC").Limit(count).Find(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("get messages failed: %w", err)
+	}
+	// 按时间从旧到新返回
+	for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+		rows[i], rows[j] = rows[j], rows[i]
+	}
+	return decodeChatMessages(rows)
+}
+
+func (s *SqlStore) ListConversations(ctx context.Context, deviceID string) ([]Conversation, error) {
+	var rows []struct {
+		ConversationID string
+		MessageCount   int
+		StartedAt      string
+		UpdatedAt      string
+	}
+	// sqlite 的聚合结果为字符串, 统一按字符串读取后解析
+	err := s.db.WithContext(ctx).Model(&ChatMessage{}).
+		Select("conversation_id, COUNT(*) AS message_count, MIN(created_at) AS started_at, MAX(created_at) AS updated_at").
+		Where("device_id = ?", deviceID).
+		Group("conversation_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("list conversations failed: %w", err)
+	}
+
+	conversations := make([]Conversation, 0, len(rows))
+	for _, row := range rows {
+		conversations = append(conversations, Conversation{
+			ID:           row.ConversationID,
+			DeviceID:     deviceID,
+			MessageCount: row.MessageCount,
+			StartedAt:    parseSqlTime(row.StartedAt),
+			UpdatedAt:    parseSqlTime(row.UpdatedAt),
+		})
+	}
+	sortConversations(conversations)
+	return conversations, nil
+}
+
+// parseSqlTime 解析聚合函数返回的时间, 兼容 sqlite 与 mysql 的格式
+func parseSqlTime(value string) time.Time {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *SqlStore) GetConversation(ctx context.Context, deviceID string, conversationID string) ([]*schema.Message, error) {
+	var rows []ChatMessage
+	err := s.db.WithContext(ctx).Where("device_id = ? AND conversation_id = ?", deviceID, conversationID).Order("id ASC").Find(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("get conversation failed: %w", err)
+	}
+	return decodeChatMessages(rows)
+}
+
+func (s *SqlStore) DeleteConversation(ctx context.Context, deviceID string, conversationID string) error {
+	return s.db.WithContext(ctx).Where("device_id = ? AND conversation_id = ?", deviceID, conversationID).Delete(&ChatMessage{}).Error
+}
+
+func (s *SqlStore) DeleteMessages(ctx context.Context, deviceID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("device_id = ?", deviceID).Delete(&ChatMessage{}).Error; err != nil {
+			return fmt.Errorf("delete history failed: %w", err)
+		}
+		return tx.Model(&ChatMemoryMeta{}).Where("device_id = ?", deviceID).Update("summary", "").Error
+	})
+}
+
+func (s *SqlStore) RemoveExpired(ctx context.Context, before time.Time) error {
+	return s.db.WithContext(ctx).Where("created_at < ?", before).Delete(&ChatMessage{}).Error
+}
+
+func (s *SqlStore) SetSystemPrompt(ctx context.Context, deviceID string, prompt string) error {
+	return s.upsertMeta(ctx, ChatMemoryMeta{DeviceID: deviceID, SystemPrompt: prompt}, "system_prompt")
+}
+
+func (s *SqlStore) GetSystemPrompt(ctx context.Context, deviceID string) (string, error) {
+	meta, err := s.getMeta(ctx, deviceID)
+	return meta.SystemPrompt, err
+}
+
+func (s *SqlStore) SetSummary(ctx context.Context, deviceID string, summary string) error {
+	return s.upsertMeta(ctx, ChatMemoryMeta{DeviceID: deviceID, Summary: summary}, "summary")
+}
+
+func (s *SqlStore) GetSummary(ctx context.Context, deviceID string) (string, error) {
+	meta, err := s.getMeta(ctx, deviceID)
+	return meta.Summary, err
+}
+
+func (s *SqlStore) upsertMeta(ctx context.Context, meta ChatMemoryMeta, column string) error {
+	meta.UpdatedAt = time.Now()
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "device_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{column, "updated_at"}),
+	}).Create(&meta).Error
+}
+
+func (s *SqlStore) getMeta(ctx context.Context, deviceID string) (ChatMemoryMeta, error) {
+	var metas []ChatMemoryMeta
+	if err := s.db.WithContext(ctx).Where("device_id = ?", deviceID).Limit(1).Find(&metas).Error; err != nil {
+		return ChatMemoryMeta{}, fmt.Errorf("get memory meta failed: %w", err)
+	}
+	if len(metas) == 0 {
+		return ChatMemoryMeta{}, nil
+	}
+	return metas[0], nil
+}
+
+func decodeChatMessages(rows []ChatMessage) ([]*schema.Message, error) {
+	messages := make([]*schema.Message, 0, len(rows))
+	for _, row := range rows {
+		var msg schema.Message
+		if err := json.Unmarshal([]byte(row.Data), &msg); err != nil {
+			return nil, fmt.Errorf("unmarshal message failed: %w", err)
+		}
+		messages = append(messages, &msg)
+	}
+	return messages, nil
+}
@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestMemoryStores(t *testing.T) {
+	sqlStore, err := NewSqlStore(SqlDriverSqlite, filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatalf("NewSqlStore: %v", err)
+	}
+
+	stores := map[string]MemoryStore{
+		StoreTypeMemory: NewInMemoryStore(),
+		StoreTypeSql:    sqlStore,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			testMemoryStore(t, store)
+		})
+	}
+}
+
+func testMemoryStore(t *testing.T, store MemoryStore) {
+	ctx := context.Background()
+	m := NewMemoryWithStore(store, time.Hour)
+
+	add := func(conversationID string, role schema.RoleType, content string) {
+		if err := m.AddMessage(ctx, "dev-1", conversationID, schema.Message{Role: role, Content: content}); err != nil {
+			t.Fatalf("AddMessage: %v", err)
+		}
+	}
+	add("s1", schema.User, "你好")
+	add("s1", schema.Assistant, "你好呀")
+	time.Sleep(10 * time.Millisecond)
+	add("s2", schema.User, "今天天气怎么样")
+	add("s2", schema.Assistant, "今天晴天")
+
+	messages, err := m.GetMessages(ctx, "dev-1", 3)
+	if err != nil || len(messages) != 3 || messages[0].Content != "你好呀" || messages[2].Content != "今天晴天" {
+		t.Fatalf("GetMessages = %+v, err = %v", messages, err)
+	}
+
+	conversations, err := m.ListConversations(ctx, "dev-1")
+	if err != nil || len(conversations) != 2 {
+		t.Fatalf("ListConversations = %+v, err = %v", conversations, err)
+	}
+	if conversations[0].ID != "s2" || conversations[0].MessageCount != 2 || conversations[0].StartedAt.IsZero() {
+		t.Fatalf("最新的会话应排在前面: %+v", conversations)
+	}
+
+	exports, err := m.Export(ctx, "dev-1")
+	if err != nil || len(exports) != 2 || len(exports[1].Messages) != 2 || exports[1].Messages[0].Content != "你好" {
+		t.Fatalf("Export = %+v, err = %v", exports, err)
+	}
+
+	if err := m.DeleteConversation(ctx, "dev-1", "s1"); err != nil {
+		t.Fatalf("DeleteConversation: %v", err)
+	}
+	if messages, _ := m.GetConversation(ctx, "dev-1", "s1"); len(messages) != 0 {
+		t.Fatalf("会话 s1 未删除: %+v", messages)
+	}
+	if messages, _ := m.GetConversation(ctx, "dev-1", "s2"); len(messages) != 2 {
+		t.Fatalf("不应删除其他会话: %+v", messages)
+	}
+
+	if err := m.SetSummary(ctx, "dev-1", "摘要"); err != nil {
+		t.Fatalf("SetSummary: %v", err)
+	}
+	if err := m.SetSystemPrompt(ctx, "dev-1", "你是小智"); err != nil {
+		t.Fatalf("SetSystemPrompt: %v", err)
+	}
+	if summary, _ := m.GetSummary(ctx, "dev-1"); summary != "摘要" {
+		t.Fatalf("GetSummary = %s", summary)
+	}
+	if prompt, _ := m.GetSystemPrompt(ctx, "dev-1"); prompt.Content != "你是小智" {
+		t.Fatalf("GetSystemPrompt = %+v", prompt)
+	}
+
+	// 超过保留时长的消息被清理
+	if err := store.RemoveExpired(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("RemoveExpired: %v", err)
+	}
+	if messages, _ := m.GetMessages(ctx, "dev-1", 10); len(messages) != 0 {
+		t.Fatalf("过期消息未清理: %+v", messages)
+	}
+
+	if err := m.ResetMemory(ctx, "dev-1"); err != nil {
+		t.Fatalf("ResetMemory: %v", err)
+	}
+	if summary, _ := m.GetSummary(ctx, "dev-1"); summary != "" {
+		t.Fatalf("重置后摘要应清空: %s", summary)
+	}
+}
+
+func TestInMemoryStoreLimit(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryStore()
+	total := maxInMemoryMessages*2 + 10
+	for i := 0; i < total; i++ {
+		if err := store.AddMessage(ctx, "dev-1", "s1", schema.Message{Role: schema.User, Content: strconv.Itoa(i)}); err != nil {
+			t.Fatalf("AddMessage: %v", err)
+		}
+	}
+	if n := len(store.messages["dev-1"]); n > maxInMemoryMessages+maxInMemoryMessages/4 {
+		t.Fatalf("保留了 %d 条消息, 超出上限", n)
+	}
+	messages, err := store.GetMessages(ctx, "dev-1", 0)
+	if err != nil || len(messages) != maxInMemoryMessages {
+		t.Fatalf("GetMessages = %d 条, err = %v", len(messages), err)
+	}
+	if first, last := messages[0].Content, messages[len(messages)-1].Content; first != strconv.Itoa(total-maxInMemoryMessages) || last != strconv.Itoa(total-1) {
+		t.Fatalf("应保留最新的消息, first = %s, last = %s", first, last)
+	}
+}
@@ -64,3 +64,22 @@ var MemorySummaryPrompt = `
   ]
 }
 ` + "```"
+
+// LongTermMemoryPrompt 长期记忆总结提示词, 输出早期对话摘要和用户的长期事实
+var LongTermMemoryPrompt = `
+# 长期记忆整理
+
+## 任务
+根据已有摘要和新的对话记录:
+1. 更新对话摘要: 合并已有摘要与新对话中值得延续的话题、约定和未完成的事项, 不超过300字
+2. 提取用户的长期事实: 只保留长期有效的信息, 如名字、年龄、喜好、习惯、家庭成员、宠物、重要日期等, 每条事实为一句完整的陈述句, 如"用户叫张三"、"用户的女儿叫小美, 今年5岁"
+3. 用户更正过的信息以最新的为准, 一时的情绪、闲聊内容和助手说的话不作为事实
+
+## 输出格式
+输出必须为可解析的json字符串, 不需要解释、注释和说明, 没有新事实时 facts 为空数组
+` + "```" + `json
+{
+  "summary": "对话摘要",
+  "facts": ["用户叫张三", "用户喜欢听周杰伦的歌"]
+}
+` + "```"
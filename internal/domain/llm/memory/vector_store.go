@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryItem 一条长期记忆, 如用户的名字、喜好、家庭成员等
+type MemoryItem struct {
+	ID        string    `json:"id"`
+	Text      string    `json:"text"`
+	Vector    []float64 `json:"vector"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Score     float64   `json:"score,omitempty"` //检索时的相似度
+}
+
+// VectorStore 长期记忆的向量存储, 按设备隔离
+type VectorStore interface {
+	// Upsert 按 ID 写入或覆盖记忆
+	Upsert(ctx context.Context, deviceID string, items []MemoryItem) error
+	// Search 返回与 vector 相似度不低于 minScore 的前 topK 条记忆, 相似度从高到低
+	Search(ctx context.Context, deviceID string, vector []float64, topK int, minScore float64) ([]MemoryItem, error)
+	// Delete 删除设备的全部长期记忆
+	Delete(ctx context.Context, deviceID string) error
+}
+
+const VectorStoreMemory = "memory"
+
+// NewVectorStore 按类型创建向量存储
+func NewVectorStore(storeType string, maxItems int) (VectorStore, error) {
+	switch storeType {
+	case "", VectorStoreMemory:
+		return NewInMemoryVectorStore(maxItems), nil
+	default:
+		return nil, fmt.Errorf("不支持的向量存储类型: %s", storeType)
+	}
+}
+
+// InMemoryVectorStore 进程内向量存储, 暴力计算余弦相似度, 单设备记忆条数有限时足够快
+type InMemoryVectorStore struct {
+	items    map[string][]MemoryItem
+	maxItems int //每个设备最多保留的记忆条数, 超出时淘汰最久未更新的
+	sync.RWMutex
+}
+
+func NewInMemoryVectorStore(maxItems int) *InMemoryVectorStore {
+	return &InMemoryVectorStore{
+		items:    make(map[string][]MemoryItem),
+		maxItems: maxItems,
+	}
+}
+
+func (s *InMemoryVectorStore) Upsert(ctx context.Context, deviceID string, items []MemoryItem) error {
+	s.Lock()
+	defer s.Unlock()
+
+	list := s.items[deviceID]
+	for _, item := range items {
+		replaced := false
+		for i := range list {
+			if list[i].ID == item.ID {
+				list[i] = item
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			list = append(list, item)
+		}
+	}
+
+	if s.maxItems > 0 && len(list) > s.maxItems {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].UpdatedAt.After(list[j].UpdatedAt)
+		})
+		list = list[:s.maxItems]
+	}
+	s.items[deviceID] = list
+	return nil
+}
+
+func (s *InMemoryVectorStore) Search(ctx context.Context, deviceID string, vector []float64, topK int, minScore float64) ([]MemoryItem, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	result := make([]MemoryItem, 0)
+	for _, item := range s.items[deviceID] {
+		score := cosineSimilarity(vector, item.Vector)
+		if score < minScore {
+			continue
+		}
+		item.Score = score
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Score > result[j].Score
+	})
+	if topK > 0 && len(result) > topK {
+		result = result[:topK]
+	}
+	return result, nil
+}
+
+func (s *InMemoryVectorStore) Delete(ctx context.Context, deviceID string) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.items, deviceID)
+	return nil
+}
+
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
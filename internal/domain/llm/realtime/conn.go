@@ -0,0 +1,212 @@
+package realtime
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+const AudioFormatPcm16 = "pcm16"
+
+// 服务端事件类型
+const (
+	EventSessionCreated          = "session.created"
+	EventSessionUpdated          = "session.updated"
+	EventSpeechStarted           = "input_audio_buffer.speech_started"
+	EventSpeechStopped           = "input_audio_buffer.speech_stopped"
+	EventInputTranscriptionDone  = "conversation.item.input_audio_transcription.completed"
+	EventResponseCreated         = "response.created"
+	EventResponseAudioDelta      = "response.audio.delta"
+	EventResponseTranscriptDelta = "response.audio_transcript.delta"
+	EventResponseTranscriptDone  = "response.audio_transcript.done"
+	EventFunctionCallArgsDone    = "response.function_call_arguments.done"
+	EventResponseDone            = "response.done"
+	EventError                   = "error"
+)
+
+// Session 会话配置, 对应 session.update 事件的 session 字段
+type Session struct {
+	Instructions            string                   `json:"instructions,omitempty"`
+	Voice                   string                   `json:"voice,omitempty"`
+	Modalities              []string                 `json:"modalities,omitempty"`
+	InputAudioFormat        string                   `json:"input_audio_format,omitempty"`
+	OutputAudioFormat       string                   `json:"output_audio_format,omitempty"`
+	InputAudioTranscription *InputAudioTranscription `json:"input_audio_transcription,omitempty"`
+	TurnDetection           *TurnDetection           `json:"turn_detection,omitempty"`
+	Tools                   []Tool                   `json:"tools,omitempty"`
+	ToolChoice              string                   `json:"tool_choice,omitempty"`
+}
+
+type InputAudioTranscription struct {
+	Model string `json:"model"`
+}
+
+// TurnDetection 服务端vad, 由模型判断用户何时说完并自动生成回复
+type TurnDetection struct {
+	Type              string  `json:"type"`
+	Threshold         float64 `json:"threshold,omitempty"`
+	SilenceDurationMs int     `json:"silence_duration_ms,omitempty"`
+}
+
+// Tool 函数工具描述, parameters 为 JSON Schema
+type Tool struct {
+	Type        string      `json:"type"`
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Parameters  interface{} `json:"parameters,omitempty"`
+}
+
+// ServerEvent 服务端事件, 只解析用到的字段
+type ServerEvent struct {
+	Type       string       `json:"type"`
+	EventID    string       `json:"event_id"`
+	ResponseID string       `json:"response_id"`
+	ItemID     string       `json:"item_id"`
+	Delta      string       `json:"delta"`
+	Transcript string       `json:"transcript"`
+	CallID     string       `json:"call_id"`
+	Name       string       `json:"name"`
+	Arguments  string       `json:"arguments"`
+	Error      *ServerError `json:"error,omitempty"`
+}
+
+type ServerError struct {
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Audio 解码 response.audio.delta 中的 pcm16 音频
+func (e *ServerEvent) Audio() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(e.Delta)
+}
+
+// Conn 实时语音会话连接
+type Conn struct {
+	ws     *websocket.Conn
+	events chan ServerEvent
+	done   chan struct{}
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+}
+
+// Dial 连接实时语音服务, 模型名称通过 model 查询参数传递
+func Dial(ctx context.Context, config Config) (*Conn, error) {
+	u, err := url.Parse(config.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("解析实时语音地址失败: %v", err)
+	}
+	query := u.Query()
+	query.Set("model", config.ModelName)
+	u.RawQuery = query.Encode()
+
+	header := http.Header{}
+	if config.APIKey != "" {
+		header.Set("Authorization", "Bearer "+config.APIKey)
+	}
+	header.Set("OpenAI-Beta", "realtime=v1")
+
+	ws, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		return nil, fmt.Errorf("连接实时语音服务失败: %v", err)
+	}
+
+	c := &Conn{
+		ws:     ws,
+		events: make(chan ServerEvent, 100),
+		done:   make(chan struct{}),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+func (c *Conn) readLoop() {
+	defer close(c.events)
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			log.Debugf("实时语音连接读取结束: %v", err)
+			return
+		}
+		var event ServerEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			log.Errorf("解析实时语音事件失败: %v", err)
+			continue
+		}
+		select {
+		case c.events <- event:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Events 服务端事件, 连接断开后关闭
+func (c *Conn) Events() <-chan ServerEvent {
+	return c.events
+}
+
+func (c *Conn) send(event map[string]interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.ws.WriteJSON(event); err != nil {
+		return fmt.Errorf("发送实时语音事件 %v 失败: %v", event["type"], err)
+	}
+	return nil
+}
+
+// UpdateSession 更新会话配置
+func (c *Conn) UpdateSession(session Session) error {
+	return c.send(map[string]interface{}{
+		"type":    "session.update",
+		"session": session,
+	})
+}
+
+// AppendAudio 追加用户音频, pcm 为 24kHz 单声道 pcm16 小端数据
+func (c *Conn) AppendAudio(pcm []byte) error {
+	return c.send(map[string]interface{}{
+		"type":  "input_audio_buffer.append",
+		"audio": base64.StdEncoding.EncodeToString(pcm),
+	})
+}
+
+// SendToolResult 返回工具调用结果, 需再调用 CreateResponse 让模型继续回复
+func (c *Conn) SendToolResult(callID string, output string) error {
+	return c.send(map[string]interface{}{
+		"type": "conversation.item.create",
+		"item": map[string]interface{}{
+			"type":    "function_call_output",
+			"call_id": callID,
+			"output":  output,
+		},
+	})
+}
+
+// CreateResponse 请求模型生成回复
+func (c *Conn) CreateResponse() error {
+	return c.send(map[string]interface{}{"type": "response.create"})
+}
+
+// CancelResponse 取消正在生成的回复, 用于用户插话
+func (c *Conn) CancelResponse() error {
+	return c.send(map[string]interface{}{"type": "response.cancel"})
+}
+
+func (c *Conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.ws.Close()
+	})
+	return err
+}
@@ -0,0 +1,149 @@
+// Package realtime 实时语音模型, 使用 OpenAI Realtime 兼容的 websocket 协议
+// 选用后会话跳过 vad/asr/tts, 设备音频直接发送给模型, 模型返回的音频直接下发给设备
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/cloudwego/eino/schema"
+
+	"xiaozhi-esp32-server-golang/constants"
+	"xiaozhi-esp32-server-golang/internal/domain/llm"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+// SampleRate 实时语音协议 pcm16 音频的采样率
+const SampleRate = 24000
+
+// ErrTextUnsupported 实时语音模型只能通过语音会话使用, 文本对话、注入消息和记忆总结返回此错误
+var ErrTextUnsupported = errors.New("实时语音模型不支持文本对话, 请通过语音会话使用")
+
+func init() {
+	configSchema := registry.ConfigSchema{
+		Description: "实时语音模型(OpenAI Realtime 兼容协议), 选用后设备音频直接与模型对话, 不再经过vad/asr/tts",
+		Fields: []registry.ConfigField{
+			{Name: "type", Type: registry.FieldTypeString, Required: true, Description: "接口类型, 固定为 realtime"},
+			{Name: "base_url", Type: registry.FieldTypeString, Required: true, Description: "websocket 地址, 如 wss://api.openai.com/v1/realtime"},
+			{Name: "model_name", Type: registry.FieldTypeString, Required: true, Description: "模型名称"},
+			{Name: "api_key", Type: registry.FieldTypeString, Description: "API密钥"},
+			{Name: "voice", Type: registry.FieldTypeString, Description: "音色"},
+			{Name: "transcription_model", Type: registry.FieldTypeString, Description: "用户语音转写模型, 为空时不返回用户文本"},
+			{Name: "vad_threshold", Type: registry.FieldTypeNumber, Description: "服务端vad阈值"},
+			{Name: "silence_duration_ms", Type: registry.FieldTypeNumber, Description: "静音多久判定说话结束(毫秒)"},
+		},
+	}
+	factory := func(config map[string]interface{}) (llm.LLMProvider, error) {
+		return NewProvider(config)
+	}
+	llm.Register(constants.LlmTypeRealtime, factory, configSchema)
+}
+
+// Config 实时语音模型配置
+type Config struct {
+	BaseURL            string  `json:"base_url"`
+	ModelName          string  `json:"model_name"`
+	APIKey             string  `json:"api_key"`
+	Voice              string  `json:"voice"`
+	TranscriptionModel string  `json:"transcription_model"`
+	VadThreshold       float64 `json:"vad_threshold"`
+	SilenceDurationMs  int     `json:"silence_duration_ms"`
+}
+
+// Provider 实时语音模型提供者
+// 实现 llm.LLMProvider 以便按智能体的LLM配置选择, 但文本对话接口不可用, 需通过 Dial 建立语音会话
+type Provider struct {
+	config Config
+}
+
+// NewProvider 创建实时语音模型提供者
+func NewProvider(config map[string]interface{}) (*Provider, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("序列化实时语音模型配置失败: %v", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("解析实时语音模型配置失败: %v", err)
+	}
+	if cfg.BaseURL == "" || cfg.ModelName == "" {
+		return nil, fmt.Errorf("实时语音模型缺少 base_url 或 model_name")
+	}
+	return &Provider{config: cfg}, nil
+}
+
+// Dial 建立实时语音会话, 并按 session 设置提示词、音色、工具等
+func (p *Provider) Dial(ctx context.Context, session Session) (*Conn, error) {
+	conn, err := Dial(ctx, p.config)
+	if err != nil {
+		return nil, err
+	}
+
+	session.Modalities = []string{"text", "audio"}
+	session.InputAudioFormat = AudioFormatPcm16
+	session.OutputAudioFormat = AudioFormatPcm16
+	if session.Voice == "" {
+		session.Voice = p.config.Voice
+	}
+	if p.config.TranscriptionModel != "" {
+		session.InputAudioTranscription = &InputAudioTranscription{Model: p.config.TranscriptionModel}
+	}
+	session.TurnDetection = &TurnDetection{
+		Type:              "server_vad",
+		Threshold:         p.config.VadThreshold,
+		SilenceDurationMs: p.config.SilenceDurationMs,
+	}
+	if err := conn.UpdateSession(session); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// ResponseWithContext 不支持文本对话, 调用方应先判断提供者类型并返回 ErrTextUnsupported, 此处只返回已关闭的通道
+func (p *Provider) ResponseWithContext(ctx context.Context, sessionID string, dialogue []*schema.Message, functions []*schema.ToolInfo) chan *schema.Message {
+	log.Errorf("实时语音模型 %s: %v", p.config.ModelName, ErrTextUnsupported)
+	msgChan := make(chan *schema.Message)
+	close(msgChan)
+	return msgChan
+}
+
+func (p *Provider) ResponseWithVllm(ctx context.Context, file []byte, text string, mimeType string) (string, error) {
+	return "", fmt.Errorf("实时语音模型不支持图片识别")
+}
+
+func (p *Provider) GetModelInfo() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       constants.LlmTypeRealtime,
+		"model_name": p.config.ModelName,
+		"voice":      p.config.Voice,
+	}
+}
+
+// ToolsFromEino 将 Eino 工具描述转换为实时语音协议的函数工具
+func ToolsFromEino(infos []*schema.ToolInfo) []Tool {
+	tools := make([]Tool, 0, len(infos))
+	for _, info := range infos {
+		if info == nil {
+			continue
+		}
+		tool := Tool{
+			Type:        "function",
+			Name:        info.Name,
+			Description: info.Desc,
+		}
+		if info.ParamsOneOf != nil {
+			params, err := info.ParamsOneOf.ToOpenAPIV3()
+			if err != nil {
+				log.Errorf("转换工具 %s 参数失败: %v", info.Name, err)
+				continue
+			}
+			tool.Parameters = params
+		}
+		tools = append(tools, tool)
+	}
+	return tools
+}
@@ -0,0 +1,119 @@
+package realtime
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeServer 模拟实时语音服务, 记录收到的客户端事件, 连接后下发 events
+func fakeServer(t *testing.T, events []string, received chan map[string]interface{}) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("Authorization = %s", r.Header.Get("Authorization"))
+		}
+		if r.URL.Query().Get("model") != "test-model" {
+			t.Errorf("model = %s", r.URL.Query().Get("model"))
+		}
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer ws.Close()
+		for _, event := range events {
+			ws.WriteMessage(websocket.TextMessage, []byte(event))
+		}
+		for {
+			var event map[string]interface{}
+			if err := ws.ReadJSON(&event); err != nil {
+				return
+			}
+			received <- event
+		}
+	}))
+}
+
+func receive(t *testing.T, received chan map[string]interface{}) map[string]interface{} {
+	select {
+	case event := <-received:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待客户端事件超时")
+	}
+	return nil
+}
+
+func TestProviderDial(t *testing.T) {
+	pcm := []byte{1, 0, 2, 0}
+	events := []string{
+		`{"type":"response.audio.delta","delta":"` + base64.StdEncoding.EncodeToString(pcm) + `"}`,
+		`{"type":"response.function_call_arguments.done","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"北京\"}"}`,
+	}
+	received := make(chan map[string]interface{}, 10)
+	server := fakeServer(t, events, received)
+	defer server.Close()
+
+	provider, err := NewProvider(map[string]interface{}{
+		"type":                "realtime",
+		"base_url":            "ws" + strings.TrimPrefix(server.URL, "http"),
+		"model_name":          "test-model",
+		"api_key":             "test-key",
+		"voice":               "alloy",
+		"silence_duration_ms": 500,
+	})
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	conn, err := provider.Dial(context.Background(), Session{Instructions: "你是小智"})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	update := receive(t, received)
+	if update["type"] != "session.update" {
+		t.Fatalf("type = %v", update["type"])
+	}
+	data, _ := json.Marshal(update["session"])
+	var session Session
+	json.Unmarshal(data, &session)
+	if session.Instructions != "你是小智" || session.Voice != "alloy" || session.InputAudioFormat != AudioFormatPcm16 {
+		t.Fatalf("session = %+v", session)
+	}
+	if session.TurnDetection == nil || session.TurnDetection.SilenceDurationMs != 500 {
+		t.Fatalf("turn_detection = %+v", session.TurnDetection)
+	}
+
+	if err := conn.AppendAudio(pcm); err != nil {
+		t.Fatalf("AppendAudio: %v", err)
+	}
+	appendEvent := receive(t, received)
+	if appendEvent["type"] != "input_audio_buffer.append" || appendEvent["audio"] != base64.StdEncoding.EncodeToString(pcm) {
+		t.Fatalf("append = %v", appendEvent)
+	}
+
+	delta := <-conn.Events()
+	audio, err := delta.Audio()
+	if err != nil || string(audio) != string(pcm) {
+		t.Fatalf("audio = %v, err = %v", audio, err)
+	}
+	call := <-conn.Events()
+	if call.Type != EventFunctionCallArgsDone || call.CallID != "call_1" || call.Name != "get_weather" {
+		t.Fatalf("call = %+v", call)
+	}
+}
+
+func TestNewProviderMissingConfig(t *testing.T) {
+	if _, err := NewProvider(map[string]interface{}{"type": "realtime"}); err == nil {
+		t.Fatal("缺少 base_url 时应返回错误")
+	}
+}
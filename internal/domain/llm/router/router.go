@@ -0,0 +1,388 @@
+// Package router 组合多个LLM提供者的路由提供者
+// 按规则或权重选出首选模型, 首选模型出错或在首个响应前超时时按顺序切换到下一个模型
+package router
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+
+	"xiaozhi-esp32-server-golang/constants"
+	config_types "xiaozhi-esp32-server-golang/internal/domain/config/types"
+	"xiaozhi-esp32-server-golang/internal/domain/llm"
+	"xiaozhi-esp32-server-golang/internal/domain/metrics"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+// 选择首选模型的策略
+const (
+	StrategyFallback = "fallback" //按配置顺序, 第一个为首选
+	StrategyWeighted = "weighted" //按权重随机选出首选, 其余按配置顺序兜底
+)
+
+// DefaultFirstTokenTimeout 默认等待首个响应的超时时间
+const DefaultFirstTokenTimeout = 8 * time.Second
+
+func init() {
+	configSchema := registry.ConfigSchema{
+		Description: "多模型路由, 按规则或权重选择模型, 出错或首个响应超时时切换到下一个模型",
+		Fields: []registry.ConfigField{
+			{Name: "type", Type: registry.FieldTypeString, Required: true, Description: "接口类型, 固定为 router"},
+			{Name: "models", Type: registry.FieldTypeArray, Required: true, Description: "候选模型列表, 每项为完整的LLM配置, 另可设置 name(默认为 model_name) 和 weight"},
+			{Name: "strategy", Type: registry.FieldTypeString, Default: StrategyFallback, Options: []string{StrategyFallback, StrategyWeighted}, Description: "首选模型的选择策略"},
+			{Name: "first_token_timeout", Type: registry.FieldTypeNumber, Default: int(DefaultFirstTokenTimeout / time.Millisecond), Description: "等待首个响应的超时时间(毫秒), 超时后切换到下一个模型"},
+			{Name: "rules", Type: registry.FieldTypeArray, Description: "路由规则, 按顺序匹配, 命中后该规则的 model 为首选; 条件有 min_tools、after_tool_call、keywords、max_chars"},
+			{Name: "context_length", Type: registry.FieldTypeNumber, Description: "模型上下文长度, 配置后按token预算裁剪对话历史, 应取候选模型中最小的值"},
+			{Name: "max_tokens", Type: registry.FieldTypeNumber, Description: "最大生成token数, 用于裁剪对话历史时预留回复空间"},
+		},
+	}
+	llm.Register(constants.LlmTypeRouter, func(config map[string]interface{}) (llm.LLMProvider, error) {
+		return NewRouter(config)
+	}, configSchema)
+}
+
+// Rule 路由规则, 配置的条件需全部满足, 没有条件的规则匹配所有请求
+type Rule struct {
+	Model         string   //命中后的首选模型名称
+	MinTools      int      //请求携带的工具数不少于该值
+	AfterToolCall bool     //工具调用后的再次请求
+	Keywords      []string //用户最新消息包含任一关键词
+	MaxChars      int      //用户最新消息不超过该字数
+}
+
+// Match 请求是否满足规则
+func (r Rule) Match(dialogue []*schema.Message, functions []*schema.ToolInfo) bool {
+	if r.MinTools > 0 && len(functions) < r.MinTools {
+		return false
+	}
+	if r.AfterToolCall {
+		if len(dialogue) == 0 || dialogue[len(dialogue)-1] == nil || dialogue[len(dialogue)-1].Role != schema.Tool {
+			return false
+		}
+	}
+	if len(r.Keywords) == 0 && r.MaxChars <= 0 {
+		return true
+	}
+	text := lastUserText(dialogue)
+	if r.MaxChars > 0 && len([]rune(text)) > r.MaxChars {
+		return false
+	}
+	if len(r.Keywords) > 0 {
+		for _, keyword := range r.Keywords {
+			if keyword != "" && strings.Contains(text, keyword) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func lastUserText(dialogue []*schema.Message) string {
+	for i := len(dialogue) - 1; i >= 0; i-- {
+		if dialogue[i] != nil && dialogue[i].Role == schema.User {
+			return dialogue[i].Content
+		}
+	}
+	return ""
+}
+
+// Model 候选模型
+type Model struct {
+	Name     string
+	Weight   int
+	Provider llm.LLMProvider
+}
+
+// Router 多模型路由提供者
+type Router struct {
+	models            []Model
+	rules             []Rule
+	strategy          string
+	firstTokenTimeout time.Duration
+	intn              func(n int) int //权重选择的随机数, 测试时可替换
+}
+
+// NewRouter 根据配置创建路由提供者, 候选模型按各自的 type 创建
+func NewRouter(config map[string]interface{}) (*Router, error) {
+	r := &Router{
+		strategy:          StrategyFallback,
+		firstTokenTimeout: DefaultFirstTokenTimeout,
+		intn:              rand.Intn,
+	}
+	if strategy, _ := config["strategy"].(string); strategy != "" {
+		if strategy != StrategyFallback && strategy != StrategyWeighted {
+			return nil, fmt.Errorf("不支持的路由策略: %s", strategy)
+		}
+		r.strategy = strategy
+	}
+	if timeout := config_types.ConfigInt(config["first_token_timeout"]); timeout > 0 {
+		r.firstTokenTimeout = time.Duration(timeout) * time.Millisecond
+	}
+
+	modelConfigs, err := configMaps(config["models"])
+	if err != nil {
+		return nil, fmt.Errorf("解析 models 失败: %v", err)
+	}
+	if len(modelConfigs) == 0 {
+		return nil, fmt.Errorf("路由至少需要一个候选模型")
+	}
+	for i, modelConfig := range modelConfigs {
+		model, err := newModel(modelConfig)
+		if err != nil {
+			return nil, fmt.Errorf("创建第 %d 个候选模型失败: %v", i+1, err)
+		}
+		for _, existing := range r.models {
+			if existing.Name == model.Name {
+				return nil, fmt.Errorf("候选模型 %s 重复", model.Name)
+			}
+		}
+		r.models = append(r.models, model)
+	}
+
+	ruleConfigs, err := configMaps(config["rules"])
+	if err != nil {
+		return nil, fmt.Errorf("解析 rules 失败: %v", err)
+	}
+	for _, ruleConfig := range ruleConfigs {
+		rule := Rule{
+			Model:         configString(ruleConfig["model"]),
+			MinTools:      config_types.ConfigInt(ruleConfig["min_tools"]),
+			MaxChars:      config_types.ConfigInt(ruleConfig["max_chars"]),
+			Keywords:      configStrings(ruleConfig["keywords"]),
+			AfterToolCall: ruleConfig["after_tool_call"] == true,
+		}
+		if r.modelIndex(rule.Model) < 0 {
+			return nil, fmt.Errorf("路由规则指向的模型 %s 不存在", rule.Model)
+		}
+		r.rules = append(r.rules, rule)
+	}
+	return r, nil
+}
+
+func newModel(config map[string]interface{}) (Model, error) {
+	name := configString(config["name"])
+	if name == "" {
+		name = configString(config["model_name"])
+	}
+	if name == "" {
+		return Model{}, fmt.Errorf("缺少 name 或 model_name")
+	}
+	llmType := configString(config["type"])
+	if llmType == constants.LlmTypeRouter || llmType == constants.LlmTypeRealtime {
+		return Model{}, fmt.Errorf("候选模型不支持 %s 类型", llmType)
+	}
+	weight := 1
+	if _, ok := config["weight"]; ok {
+		weight = config_types.ConfigInt(config["weight"])
+	}
+	provider, err := llm.GetLLMProvider(llmType, config)
+	if err != nil {
+		return Model{}, err
+	}
+	return Model{Name: name, Weight: weight, Provider: provider}, nil
+}
+
+func (r *Router) modelIndex(name string) int {
+	for i, model := range r.models {
+		if model.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Route 本次请求依次尝试的模型
+func (r *Router) Route(dialogue []*schema.Message, functions []*schema.ToolInfo) []Model {
+	first := -1
+	for _, rule := range r.rules {
+		if rule.Match(dialogue, functions) {
+			first = r.modelIndex(rule.Model)
+			break
+		}
+	}
+	if first < 0 && r.strategy == StrategyWeighted {
+		first = r.pickWeighted()
+	}
+	if first <= 0 {
+		return r.models
+	}
+	models := make([]Model, 0, len(r.models))
+	models = append(models, r.models[first])
+	models = append(models, r.models[:first]...)
+	models = append(models, r.models[first+1:]...)
+	return models
+}
+
+// pickWeighted 按权重随机选择, 权重均不大于0时选择第一个
+func (r *Router) pickWeighted() int {
+	total := 0
+	for _, model := range r.models {
+		if model.Weight > 0 {
+			total += model.Weight
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	n := r.intn(total)
+	for i, model := range r.models {
+		if model.Weight <= 0 {
+			continue
+		}
+		if n < model.Weight {
+			return i
+		}
+		n -= model.Weight
+	}
+	return 0
+}
+
+func (r *Router) ResponseWithContext(ctx context.Context, sessionID string, dialogue []*schema.Message, functions []*schema.ToolInfo) chan *schema.Message {
+	models := r.Route(dialogue, functions)
+	responseChan := make(chan *schema.Message, 200)
+	go func() {
+		defer close(responseChan)
+		for i, model := range models {
+			ok := r.forward(ctx, model, sessionID, dialogue, functions, responseChan)
+			if ok || ctx.Err() != nil {
+				return
+			}
+			metrics.ObserveProviderError(metrics.KindLlm, model.Name)
+			if i+1 < len(models) {
+				log.Warnf("[LLM-Router] 模型 %s 未返回响应, 切换到 %s - SessionID: %s", model.Name, models[i+1].Name, sessionID)
+			}
+		}
+		log.Errorf("[LLM-Router] 所有候选模型均未返回响应 - SessionID: %s", sessionID)
+	}()
+	return responseChan
+}
+
+// forward 请求单个模型并转发响应, 在首个响应前出错或超时返回 false
+// 已转发过响应后不再切换模型, 避免同一轮回复混入两个模型的内容
+func (r *Router) forward(ctx context.Context, model Model, sessionID string, dialogue []*schema.Message, functions []*schema.ToolInfo, responseChan chan *schema.Message) bool {
+	attemptCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	startTs := time.Now()
+	msgChan := model.Provider.ResponseWithContext(attemptCtx, sessionID, dialogue, functions)
+	timer := time.NewTimer(r.firstTokenTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			log.Warnf("[LLM-Router] 模型 %s 首个响应超时: %v", model.Name, r.firstTokenTimeout)
+			return false
+		case msg, ok := <-msgChan:
+			if !ok {
+				return false
+			}
+			if msg == nil {
+				continue
+			}
+			log.Debugf("[LLM-Router] 模型 %s 首个响应耗时: %d ms", model.Name, time.Since(startTs).Milliseconds())
+			if !send(ctx, responseChan, msg) {
+				return true
+			}
+			for msg := range msgChan {
+				if msg != nil && !send(ctx, responseChan, msg) {
+					return true
+				}
+			}
+			return true
+		}
+	}
+}
+
+func send(ctx context.Context, responseChan chan *schema.Message, msg *schema.Message) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case responseChan <- msg:
+		return true
+	}
+}
+
+// ResponseWithVllm 按配置顺序尝试, 出错时切换到下一个模型
+func (r *Router) ResponseWithVllm(ctx context.Context, file []byte, text string, mimeType string) (string, error) {
+	var lastErr error
+	for _, model := range r.models {
+		result, err := model.Provider.ResponseWithVllm(ctx, file, text, mimeType)
+		if err == nil {
+			return result, nil
+		}
+		if ctx.Err() != nil {
+			return "", err
+		}
+		log.Warnf("[LLM-Router] 模型 %s 图片识别失败, 尝试下一个模型: %v", model.Name, err)
+		lastErr = err
+	}
+	return "", fmt.Errorf("所有候选模型图片识别均失败: %v", lastErr)
+}
+
+func (r *Router) GetModelInfo() map[string]interface{} {
+	names := make([]string, 0, len(r.models))
+	for _, model := range r.models {
+		names = append(names, model.Name)
+	}
+	return map[string]interface{}{
+		"type":       constants.LlmTypeRouter,
+		"model_name": names[0],
+		"models":     names,
+		"strategy":   r.strategy,
+	}
+}
+
+func configMaps(value interface{}) ([]map[string]interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []map[string]interface{}:
+		return v, nil
+	case []interface{}:
+		maps := make([]map[string]interface{}, 0, len(v))
+		for _, item := range v {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("列表项需为对象: %v", item)
+			}
+			maps = append(maps, m)
+		}
+		return maps, nil
+	}
+	return nil, fmt.Errorf("需为对象列表: %v", value)
+}
+
+func configString(value interface{}) string {
+	s, _ := value.(string)
+	return s
+}
+
+func configStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		strs := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+		return strs
+	case string:
+		if v == "" {
+			return nil
+		}
+		return strings.Split(v, ",")
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+
+	"xiaozhi-esp32-server-golang/internal/domain/llm"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+)
+
+const fakeType = "router_test_fake"
+
+// fakeProviders 按 model_name 记录测试用的模型行为
+var (
+	fakeLock      sync.Mutex
+	fakeProviders = map[string]*fakeLLM{}
+)
+
+func init() {
+	llm.Register(fakeType, func(config map[string]interface{}) (llm.LLMProvider, error) {
+		fakeLock.Lock()
+		defer fakeLock.Unlock()
+		name, _ := config["model_name"].(string)
+		provider, ok := fakeProviders[name]
+		if !ok {
+			return nil, errors.New("unknown fake model")
+		}
+		return provider, nil
+	}, registry.ConfigSchema{})
+}
+
+// fakeLLM 为空时模拟请求失败, hang 为 true 时直到取消都不返回
+type fakeLLM struct {
+	sync.Mutex
+	reply     []string
+	hang      bool
+	calls     int
+	cancelled bool
+}
+
+func (f *fakeLLM) ResponseWithContext(ctx context.Context, sessionID string, dialogue []*schema.Message, functions []*schema.ToolInfo) chan *schema.Message {
+	f.Lock()
+	f.calls++
+	f.Unlock()
+	msgChan := make(chan *schema.Message)
+	go func() {
+		defer close(msgChan)
+		if f.hang {
+			<-ctx.Done()
+			f.Lock()
+			f.cancelled = true
+			f.Unlock()
+			return
+		}
+		for _, text := range f.reply {
+			select {
+			case <-ctx.Done():
+				return
+			case msgChan <- schema.AssistantMessage(text, nil):
+			}
+		}
+	}()
+	return msgChan
+}
+
+func (f *fakeLLM) ResponseWithVllm(ctx context.Context, file []byte, text string, mimeType string) (string, error) {
+	if len(f.reply) == 0 {
+		return "", errors.New("vllm failed")
+	}
+	return strings.Join(f.reply, ""), nil
+}
+
+func (f *fakeLLM) GetModelInfo() map[string]interface{} {
+	return map[string]interface{}{"type": fakeType}
+}
+
+func (f *fakeLLM) callCount() int {
+	f.Lock()
+	defer f.Unlock()
+	return f.calls
+}
+
+func newTestRouter(t *testing.T, config map[string]interface{}, models map[string]*fakeLLM) *Router {
+	t.Helper()
+	fakeLock.Lock()
+	for name, provider := range models {
+		fakeProviders[name] = provider
+	}
+	fakeLock.Unlock()
+
+	config["type"] = "router"
+	provider, err := llm.GetLLMProvider("router", config)
+	if err != nil {
+		t.Fatalf("创建路由失败: %v", err)
+	}
+	return provider.(*Router)
+}
+
+func modelConfig(name string, extra ...interface{}) map[string]interface{} {
+	config := map[string]interface{}{"type": fakeType, "model_name": name}
+	for i := 0; i+1 < len(extra); i += 2 {
+		config[extra[i].(string)] = extra[i+1]
+	}
+	return config
+}
+
+func collect(ch chan *schema.Message) string {
+	var text strings.Builder
+	for msg := range ch {
+		text.WriteString(msg.Content)
+	}
+	return text.String()
+}
+
+func TestRouterFallback(t *testing.T) {
+	failing := &fakeLLM{}
+	hanging := &fakeLLM{hang: true}
+	healthy := &fakeLLM{reply: []string{"你好", "呀"}}
+	r := newTestRouter(t, map[string]interface{}{
+		"first_token_timeout": 50,
+		"models":              []interface{}{modelConfig("failing"), modelConfig("hanging"), modelConfig("healthy")},
+	}, map[string]*fakeLLM{"failing": failing, "hanging": hanging, "healthy": healthy})
+
+	if text := collect(r.ResponseWithContext(context.Background(), "s", nil, nil)); text != "你好呀" {
+		t.Fatalf("text = %q", text)
+	}
+	if failing.callCount() != 1 || hanging.callCount() != 1 || healthy.callCount() != 1 {
+		t.Fatalf("calls = %d, %d, %d", failing.callCount(), hanging.callCount(), healthy.callCount())
+	}
+	time.Sleep(10 * time.Millisecond)
+	hanging.Lock()
+	defer hanging.Unlock()
+	if !hanging.cancelled {
+		t.Fatal("超时的模型请求应被取消")
+	}
+}
+
+func TestRouterNoFallbackAfterFirstToken(t *testing.T) {
+	primary := &fakeLLM{reply: []string{"只有半句"}}
+	backup := &fakeLLM{reply: []string{"备用"}}
+	r := newTestRouter(t, map[string]interface{}{
+		"models": []interface{}{modelConfig("primary"), modelConfig("backup")},
+	}, map[string]*fakeLLM{"primary": primary, "backup": backup})
+
+	if text := collect(r.ResponseWithContext(context.Background(), "s", nil, nil)); text != "只有半句" {
+		t.Fatalf("text = %q", text)
+	}
+	if backup.callCount() != 0 {
+		t.Fatal("已返回响应后不应切换模型")
+	}
+}
+
+func TestRouterAllFailed(t *testing.T) {
+	r := newTestRouter(t, map[string]interface{}{
+		"models": []interface{}{modelConfig("down1"), modelConfig("down2")},
+	}, map[string]*fakeLLM{"down1": {}, "down2": {}})
+
+	if text := collect(r.ResponseWithContext(context.Background(), "s", nil, nil)); text != "" {
+		t.Fatalf("text = %q", text)
+	}
+	if _, err := r.ResponseWithVllm(context.Background(), nil, "", ""); err == nil {
+		t.Fatal("全部失败时应返回错误")
+	}
+}
+
+func TestRouterRoute(t *testing.T) {
+	r := newTestRouter(t, map[string]interface{}{
+		"strategy": StrategyWeighted,
+		"models": []interface{}{
+			modelConfig("cheap", "weight", 3),
+			modelConfig("smart", "name", "smart", "weight", float64(1)),
+			modelConfig("tools", "weight", 0),
+		},
+		"rules": []interface{}{
+			map[string]interface{}{"model": "tools", "after_tool_call": true},
+			map[string]interface{}{"model": "tools", "min_tools": 2},
+			map[string]interface{}{"model": "smart", "keywords": []interface{}{"写", "翻译"}},
+		},
+	}, map[string]*fakeLLM{"cheap": {}, "smart": {}, "tools": {}})
+
+	user := func(text string) []*schema.Message {
+		return []*schema.Message{schema.SystemMessage("prompt"), schema.UserMessage(text)}
+	}
+	twoTools := []*schema.ToolInfo{{Name: "a"}, {Name: "b"}}
+	afterTool := append(user("查天气"), schema.ToolMessage("晴", "call_1"))
+
+	tests := []struct {
+		name      string
+		dialogue  []*schema.Message
+		functions []*schema.ToolInfo
+		random    int
+		want      []string
+	}{
+		{name: "工具调用后的请求", dialogue: afterTool, want: []string{"tools", "cheap", "smart"}},
+		{name: "携带多个工具", dialogue: user("你好"), functions: twoTools, want: []string{"tools", "cheap", "smart"}},
+		{name: "关键词", dialogue: user("帮我写首诗"), functions: twoTools[:1], want: []string{"smart", "cheap", "tools"}},
+		{name: "按权重选中第一个", dialogue: user("你好"), random: 2, want: []string{"cheap", "smart", "tools"}},
+		{name: "按权重选中第二个", dialogue: user("你好"), random: 3, want: []string{"smart", "cheap", "tools"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r.intn = func(n int) int {
+				if n != 4 {
+					t.Fatalf("权重总和 = %d", n)
+				}
+				return tt.random
+			}
+			var got []string
+			for _, model := range r.Route(tt.dialogue, tt.functions) {
+				got = append(got, model.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("route = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleMatch(t *testing.T) {
+	dialogue := []*schema.Message{schema.UserMessage("今天天气怎么样")}
+	tests := []struct {
+		name string
+		rule Rule
+		want bool
+	}{
+		{name: "无条件", rule: Rule{}, want: true},
+		{name: "字数以内", rule: Rule{MaxChars: 7}, want: true},
+		{name: "超过字数", rule: Rule{MaxChars: 6}, want: false},
+		{name: "命中关键词", rule: Rule{Keywords: []string{"天气"}}, want: true},
+		{name: "未命中关键词", rule: Rule{Keywords: []string{"音乐"}}, want: false},
+		{name: "关键词且字数超限", rule: Rule{Keywords: []string{"天气"}, MaxChars: 3}, want: false},
+		{name: "非工具调用后的请求", rule: Rule{AfterToolCall: true}, want: false},
+		{name: "工具数不足", rule: Rule{MinTools: 1}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Match(dialogue, nil); got != tt.want {
+				t.Fatalf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRouterInvalidConfig(t *testing.T) {
+	fakeLock.Lock()
+	fakeProviders["valid"] = &fakeLLM{}
+	fakeLock.Unlock()
+
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+	}{
+		{name: "没有候选模型", config: map[string]interface{}{"models": []interface{}{}}},
+		{name: "候选模型创建失败", config: map[string]interface{}{"models": []interface{}{modelConfig("missing")}}},
+		{name: "嵌套路由", config: map[string]interface{}{"models": []interface{}{map[string]interface{}{"type": "router", "name": "r"}}}},
+		{name: "重复的模型", config: map[string]interface{}{"models": []interface{}{modelConfig("valid"), modelConfig("valid")}}},
+		{name: "规则指向不存在的模型", config: map[string]interface{}{
+			"models": []interface{}{modelConfig("valid")},
+			"rules":  []interface{}{map[string]interface{}{"model": "missing"}},
+		}},
+		{name: "未知策略", config: map[string]interface{}{"strategy": "random", "models": []interface{}{modelConfig("valid")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRouter(tt.config); err == nil {
+				t.Fatal("应返回错误")
+			}
+		})
+	}
+}
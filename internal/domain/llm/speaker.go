@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSpeakerNameLen "角色：" 形式的标记中角色名的最大长度
+const maxSpeakerNameLen = 12
+
+// SpeakerParser 解析句子的说话角色, 用于多角色朗读
+// 句首的 [角色]、【角色】、角色： 标记只识别配置的角色, 避免误判普通文本
+// 没有标记的句子沿用上一句的角色, 因此每次LLM请求需要创建新的解析器
+type SpeakerParser struct {
+	roles   map[string]string
+	current string
+}
+
+// NewSpeakerParser roles 为配置了音色的角色
+func NewSpeakerParser(roles []string) *SpeakerParser {
+	p := &SpeakerParser{roles: make(map[string]string, len(roles))}
+	for _, role := range roles {
+		p.roles[strings.ToLower(strings.TrimSpace(role))] = role
+	}
+	return p
+}
+
+// Parse 返回去除角色标记后的文本和句子的说话角色, 没有角色时返回空字符串
+func (p *SpeakerParser) Parse(sentence string) (string, string) {
+	text := strings.TrimSpace(sentence)
+	if role, rest, ok := p.parseTag(text); ok {
+		p.current = role
+		text = strings.TrimSpace(rest)
+	}
+	return text, p.current
+}
+
+func (p *SpeakerParser) parseTag(text string) (string, string, bool) {
+	r, size := utf8.DecodeRuneInString(text)
+	if closing, ok := tagClosing[r]; ok {
+		if end := strings.IndexRune(text[size:], closing); end >= 0 {
+			if role, ok := p.role(text[size : size+end]); ok {
+				return role, text[size+end+utf8.RuneLen(closing):], true
+			}
+		}
+		return "", "", false
+	}
+	if end := strings.IndexAny(text, ":："); end > 0 && utf8.RuneCountInString(text[:end]) <= maxSpeakerNameLen {
+		if role, ok := p.role(text[:end]); ok {
+			_, colonSize := utf8.DecodeRuneInString(text[end:])
+			return role, text[end+colonSize:], true
+		}
+	}
+	return "", "", false
+}
+
+func (p *SpeakerParser) role(name string) (string, bool) {
+	role, ok := p.roles[strings.ToLower(strings.TrimSpace(name))]
+	return role, ok
+}
+
+// speakerLine JSON 模式下每行的格式, 如 {"speaker": "小红", "text": "你好呀！"}
+type speakerLine struct {
+	Speaker string `json:"speaker"`
+	Role    string `json:"role"`
+	Text    string `json:"text"`
+}
+
+// ParseJSONLine 解析 JSON 模式的一行, 角色不限于配置的角色, 没有音色的角色使用默认音色
+// 不是 JSON 时按普通句子解析, 返回 false
+func (p *SpeakerParser) ParseJSONLine(line string) (string, string, bool) {
+	var item speakerLine
+	if err := json.Unmarshal([]byte(strings.TrimSpace(line)), &item); err != nil {
+		text, speaker := p.Parse(line)
+		return text, speaker, false
+	}
+	speaker := item.Speaker
+	if speaker == "" {
+		speaker = item.Role
+	}
+	if role, ok := p.role(speaker); ok {
+		speaker = role
+	}
+	p.current = speaker
+	return strings.TrimSpace(item.Text), speaker, true
+}
+
+// isJSONMode LLM 的回复以 { 开头时按每行一个 JSON 对象处理
+func isJSONMode(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), "{")
+}
+
+// extractLines 返回完整的行和剩余的未完成内容
+func extractLines(text string) ([]string, string) {
+	end := strings.LastIndexByte(text, '\n')
+	if end < 0 {
+		return nil, text
+	}
+	var lines []string
+	for _, line := range strings.Split(text[:end], "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, text[end+1:]
+}
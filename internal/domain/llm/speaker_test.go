@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestSpeakerParserParse(t *testing.T) {
+	parser := NewSpeakerParser([]string{"旁白", "小红", "Wolf"})
+	tests := []struct {
+		sentence    string
+		wantText    string
+		wantSpeaker string
+	}{
+		{sentence: "从前有一座山。", wantText: "从前有一座山。", wantSpeaker: ""},
+		{sentence: "小红：外婆，你的耳朵怎么这么大？", wantText: "外婆，你的耳朵怎么这么大？", wantSpeaker: "小红"},
+		{sentence: "你的眼睛也好大！", wantText: "你的眼睛也好大！", wantSpeaker: "小红"},
+		{sentence: "[wolf] 为了更好地看你呀。", wantText: "为了更好地看你呀。", wantSpeaker: "Wolf"},
+		{sentence: "【旁白】小红吓了一跳。", wantText: "小红吓了一跳。", wantSpeaker: "旁白"},
+		{sentence: "注意: 这不是角色。", wantText: "注意: 这不是角色。", wantSpeaker: "旁白"},
+		{sentence: "[开心]好呀", wantText: "[开心]好呀", wantSpeaker: "旁白"},
+	}
+	for _, tt := range tests {
+		text, speaker := parser.Parse(tt.sentence)
+		if text != tt.wantText || speaker != tt.wantSpeaker {
+			t.Fatalf("Parse(%q) = %q, %q, want %q, %q", tt.sentence, text, speaker, tt.wantText, tt.wantSpeaker)
+		}
+	}
+}
+
+type fakeLLMProvider struct {
+	chunks []string
+}
+
+func (f *fakeLLMProvider) ResponseWithContext(ctx context.Context, sessionID string, dialogue []*schema.Message, functions []*schema.ToolInfo) chan *schema.Message {
+	ch := make(chan *schema.Message, len(f.chunks))
+	for _, chunk := range f.chunks {
+		ch <- &schema.Message{Role: schema.Assistant, Content: chunk}
+	}
+	close(ch)
+	return ch
+}
+
+func (f *fakeLLMProvider) ResponseWithVllm(ctx context.Context, file []byte, text string, mimeType string) (string, error) {
+	return "", nil
+}
+
+func (f *fakeLLMProvider) GetModelInfo() map[string]interface{} {
+	return nil
+}
+
+func TestHandleLLMSpeakerJSONMode(t *testing.T) {
+	provider := &fakeLLMProvider{chunks: []string{
+		`{"speaker": "旁白", "text": "狼敲了敲门。"}` + "\n" + `{"speaker": "小`,
+		`红", "text": "谁呀？请进！"}` + "\n",
+		`{"role": "狼", "text": "是我"}`,
+	}}
+	responses, err := HandleLLMWithContextAndTools(context.Background(), provider, nil, nil, "test", WithSpeakerParser(NewSpeakerParser([]string{"小红"})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ text, speaker string }{
+		{"狼敲了敲门。", "旁白"},
+		{"谁呀？", "小红"},
+		{"请进！", "小红"},
+		{"是我", "狼"},
+	}
+	var got []struct{ text, speaker string }
+	var ended bool
+	for response := range responses {
+		got = append(got, struct{ text, speaker string }{response.Text, response.Speaker})
+		ended = response.IsEnd
+	}
+	if !ended || len(got) != len(want) {
+		t.Fatalf("got = %v, ended = %v", got, ended)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Package tokenizer 文本token计数, 用于按模型的上下文长度裁剪对话历史
+// 默认提供不依赖词表的估算实现, 需要精确计数时可以注册模型对应的分词器
+package tokenizer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// TypeEstimate 估算: 中日韩等非ASCII字符按1个token, 连续ASCII字符按4个字符1个token
+	TypeEstimate = "estimate"
+	// TypeRune 按字符数计数, 偏保守
+	TypeRune = "rune"
+)
+
+// Tokenizer 计算文本的token数
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+var (
+	tokenizers = map[string]Tokenizer{
+		TypeEstimate: EstimateTokenizer{},
+		TypeRune:     RuneTokenizer{},
+	}
+	mu sync.RWMutex
+)
+
+// Register 注册分词器, 名称重复时覆盖
+func Register(name string, t Tokenizer) {
+	mu.Lock()
+	defer mu.Unlock()
+	tokenizers[name] = t
+}
+
+// Get 根据名称获取分词器, 名称为空时返回默认的估算分词器
+func Get(name string) (Tokenizer, error) {
+	if name == "" {
+		name = TypeEstimate
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	t, ok := tokenizers[name]
+	if !ok {
+		return nil, fmt.Errorf("不支持的分词器: %s", name)
+	}
+	return t, nil
+}
+
+// Names 返回已注册的分词器名称（已排序）
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(tokenizers))
+	for name := range tokenizers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EstimateTokenizer 不依赖词表的token估算
+type EstimateTokenizer struct{}
+
+func (EstimateTokenizer) CountTokens(text string) int {
+	tokens := 0
+	ascii := 0
+	flush := func() {
+		tokens += (ascii + 3) / 4
+		ascii = 0
+	}
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case r < utf8.RuneSelf:
+			ascii++
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return tokens
+}
+
+// RuneTokenizer 每个字符计为1个token
+type RuneTokenizer struct{}
+
+func (RuneTokenizer) CountTokens(text string) int {
+	return utf8.RuneCountInString(text)
+}
+
+// Truncate 截断文本使其不超过 maxTokens 个token
+func Truncate(t Tokenizer, text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	if t.CountTokens(text) <= maxTokens {
+		return text
+	}
+	runes := []rune(text)
+	// 二分查找满足预算的最长前缀
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if t.CountTokens(string(runes[:mid])) <= maxTokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return string(runes[:lo])
+}
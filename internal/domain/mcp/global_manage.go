@@ -151,6 +151,21 @@ func (g *GlobalMCPManager) Stop() error {
 	return nil
 }
 
+// GetConnectionStats 获取MCP服务器连接数, 返回已连接数和配置的服务器总数
+func (g *GlobalMCPManager) GetConnectionStats() (connected int, total int) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for _, conn := range g.servers {
+		conn.mu.RLock()
+		if conn.connected {
+			connected++
+		}
+		conn.mu.RUnlock()
+	}
+	return connected, len(g.servers)
+}
+
 // createFailedConnection 创建失败的连接对象用于后续重连
 func (g *GlobalMCPManager) createFailedConnection(config MCPServerConfig) {
 	conn := &MCPServerConnection{
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ReportFunc 上报一组标签对应的值
+type ReportFunc func(value float64, labelValues ...string)
+
+// gaugeVecFunc 在抓取时才计算的带标签 gauge, 适合连接数、资源池等已由其他模块维护的状态
+type gaugeVecFunc struct {
+	desc    *prometheus.Desc
+	collect func(report ReportFunc)
+}
+
+func (g *gaugeVecFunc) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+func (g *gaugeVecFunc) Collect(ch chan<- prometheus.Metric) {
+	g.collect(func(value float64, labelValues ...string) {
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, value, labelValues...)
+	})
+}
+
+// RegisterGaugeVecFunc 注册抓取时计算的 gauge, 同名重复注册时返回错误
+func RegisterGaugeVecFunc(name string, help string, labelNames []string, collect func(report ReportFunc)) error {
+	return prometheus.Register(&gaugeVecFunc{
+		desc:    prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, labelNames, nil),
+		collect: collect,
+	})
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGaugeVecFunc(t *testing.T) {
+	counts := map[string]int{"websocket": 2, "udp": 1}
+	collector := &gaugeVecFunc{
+		desc: prometheus.NewDesc("xiaozhi_test_active_chat_managers", "测试", []string{"transport"}, nil),
+		collect: func(report ReportFunc) {
+			for transport, count := range counts {
+				report(float64(count), transport)
+			}
+		},
+	}
+
+	expected := `
+# HELP xiaozhi_test_active_chat_managers 测试
+# TYPE xiaozhi_test_active_chat_managers gauge
+xiaozhi_test_active_chat_managers{transport="udp"} 1
+xiaozhi_test_active_chat_managers{transport="websocket"} 2
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Fatalf("指标不一致: %v", err)
+	}
+
+	// 抓取时重新计算
+	counts["websocket"] = 0
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(strings.Replace(expected, `"websocket"} 2`, `"websocket"} 0`, 1))); err != nil {
+		t.Fatalf("指标未重新计算: %v", err)
+	}
+}
+
+func TestRegisterGaugeVecFuncDuplicate(t *testing.T) {
+	collect := func(report ReportFunc) {}
+	if err := RegisterGaugeVecFunc("test_duplicate", "测试", nil, collect); err != nil {
+		t.Fatalf("注册失败: %v", err)
+	}
+	if err := RegisterGaugeVecFunc("test_duplicate", "测试", nil, collect); err == nil {
+		t.Fatalf("重复注册应返回错误")
+	}
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "xiaozhi"
+
+// 提供者类型, 用于 provider_errors_total 的 kind 标签
+const (
+	KindVad = "vad"
+	KindAsr = "asr"
+	KindLlm = "llm"
+	KindTts = "tts"
+)
+
+// 工具调用结果, 用于 tool_calls_total 的 result 标签
+const (
+	ToolResultSuccess  = "success"
+	ToolResultError    = "error"
+	ToolResultNotFound = "not_found"
+)
+
+// 语音链路各环节耗时分布在 0.05s ~ 10s 之间
+var latencyBuckets = []float64{0.05, 0.1, 0.2, 0.3, 0.5, 0.75, 1, 1.5, 2, 3, 5, 10}
+
+var (
+	// AsrLatency 说话结束到asr最终结果的耗时
+	AsrLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "asr_latency_seconds",
+		Help:      "说话结束到asr最终结果的耗时",
+		Buckets:   latencyBuckets,
+	}, []string{"provider"})
+
+	// LlmLatency 发起llm请求到收到首个响应的耗时
+	LlmLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_first_token_latency_seconds",
+		Help:      "发起llm请求到收到首个响应的耗时",
+		Buckets:   latencyBuckets,
+	}, []string{"provider"})
+
+	// TtsLatency 请求tts到发送第一帧音频的耗时
+	TtsLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tts_first_frame_latency_seconds",
+		Help:      "请求tts到发送该句第一帧音频的耗时",
+		Buckets:   latencyBuckets,
+	}, []string{"provider"})
+
+	// DroppedAudioFrames 音频接收缓冲区已满被丢弃的帧数
+	DroppedAudioFrames = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dropped_audio_frames_total",
+		Help:      "音频接收缓冲区已满被丢弃的上行音频帧数",
+	}, []string{"transport"})
+
+	// ToolCalls 工具调用次数, 按结果区分
+	ToolCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_calls_total",
+		Help:      "工具调用次数, 按工具名和结果区分",
+	}, []string{"tool", "result"})
+
+	// ProviderErrors 各提供者的错误次数
+	ProviderErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_errors_total",
+		Help:      "ASR/LLM/TTS/VAD 提供者的错误次数",
+	}, []string{"kind", "provider"})
+)
+
+// ObserveProviderError 记录一次提供者错误
+func ObserveProviderError(kind string, provider string) {
+	ProviderErrors.WithLabelValues(kind, provider).Inc()
+}
+
+// Handler 返回 /metrics 的 http 处理器
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,78 @@
+// Package providers 引入所有内置的ASR/TTS/VAD/LLM提供者
+// 各提供者在自身包的 init() 中向对应领域的注册表注册，使用方只需匿名导入本包
+package providers
+
+import (
+	"fmt"
+
+	"xiaozhi-esp32-server-golang/internal/domain/asr"
+	"xiaozhi-esp32-server-golang/internal/domain/llm"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+	"xiaozhi-esp32-server-golang/internal/domain/tts"
+	"xiaozhi-esp32-server-golang/internal/domain/vad"
+
+	_ "xiaozhi-esp32-server-golang/internal/domain/asr/doubao"
+	_ "xiaozhi-esp32-server-golang/internal/domain/asr/whisper"
+	_ "xiaozhi-esp32-server-golang/internal/domain/llm/eino_llm"
+	_ "xiaozhi-esp32-server-golang/internal/domain/llm/realtime"
+	_ "xiaozhi-esp32-server-golang/internal/domain/llm/router"
+	_ "xiaozhi-esp32-server-golang/internal/domain/tts/chain"
+	_ "xiaozhi-esp32-server-golang/internal/domain/tts/cosyvoice"
+	_ "xiaozhi-esp32-server-golang/internal/domain/tts/doubao"
+	_ "xiaozhi-esp32-server-golang/internal/domain/tts/edge"
+	_ "xiaozhi-esp32-server-golang/internal/domain/tts/edge_offline"
+	_ "xiaozhi-esp32-server-golang/internal/domain/tts/xiaozhi"
+	_ "xiaozhi-esp32-server-golang/internal/domain/vad/silero_vad"
+	_ "xiaozhi-esp32-server-golang/internal/domain/vad/webrtc_vad"
+)
+
+// 提供者类别，与管理后台配置的 type 字段保持一致
+const (
+	KindVad = "vad"
+	KindAsr = "asr"
+	KindLlm = "llm"
+	KindTts = "tts"
+)
+
+// List 列出指定类别已注册的提供者，kind 为空时返回全部类别
+func List(kind string) (map[string][]registry.ProviderInfo, error) {
+	all := map[string]func() []registry.ProviderInfo{
+		KindVad: vad.ListProviders,
+		KindAsr: asr.ListProviders,
+		KindLlm: llm.ListProviders,
+		KindTts: tts.ListProviders,
+	}
+	result := make(map[string][]registry.ProviderInfo)
+	if kind == "" {
+		for k, list := range all {
+			result[k] = list()
+		}
+		return result, nil
+	}
+	list, ok := all[kind]
+	if !ok {
+		return nil, fmt.Errorf("未知的提供者类别: %s", kind)
+	}
+	result[kind] = list()
+	return result, nil
+}
+
+// Validate 按指定类别提供者的配置描述校验配置
+func Validate(kind, name string, config map[string]interface{}) error {
+	switch kind {
+	case KindVad:
+		return vad.ValidateConfig(name, config)
+	case KindAsr:
+		return asr.ValidateConfig(name, config)
+	case KindLlm:
+		// LLM 按配置中的 type 选择实现
+		if llmType, ok := config["type"].(string); ok && llmType != "" {
+			name = llmType
+		}
+		return llm.ValidateConfig(name, config)
+	case KindTts:
+		return tts.ValidateConfig(name, config)
+	default:
+		return fmt.Errorf("未知的提供者类别: %s", kind)
+	}
+}
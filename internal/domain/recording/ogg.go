@@ -0,0 +1,106 @@
+package recording
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// opus 的 granule position 固定按 48kHz 计算
+const opusGranuleRate = 48000
+
+// opus 解码器默认的 pre-skip, 参见 RFC 7845
+const opusPreSkip = 312
+
+var oggCrcTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		crc := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+// OpusToOgg 将 opus 帧封装为 Ogg Opus 文件, 每个页面存放一帧
+func OpusToOgg(frames [][]byte, sampleRate int, channels int, frameDuration int) []byte {
+	if channels <= 0 {
+		channels = 1
+	}
+	w := &oggWriter{serial: 0x78697a68}
+
+	head := bytes.NewBuffer(nil)
+	head.WriteString("OpusHead")
+	head.WriteByte(1) // 版本
+	head.WriteByte(byte(channels))
+	binary.Write(head, binary.LittleEndian, uint16(opusPreSkip))
+	binary.Write(head, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(head, binary.LittleEndian, int16(0)) // 输出增益
+	head.WriteByte(0)                                 // 声道映射
+	w.writePage(head.Bytes(), 0, 0x02)
+
+	vendor := "xiaozhi-esp32-server-golang"
+	tags := bytes.NewBuffer(nil)
+	tags.WriteString("OpusTags")
+	binary.Write(tags, binary.LittleEndian, uint32(len(vendor)))
+	tags.WriteString(vendor)
+	binary.Write(tags, binary.LittleEndian, uint32(0)) // 无用户注释
+	w.writePage(tags.Bytes(), 0, 0)
+
+	granule := uint64(opusPreSkip)
+	samplesPerFrame := uint64(frameDuration * opusGranuleRate / 1000)
+	for i, frame := range frames {
+		granule += samplesPerFrame
+		var headerType byte
+		if i == len(frames)-1 {
+			headerType = 0x04
+		}
+		w.writePage(frame, granule, headerType)
+	}
+	return w.buf.Bytes()
+}
+
+type oggWriter struct {
+	buf      bytes.Buffer
+	serial   uint32
+	sequence uint32
+}
+
+func (w *oggWriter) writePage(packet []byte, granule uint64, headerType byte) {
+	// lacing: 每255字节一段, 最后一段小于255表示包结束
+	segments := make([]byte, 0, len(packet)/255+1)
+	for n := len(packet); ; n -= 255 {
+		if n < 255 {
+			segments = append(segments, byte(n))
+			break
+		}
+		segments = append(segments, 255)
+	}
+
+	page := bytes.NewBuffer(make([]byte, 0, 27+len(segments)+len(packet)))
+	page.WriteString("OggS")
+	page.WriteByte(0) // 版本
+	page.WriteByte(headerType)
+	binary.Write(page, binary.LittleEndian, granule)
+	binary.Write(page, binary.LittleEndian, w.serial)
+	binary.Write(page, binary.LittleEndian, w.sequence)
+	binary.Write(page, binary.LittleEndian, uint32(0)) // crc 占位
+	page.WriteByte(byte(len(segments)))
+	page.Write(segments)
+	page.Write(packet)
+
+	data := page.Bytes()
+	var crc uint32
+	for _, b := range data {
+		crc = crc<<8 ^ oggCrcTable[byte(crc>>24)^b]
+	}
+	binary.LittleEndian.PutUint32(data[22:26], crc)
+
+	w.buf.Write(data)
+	w.sequence++
+}
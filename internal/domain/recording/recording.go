@@ -0,0 +1,170 @@
+package recording
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-esp32-server-golang/internal/util"
+	log "xiaozhi-esp32-server-golang/logger"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	retentionCheckInterval = time.Hour
+
+	InputFileName  = "input.wav"  //送入ASR的用户语音
+	OutputFileName = "output.ogg" //下发给设备的TTS音频
+)
+
+// Config 录音配置
+type Config struct {
+	Enable    bool          //总开关, 开启后按智能体的 record_audio 决定是否录音
+	RecordAll bool          //对所有设备录音, 用于未接入管理后台的部署
+	Storage   string        //local 或 s3
+	Retention time.Duration //录音保留时长, 0 为永久保留
+	LocalDir  string
+	S3        S3Config
+}
+
+// LoadConfig 从配置文件读取录音配置
+func LoadConfig() Config {
+	return Config{
+		Enable:    viper.GetBool("recording.enable"),
+		RecordAll: viper.GetBool("recording.record_all"),
+		Storage:   viper.GetString("recording.storage"),
+		Retention: viper.GetDuration("recording.retention"),
+		LocalDir:  viper.GetString("recording.local.dir"),
+		S3: S3Config{
+			Endpoint:  viper.GetString("recording.s3.endpoint"),
+			Region:    viper.GetString("recording.s3.region"),
+			Bucket:    viper.GetString("recording.s3.bucket"),
+			AccessKey: viper.GetString("recording.s3.access_key"),
+			SecretKey: viper.GetString("recording.s3.secret_key"),
+			Prefix:    viper.GetString("recording.s3.prefix"),
+			PathStyle: viper.GetBool("recording.s3.path_style"),
+		},
+	}
+}
+
+var (
+	recorderInstance *Recorder
+	once             sync.Once
+)
+
+// Recorder 保存每轮对话的录音
+type Recorder struct {
+	storage   Storage
+	recordAll bool
+	retention time.Duration
+}
+
+// Init 按配置初始化录音, 未开启时 Get 返回 nil
+func Init() error {
+	var initErr error
+	once.Do(func() {
+		config := LoadConfig()
+		if !config.Enable {
+			return
+		}
+		// 没有前缀时清理会遍历整个 bucket, 要求为录音单独指定前缀
+		if config.Storage == StorageTypeS3 && config.Retention > 0 && strings.Trim(config.S3.Prefix, "/") == "" {
+			initErr = fmt.Errorf("s3 存储开启录音保留时长时需配置 recording.s3.prefix, 录音未启用")
+			return
+		}
+		storage, err := NewStorage(config)
+		if err != nil {
+			initErr = fmt.Errorf("创建录音存储失败, 录音未启用: %v", err)
+			return
+		}
+		recorderInstance = NewRecorder(storage, config.RecordAll, config.Retention)
+		if config.Retention > 0 {
+			go recorderInstance.retentionLoop()
+		}
+		log.Infof("对话录音已启用, 存储: %s", config.Storage)
+	})
+	return initErr
+}
+
+// Get 获取录音实例, 未开启录音时返回 nil
+func Get() *Recorder {
+	return recorderInstance
+}
+
+func NewRecorder(storage Storage, recordAll bool, retention time.Duration) *Recorder {
+	return &Recorder{
+		storage:   storage,
+		recordAll: recordAll,
+		retention: retention,
+	}
+}
+
+// Enabled 智能体是否需要录音
+func (r *Recorder) Enabled(agentRecordAudio bool) bool {
+	return r != nil && (r.recordAll || agentRecordAudio)
+}
+
+// TurnAudio 一轮对话的音频
+type TurnAudio struct {
+	Input           []float32 //送入ASR的单声道PCM
+	InputSampleRate int
+
+	Output              [][]byte //下发的TTS opus帧
+	OutputSampleRate    int
+	OutputChannels      int
+	OutputFrameDuration int
+}
+
+// key 录音文件的存储路径
+func key(deviceID string, turnID string, name string) string {
+	return deviceID + "/" + turnID + "/" + name
+}
+
+// isRecordingKey 是否为录音写入的 key, 清理过期录音时只删除 <设备>/<轮次>/<录音文件> 形式的文件
+func isRecordingKey(key string) bool {
+	parts := strings.Split(key, "/")
+	return len(parts) == 3 && parts[0] != "" && parts[1] != "" && (parts[2] == InputFileName || parts[2] == OutputFileName)
+}
+
+// Save 保存一轮对话的录音, 返回保存的文件名
+func (r *Recorder) Save(ctx context.Context, deviceID string, turnID string, audio TurnAudio) ([]string, error) {
+	files := make([]string, 0, 2)
+	if len(audio.Input) > 0 {
+		data := util.PcmToWav(audio.Input, audio.InputSampleRate)
+		if err := r.storage.Put(ctx, key(deviceID, turnID, InputFileName), data, "audio/wav"); err != nil {
+			return files, fmt.Errorf("保存用户录音失败: %v", err)
+		}
+		files = append(files, InputFileName)
+	}
+	if len(audio.Output) > 0 {
+		data := OpusToOgg(audio.Output, audio.OutputSampleRate, audio.OutputChannels, audio.OutputFrameDuration)
+		if err := r.storage.Put(ctx, key(deviceID, turnID, OutputFileName), data, "audio/ogg"); err != nil {
+			return files, fmt.Errorf("保存TTS录音失败: %v", err)
+		}
+		files = append(files, OutputFileName)
+	}
+	return files, nil
+}
+
+// Open 读取录音文件, name 为 InputFileName 或 OutputFileName
+func (r *Recorder) Open(ctx context.Context, deviceID string, turnID string, name string) (io.ReadCloser, error) {
+	if name != InputFileName && name != OutputFileName {
+		return nil, ErrNotFound
+	}
+	return r.storage.Get(ctx, key(deviceID, turnID, name))
+}
+
+// retentionLoop 定期删除超过保留时长的录音
+func (r *Recorder) retentionLoop() {
+	ticker := time.NewTicker(retentionCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := r.storage.RemoveExpired(context.Background(), time.Now().Add(-r.retention)); err != nil {
+			log.Errorf("清理过期录音失败: %v", err)
+		}
+	}
+}
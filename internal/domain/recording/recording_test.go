@@ -0,0 +1,178 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOpusToOgg(t *testing.T) {
+	frames := [][]byte{bytes.Repeat([]byte{1}, 10), bytes.Repeat([]byte{2}, 300)}
+	data := OpusToOgg(frames, 24000, 1, 60)
+
+	var pages [][]byte
+	for len(data) > 0 {
+		if string(data[:4]) != "OggS" {
+			t.Fatalf("页面头错误: %q", data[:4])
+		}
+		segments := int(data[26])
+		size := 27 + segments
+		for _, n := range data[27 : 27+segments] {
+			size += int(n)
+		}
+		pages = append(pages, data[:size])
+		data = data[size:]
+	}
+	if len(pages) != 4 {
+		t.Fatalf("页面数 = %d, 期望 4", len(pages))
+	}
+	if !bytes.Contains(pages[0], []byte("OpusHead")) || pages[0][5] != 0x02 {
+		t.Fatalf("首页应为 OpusHead 且标记 BOS")
+	}
+	last := pages[3]
+	if last[5] != 0x04 {
+		t.Fatalf("末页应标记 EOS")
+	}
+	if granule := binary.LittleEndian.Uint64(last[6:14]); granule != opusPreSkip+2*60*48 {
+		t.Fatalf("granule = %d", granule)
+	}
+	// 300 字节的包分为 255 + 45 两段
+	if last[26] != 2 || last[27] != 255 || last[28] != 45 {
+		t.Fatalf("分段错误: %v", last[26:29])
+	}
+
+	// crc 置零后重新计算应与原值一致
+	page := append([]byte(nil), pages[1]...)
+	want := binary.LittleEndian.Uint32(page[22:26])
+	binary.LittleEndian.PutUint32(page[22:26], 0)
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCrcTable[byte(crc>>24)^b]
+	}
+	if crc != want {
+		t.Fatalf("crc = %x, 期望 %x", crc, want)
+	}
+}
+
+func TestRecorderLocalStorage(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	testRecorder(t, storage)
+
+	if _, err := storage.Get(context.Background(), "../etc/passwd"); err == nil {
+		t.Fatalf("应拒绝跳出录音目录的路径")
+	}
+}
+
+func TestRecorderS3Storage(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=ak/") || r.Header.Get("x-amz-date") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+		switch r.Method {
+		case http.MethodPut:
+			objects[key], _ = io.ReadAll(r.Body)
+		case http.MethodGet:
+			if r.URL.Query().Get("list-type") == "2" {
+				io.WriteString(w, "<ListBucketResult>")
+				for k := range objects {
+					io.WriteString(w, "<Contents><Key>"+k+"</Key><LastModified>2020-01-01T00:00:00.000Z</LastModified></Contents>")
+				}
+				io.WriteString(w, "<IsTruncated>false</IsTruncated></ListBucketResult>")
+				return
+			}
+			data, ok := objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		case http.MethodDelete:
+			delete(objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	storage, err := NewS3Storage(S3Config{Endpoint: server.URL, Bucket: "bucket", AccessKey: "ak", SecretKey: "sk", Prefix: "rec", PathStyle: true})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	testRecorder(t, storage)
+
+	if _, ok := objects["rec/ba:8f:17:de:94:94/turn-1/input.wav"]; ok {
+		t.Fatalf("过期录音未删除")
+	}
+}
+
+func testRecorder(t *testing.T, storage Storage) {
+	ctx := context.Background()
+	recorder := NewRecorder(storage, false, time.Hour)
+	if recorder.Enabled(false) || !recorder.Enabled(true) {
+		t.Fatalf("应按智能体配置决定是否录音")
+	}
+
+	files, err := recorder.Save(ctx, "ba:8f:17:de:94:94", "turn-1", TurnAudio{
+		Input:               make([]float32, 1600),
+		InputSampleRate:     16000,
+		Output:              [][]byte{{1, 2, 3}},
+		OutputSampleRate:    24000,
+		OutputChannels:      1,
+		OutputFrameDuration: 60,
+	})
+	if err != nil || len(files) != 2 {
+		t.Fatalf("Save = %v, err = %v", files, err)
+	}
+
+	reader, err := recorder.Open(ctx, "ba:8f:17:de:94:94", "turn-1", InputFileName)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if len(data) != 44+3200 || string(data[:4]) != "RIFF" {
+		t.Fatalf("wav 长度 = %d", len(data))
+	}
+
+	if _, err := recorder.Open(ctx, "ba:8f:17:de:94:94", "turn-2", OutputFileName); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("不存在的录音应返回 ErrNotFound, err = %v", err)
+	}
+
+	// 存储中不是录音写入的文件
+	others := []string{"backup.sql", "ba:8f:17:de:94:94/turn-1/notes.txt", "a/b/c/input.wav"}
+	for _, other := range others {
+		if err := storage.Put(ctx, other, []byte("x"), ""); err != nil {
+			t.Fatalf("Put %s: %v", other, err)
+		}
+	}
+
+	if err := storage.RemoveExpired(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("RemoveExpired: %v", err)
+	}
+	if _, err := recorder.Open(ctx, "ba:8f:17:de:94:94", "turn-1", InputFileName); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("过期录音未删除, err = %v", err)
+	}
+	for _, other := range others {
+		reader, err := storage.Get(ctx, other)
+		if err != nil {
+			t.Fatalf("不应删除非录音文件 %s, err = %v", other, err)
+		}
+		reader.Close()
+	}
+}
@@ -0,0 +1,114 @@
+package recording
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	StorageTypeLocal = "local"
+	StorageTypeS3    = "s3"
+)
+
+// ErrNotFound 录音不存在
+var ErrNotFound = errors.New("录音不存在")
+
+// Storage 录音文件的存储后端, key 为以 / 分隔的相对路径
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get 读取录音, 不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// RemoveExpired 删除 before 之前写入的录音, 只删除录音自己写入的文件
+	RemoveExpired(ctx context.Context, before time.Time) error
+}
+
+// NewStorage 按类型创建存储后端
+func NewStorage(config Config) (Storage, error) {
+	switch config.Storage {
+	case "", StorageTypeLocal:
+		return NewLocalStorage(config.LocalDir)
+	case StorageTypeS3:
+		return NewS3Storage(config.S3)
+	default:
+		return nil, fmt.Errorf("不支持的录音存储类型: %s", config.Storage)
+	}
+}
+
+// LocalStorage 本地文件系统存储
+type LocalStorage struct {
+	dir string
+}
+
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if dir == "" {
+		dir = "data/recordings"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建录音目录失败: %v", err)
+	}
+	return &LocalStorage{dir: dir}, nil
+}
+
+// path 将 key 转换为本地路径, 拒绝跳出录音目录的 key
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if clean == "." || filepath.IsAbs(clean) || strings.HasPrefix(clean, "..") {
+		return "", fmt.Errorf("非法的录音路径: %s", key)
+	}
+	return filepath.Join(s.dir, clean), nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建录音目录失败: %v", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (s *LocalStorage) RemoveExpired(ctx context.Context, before time.Time) error {
+	return filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		// 录音目录中的其他文件不是录音写入的, 不做处理
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil || !isRecordingKey(filepath.ToSlash(rel)) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Before(before) {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("删除过期录音失败: %v", err)
+			}
+			// 尽量删除空的轮次目录, 非空时忽略错误
+			os.Remove(filepath.Dir(path))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,100 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ProviderInfo 已注册提供者的描述信息，用于对外列出可用提供者
+type ProviderInfo struct {
+	Name   string       `json:"name"`
+	Schema ConfigSchema `json:"schema"`
+}
+
+type entry[F any] struct {
+	factory F
+	schema  ConfigSchema
+}
+
+// Registry 提供者注册表
+// F 为各领域自己的工厂类型（ASR/TTS/VAD/LLM），注册表只负责按名称保存工厂与配置描述
+type Registry[F any] struct {
+	kind    string
+	mu      sync.RWMutex
+	entries map[string]entry[F]
+}
+
+// New 创建一个注册表，kind 用于错误信息，如 "asr"、"tts"
+func New[F any](kind string) *Registry[F] {
+	return &Registry[F]{
+		kind:    kind,
+		entries: make(map[string]entry[F]),
+	}
+}
+
+// Register 注册提供者，通常在提供者包的 init() 中调用
+// 名称为空或重复注册会直接 panic，与 database/sql 的驱动注册保持一致
+func (r *Registry[F]) Register(name string, factory F, schema ConfigSchema) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name == "" {
+		panic(fmt.Sprintf("%s: 提供者名称不能为空", r.kind))
+	}
+	if _, exists := r.entries[name]; exists {
+		panic(fmt.Sprintf("%s: 提供者 %s 重复注册", r.kind, name))
+	}
+	r.entries[name] = entry[F]{factory: factory, schema: schema}
+}
+
+// Lookup 根据名称获取工厂
+func (r *Registry[F]) Lookup(name string) (F, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.entries[name]
+	return e.factory, ok
+}
+
+// Schema 根据名称获取配置描述
+func (r *Registry[F]) Schema(name string) (ConfigSchema, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.entries[name]
+	return e.schema, ok
+}
+
+// Names 返回已注册的提供者名称（已排序）
+func (r *Registry[F]) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.entries))
+	for name := range r.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List 返回所有已注册提供者的描述信息（按名称排序）
+func (r *Registry[F]) List() []ProviderInfo {
+	names := r.Names()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	infos := make([]ProviderInfo, 0, len(names))
+	for _, name := range names {
+		infos = append(infos, ProviderInfo{Name: name, Schema: r.entries[name].schema})
+	}
+	return infos
+}
+
+// Validate 按提供者的配置描述校验配置
+func (r *Registry[F]) Validate(name string, config map[string]interface{}) error {
+	schema, ok := r.Schema(name)
+	if !ok {
+		return fmt.Errorf("不支持的%s提供者: %s", r.kind, name)
+	}
+	if err := schema.Validate(config); err != nil {
+		return fmt.Errorf("%s提供者 %s 配置校验失败: %v", r.kind, name, err)
+	}
+	return nil
+}
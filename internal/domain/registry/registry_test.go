@@ -0,0 +1,107 @@
+package registry
+
+import (
+	"testing"
+)
+
+type testFactory func(config map[string]interface{}) (string, error)
+
+func TestRegistryRegisterAndLookup(t *testing.T) {
+	r := New[testFactory]("test")
+	r.Register("b", func(map[string]interface{}) (string, error) { return "b", nil }, ConfigSchema{})
+	r.Register("a", func(map[string]interface{}) (string, error) { return "a", nil }, ConfigSchema{Description: "provider a"})
+
+	factory, ok := r.Lookup("a")
+	if !ok {
+		t.Fatalf("提供者 a 未注册")
+	}
+	if name, _ := factory(nil); name != "a" {
+		t.Fatalf("期望 a，实际 %s", name)
+	}
+	if _, ok := r.Lookup("c"); ok {
+		t.Fatalf("提供者 c 不应存在")
+	}
+
+	list := r.List()
+	if len(list) != 2 || list[0].Name != "a" || list[1].Name != "b" {
+		t.Fatalf("提供者列表不符合预期: %+v", list)
+	}
+	if list[0].Schema.Description != "provider a" {
+		t.Fatalf("配置描述不符合预期: %+v", list[0].Schema)
+	}
+}
+
+func TestRegistryDuplicateRegisterPanics(t *testing.T) {
+	r := New[testFactory]("test")
+	r.Register("a", nil, ConfigSchema{})
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("重复注册应当 panic")
+		}
+	}()
+	r.Register("a", nil, ConfigSchema{})
+}
+
+func TestConfigSchemaValidate(t *testing.T) {
+	schema := ConfigSchema{
+		Fields: []ConfigField{
+			{Name: "api_key", Type: FieldTypeString, Required: true},
+			{Name: "mode", Type: FieldTypeString, Options: []string{"online", "offline"}},
+			{Name: "timeout", Type: FieldTypeNumber},
+			{Name: "stream", Type: FieldTypeBool},
+			{Name: "chunk_size", Type: FieldTypeArray},
+			{Name: "extra", Type: FieldTypeObject},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr bool
+	}{
+		{"最小配置", map[string]interface{}{"api_key": "k"}, false},
+		{"缺少必填项", map[string]interface{}{}, true},
+		{"必填项为空", map[string]interface{}{"api_key": ""}, true},
+		{"可选值合法", map[string]interface{}{"api_key": "k", "mode": "offline"}, false},
+		{"可选值非法", map[string]interface{}{"api_key": "k", "mode": "2pass"}, true},
+		{"yaml整数", map[string]interface{}{"api_key": "k", "timeout": 30}, false},
+		{"json浮点数", map[string]interface{}{"api_key": "k", "timeout": float64(30)}, false},
+		{"数字字符串", map[string]interface{}{"api_key": "k", "timeout": "30"}, false},
+		{"数值类型错误", map[string]interface{}{"api_key": "k", "timeout": "abc"}, true},
+		{"布尔类型错误", map[string]interface{}{"api_key": "k", "stream": "true"}, true},
+		{"数组", map[string]interface{}{"api_key": "k", "chunk_size": []interface{}{5, 10, 5}}, false},
+		{"对象类型错误", map[string]interface{}{"api_key": "k", "extra": []interface{}{}}, true},
+		{"未声明的配置项不校验", map[string]interface{}{"api_key": "k", "unknown": 1}, false},
+		{"字符串配置项为数值", map[string]interface{}{"api_key": float64(123456)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Validate(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigSchemaValidateNumberString(t *testing.T) {
+	schema := ConfigSchema{Fields: []ConfigField{{Name: "appid", Type: FieldTypeString, Required: true}}}
+	for _, value := range []interface{}{123456, float64(123456)} {
+		config := map[string]interface{}{"appid": value}
+		if err := schema.Validate(config); err != nil {
+			t.Fatalf("Validate(%v) err = %v", value, err)
+		}
+		if config["appid"] != "123456" {
+			t.Fatalf("appid = %#v, want \"123456\"", config["appid"])
+		}
+	}
+}
+
+func TestRegistryValidateUnknownProvider(t *testing.T) {
+	r := New[testFactory]("test")
+	if err := r.Validate("missing", nil); err == nil {
+		t.Fatalf("未注册的提供者应当返回错误")
+	}
+}
@@ -0,0 +1,131 @@
+package registry
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 配置字段类型
+const (
+	FieldTypeAny    = ""
+	FieldTypeString = "string"
+	FieldTypeNumber = "number"
+	FieldTypeBool   = "bool"
+	FieldTypeArray  = "array"
+	FieldTypeObject = "object"
+)
+
+// ConfigField 单个配置项的描述
+type ConfigField struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+	Options     []string    `json:"options,omitempty"` // 可选值，仅对字符串类型生效
+	Description string      `json:"description,omitempty"`
+}
+
+// ConfigSchema 提供者配置描述
+// 未在 Fields 中声明的配置项不做校验，便于各提供者逐步补充
+type ConfigSchema struct {
+	Description string        `json:"description,omitempty"`
+	Fields      []ConfigField `json:"fields"`
+}
+
+// Validate 校验配置是否满足描述
+// 字符串配置项填写为数值时(如 appid 在 yaml/json 中未加引号)转换为字符串写回 config, 供提供者按字符串读取
+func (s ConfigSchema) Validate(config map[string]interface{}) error {
+	var errs []string
+	for _, field := range s.Fields {
+		value, exists := config[field.Name]
+		if !exists || value == nil {
+			if field.Required {
+				errs = append(errs, fmt.Sprintf("缺少必填配置项 %s", field.Name))
+			}
+			continue
+		}
+		if field.Type == FieldTypeString {
+			if str, ok := numberString(value); ok {
+				value = str
+				config[field.Name] = str
+			}
+		}
+		if err := field.check(value); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (f ConfigField) check(value interface{}) error {
+	switch f.Type {
+	case FieldTypeString:
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("配置项 %s 应为字符串类型，实际为 %T", f.Name, value)
+		}
+		if f.Required && str == "" {
+			return fmt.Errorf("配置项 %s 不能为空", f.Name)
+		}
+		if len(f.Options) > 0 && !contains(f.Options, str) {
+			return fmt.Errorf("配置项 %s 的值 %s 不在可选范围 %v 内", f.Name, str, f.Options)
+		}
+	case FieldTypeNumber:
+		if !isNumber(value) {
+			return fmt.Errorf("配置项 %s 应为数值类型，实际为 %T", f.Name, value)
+		}
+	case FieldTypeBool:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("配置项 %s 应为布尔类型，实际为 %T", f.Name, value)
+		}
+	case FieldTypeArray:
+		switch value.(type) {
+		case []interface{}, []string, []int, []float64:
+		default:
+			return fmt.Errorf("配置项 %s 应为数组类型，实际为 %T", f.Name, value)
+		}
+	case FieldTypeObject:
+		if _, ok := value.(map[string]interface{}); !ok {
+			return fmt.Errorf("配置项 %s 应为对象类型，实际为 %T", f.Name, value)
+		}
+	}
+	return nil
+}
+
+// isNumber 判断是否为数值，yaml 解析为 int，json 解析为 float64，也兼容数字字符串
+func isNumber(value interface{}) bool {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return true
+	case string:
+		_, err := strconv.ParseFloat(v, 64)
+		return err == nil
+	}
+	return false
+}
+
+// numberString 把数值转换为字符串, 非数值返回 false
+func numberString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+func contains(options []string, value string) bool {
+	for _, option := range options {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	log "xiaozhi-esp32-server-golang/logger"
+
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterOtlp   = "otlp"
+	ExporterStdout = "stdout"
+
+	tracerName         = "xiaozhi-esp32-server-golang/chat"
+	defaultServiceName = "xiaozhi-esp32-server"
+)
+
+// Config 链路追踪配置, 对应配置文件 tracing
+type Config struct {
+	Enable      bool
+	Exporter    string            // otlp 或 stdout
+	Endpoint    string            // otlp http 接收地址, 如 127.0.0.1:4318
+	URLPath     string            // otlp 上报路径, 为空时使用 /v1/traces
+	Insecure    bool              // 是否使用 http 上报
+	Headers     map[string]string // otlp 上报附加的请求头
+	SampleRatio float64           // 采样比例, 0~1
+	ServiceName string
+
+	// Writer stdout 导出器的输出, 为空时输出到标准输出, 主要用于测试
+	Writer io.Writer
+}
+
+// LoadConfig 从配置文件读取链路追踪配置
+func LoadConfig() Config {
+	config := Config{
+		Enable:      viper.GetBool("tracing.enable"),
+		Exporter:    viper.GetString("tracing.exporter"),
+		Endpoint:    viper.GetString("tracing.endpoint"),
+		URLPath:     viper.GetString("tracing.url_path"),
+		Insecure:    viper.GetBool("tracing.insecure"),
+		Headers:     viper.GetStringMapString("tracing.headers"),
+		SampleRatio: viper.GetFloat64("tracing.sample_ratio"),
+		ServiceName: viper.GetString("tracing.service_name"),
+	}
+	if !viper.IsSet("tracing.sample_ratio") {
+		config.SampleRatio = 1
+	}
+	return config
+}
+
+// Init 初始化全局 TracerProvider, 返回的 shutdown 用于退出时刷新未上报的 span
+// 未启用时不做任何处理, 全局使用 otel 默认的 noop 实现
+func Init(ctx context.Context, config Config) (func(context.Context) error, error) {
+	if !config.Enable {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	log.Infof("链路追踪已启用, exporter: %s, endpoint: %s, sample_ratio: %v", config.Exporter, config.Endpoint, config.SampleRatio)
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, config Config) (sdktrace.SpanExporter, error) {
+	switch config.Exporter {
+	case ExporterStdout:
+		writer := config.Writer
+		if writer == nil {
+			writer = os.Stdout
+		}
+		return stdouttrace.New(stdouttrace.WithWriter(writer))
+	case ExporterOtlp, "":
+		opts := []otlptracehttp.Option{}
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+		if config.URLPath != "" {
+			opts = append(opts, otlptracehttp.WithURLPath(config.URLPath))
+		}
+		if config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if len(config.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(config.Headers))
+		}
+		exporter, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("创建otlp导出器失败: %v", err)
+		}
+		return exporter, nil
+	default:
+		return nil, fmt.Errorf("不支持的链路追踪导出器: %s", config.Exporter)
+	}
+}
+
+// Tracer 返回对话链路使用的 tracer
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
@@ -0,0 +1,213 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "xiaozhi-esp32-server-golang/logger"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 一轮对话中的关键时间点, 以 vad 检测到说话结束为起点
+const (
+	MilestoneAsrFinal      = "asr_final"
+	MilestoneLlmFirstToken = "llm_first_token"
+	MilestoneFirstSentence = "first_sentence"
+	MilestoneTtsFirstFrame = "tts_first_frame"
+	MilestoneTtsLastFrame  = "tts_last_frame"
+)
+
+var milestoneOrder = []string{
+	MilestoneAsrFinal,
+	MilestoneLlmFirstToken,
+	MilestoneFirstSentence,
+	MilestoneTtsFirstFrame,
+	MilestoneTtsLastFrame,
+}
+
+type turnCtxKey struct{}
+
+// Turn 一轮对话的链路追踪
+// 根 span 从说话结束开始, 到最后一帧音频发送完成结束; asr、llm 请求、工具调用、tts 句子作为子 span
+// 所有方法对 nil 安全, 调用方无需判断是否开启了追踪
+type Turn struct {
+	span    trace.Span
+	asrSpan trace.Span
+	start   time.Time
+
+	mu         sync.Mutex
+	milestones map[string]time.Duration
+	ended      bool
+}
+
+// StartTurn 在说话结束时开始一轮对话的追踪
+func StartTurn(deviceID string, sessionID string) *Turn {
+	start := time.Now()
+	ctx, span := Tracer().Start(context.Background(), "chat.turn",
+		trace.WithTimestamp(start),
+		trace.WithAttributes(
+			attribute.String("device_id", deviceID),
+			attribute.String("session_id", sessionID),
+		),
+	)
+	span.AddEvent("vad.speech_end", trace.WithTimestamp(start))
+	_, asrSpan := Tracer().Start(ctx, "asr", trace.WithTimestamp(start))
+
+	return &Turn{
+		span:       span,
+		asrSpan:    asrSpan,
+		start:      start,
+		milestones: make(map[string]time.Duration),
+	}
+}
+
+// FromContext 获取 ctx 中的对话追踪, 不存在时返回 nil
+func FromContext(ctx context.Context) *Turn {
+	if ctx == nil {
+		return nil
+	}
+	turn, _ := ctx.Value(turnCtxKey{}).(*Turn)
+	return turn
+}
+
+// Context 将本轮追踪挂到 parent 上, 之后基于该 ctx 创建的 span 都是本轮的子 span
+func (t *Turn) Context(parent context.Context) context.Context {
+	if t == nil {
+		return parent
+	}
+	ctx := trace.ContextWithSpan(parent, t.span)
+	return context.WithValue(ctx, turnCtxKey{}, t)
+}
+
+// AsrFinal 收到 asr 最终结果
+func (t *Turn) AsrFinal(text string) {
+	if t == nil {
+		return
+	}
+	if t.mark(MilestoneAsrFinal, true) {
+		t.asrSpan.SetAttributes(attribute.Int("asr.text_len", len([]rune(text))))
+		t.asrSpan.End()
+	}
+}
+
+// LlmFirstToken 收到 llm 首个响应
+func (t *Turn) LlmFirstToken() {
+	if t == nil {
+		return
+	}
+	t.mark(MilestoneLlmFirstToken, true)
+}
+
+// FirstSentence 开始合成第一句
+func (t *Turn) FirstSentence() {
+	if t == nil {
+		return
+	}
+	t.mark(MilestoneFirstSentence, true)
+}
+
+// TtsFirstFrame 发送第一帧音频
+func (t *Turn) TtsFirstFrame() {
+	if t == nil {
+		return
+	}
+	t.mark(MilestoneTtsFirstFrame, true)
+}
+
+// TtsLastFrame 发送完一句的最后一帧音频, 多次调用以最后一次为准
+func (t *Turn) TtsLastFrame() {
+	if t == nil {
+		return
+	}
+	t.mark(MilestoneTtsLastFrame, false)
+}
+
+// mark 记录时间点, once 为 true 时只记录第一次, 返回是否记录
+func (t *Turn) mark(name string, once bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ended {
+		return false
+	}
+	if _, ok := t.milestones[name]; ok && once {
+		return false
+	}
+	now := time.Now()
+	t.milestones[name] = now.Sub(t.start)
+	if once {
+		t.span.AddEvent(name, trace.WithTimestamp(now))
+	}
+	return true
+}
+
+// Milestone 获取时间点相对说话结束的耗时
+func (t *Turn) Milestone(name string) (time.Duration, bool) {
+	if t == nil {
+		return 0, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d, ok := t.milestones[name]
+	return d, ok
+}
+
+// End 结束本轮追踪, 只有第一次调用生效
+func (t *Turn) End(err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if t.ended {
+		t.mu.Unlock()
+		return
+	}
+	t.ended = true
+	milestones := make(map[string]time.Duration, len(t.milestones))
+	for k, v := range t.milestones {
+		milestones[k] = v
+	}
+	t.mu.Unlock()
+
+	if _, ok := milestones[MilestoneAsrFinal]; !ok {
+		t.asrSpan.End()
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(milestones))
+	summary := make([]any, 0, len(milestones)*2)
+	for _, name := range milestoneOrder {
+		if d, ok := milestones[name]; ok {
+			attrs = append(attrs, attribute.Int64("latency."+name+"_ms", d.Milliseconds()))
+			summary = append(summary, name, d.Milliseconds())
+		}
+	}
+	t.span.SetAttributes(attrs...)
+	if err != nil {
+		t.span.RecordError(err)
+		t.span.SetStatus(codes.Error, err.Error())
+	}
+	if lastFrame, ok := milestones[MilestoneTtsLastFrame]; ok {
+		t.span.End(trace.WithTimestamp(t.start.Add(lastFrame)))
+	} else {
+		t.span.End()
+	}
+
+	log.Debugf("本轮对话耗时(ms): %v", summary)
+}
+
+// StartSpan 基于 ctx 创建子 span
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan 结束 span, err 不为空时标记为失败
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
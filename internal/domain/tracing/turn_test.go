@@ -0,0 +1,124 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+type exportedSpan struct {
+	Name        string
+	SpanContext struct {
+		TraceID string
+		SpanID  string
+	}
+	Parent struct {
+		SpanID string
+	}
+	Attributes []struct {
+		Key   string
+		Value struct {
+			Value any
+		}
+	}
+	Events []struct {
+		Name string
+	}
+}
+
+func readSpans(t *testing.T, r io.Reader) map[string]exportedSpan {
+	spans := make(map[string]exportedSpan)
+	decoder := json.NewDecoder(r)
+	for decoder.More() {
+		var span exportedSpan
+		if err := decoder.Decode(&span); err != nil {
+			t.Fatalf("解析span失败: %v", err)
+		}
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+func TestTurnStdoutExporter(t *testing.T) {
+	defaultProvider := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(defaultProvider)
+
+	var buf bytes.Buffer
+	shutdown, err := Init(context.Background(), Config{Enable: true, Exporter: ExporterStdout, SampleRatio: 1, Writer: &buf})
+	if err != nil {
+		t.Fatalf("初始化失败: %v", err)
+	}
+
+	turn := StartTurn("device-1", "session-1")
+	turn.AsrFinal("今天天气怎么样")
+	ctx := turn.Context(context.Background())
+	if FromContext(ctx) != turn {
+		t.Fatalf("ctx 中未找到本轮追踪")
+	}
+
+	llmCtx, llmSpan := StartSpan(ctx, "llm.request")
+	FromContext(llmCtx).LlmFirstToken()
+	_, toolSpan := StartSpan(llmCtx, "tool_call")
+	EndSpan(toolSpan, errors.New("工具调用失败"))
+	FromContext(llmCtx).FirstSentence()
+	FromContext(llmCtx).TtsFirstFrame()
+	FromContext(llmCtx).TtsLastFrame()
+	EndSpan(llmSpan, nil)
+	turn.End(nil)
+	turn.End(errors.New("重复结束不生效"))
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown 失败: %v", err)
+	}
+
+	spans := readSpans(t, &buf)
+	root, ok := spans["chat.turn"]
+	if !ok {
+		t.Fatalf("未导出 chat.turn, spans: %v", spans)
+	}
+	for name, parent := range map[string]string{
+		"asr":         root.SpanContext.SpanID,
+		"llm.request": root.SpanContext.SpanID,
+		"tool_call":   spans["llm.request"].SpanContext.SpanID,
+	} {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("未导出 %s", name)
+		}
+		if span.SpanContext.TraceID != root.SpanContext.TraceID || span.Parent.SpanID != parent {
+			t.Fatalf("%s 的父span错误", name)
+		}
+	}
+
+	attrs := make(map[string]bool)
+	for _, attr := range root.Attributes {
+		attrs[attr.Key] = true
+	}
+	for _, name := range milestoneOrder {
+		if !attrs["latency."+name+"_ms"] {
+			t.Fatalf("缺少耗时属性 %s, attrs: %v", name, attrs)
+		}
+	}
+	if len(root.Events) != 5 || root.Events[0].Name != "vad.speech_end" {
+		t.Fatalf("事件错误: %+v", root.Events)
+	}
+}
+
+func TestNilTurn(t *testing.T) {
+	var turn *Turn
+	turn.AsrFinal("")
+	turn.LlmFirstToken()
+	turn.TtsLastFrame()
+	turn.End(nil)
+	if turn.Context(context.Background()) == nil {
+		t.Fatalf("nil 追踪应返回原 ctx")
+	}
+	if FromContext(context.Background()) != nil {
+		t.Fatalf("未挂追踪的 ctx 应返回 nil")
+	}
+}
@@ -3,13 +3,13 @@ package tts
 import (
 	"context"
 	"fmt"
+	"io"
+
+	"github.com/spf13/viper"
 
 	"xiaozhi-esp32-server-golang/constants"
-	"xiaozhi-esp32-server-golang/internal/domain/tts/cosyvoice"
-	"xiaozhi-esp32-server-golang/internal/domain/tts/doubao"
-	"xiaozhi-esp32-server-golang/internal/domain/tts/edge"
-	"xiaozhi-esp32-server-golang/internal/domain/tts/edge_offline"
-	"xiaozhi-esp32-server-golang/internal/domain/tts/xiaozhi"
+	"xiaozhi-esp32-server-golang/internal/domain/tts/cache"
+	log "xiaozhi-esp32-server-golang/logger"
 )
 
 // 基础TTS提供者接口（不含Context方法）
@@ -18,50 +18,149 @@ type BaseTTSProvider interface {
 	TextToSpeechStream(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (outputChan chan []byte, err error)
 }
 
+// StreamResultTTSProvider 流式合成结束后报告结果的提供者
+// result 在合成结束后收到一个值: 完整合成为 nil, 中途失败或取消为对应的错误
+// 未实现的提供者无法区分完整合成和中途失败, 流式合成的音频不写入缓存
+type StreamResultTTSProvider interface {
+	TextToSpeechStreamWithResult(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (outputChan chan []byte, result <-chan error, err error)
+}
+
 // 完整TTS提供者接口（包含Context方法）
 type TTSProvider interface {
 	BaseTTSProvider
 }
 
 // GetTTSProvider 获取一个完整的TTS提供者（支持Context）
+// providerName 未注册时按 tts.chains 中同名的故障转移链创建, config 中的配置项覆盖链的配置
 func GetTTSProvider(providerName string, config map[string]interface{}) (TTSProvider, error) {
-	var baseProvider BaseTTSProvider
-
-	switch providerName {
-	case constants.TtsTypeDoubao:
-		baseProvider = doubao.NewDoubaoTTSProvider(config)
-	case constants.TtsTypeDoubaoWS:
-		baseProvider = doubao.NewDoubaoWSProvider(config)
-	case constants.TtsTypeCosyvoice:
-		baseProvider = cosyvoice.NewCosyVoiceTTSProvider(config)
-	case constants.TtsTypeEdge:
-		baseProvider = edge.NewEdgeTTSProvider(config)
-	case constants.TtsTypeEdgeOffline:
-		baseProvider = edge_offline.NewEdgeOfflineTTSProvider(config)
-	case constants.TtsTypeXiaozhi:
-		baseProvider = xiaozhi.NewXiaozhiProvider(config)
-	default:
-		return nil, fmt.Errorf("不支持的TTS提供者: %s", providerName)
+	factory, ok := providers.Lookup(providerName)
+	if !ok {
+		chainConfig, isChain := GetChainConfig(providerName)
+		if !isChain {
+			return nil, fmt.Errorf("不支持的TTS提供者: %s", providerName)
+		}
+		for k, v := range config {
+			chainConfig[k] = v
+		}
+		return GetTTSProvider(constants.TtsTypeChain, chainConfig)
+	}
+	if err := providers.Validate(providerName, config); err != nil {
+		return nil, err
+	}
+
+	baseProvider, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建TTS提供者 %s 失败: %v", providerName, err)
 	}
 
 	// 使用适配器包装基础提供者，转换为完整的TTSProvider
-	provider := &ContextTTSAdapter{baseProvider}
+	provider := &ContextTTSAdapter{Provider: baseProvider, Name: providerName, Config: config}
 	return provider, nil
 }
 
+// GetChainConfig 获取 tts.chains 中配置的故障转移链
+func GetChainConfig(name string) (map[string]interface{}, bool) {
+	key := "tts.chains." + name
+	if name == "" || !viper.IsSet(key) {
+		return nil, false
+	}
+	// 复制一份, 避免调用方修改 viper 内部的配置
+	config := make(map[string]interface{})
+	for k, v := range viper.GetStringMap(key) {
+		config[k] = v
+	}
+	return config, true
+}
+
 // ContextTTSAdapter 是一个适配器，为基础TTS提供者添加Context支持
+// 开启TTS缓存时, 短文本的合成结果按提供者、配置、文本和输出格式缓存
+// 文本中的语音标记由适配器解析, 按提供者的能力转换或去除
 type ContextTTSAdapter struct {
 	Provider BaseTTSProvider
+	Name     string                 //提供者名称, 用于缓存 key
+	Config   map[string]interface{} //提供者配置, 用于缓存 key
+}
+
+// Close 释放基础提供者持有的连接池等资源, 基础提供者未实现 Close 时不做处理
+func (a *ContextTTSAdapter) Close() error {
+	if closer, ok := a.Provider.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// cacheKey 返回缓存实例和 key, 不需要缓存时返回 nil
+// 故障转移链不缓存, 由实际合成的提供者各自缓存
+func (a *ContextTTSAdapter) cacheKey(text string, sampleRate int, channels int, frameDuration int) (*cache.Cache, string) {
+	c := cache.Get()
+	if a.Name == "" || a.Name == constants.TtsTypeChain || !c.Cacheable(text) {
+		return nil, ""
+	}
+	return c, cache.Key(a.Name, a.Config, text, sampleRate, channels, frameDuration)
 }
 
 // TextToSpeech 代理到原始提供者
 func (a *ContextTTSAdapter) TextToSpeech(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) ([][]byte, error) {
-	return a.Provider.TextToSpeech(ctx, text, sampleRate, channels, frameDuration)
+	c, key := a.cacheKey(text, sampleRate, channels, frameDuration)
+	if c != nil {
+		if frames, ok := c.Get(ctx, key); ok {
+			return frames, nil
+		}
+	}
+	frames, complete, err := a.textToSpeech(ctx, text, sampleRate, channels, frameDuration)
+	if err == nil && complete && c != nil {
+		c.Set(key, frames)
+	}
+	return frames, err
 }
 
-// TextToSpeechStream 代理到原始提供者
+// TextToSpeechStream 代理到原始提供者, 缓存命中时直接输出缓存的音频帧
 func (a *ContextTTSAdapter) TextToSpeechStream(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (outputChan chan []byte, err error) {
-	return a.Provider.TextToSpeechStream(ctx, text, sampleRate, channels, frameDuration)
+	c, key := a.cacheKey(text, sampleRate, channels, frameDuration)
+	if c != nil {
+		if frames, ok := c.Get(ctx, key); ok {
+			outputChan = make(chan []byte, len(frames))
+			for _, frame := range frames {
+				outputChan <- frame
+			}
+			close(outputChan)
+			return outputChan, nil
+		}
+	}
+
+	streamChan, result, err := a.textToSpeechStream(ctx, text, sampleRate, channels, frameDuration)
+	if err != nil || c == nil || result == nil {
+		return streamChan, err
+	}
+
+	// 转发的同时收集音频帧, 提供者报告完整合成后才写入缓存, 中途失败或取消的不缓存
+	outputChan = make(chan []byte, 100)
+	go func() {
+		var frames [][]byte
+		for frame := range streamChan {
+			frames = append(frames, frame)
+			select {
+			case <-ctx.Done():
+				close(outputChan)
+				return
+			case outputChan <- frame:
+			}
+		}
+		// 先结束输出, 等待结果不影响播放
+		close(outputChan)
+		select {
+		case <-ctx.Done():
+		case err := <-result:
+			if err != nil {
+				log.Debugf("TTS流式合成未完成, 不缓存: %s, %v", text, err)
+				return
+			}
+			if ctx.Err() == nil {
+				c.Set(key, frames)
+			}
+		}
+	}()
+	return outputChan, nil
 }
 
 // TextToSpeechWithContext 使用Context版本的文本转语音
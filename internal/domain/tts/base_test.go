@@ -0,0 +1,95 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"xiaozhi-esp32-server-golang/internal/domain/tts/cache"
+)
+
+// resultTTS 输出 frames 个音频帧, 结束后通过 result 报告 err
+type resultTTS struct {
+	frames int
+	err    error
+	calls  int
+}
+
+func (f *resultTTS) TextToSpeech(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) ([][]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *resultTTS) TextToSpeechStream(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (chan []byte, error) {
+	outputChan, _, err := f.TextToSpeechStreamWithResult(ctx, text, sampleRate, channels, frameDuration)
+	return outputChan, err
+}
+
+func (f *resultTTS) TextToSpeechStreamWithResult(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (chan []byte, <-chan error, error) {
+	f.calls++
+	outputChan := make(chan []byte, f.frames)
+	result := make(chan error, 1)
+	for i := 0; i < f.frames; i++ {
+		outputChan <- []byte{byte(i)}
+	}
+	close(outputChan)
+	result <- f.err
+	return outputChan, result, nil
+}
+
+func TestContextTTSAdapterStreamCache(t *testing.T) {
+	viper.Set("tts_cache.enable", true)
+	if err := cache.Init(); err != nil || cache.Get() == nil {
+		t.Fatalf("初始化TTS缓存失败: %v", err)
+	}
+
+	drain := func(a *ContextTTSAdapter, text string) int {
+		outputChan, err := a.TextToSpeechStream(context.Background(), text, 16000, 1, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for range outputChan {
+			n++
+		}
+		return n
+	}
+	cached := func(a *ContextTTSAdapter, text string) bool {
+		c, key := a.cacheKey(text, 16000, 1, 20)
+		// 缓存在输出结束后异步写入
+		for i := 0; i < 20; i++ {
+			if _, ok := c.Get(context.Background(), key); ok {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+
+	// 合成几帧后失败, 截断的音频照常输出但不缓存
+	failing := &resultTTS{frames: 3, err: errors.New("连接断开")}
+	a := &ContextTTSAdapter{Provider: failing, Name: "fake", Config: map[string]interface{}{}}
+	if n := drain(a, "中途失败"); n != 3 {
+		t.Fatalf("输出 %d 帧, want 3", n)
+	}
+	if cached(a, "中途失败") {
+		t.Fatal("中途失败的合成结果不应缓存")
+	}
+	drain(a, "中途失败")
+	if failing.calls != 2 {
+		t.Fatalf("未缓存时应重新合成, calls = %d", failing.calls)
+	}
+
+	// 完整合成后缓存, 再次合成直接使用缓存
+	ok := &resultTTS{frames: 3}
+	a = &ContextTTSAdapter{Provider: ok, Name: "fake", Config: map[string]interface{}{}}
+	drain(a, "完整合成")
+	if !cached(a, "完整合成") {
+		t.Fatal("完整合成的结果应缓存")
+	}
+	if n := drain(a, "完整合成"); n != 3 || ok.calls != 1 {
+		t.Fatalf("输出 %d 帧, calls = %d, want 3, 1", n, ok.calls)
+	}
+}
@@ -0,0 +1,174 @@
+// Package cache TTS音频缓存
+// 按提供者、配置(含音色)、文本和输出格式缓存编码后的 opus 帧, 内存中按 LRU 淘汰, 可选磁盘或 redis 作为二级缓存
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	redisdb "xiaozhi-esp32-server-golang/internal/db/redis"
+	log "xiaozhi-esp32-server-golang/logger"
+
+	"github.com/spf13/viper"
+)
+
+// 二级缓存类型
+const (
+	StoreTypeNone  = ""
+	StoreTypeDisk  = "disk"
+	StoreTypeRedis = "redis"
+)
+
+// 默认配置
+const (
+	DefaultMaxEntries    = 500
+	DefaultMaxTextLength = 50
+	storeTimeout         = 3 * time.Second
+)
+
+// Config TTS缓存配置
+type Config struct {
+	Enable        bool
+	MaxEntries    int           //内存中最多缓存的条数
+	MaxTextLength int           //只缓存不超过该字数的文本, 长回复很少重复
+	Store         string        //二级缓存: 空、disk 或 redis
+	TTL           time.Duration //二级缓存的过期时间, 0 为永不过期
+	DiskDir       string
+	KeyPrefix     string //redis key 前缀
+}
+
+// LoadConfig 从配置文件读取TTS缓存配置
+func LoadConfig() Config {
+	return Config{
+		Enable:        viper.GetBool("tts_cache.enable"),
+		MaxEntries:    viper.GetInt("tts_cache.max_entries"),
+		MaxTextLength: viper.GetInt("tts_cache.max_text_length"),
+		Store:         viper.GetString("tts_cache.store"),
+		TTL:           viper.GetDuration("tts_cache.ttl"),
+		DiskDir:       viper.GetString("tts_cache.disk.dir"),
+		KeyPrefix:     viper.GetString("redis.key_prefix"),
+	}
+}
+
+var (
+	cacheInstance *Cache
+	once          sync.Once
+)
+
+// Init 按配置初始化TTS缓存, 未开启时 Get 返回 nil
+func Init() error {
+	var initErr error
+	once.Do(func() {
+		config := LoadConfig()
+		if !config.Enable {
+			return
+		}
+		store, err := NewStore(config)
+		if err != nil {
+			initErr = fmt.Errorf("创建TTS二级缓存失败, 仅使用内存缓存: %v", err)
+		}
+		cacheInstance = New(config.MaxEntries, config.MaxTextLength, store)
+		log.Infof("TTS缓存已启用, 内存条数: %d, 二级缓存: %s", cacheInstance.memory.capacity, config.Store)
+	})
+	return initErr
+}
+
+// Get 获取缓存实例, 未开启缓存时返回 nil
+func Get() *Cache {
+	return cacheInstance
+}
+
+// NewStore 按类型创建二级缓存, 未配置时返回 nil
+func NewStore(config Config) (Store, error) {
+	switch config.Store {
+	case StoreTypeNone:
+		return nil, nil
+	case StoreTypeDisk:
+		return NewDiskStore(config.DiskDir, config.TTL)
+	case StoreTypeRedis:
+		client := redisdb.GetClient()
+		if client == nil {
+			return nil, fmt.Errorf("redis 未初始化")
+		}
+		return NewRedisStore(client, config.KeyPrefix, config.TTL), nil
+	default:
+		return nil, fmt.Errorf("不支持的TTS缓存类型: %s", config.Store)
+	}
+}
+
+// Key 缓存 key, config 为提供者的完整配置, 音色、语速等任一配置变化都会生成不同的 key
+func Key(provider string, config map[string]interface{}, text string, sampleRate int, channels int, frameDuration int) string {
+	// fmt 按 key 排序输出 map, 相同配置得到相同的字符串
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s|%v|%s|%d|%d|%d", provider, config, text, sampleRate, channels, frameDuration)))
+	return hex.EncodeToString(h[:])
+}
+
+// Cache 内存 LRU 加可选的二级缓存
+type Cache struct {
+	memory        *lru
+	store         Store
+	maxTextLength int
+}
+
+// New 创建缓存, store 为 nil 时仅使用内存缓存
+func New(maxEntries int, maxTextLength int, store Store) *Cache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	if maxTextLength <= 0 {
+		maxTextLength = DefaultMaxTextLength
+	}
+	return &Cache{
+		memory:        newLRU(maxEntries),
+		store:         store,
+		maxTextLength: maxTextLength,
+	}
+}
+
+// Cacheable 文本是否需要缓存
+func (c *Cache) Cacheable(text string) bool {
+	return c != nil && text != "" && len([]rune(text)) <= c.maxTextLength
+}
+
+// Get 读取缓存, 二级缓存命中时同时写入内存
+func (c *Cache) Get(ctx context.Context, key string) ([][]byte, bool) {
+	if frames, ok := c.memory.Get(key); ok {
+		return frames, true
+	}
+	if c.store == nil {
+		return nil, false
+	}
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+	frames, err := c.store.Get(ctx, key)
+	if err != nil {
+		if err != ErrNotFound {
+			log.Warnf("读取TTS二级缓存失败: %v", err)
+		}
+		return nil, false
+	}
+	c.memory.Set(key, frames)
+	return frames, true
+}
+
+// Set 写入缓存, 二级缓存异步写入
+func (c *Cache) Set(key string, frames [][]byte) {
+	if len(frames) == 0 {
+		return
+	}
+	c.memory.Set(key, frames)
+	if c.store == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+		defer cancel()
+		if err := c.store.Set(ctx, key, frames); err != nil {
+			log.Warnf("写入TTS二级缓存失败: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLRUEviction(t *testing.T) {
+	l := newLRU(2)
+	l.Set("a", [][]byte{{1}})
+	l.Set("b", [][]byte{{2}})
+	if _, ok := l.Get("a"); !ok {
+		t.Fatal("a 应在缓存中")
+	}
+	l.Set("c", [][]byte{{3}})
+	if _, ok := l.Get("b"); ok {
+		t.Fatal("b 最久未使用, 应被淘汰")
+	}
+	if _, ok := l.Get("a"); !ok {
+		t.Fatal("a 最近使用过, 不应被淘汰")
+	}
+	if l.Len() != 2 {
+		t.Fatalf("len = %d", l.Len())
+	}
+}
+
+func TestKey(t *testing.T) {
+	config := map[string]interface{}{"voice": "zh-CN-XiaoxiaoNeural", "rate": "+0%"}
+	key := Key("edge", config, "你好", 16000, 1, 60)
+	if key != Key("edge", map[string]interface{}{"rate": "+0%", "voice": "zh-CN-XiaoxiaoNeural"}, "你好", 16000, 1, 60) {
+		t.Fatal("相同配置应生成相同的 key")
+	}
+	others := []string{
+		Key("edge_offline", config, "你好", 16000, 1, 60),
+		Key("edge", map[string]interface{}{"voice": "zh-CN-YunxiNeural", "rate": "+0%"}, "你好", 16000, 1, 60),
+		Key("edge", config, "你好呀", 16000, 1, 60),
+		Key("edge", config, "你好", 24000, 1, 60),
+		Key("edge", config, "你好", 16000, 1, 20),
+	}
+	for _, other := range others {
+		if other == key {
+			t.Fatal("提供者、音色、文本或格式不同时应生成不同的 key")
+		}
+	}
+}
+
+func TestDiskStore(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := store.Get(ctx, "abcdef"); err != ErrNotFound {
+		t.Fatalf("err = %v", err)
+	}
+	frames := [][]byte{{1, 2, 3}, {}, {4}}
+	if err := store.Set(ctx, "abcdef", frames); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Get(ctx, "abcdef")
+	if err != nil || !reflect.DeepEqual(got, [][]byte{{1, 2, 3}, {}, {4}}) {
+		t.Fatalf("got = %v, %v", got, err)
+	}
+
+	// 过期后视为不存在
+	expired := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(store.path("abcdef"), expired, expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "abcdef"); err != ErrNotFound {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestCacheSecondTier(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	frames := [][]byte{{1}, {2}}
+	if err := store.Set(ctx, "key", frames); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(10, 5, store)
+	got, ok := c.Get(ctx, "key")
+	if !ok || !reflect.DeepEqual(got, frames) {
+		t.Fatalf("got = %v, %v", got, ok)
+	}
+	if _, ok := c.memory.Get("key"); !ok {
+		t.Fatal("二级缓存命中后应写入内存")
+	}
+	if c.Cacheable("你好你好你好") || !c.Cacheable("你好") || c.Cacheable("") {
+		t.Fatal("只缓存不超过 max_text_length 的文本")
+	}
+	var nilCache *Cache
+	if nilCache.Cacheable("你好") {
+		t.Fatal("未开启缓存时不应缓存")
+	}
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+type lruEntry struct {
+	key    string
+	frames [][]byte
+}
+
+// lru 按条数淘汰最久未使用的缓存
+type lru struct {
+	sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List //最近使用的在前
+}
+
+func newLRU(capacity int) *lru {
+	return &lru{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (l *lru) Get(key string) ([][]byte, bool) {
+	l.Lock()
+	defer l.Unlock()
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+	return elem.Value.(*lruEntry).frames, true
+}
+
+func (l *lru) Set(key string, frames [][]byte) {
+	l.Lock()
+	defer l.Unlock()
+	if elem, ok := l.items[key]; ok {
+		elem.Value.(*lruEntry).frames = frames
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.items[key] = l.order.PushFront(&lruEntry{key: key, frames: frames})
+	for l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (l *lru) Len() int {
+	l.Lock()
+	defer l.Unlock()
+	return l.order.Len()
+}
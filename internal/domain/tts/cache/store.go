@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNotFound 缓存不存在或已过期
+var ErrNotFound = errors.New("缓存不存在")
+
+// Store 二级缓存
+type Store interface {
+	// Get 读取缓存, 不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) ([][]byte, error)
+	Set(ctx context.Context, key string, frames [][]byte) error
+}
+
+// encodeFrames 每帧前写入4字节长度
+func encodeFrames(frames [][]byte) []byte {
+	size := 0
+	for _, frame := range frames {
+		size += 4 + len(frame)
+	}
+	data := make([]byte, 0, size)
+	for _, frame := range frames {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(frame)))
+		data = append(data, frame...)
+	}
+	return data
+}
+
+func decodeFrames(data []byte) ([][]byte, error) {
+	var frames [][]byte
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("缓存数据不完整")
+		}
+		n := int(binary.BigEndian.Uint32(data))
+		data = data[4:]
+		if n > len(data) {
+			return nil, fmt.Errorf("缓存数据不完整")
+		}
+		frames = append(frames, data[:n])
+		data = data[n:]
+	}
+	return frames, nil
+}
+
+// DiskStore 本地磁盘缓存, 按文件修改时间判断过期
+type DiskStore struct {
+	dir string
+	ttl time.Duration
+}
+
+func NewDiskStore(dir string, ttl time.Duration) (*DiskStore, error) {
+	if dir == "" {
+		dir = "data/tts_cache"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建TTS缓存目录失败: %v", err)
+	}
+	return &DiskStore{dir: dir, ttl: ttl}, nil
+}
+
+// path key 为十六进制摘要, 按前两位分目录避免单目录文件过多
+func (s *DiskStore) path(key string) string {
+	if len(key) < 2 {
+		return filepath.Join(s.dir, key)
+	}
+	return filepath.Join(s.dir, key[:2], key)
+}
+
+func (s *DiskStore) Get(ctx context.Context, key string) ([][]byte, error) {
+	path := s.path(key)
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if s.ttl > 0 && time.Since(info.ModTime()) > s.ttl {
+		os.Remove(path)
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFrames(data)
+}
+
+// Set 先写临时文件再重命名, 避免并发读到写了一半的文件
+func (s *DiskStore) Set(ctx context.Context, key string, frames [][]byte) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(encodeFrames(frames)); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// RedisStore redis 缓存, 多个服务实例可共享
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+func NewRedisStore(client *redis.Client, prefix string, ttl time.Duration) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix, ttl: ttl}
+}
+
+func (s *RedisStore) key(key string) string {
+	if s.prefix == "" {
+		return "tts_cache:" + key
+	}
+	return s.prefix + ":tts_cache:" + key
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) ([][]byte, error) {
+	data, err := s.client.Get(ctx, s.key(key)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return decodeFrames(data)
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, frames [][]byte) error {
+	return s.client.Set(ctx, s.key(key), encodeFrames(frames), s.ttl).Err()
+}
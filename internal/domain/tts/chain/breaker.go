@@ -0,0 +1,103 @@
+package chain
+
+import (
+	"sync"
+	"time"
+)
+
+// 熔断器状态
+const (
+	BreakerClosed   = "closed"    //正常
+	BreakerOpen     = "open"      //连续失败后熔断, 冷却期内不再请求
+	BreakerHalfOpen = "half_open" //冷却结束, 等待探测结果
+)
+
+// breaker 单个提供者的熔断器, 同一提供者配置在所有设备间共享
+// 连续失败 threshold 次后熔断, 冷却结束后由健康探测决定是否恢复
+type breaker struct {
+	sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+	now       func() time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// State 当前状态
+func (b *breaker) State() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.state()
+}
+
+func (b *breaker) state() string {
+	if b.failures < b.threshold {
+		return BreakerClosed
+	}
+	if b.now().Before(b.openUntil) {
+		return BreakerOpen
+	}
+	return BreakerHalfOpen
+}
+
+// Allow 是否可以请求该提供者, probe 为 true 时调用方需发起一次健康探测
+// 熔断期间只允许一个探测, 探测完成前的请求直接跳过该提供者
+func (b *breaker) Allow() (allow bool, probe bool) {
+	b.Lock()
+	defer b.Unlock()
+	switch b.state() {
+	case BreakerClosed:
+		return true, false
+	case BreakerHalfOpen:
+		if !b.probing {
+			b.probing = true
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// Success 请求或探测成功, 恢复正常
+func (b *breaker) Success() {
+	b.Lock()
+	defer b.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure 请求或探测失败, 达到阈值时开始新一轮冷却
+func (b *breaker) Failure() {
+	b.Lock()
+	defer b.Unlock()
+	b.failures++
+	b.probing = false
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+var (
+	breakerLock sync.Mutex
+	breakers    = make(map[string]*breaker)
+)
+
+// getBreaker 按提供者配置获取共享的熔断器
+func getBreaker(key string, threshold int, cooldown time.Duration) *breaker {
+	breakerLock.Lock()
+	defer breakerLock.Unlock()
+	b, ok := breakers[key]
+	if !ok {
+		b = newBreaker(threshold, cooldown)
+		breakers[key] = b
+	}
+	return b
+}
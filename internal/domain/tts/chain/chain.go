@@ -0,0 +1,364 @@
+// Package chain 组合多个TTS提供者的故障转移链
+// 按顺序尝试各提供者, 每个提供者配有熔断器和健康探测, 输出格式不一致时自动重采样和重新编码
+package chain
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"time"
+
+	"xiaozhi-esp32-server-golang/constants"
+	"xiaozhi-esp32-server-golang/internal/domain/audio"
+	config_types "xiaozhi-esp32-server-golang/internal/domain/config/types"
+	"xiaozhi-esp32-server-golang/internal/domain/metrics"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+	"xiaozhi-esp32-server-golang/internal/domain/tts"
+	log "xiaozhi-esp32-server-golang/logger"
+
+	"github.com/spf13/viper"
+)
+
+// 默认配置
+const (
+	DefaultFailureThreshold  = 3
+	DefaultCooldown          = 30 * time.Second
+	DefaultFirstFrameTimeout = 5 * time.Second
+	DefaultProbeText         = "你好"
+)
+
+// Format 提供者输出的 opus 音频格式
+type Format = audio.Format
+
+func init() {
+	tts.Register(constants.TtsTypeChain, func(config map[string]interface{}) (tts.BaseTTSProvider, error) {
+		return NewChainTTSProvider(config)
+	}, registry.ConfigSchema{
+		Description: "TTS故障转移链, 按顺序尝试多个提供者, 出错或首帧超时时切换到下一个",
+		Fields: []registry.ConfigField{
+			{Name: "providers", Type: registry.FieldTypeArray, Required: true, Description: "提供者列表, 每项为 tts 下的配置名, 或包含 provider、config、sample_rate、frame_duration 的对象"},
+			{Name: "failure_threshold", Type: registry.FieldTypeNumber, Default: DefaultFailureThreshold, Description: "连续失败多少次后熔断"},
+			{Name: "cooldown", Type: registry.FieldTypeNumber, Default: int(DefaultCooldown / time.Millisecond), Description: "熔断冷却时间(毫秒), 冷却结束后发起健康探测"},
+			{Name: "first_frame_timeout", Type: registry.FieldTypeNumber, Default: int(DefaultFirstFrameTimeout / time.Millisecond), Description: "等待首帧音频的超时时间(毫秒), 超时后切换到下一个提供者"},
+			{Name: "probe_text", Type: registry.FieldTypeString, Default: DefaultProbeText, Description: "健康探测合成的文本"},
+			{Name: "overrides", Type: registry.FieldTypeObject, Description: "覆盖每个提供者配置的配置项, 多角色朗读时为角色的音色"},
+		},
+	})
+}
+
+// Member 链中的一个提供者
+type Member struct {
+	Name     string
+	Provider tts.TTSProvider
+	// Format 提供者实际输出的格式, 为零值的字段与请求一致
+	Format  Format
+	breaker *breaker
+}
+
+// nativeFormat 请求 target 格式时该提供者实际输出的格式
+func (m *Member) nativeFormat(target Format) Format {
+	return m.Format.Resolve(target)
+}
+
+// ChainTTSProvider TTS故障转移链
+type ChainTTSProvider struct {
+	members           []*Member
+	firstFrameTimeout time.Duration
+	probeText         string
+}
+
+// NewChainTTSProvider 根据配置创建故障转移链
+func NewChainTTSProvider(config map[string]interface{}) (*ChainTTSProvider, error) {
+	c := &ChainTTSProvider{
+		firstFrameTimeout: DefaultFirstFrameTimeout,
+		probeText:         DefaultProbeText,
+	}
+	threshold := DefaultFailureThreshold
+	if v := config_types.ConfigInt(config["failure_threshold"]); v > 0 {
+		threshold = v
+	}
+	cooldown := DefaultCooldown
+	if v := config_types.ConfigInt(config["cooldown"]); v > 0 {
+		cooldown = time.Duration(v) * time.Millisecond
+	}
+	if v := config_types.ConfigInt(config["first_frame_timeout"]); v > 0 {
+		c.firstFrameTimeout = time.Duration(v) * time.Millisecond
+	}
+	if v, _ := config["probe_text"].(string); v != "" {
+		c.probeText = v
+	}
+
+	overrides, _ := config["overrides"].(map[string]interface{})
+
+	items, _ := config["providers"].([]interface{})
+	if len(items) == 0 {
+		return nil, fmt.Errorf("故障转移链至少需要一个提供者")
+	}
+	for i, item := range items {
+		member, err := newMember(item, overrides, threshold, cooldown)
+		if err != nil {
+			return nil, fmt.Errorf("创建第 %d 个提供者失败: %v", i+1, err)
+		}
+		c.members = append(c.members, member)
+	}
+	return c, nil
+}
+
+// Close 释放链中各提供者的资源, 返回第一个失败的错误
+func (c *ChainTTSProvider) Close() error {
+	var firstErr error
+	for _, member := range c.members {
+		closer, ok := member.Provider.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("关闭提供者 %s 失败: %v", member.Name, err)
+		}
+	}
+	return firstErr
+}
+
+// newMember 字符串为 tts 下的配置名, 对象中未指定 config 时同样使用 tts 下同名的配置
+// overrides 覆盖到提供者配置的副本上, 熔断器仍按原配置共享
+func newMember(item interface{}, overrides map[string]interface{}, threshold int, cooldown time.Duration) (*Member, error) {
+	var name string
+	var config map[string]interface{}
+	var format Format
+	switch v := item.(type) {
+	case string:
+		name = v
+	case map[string]interface{}:
+		name, _ = v["provider"].(string)
+		config, _ = v["config"].(map[string]interface{})
+		format = Format{SampleRate: config_types.ConfigInt(v["sample_rate"]), FrameDuration: config_types.ConfigInt(v["frame_duration"])}
+	default:
+		return nil, fmt.Errorf("提供者配置需为字符串或对象: %v", item)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("缺少 provider")
+	}
+	if _, isChain := tts.GetChainConfig(name); isChain || name == constants.TtsTypeChain {
+		return nil, fmt.Errorf("不支持嵌套故障转移链: %s", name)
+	}
+	if config == nil {
+		config = viper.GetStringMap("tts." + name)
+	}
+	providerConfig := config
+	if len(overrides) > 0 {
+		providerConfig = make(map[string]interface{}, len(config)+len(overrides))
+		for k, v := range config {
+			providerConfig[k] = v
+		}
+		for k, v := range overrides {
+			providerConfig[k] = v
+		}
+	}
+	provider, err := tts.GetTTSProvider(name, providerConfig)
+	if err != nil {
+		return nil, err
+	}
+	// 配置中未指定的字段使用提供者声明的原生格式
+	format = format.Resolve(tts.NativeFormat(provider))
+	return &Member{
+		Name:     name,
+		Provider: provider,
+		Format:   format,
+		breaker:  getBreaker(breakerKey(name, config), threshold, cooldown),
+	}, nil
+}
+
+// breakerKey 同一提供者和配置在所有设备间共享熔断器
+func breakerKey(name string, config map[string]interface{}) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%v", config)
+	return fmt.Sprintf("%s:%x", name, h.Sum64())
+}
+
+// candidates 本次请求依次尝试的提供者, 跳过熔断中的提供者
+// 全部熔断时仍按顺序尝试, 避免设备完全无声
+func (c *ChainTTSProvider) candidates() []*Member {
+	members := make([]*Member, 0, len(c.members))
+	for _, member := range c.members {
+		allow, probe := member.breaker.Allow()
+		if probe {
+			go c.probe(member)
+		}
+		if allow {
+			members = append(members, member)
+		}
+	}
+	if len(members) == 0 {
+		log.Warnf("[TTS-Chain] 所有提供者均已熔断, 按顺序重试")
+		return c.members
+	}
+	return members
+}
+
+// probe 熔断冷却结束后的健康探测, 成功则恢复该提供者
+func (c *ChainTTSProvider) probe(member *Member) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.firstFrameTimeout)
+	defer cancel()
+	format := member.nativeFormat(Format{SampleRate: 16000, Channels: 1, FrameDuration: 60})
+	frames, err := member.Provider.TextToSpeech(ctx, c.probeText, format.SampleRate, 1, format.FrameDuration)
+	if err != nil || len(frames) == 0 {
+		log.Warnf("[TTS-Chain] 提供者 %s 健康探测失败: %v", member.Name, err)
+		member.breaker.Failure()
+		return
+	}
+	log.Infof("[TTS-Chain] 提供者 %s 健康探测成功, 恢复使用", member.Name)
+	member.breaker.Success()
+}
+
+// fail 记录一次提供者失败
+func (c *ChainTTSProvider) fail(member *Member, err error) {
+	log.Warnf("[TTS-Chain] 提供者 %s 失败: %v", member.Name, err)
+	member.breaker.Failure()
+	metrics.ObserveProviderError(metrics.KindTts, member.Name)
+}
+
+// TextToSpeech 按顺序尝试各提供者, 返回第一个成功的结果
+func (c *ChainTTSProvider) TextToSpeech(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) ([][]byte, error) {
+	target := Format{SampleRate: sampleRate, Channels: channels, FrameDuration: frameDuration}
+	var lastErr error
+	for _, member := range c.candidates() {
+		native := member.nativeFormat(target)
+		frames, err := member.Provider.TextToSpeech(ctx, text, native.SampleRate, channels, native.FrameDuration)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil && len(frames) == 0 {
+			err = fmt.Errorf("未返回音频")
+		}
+		if err == nil && native.NeedTranscode(target) {
+			frames, err = audio.TranscodeAll(frames, native, target)
+		}
+		if err != nil {
+			c.fail(member, err)
+			lastErr = err
+			continue
+		}
+		member.breaker.Success()
+		return frames, nil
+	}
+	return nil, fmt.Errorf("所有TTS提供者均合成失败: %v", lastErr)
+}
+
+// TextToSpeechStream 按顺序尝试各提供者, 使用第一个在超时前返回首帧的提供者
+// 已输出音频后不再切换提供者, 避免同一句话由两种声音拼接
+func (c *ChainTTSProvider) TextToSpeechStream(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (chan []byte, error) {
+	target := Format{SampleRate: sampleRate, Channels: channels, FrameDuration: frameDuration}
+	var lastErr error
+	for _, member := range c.candidates() {
+		outputChan, err := c.stream(ctx, member, text, channels, target)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			c.fail(member, err)
+			lastErr = err
+			continue
+		}
+		member.breaker.Success()
+		return outputChan, nil
+	}
+	return nil, fmt.Errorf("所有TTS提供者均合成失败: %v", lastErr)
+}
+
+// stream 请求单个提供者并等待首帧, 成功后转发剩余的音频帧
+func (c *ChainTTSProvider) stream(ctx context.Context, member *Member, text string, channels int, target Format) (chan []byte, error) {
+	native := member.nativeFormat(target)
+	var t *audio.Transcoder
+	if native.NeedTranscode(target) {
+		var err error
+		if t, err = audio.NewTranscoder(native, target); err != nil {
+			return nil, err
+		}
+	}
+	closeTranscoder := func() {
+		if t != nil {
+			t.Close()
+		}
+	}
+
+	memberCtx, cancel := context.WithCancel(ctx)
+	frameChan, err := member.Provider.TextToSpeechStream(memberCtx, text, native.SampleRate, channels, native.FrameDuration)
+	if err != nil {
+		cancel()
+		closeTranscoder()
+		return nil, err
+	}
+
+	timer := time.NewTimer(c.firstFrameTimeout)
+	defer timer.Stop()
+	var first []byte
+	select {
+	case <-ctx.Done():
+		cancel()
+		closeTranscoder()
+		return nil, ctx.Err()
+	case <-timer.C:
+		cancel()
+		closeTranscoder()
+		return nil, fmt.Errorf("首帧超时: %v", c.firstFrameTimeout)
+	case frame, ok := <-frameChan:
+		if !ok {
+			cancel()
+			closeTranscoder()
+			return nil, fmt.Errorf("未返回音频")
+		}
+		first = frame
+	}
+
+	outputChan := make(chan []byte, 100)
+	go func() {
+		defer close(outputChan)
+		defer cancel()
+		defer closeTranscoder()
+		send := func(frame []byte) bool {
+			frames := [][]byte{frame}
+			if t != nil {
+				var err error
+				if frames, err = t.Write(frame); err != nil {
+					log.Errorf("[TTS-Chain] 提供者 %s 音频转码失败: %v", member.Name, err)
+					return false
+				}
+			}
+			for _, f := range frames {
+				select {
+				case <-ctx.Done():
+					return false
+				case outputChan <- f:
+				}
+			}
+			return true
+		}
+
+		if !send(first) {
+			return
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case frame, ok := <-frameChan:
+				if !ok {
+					if t != nil {
+						if last, err := t.Flush(); err == nil && last != nil {
+							select {
+							case <-ctx.Done():
+							case outputChan <- last:
+							}
+						}
+					}
+					return
+				}
+				if !send(frame) {
+					return
+				}
+			}
+		}
+	}()
+	return outputChan, nil
+}
@@ -0,0 +1,328 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"xiaozhi-esp32-server-golang/internal/domain/audio"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+	"xiaozhi-esp32-server-golang/internal/domain/tts"
+
+	"github.com/spf13/viper"
+)
+
+const fakeType = "chain_test_fake"
+
+// fakeProviders 按配置中的 name 记录测试用的提供者行为
+var (
+	fakeLock      sync.Mutex
+	fakeProviders = map[string]*fakeTTS{}
+)
+
+func init() {
+	tts.Register(fakeType, func(config map[string]interface{}) (tts.BaseTTSProvider, error) {
+		fakeLock.Lock()
+		defer fakeLock.Unlock()
+		name, _ := config["name"].(string)
+		provider, ok := fakeProviders[name]
+		if !ok {
+			return nil, errors.New("unknown fake provider")
+		}
+		return provider, nil
+	}, registry.ConfigSchema{})
+}
+
+// fakeTTS frames 为0时模拟返回空音频, fail 为 true 时返回错误, hang 为 true 时直到取消都不返回音频
+type fakeTTS struct {
+	sync.Mutex
+	frames  int
+	fail    bool
+	hang    bool
+	calls   int
+	formats []Format
+}
+
+func (f *fakeTTS) record(sampleRate int, frameDuration int) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls++
+	f.formats = append(f.formats, Format{SampleRate: sampleRate, FrameDuration: frameDuration})
+}
+
+func (f *fakeTTS) setFail(fail bool) {
+	f.Lock()
+	defer f.Unlock()
+	f.fail = fail
+}
+
+func (f *fakeTTS) callCount() int {
+	f.Lock()
+	defer f.Unlock()
+	return f.calls
+}
+
+func (f *fakeTTS) encode(sampleRate int, frameDuration int) ([][]byte, error) {
+	processer, err := audio.GetAudioProcesser(sampleRate, 1, frameDuration)
+	if err != nil {
+		return nil, err
+	}
+	pcm := make([]int16, sampleRate*frameDuration/1000)
+	var frames [][]byte
+	for i := 0; i < f.frames; i++ {
+		buf := make([]byte, len(pcm)*2)
+		n, err := processer.Encoder(pcm, buf)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, buf[:n])
+	}
+	return frames, nil
+}
+
+func (f *fakeTTS) TextToSpeech(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) ([][]byte, error) {
+	f.record(sampleRate, frameDuration)
+	f.Lock()
+	fail := f.fail
+	f.Unlock()
+	if fail {
+		return nil, errors.New("tts failed")
+	}
+	return f.encode(sampleRate, frameDuration)
+}
+
+func (f *fakeTTS) TextToSpeechStream(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (chan []byte, error) {
+	f.record(sampleRate, frameDuration)
+	f.Lock()
+	fail := f.fail
+	f.Unlock()
+	if fail {
+		return nil, errors.New("tts failed")
+	}
+	frames, err := f.encode(sampleRate, frameDuration)
+	if err != nil {
+		return nil, err
+	}
+	outputChan := make(chan []byte)
+	go func() {
+		if f.hang {
+			<-ctx.Done()
+			return
+		}
+		defer close(outputChan)
+		for _, frame := range frames {
+			select {
+			case <-ctx.Done():
+				return
+			case outputChan <- frame:
+			}
+		}
+	}()
+	return outputChan, nil
+}
+
+func member(name string, extra ...interface{}) map[string]interface{} {
+	m := map[string]interface{}{"provider": fakeType, "config": map[string]interface{}{"name": name}}
+	for i := 0; i+1 < len(extra); i += 2 {
+		m[extra[i].(string)] = extra[i+1]
+	}
+	return m
+}
+
+func newTestChain(t *testing.T, config map[string]interface{}, fakes map[string]*fakeTTS) *ChainTTSProvider {
+	t.Helper()
+	fakeLock.Lock()
+	for name, provider := range fakes {
+		fakeProviders[name] = provider
+	}
+	fakeLock.Unlock()
+
+	// 熔断器按配置共享, 每个用例重新开始计数
+	breakerLock.Lock()
+	breakers = make(map[string]*breaker)
+	breakerLock.Unlock()
+
+	c, err := NewChainTTSProvider(config)
+	if err != nil {
+		t.Fatalf("创建故障转移链失败: %v", err)
+	}
+	return c
+}
+
+func collect(t *testing.T, c *ChainTTSProvider) int {
+	t.Helper()
+	outputChan, err := c.TextToSpeechStream(context.Background(), "你好", 16000, 1, 60)
+	if err != nil {
+		t.Fatalf("TextToSpeechStream: %v", err)
+	}
+	count := 0
+	for range outputChan {
+		count++
+	}
+	return count
+}
+
+func TestChainFailover(t *testing.T) {
+	failing := &fakeTTS{fail: true}
+	empty := &fakeTTS{}
+	hanging := &fakeTTS{frames: 3, hang: true}
+	healthy := &fakeTTS{frames: 3}
+	c := newTestChain(t, map[string]interface{}{
+		"first_frame_timeout": 50,
+		"providers": []interface{}{
+			member("failover_failing"), member("failover_empty"), member("failover_hanging"), member("failover_healthy"),
+		},
+	}, map[string]*fakeTTS{
+		"failover_failing": failing, "failover_empty": empty, "failover_hanging": hanging, "failover_healthy": healthy,
+	})
+
+	if count := collect(t, c); count != 3 {
+		t.Fatalf("frames = %d", count)
+	}
+	for _, f := range []*fakeTTS{failing, empty, hanging, healthy} {
+		if f.callCount() != 1 {
+			t.Fatalf("calls = %d", f.callCount())
+		}
+	}
+
+	frames, err := c.TextToSpeech(context.Background(), "你好", 16000, 1, 60)
+	if err != nil || len(frames) != 3 {
+		t.Fatalf("TextToSpeech = %d, %v", len(frames), err)
+	}
+}
+
+func TestChainAllFailed(t *testing.T) {
+	c := newTestChain(t, map[string]interface{}{
+		"providers": []interface{}{member("all_failed_1"), member("all_failed_2")},
+	}, map[string]*fakeTTS{"all_failed_1": {fail: true}, "all_failed_2": {}})
+
+	if _, err := c.TextToSpeechStream(context.Background(), "你好", 16000, 1, 60); err == nil {
+		t.Fatal("全部失败时应返回错误")
+	}
+	if _, err := c.TextToSpeech(context.Background(), "你好", 16000, 1, 60); err == nil {
+		t.Fatal("全部失败时应返回错误")
+	}
+}
+
+func TestChainBreaker(t *testing.T) {
+	primary := &fakeTTS{frames: 2, fail: true}
+	backup := &fakeTTS{frames: 2}
+	c := newTestChain(t, map[string]interface{}{
+		"failure_threshold": 2,
+		"cooldown":          30,
+		"providers":         []interface{}{member("breaker_primary"), member("breaker_backup")},
+	}, map[string]*fakeTTS{"breaker_primary": primary, "breaker_backup": backup})
+
+	for i := 0; i < 3; i++ {
+		if count := collect(t, c); count != 2 {
+			t.Fatalf("frames = %d", count)
+		}
+	}
+	if primary.callCount() != 2 {
+		t.Fatalf("熔断后不应再请求, calls = %d", primary.callCount())
+	}
+
+	// 冷却结束后由健康探测恢复
+	primary.setFail(false)
+	time.Sleep(50 * time.Millisecond)
+	collect(t, c)
+	deadline := time.Now().Add(time.Second)
+	for c.members[0].breaker.State() != BreakerClosed {
+		if time.Now().After(deadline) {
+			t.Fatalf("健康探测后应恢复, state = %s", c.members[0].breaker.State())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	calls := primary.callCount()
+	collect(t, c)
+	if primary.callCount() != calls+1 {
+		t.Fatal("恢复后应优先使用首个提供者")
+	}
+}
+
+func TestChainTranscode(t *testing.T) {
+	native := &fakeTTS{frames: 10}
+	c := newTestChain(t, map[string]interface{}{
+		"providers": []interface{}{member("transcode_native", "sample_rate", 24000, "frame_duration", 20)},
+	}, map[string]*fakeTTS{"transcode_native": native})
+
+	// 10帧20ms共200ms, 转为60ms帧后为3个整帧加1个补静音的帧
+	if count := collect(t, c); count != 4 {
+		t.Fatalf("frames = %d", count)
+	}
+	frames, err := c.TextToSpeech(context.Background(), "你好", 16000, 1, 60)
+	if err != nil || len(frames) != 4 {
+		t.Fatalf("TextToSpeech = %d, %v", len(frames), err)
+	}
+	for _, format := range native.formats {
+		if format != (Format{SampleRate: 24000, FrameDuration: 20}) {
+			t.Fatalf("提供者应按自身格式合成, format = %+v", format)
+		}
+	}
+
+	decoder, err := audio.GetAudioProcesser(16000, 1, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcm := make([]float32, 16000*audio.MaxOpusFrameDuration/1000)
+	for _, frame := range frames {
+		n, err := decoder.DecoderFloat32(frame, pcm)
+		if err != nil || n != 960 {
+			t.Fatalf("decoded = %d, %v", n, err)
+		}
+	}
+}
+
+func TestNewChainInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+	}{
+		{name: "没有提供者", config: map[string]interface{}{"providers": []interface{}{}}},
+		{name: "提供者创建失败", config: map[string]interface{}{"providers": []interface{}{member("missing")}}},
+		{name: "嵌套故障转移链", config: map[string]interface{}{"providers": []interface{}{"chain"}}},
+		{name: "提供者配置类型错误", config: map[string]interface{}{"providers": []interface{}{1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewChainTTSProvider(tt.config); err == nil {
+				t.Fatal("应返回错误")
+			}
+		})
+	}
+}
+
+func TestChainSpeakerVoices(t *testing.T) {
+	fakeLock.Lock()
+	fakeProviders["default_voice"] = &fakeTTS{frames: 1}
+	fakeProviders["speaker_voice"] = &fakeTTS{frames: 1}
+	fakeLock.Unlock()
+	viper.Set("tts.chains.speaker_chain", map[string]interface{}{
+		"providers": []interface{}{member("default_voice")},
+		"voices":    map[string]interface{}{"小红": map[string]interface{}{"name": "speaker_voice"}},
+	})
+	defer viper.Set("tts.chains.speaker_chain", nil)
+
+	if roles := tts.SpeakerRoles("speaker_chain", nil); len(roles) != 1 || roles[0] != "小红" {
+		t.Fatalf("roles = %v, want [小红]", roles)
+	}
+	config, ok := tts.SpeakerConfig("speaker_chain", nil, "小红")
+	if !ok {
+		t.Fatal("故障转移链的 voices 未生效")
+	}
+	provider, err := tts.GetTTSProvider("speaker_chain", config)
+	if err != nil {
+		t.Fatalf("创建角色的故障转移链失败: %v", err)
+	}
+	if _, err := provider.TextToSpeech(context.Background(), "你好", 16000, 1, 60); err != nil {
+		t.Fatalf("TextToSpeech: %v", err)
+	}
+	if calls := fakeProviders["speaker_voice"].callCount(); calls != 1 {
+		t.Fatalf("角色音色的提供者调用 %d 次, want 1", calls)
+	}
+	if calls := fakeProviders["default_voice"].callCount(); calls != 0 {
+		t.Fatalf("默认音色的提供者调用 %d 次, want 0", calls)
+	}
+}
@@ -10,11 +10,30 @@ import (
 	"sync"
 	"time"
 
+	"xiaozhi-esp32-server-golang/constants"
 	"xiaozhi-esp32-server-golang/internal/data/audio"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+	"xiaozhi-esp32-server-golang/internal/domain/tts"
 	"xiaozhi-esp32-server-golang/internal/util"
 	log "xiaozhi-esp32-server-golang/logger"
 )
 
+func init() {
+	tts.Register(constants.TtsTypeCosyvoice, func(config map[string]interface{}) (tts.BaseTTSProvider, error) {
+		return NewCosyVoiceTTSProvider(config), nil
+	}, registry.ConfigSchema{
+		Description: "CosyVoice 语音合成",
+		Fields: []registry.ConfigField{
+			{Name: "api_url", Type: registry.FieldTypeString, Required: true, Description: "API地址"},
+			{Name: "spk_id", Type: registry.FieldTypeString, Description: "说话人ID"},
+			{Name: "frame_duration", Type: registry.FieldTypeNumber, Description: "帧持续时间（毫秒）"},
+			{Name: "target_sr", Type: registry.FieldTypeNumber, Description: "目标采样率"},
+			{Name: "audio_format", Type: registry.FieldTypeString, Description: "音频格式"},
+			{Name: "instruct_text", Type: registry.FieldTypeString, Description: "指示文本"},
+		},
+	})
+}
+
 // 全局HTTP客户端，实现连接池
 var (
 	httpClient     *http.Client
@@ -193,6 +212,12 @@ func (p *CosyVoiceTTSProvider) TextToSpeech(ctx context.Context, text string, sa
 
 // TextToSpeechStream 流式语音合成实现
 func (p *CosyVoiceTTSProvider) TextToSpeechStream(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (outputChan chan []byte, err error) {
+	outputChan, _, err = p.TextToSpeechStreamWithResult(ctx, text, sampleRate, channels, frameDuration)
+	return outputChan, err
+}
+
+// TextToSpeechStreamWithResult 流式语音合成, 结束后通过 result 报告是否完整合成
+func (p *CosyVoiceTTSProvider) TextToSpeechStreamWithResult(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (outputChan chan []byte, result <-chan error, err error) {
 	// 构建查询参数
 	params := url.Values{}
 	params.Add("tts_text", text)
@@ -210,7 +235,7 @@ func (p *CosyVoiceTTSProvider) TextToSpeechStream(ctx context.Context, text stri
 	// 创建HTTP请求
 	req, err := http.NewRequest("GET", requestURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %v", err)
+		return nil, nil, fmt.Errorf("创建请求失败: %v", err)
 	}
 
 	req.Header.Set("Accept", "application/json")
@@ -220,12 +245,21 @@ func (p *CosyVoiceTTSProvider) TextToSpeechStream(ctx context.Context, text stri
 
 	// 创建输出通道
 	outputChan = make(chan []byte, 100)
+	done := make(chan error, 1)
 	// 启动goroutine处理流式响应
 	go func() {
+		var streamErr error
+		defer func() {
+			if streamErr == nil {
+				streamErr = ctx.Err()
+			}
+			done <- streamErr
+		}()
 		// 发送请求
 		resp, err := client.Do(req)
 		if err != nil {
 			log.Errorf("发送请求失败: %v", err)
+			streamErr = fmt.Errorf("发送请求失败: %v", err)
 			return
 		}
 		defer func() {
@@ -236,6 +270,7 @@ func (p *CosyVoiceTTSProvider) TextToSpeechStream(ctx context.Context, text stri
 		if resp.StatusCode != http.StatusOK {
 			body, _ := io.ReadAll(resp.Body)
 			log.Errorf("API请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(body))
+			streamErr = fmt.Errorf("API请求失败，状态码: %d", resp.StatusCode)
 			return
 		}
 
@@ -249,6 +284,7 @@ func (p *CosyVoiceTTSProvider) TextToSpeechStream(ctx context.Context, text stri
 		// 判断Content-Length是否合理
 		if contentLength == 0 {
 			log.Errorf("API返回空响应，Content-Length为0")
+			streamErr = fmt.Errorf("API返回空响应")
 			return
 		}
 
@@ -256,6 +292,7 @@ func (p *CosyVoiceTTSProvider) TextToSpeechStream(ctx context.Context, text stri
 		// -1表示未知长度（例如分块传输）
 		if contentLength > 0 && contentLength < 100 {
 			log.Errorf("API返回的响应太小无法解析为MP3: %d字节", contentLength)
+			streamErr = fmt.Errorf("API返回的响应太小无法解析为MP3: %d字节", contentLength)
 			return
 		}
 
@@ -266,12 +303,14 @@ func (p *CosyVoiceTTSProvider) TextToSpeechStream(ctx context.Context, text stri
 			if err != nil {
 				log.Errorf("创建MP3解码器失败: %v", err)
 				close(outputChan)
+				streamErr = err
 				return
 			}
 
 			// 启动解码过程
 			if err := mp3Decoder.Run(startTs); err != nil {
 				log.Errorf("MP3解码失败: %v", err)
+				streamErr = fmt.Errorf("MP3解码失败: %v", err)
 				return
 			}
 
@@ -285,8 +324,9 @@ func (p *CosyVoiceTTSProvider) TextToSpeechStream(ctx context.Context, text stri
 			}
 		} else {
 			log.Errorf("当前仅支持MP3格式的流式合成")
+			streamErr = fmt.Errorf("不支持的音频格式: %s", p.AudioFormat)
 		}
 	}()
 
-	return outputChan, nil
+	return outputChan, done, nil
 }
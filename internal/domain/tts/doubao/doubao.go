@@ -15,10 +15,29 @@ import (
 	"sync"
 	"time"
 
+	"xiaozhi-esp32-server-golang/constants"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+	"xiaozhi-esp32-server-golang/internal/domain/tts"
 	"xiaozhi-esp32-server-golang/internal/util"
 	log "xiaozhi-esp32-server-golang/logger"
 )
 
+func init() {
+	tts.Register(constants.TtsTypeDoubao, func(config map[string]interface{}) (tts.BaseTTSProvider, error) {
+		return NewDoubaoTTSProvider(config), nil
+	}, registry.ConfigSchema{
+		Description: "豆包语音合成（HTTP方式）",
+		Fields: []registry.ConfigField{
+			{Name: "appid", Type: registry.FieldTypeString, Required: true, Description: "应用ID"},
+			{Name: "access_token", Type: registry.FieldTypeString, Required: true, Description: "访问令牌"},
+			{Name: "cluster", Type: registry.FieldTypeString, Description: "集群名称"},
+			{Name: "voice", Type: registry.FieldTypeString, Description: "语音模型"},
+			{Name: "api_url", Type: registry.FieldTypeString, Description: "API地址"},
+			{Name: "authorization", Type: registry.FieldTypeString, Description: "授权头"},
+		},
+	})
+}
+
 // 全局HTTP客户端，实现连接池
 var (
 	httpClient     *http.Client
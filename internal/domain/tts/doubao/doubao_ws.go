@@ -15,12 +15,31 @@ import (
 	"sync"
 	"time"
 
+	"xiaozhi-esp32-server-golang/constants"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+	"xiaozhi-esp32-server-golang/internal/domain/tts"
 	"xiaozhi-esp32-server-golang/internal/util"
 	log "xiaozhi-esp32-server-golang/logger"
 
 	"github.com/gorilla/websocket"
 )
 
+func init() {
+	tts.Register(constants.TtsTypeDoubaoWS, func(config map[string]interface{}) (tts.BaseTTSProvider, error) {
+		return NewDoubaoWSProvider(config), nil
+	}, registry.ConfigSchema{
+		Description: "豆包语音合成（WebSocket方式）",
+		Fields: []registry.ConfigField{
+			{Name: "appid", Type: registry.FieldTypeString, Required: true, Description: "应用ID"},
+			{Name: "access_token", Type: registry.FieldTypeString, Required: true, Description: "访问令牌"},
+			{Name: "cluster", Type: registry.FieldTypeString, Description: "集群名称"},
+			{Name: "voice", Type: registry.FieldTypeString, Description: "语音模型"},
+			{Name: "ws_host", Type: registry.FieldTypeString, Description: "WebSocket主机"},
+			{Name: "use_stream", Type: registry.FieldTypeBool, Description: "使用流式传输"},
+		},
+	})
+}
+
 // 枚举消息类型
 var (
 	enumMessageType = map[byte]string{
@@ -301,6 +320,20 @@ func (p *DoubaoWSProvider) TextToSpeech(ctx context.Context, text string, sample
 
 // TextToSpeech 将文本转换为语音，返回音频帧数据和错误
 func (p *DoubaoWSProvider) TextToSpeechStream(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (outputOpusChan chan []byte, err error) {
+	outputOpusChan, _, err = p.TextToSpeechStreamWithProsody(ctx, text, tts.Prosody{}, "", sampleRate, channels, frameDuration)
+	return outputOpusChan, err
+}
+
+// TextToSpeechStreamWithResult 流式合成, 结束后通过 result 报告是否完整合成
+func (p *DoubaoWSProvider) TextToSpeechStreamWithResult(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (outputOpusChan chan []byte, result <-chan error, err error) {
+	return p.TextToSpeechStreamWithProsody(ctx, text, tts.Prosody{}, "", sampleRate, channels, frameDuration)
+}
+
+// TextToSpeechStreamWithProsody 按语音标记调整语速、音调、音量和音色, voice 为空时使用配置的音色
+func (p *DoubaoWSProvider) TextToSpeechStreamWithProsody(ctx context.Context, text string, prosody tts.Prosody, voice string, sampleRate int, channels int, frameDuration int) (outputOpusChan chan []byte, result <-chan error, err error) {
+	if voice == "" {
+		voice = p.Voice
+	}
 	var operation string
 	if p.UseStream {
 		operation = optSubmit // 流式合成
@@ -311,12 +344,12 @@ func (p *DoubaoWSProvider) TextToSpeechStream(ctx context.Context, text string,
 	startTs := time.Now().UnixMilli()
 
 	// 准备请求数据
-	input := p.setupInput(text, p.Voice, operation, sampleRate, channels, frameDuration)
+	input := p.setupInput(text, voice, operation, prosody, sampleRate, channels, frameDuration)
 
 	// 获取或创建WebSocket连接
 	conn, err := p.getWSConnection()
 	if err != nil {
-		return nil, fmt.Errorf("获取WebSocket连接失败: %v", err)
+		return nil, nil, fmt.Errorf("获取WebSocket连接失败: %v", err)
 	}
 
 	// 压缩输入
@@ -338,7 +371,7 @@ func (p *DoubaoWSProvider) TextToSpeechStream(ctx context.Context, text string,
 	if err != nil {
 		// 连接可能已关闭，移除并重试
 		p.removeWSConnection(conn)
-		return nil, fmt.Errorf("发送WebSocket消息失败: %v", err)
+		return nil, nil, fmt.Errorf("发送WebSocket消息失败: %v", err)
 	}
 
 	// 设置读取超时
@@ -347,24 +380,39 @@ func (p *DoubaoWSProvider) TextToSpeechStream(ctx context.Context, text string,
 	pipeReader, pipeWriter := io.Pipe()
 
 	outputOpusChan = make(chan []byte, 1000)
+	// readResult 接收读取响应的结果, done 合并读取和解码的结果
+	readResult := make(chan error, 1)
+	done := make(chan error, 1)
 
 	go func() {
 		mp3Decoder, err := util.CreateAudioDecoder(ctx, pipeReader, outputOpusChan, frameDuration, "mp3")
 		if err != nil {
 			log.Errorf("创建MP3解码器失败: %v", err)
+			pipeReader.CloseWithError(err)
 			close(outputOpusChan)
+			done <- err
 			return
 		}
 		err = mp3Decoder.Run(startTs)
 		if err != nil {
 			log.Errorf("MP3解码器运行失败: %v", err)
-			return
 		}
+		// 解码中途退出时让读取协程的写入返回, 避免阻塞
+		pipeReader.Close()
+		if readErr := <-readResult; readErr != nil {
+			err = readErr
+		}
+		if err == nil {
+			err = ctx.Err()
+		}
+		done <- err
 	}()
 	go func() {
+		var readErr error
 		defer func() {
 			pipeReader.Close()
 			pipeWriter.Close()
+			readResult <- readErr
 		}()
 		// 流式合成
 		chunkCount := 0
@@ -374,7 +422,7 @@ func (p *DoubaoWSProvider) TextToSpeechStream(ctx context.Context, text string,
 			if err != nil {
 				p.removeWSConnection(conn)
 				log.Errorf("读取WebSocket消息失败: %v", err)
-
+				readErr = fmt.Errorf("读取WebSocket消息失败: %v", err)
 				return
 			}
 
@@ -382,6 +430,7 @@ func (p *DoubaoWSProvider) TextToSpeechStream(ctx context.Context, text string,
 			if err != nil {
 				p.removeWSConnection(conn)
 				log.Errorf("解析响应失败: %v", err)
+				readErr = fmt.Errorf("解析响应失败: %v", err)
 				return
 			}
 
@@ -418,7 +467,7 @@ func (p *DoubaoWSProvider) TextToSpeechStream(ctx context.Context, text string,
 		}
 	}()
 
-	return outputOpusChan, nil
+	return outputOpusChan, done, nil
 }
 
 // GetVoiceInfo 获取语音信息
@@ -493,7 +542,7 @@ func (p *DoubaoWSProvider) removeWSConnection(conn *websocket.Conn) {
 }
 
 // 设置请求输入
-func (p *DoubaoWSProvider) setupInput(text, voiceType, opt string, sampleRate int, channels int, frameDuration int) []byte {
+func (p *DoubaoWSProvider) setupInput(text, voiceType, opt string, prosody tts.Prosody, sampleRate int, channels int, frameDuration int) []byte {
 	// 生成请求ID
 	reqID := generateUUID()
 
@@ -515,9 +564,9 @@ func (p *DoubaoWSProvider) setupInput(text, voiceType, opt string, sampleRate in
 	params["audio"]["voice_type"] = voiceType
 	params["audio"]["encoding"] = "mp3"
 	params["audio"]["rate"] = sampleRate
-	params["audio"]["speed_ratio"] = 1.0
-	params["audio"]["volume_ratio"] = 1.0
-	params["audio"]["pitch_ratio"] = 1.0
+	params["audio"]["speed_ratio"] = prosodyRatio(prosody.Rate)
+	params["audio"]["volume_ratio"] = prosodyRatio(prosody.Volume)
+	params["audio"]["pitch_ratio"] = prosodyRatio(prosody.Pitch)
 
 	// 请求信息
 	params["request"] = make(map[string]interface{})
@@ -532,6 +581,14 @@ func (p *DoubaoWSProvider) setupInput(text, voiceType, opt string, sampleRate in
 }
 
 // gzip压缩
+// prosodyRatio 未调整时为 1
+func prosodyRatio(v float64) float64 {
+	if v <= 0 {
+		return 1.0
+	}
+	return v
+}
+
 func gzipCompress(input []byte) []byte {
 	var b bytes.Buffer
 	w := gzip.NewWriter(&b)
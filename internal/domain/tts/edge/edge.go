@@ -4,15 +4,35 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"time"
 
+	"xiaozhi-esp32-server-golang/constants"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+	"xiaozhi-esp32-server-golang/internal/domain/tts"
 	"xiaozhi-esp32-server-golang/internal/util"
 	log "xiaozhi-esp32-server-golang/logger"
 
 	"github.com/difyz9/edge-tts-go/pkg/communicate"
 )
 
+func init() {
+	tts.Register(constants.TtsTypeEdge, func(config map[string]interface{}) (tts.BaseTTSProvider, error) {
+		return NewEdgeTTSProvider(config), nil
+	}, registry.ConfigSchema{
+		Description: "Microsoft Edge 语音合成",
+		Fields: []registry.ConfigField{
+			{Name: "voice", Type: registry.FieldTypeString, Description: "语音模型"},
+			{Name: "rate", Type: registry.FieldTypeString, Description: "语速调整"},
+			{Name: "volume", Type: registry.FieldTypeString, Description: "音量调整"},
+			{Name: "pitch", Type: registry.FieldTypeString, Description: "音调调整"},
+			{Name: "connect_timeout", Type: registry.FieldTypeNumber, Description: "连接超时（秒）"},
+			{Name: "receive_timeout", Type: registry.FieldTypeNumber, Description: "接收超时（秒）"},
+		},
+	})
+}
+
 // EdgeTTSProvider Edge TTS 提供者
 // 支持一次性和流式TTS，输出Opus帧
 // 配置参数：voice, rate, volume, pitch, connectTimeout, receiveTimeout
@@ -119,33 +139,67 @@ func (p *EdgeTTSProvider) TextToSpeech(ctx context.Context, text string, sampleR
 
 // TextToSpeechStream 流式合成，返回Opus帧chan
 func (p *EdgeTTSProvider) TextToSpeechStream(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (chan []byte, error) {
+	outputChan, _, err := p.textToSpeechStream(ctx, text, p.Voice, p.Rate, p.Volume, p.Pitch, frameDuration)
+	return outputChan, err
+}
+
+// TextToSpeechStreamWithResult 流式合成, 结束后通过 result 报告是否完整合成
+func (p *EdgeTTSProvider) TextToSpeechStreamWithResult(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (chan []byte, <-chan error, error) {
+	return p.textToSpeechStream(ctx, text, p.Voice, p.Rate, p.Volume, p.Pitch, frameDuration)
+}
+
+// TextToSpeechStreamWithProsody 按语音标记调整语速、音调、音量和音色, 未调整的项使用配置值
+func (p *EdgeTTSProvider) TextToSpeechStreamWithProsody(ctx context.Context, text string, prosody tts.Prosody, voice string, sampleRate int, channels int, frameDuration int) (chan []byte, <-chan error, error) {
+	if voice == "" {
+		voice = p.Voice
+	}
+	rate, volume, pitch := p.Rate, p.Volume, p.Pitch
+	if prosody.Rate > 0 && prosody.Rate != 1 {
+		rate = fmt.Sprintf("%+d%%", int(math.Round((prosody.Rate-1)*100)))
+	}
+	if prosody.Volume > 0 && prosody.Volume != 1 {
+		volume = fmt.Sprintf("%+d%%", int(math.Round((prosody.Volume-1)*100)))
+	}
+	if prosody.Pitch > 0 && prosody.Pitch != 1 {
+		// edge 的音调以 Hz 为单位, 按约 200Hz 的基频换算
+		pitch = fmt.Sprintf("%+dHz", int(math.Round((prosody.Pitch-1)*200)))
+	}
+	return p.textToSpeechStream(ctx, text, voice, rate, volume, pitch, frameDuration)
+}
+
+func (p *EdgeTTSProvider) textToSpeechStream(ctx context.Context, text, voice, rate, volume, pitch string, frameDuration int) (chan []byte, <-chan error, error) {
 	startTs := time.Now().UnixMilli()
 	comm, err := communicate.NewCommunicate(
 		text,
-		p.Voice,
-		p.Rate,
-		p.Volume,
-		p.Pitch,
+		voice,
+		rate,
+		volume,
+		pitch,
 		"", // proxy
 		p.ConnectTimeout,
 		p.ReceiveTimeout,
 	)
 	if err != nil {
 		log.Errorf("EdgeTTS Communicate创建失败: %v", err)
-		return nil, err
+		return nil, nil, err
 	}
 
 	chunkChan, errChan := comm.Stream(ctx)
 	outputChan := make(chan []byte, 100)
 	pipeReader, pipeWriter := io.Pipe()
+	// streamResult 接收上游合成的结果, result 合并上游和解码的结果
+	streamResult := make(chan error, 1)
+	result := make(chan error, 1)
 	// MP3转Opus解码器
 	go func() {
 		defer func() {
 			pipeWriter.Close()
 			log.Debugf("EdgeTTS流式合成结束, 耗时: %d ms", time.Now().UnixMilli()-startTs)
-			if err := <-errChan; err != nil {
+			err := <-errChan
+			if err != nil {
 				log.Errorf("EdgeTTS流式合成出错: %v", err)
 			}
+			streamResult <- err
 		}()
 		for {
 			select {
@@ -172,12 +226,24 @@ func (p *EdgeTTSProvider) TextToSpeechStream(ctx context.Context, text string, s
 		mp3Decoder, err := util.CreateAudioDecoder(ctx, pipeReader, outputChan, frameDuration, "mp3")
 		if err != nil {
 			log.Errorf("EdgeTTS MP3解码器创建失败: %v", err)
+			pipeReader.CloseWithError(err)
+			close(outputChan)
+			result <- err
 			return
 		}
-		if err := mp3Decoder.Run(startTs); err != nil {
+		if err = mp3Decoder.Run(startTs); err != nil {
 			log.Errorf("EdgeTTS MP3解码失败: %v", err)
 		}
+		// 解码中途退出时让上游的写入返回, 避免阻塞
+		pipeReader.Close()
 		log.Debugf("EdgeTTS MP3解码结束, 耗时: %d ms", time.Now().UnixMilli()-startTs)
+		if streamErr := <-streamResult; streamErr != nil {
+			err = streamErr
+		}
+		if err == nil {
+			err = ctx.Err()
+		}
+		result <- err
 	}()
-	return outputChan, nil
+	return outputChan, result, nil
 }
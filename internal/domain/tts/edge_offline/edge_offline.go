@@ -7,6 +7,10 @@ import (
 	"sync"
 	"time"
 
+	"xiaozhi-esp32-server-golang/constants"
+	"xiaozhi-esp32-server-golang/internal/domain/audio"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+	"xiaozhi-esp32-server-golang/internal/domain/tts"
 	"xiaozhi-esp32-server-golang/internal/util"
 	log "xiaozhi-esp32-server-golang/logger"
 
@@ -14,6 +18,23 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+func init() {
+	tts.Register(constants.TtsTypeEdgeOffline, func(config map[string]interface{}) (tts.BaseTTSProvider, error) {
+		return NewEdgeOfflineTTSProvider(config), nil
+	}, registry.ConfigSchema{
+		Description: "Edge 离线语音合成",
+		Fields: []registry.ConfigField{
+			{Name: "server_url", Type: registry.FieldTypeString, Required: true, Description: "服务器地址"},
+			{Name: "timeout", Type: registry.FieldTypeNumber, Description: "超时时间（秒）"},
+			{Name: "pool_min_size", Type: registry.FieldTypeNumber, Description: "连接池最小大小"},
+			{Name: "pool_max_size", Type: registry.FieldTypeNumber, Description: "连接池最大大小"},
+			{Name: "pool_max_idle", Type: registry.FieldTypeNumber, Description: "连接池最大空闲连接数"},
+			{Name: "pool_idle_timeout", Type: registry.FieldTypeNumber, Description: "空闲超时（秒）"},
+			{Name: "pool_acquire_timeout", Type: registry.FieldTypeNumber, Description: "获取连接超时（秒）"},
+		},
+	})
+}
+
 // WebSocket连接配置
 type WSConnConfig struct {
 	ServerURL        string
@@ -211,6 +232,11 @@ func (p *EdgeOfflineTTSProvider) Stats() map[string]interface{} {
 	return nil
 }
 
+// NativeFormat 离线服务返回 24kHz 音频, 帧长与请求一致
+func (p *EdgeOfflineTTSProvider) NativeFormat() audio.Format {
+	return audio.Format{SampleRate: 24000}
+}
+
 // TextToSpeech 将文本转换为语音，返回音频帧数据
 func (p *EdgeOfflineTTSProvider) TextToSpeech(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) ([][]byte, error) {
 	var frames [][]byte
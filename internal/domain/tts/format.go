@@ -0,0 +1,23 @@
+package tts
+
+import "xiaozhi-esp32-server-golang/internal/domain/audio"
+
+// NativeFormatProvider 输出固定格式 opus 的提供者声明自身的原生格式, 如直接转发上游 opus 帧的 xiaozhi
+// 未实现的提供者按请求的采样率、声道数和帧长输出
+type NativeFormatProvider interface {
+	// NativeFormat 实际输出的格式, 为零值的字段与请求一致
+	NativeFormat() audio.Format
+}
+
+// NativeFormat 返回提供者声明的原生格式, 未声明时返回零值
+func NativeFormat(provider interface{}) audio.Format {
+	if p, ok := provider.(NativeFormatProvider); ok {
+		return p.NativeFormat()
+	}
+	return audio.Format{}
+}
+
+// NativeFormat 被适配的提供者声明的原生格式
+func (a *ContextTTSAdapter) NativeFormat() audio.Format {
+	return NativeFormat(a.Provider)
+}
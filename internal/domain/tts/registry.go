@@ -0,0 +1,25 @@
+package tts
+
+import (
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+)
+
+// Factory TTS提供者工厂函数
+type Factory func(config map[string]interface{}) (BaseTTSProvider, error)
+
+var providers = registry.New[Factory]("TTS")
+
+// Register 注册TTS提供者，由各提供者在 init() 中调用
+func Register(name string, factory Factory, schema registry.ConfigSchema) {
+	providers.Register(name, factory, schema)
+}
+
+// ListProviders 列出已注册的TTS提供者及其配置描述
+func ListProviders() []registry.ProviderInfo {
+	return providers.List()
+}
+
+// ValidateConfig 按提供者的配置描述校验TTS配置
+func ValidateConfig(name string, config map[string]interface{}) error {
+	return providers.Validate(name, config)
+}
@@ -0,0 +1,79 @@
+package tts
+
+import (
+	"sort"
+
+	"xiaozhi-esp32-server-golang/constants"
+)
+
+// SpeakerRoles 返回TTS配置中 voices 配置了音色的角色
+func SpeakerRoles(providerName string, config map[string]interface{}) []string {
+	voices := speakerVoices(providerName, config)
+	roles := make([]string, 0, len(voices))
+	for role := range voices {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// SpeakerConfig 返回角色使用的TTS配置, 角色没有配置音色时返回 false
+// voices 中字符串为音色, 覆盖配置中的 voice; 对象为覆盖的配置项, 用于音色字段不是 voice 的提供者, 如 {"spk_id": "xxx"}
+// 故障转移链的覆盖项放在 overrides 中, 由链覆盖到每个提供者的配置
+func SpeakerConfig(providerName string, config map[string]interface{}, speaker string) (map[string]interface{}, bool) {
+	voice, ok := speakerVoices(providerName, config)[speaker]
+	if !ok || speaker == "" {
+		return nil, false
+	}
+	overrides := make(map[string]interface{})
+	switch v := voice.(type) {
+	case string:
+		overrides["voice"] = v
+	case map[string]interface{}:
+		for k, value := range v {
+			overrides[k] = value
+		}
+	default:
+		return nil, false
+	}
+
+	merged := make(map[string]interface{}, len(config)+len(overrides))
+	for k, v := range config {
+		if k != "voices" {
+			merged[k] = v
+		}
+	}
+	if isChain(providerName) {
+		merged["overrides"] = overrides
+		return merged, true
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged, true
+}
+
+// speakerVoices 角色音色配置, 故障转移链的 voices 也可以配置在 tts.chains 下
+func speakerVoices(providerName string, config map[string]interface{}) map[string]interface{} {
+	if voices, ok := config["voices"].(map[string]interface{}); ok {
+		return voices
+	}
+	if !isChain(providerName) {
+		return nil
+	}
+	chainConfig, _ := GetChainConfig(providerName)
+	voices, _ := chainConfig["voices"].(map[string]interface{})
+	return voices
+}
+
+// isChain providerName 是否按故障转移链创建, 与 GetTTSProvider 的查找顺序一致
+func isChain(providerName string) bool {
+	if providerName == constants.TtsTypeChain {
+		return true
+	}
+	if _, ok := providers.Lookup(providerName); ok {
+		return false
+	}
+	_, ok := GetChainConfig(providerName)
+	return ok
+}
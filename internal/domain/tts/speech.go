@@ -0,0 +1,416 @@
+package tts
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"xiaozhi-esp32-server-golang/constants"
+	"xiaozhi-esp32-server-golang/internal/domain/audio"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+// Prosody 语速、音调、音量, 均为相对提供者默认值的比例, 1 为不调整, 0 视为 1
+type Prosody struct {
+	Rate   float64
+	Pitch  float64
+	Volume float64
+}
+
+func ratio(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	return v
+}
+
+// IsDefault 是否没有任何调整
+func (p Prosody) IsDefault() bool {
+	return ratio(p.Rate) == 1 && ratio(p.Pitch) == 1 && ratio(p.Volume) == 1
+}
+
+// Apply 在当前调整的基础上叠加 other
+func (p Prosody) Apply(other Prosody) Prosody {
+	return Prosody{
+		Rate:   ratio(p.Rate) * ratio(other.Rate),
+		Pitch:  ratio(p.Pitch) * ratio(other.Pitch),
+		Volume: ratio(p.Volume) * ratio(other.Volume),
+	}
+}
+
+// Segment 语音标记中的一段, Break 大于0时为停顿, 此时 Text 为空
+type Segment struct {
+	Text    string
+	Prosody Prosody
+	Voice   string //切换的音色, 为空时使用提供者配置的音色
+	Break   time.Duration
+}
+
+// Speech 与提供者无关的语音标记
+type Speech struct {
+	Segments []Segment
+}
+
+// PlainText 去除标记后的文本
+func (s Speech) PlainText() string {
+	var text strings.Builder
+	for _, segment := range s.Segments {
+		text.WriteString(segment.Text)
+	}
+	return text.String()
+}
+
+// HasMarkup 是否包含停顿、音色切换或语速等调整
+func (s Speech) HasMarkup() bool {
+	for _, segment := range s.Segments {
+		if segment.Break > 0 || segment.Voice != "" || !segment.Prosody.IsDefault() {
+			return true
+		}
+	}
+	return false
+}
+
+// ProsodyTTSProvider 支持按请求调整语速、音调、音量和音色的提供者
+// 未实现的提供者合成语音标记时只使用去除标记后的文本
+// result 与 StreamResultTTSProvider 一致, 在合成结束后报告是否完整合成
+type ProsodyTTSProvider interface {
+	TextToSpeechStreamWithProsody(ctx context.Context, text string, prosody Prosody, voice string, sampleRate int, channels int, frameDuration int) (outputChan chan []byte, result <-chan error, err error)
+}
+
+var (
+	tagPattern  = regexp.MustCompile(`<\s*(/?)\s*([a-zA-Z:]+)([^>]*?)(/?)\s*>`)
+	attrPattern = regexp.MustCompile(`([a-zA-Z_]+)\s*=\s*["']([^"']*)["']`)
+)
+
+// 语速、音调、音量的具名取值, 与 SSML 一致
+var namedRatios = map[string]float64{
+	"x-slow": 0.5, "slow": 0.75, "fast": 1.25, "x-fast": 1.5,
+	"x-low": 0.8, "low": 0.9, "high": 1.1, "x-high": 1.2,
+	"silent": 0.01, "x-soft": 0.5, "soft": 0.75, "loud": 1.25, "x-loud": 1.5,
+	"medium": 1, "default": 1,
+}
+
+// break 的 strength 对应的停顿时长
+var breakStrengths = map[string]time.Duration{
+	"none": 0, "x-weak": 100 * time.Millisecond, "weak": 200 * time.Millisecond,
+	"medium": 400 * time.Millisecond, "strong": 700 * time.Millisecond, "x-strong": time.Second,
+}
+
+// maxBreak 单个停顿的最长时长
+const maxBreak = 5 * time.Second
+
+// ParseSpeech 解析 SSML 子集: <prosody rate pitch volume>、<voice name>、<break time|strength>, 其他标签去除
+// base 为智能体配置的整体调整, 与标签中的调整叠加
+// 标签不需要闭合, 按句子合成时未闭合的标签作用到句末
+func ParseSpeech(text string, base Prosody) Speech {
+	type scope struct {
+		name    string
+		prosody Prosody
+		voice   string
+	}
+	stack := []scope{{prosody: base}}
+	current := func() scope { return stack[len(stack)-1] }
+
+	var speech Speech
+	addText := func(s string) {
+		s = html.UnescapeString(s)
+		if strings.TrimSpace(s) == "" {
+			return
+		}
+		top := current()
+		segments := speech.Segments
+		// 与上一段的调整相同时合并, 减少合成请求
+		if n := len(segments); n > 0 && segments[n-1].Break == 0 && segments[n-1].Prosody == top.prosody && segments[n-1].Voice == top.voice {
+			segments[n-1].Text += s
+			return
+		}
+		speech.Segments = append(segments, Segment{Text: s, Prosody: top.prosody, Voice: top.voice})
+	}
+
+	last := 0
+	for _, m := range tagPattern.FindAllStringSubmatchIndex(text, -1) {
+		addText(text[last:m[0]])
+		last = m[1]
+
+		closing := text[m[2]:m[3]] == "/"
+		name := strings.ToLower(text[m[4]:m[5]])
+		attrs := parseAttrs(text[m[6]:m[7]])
+		selfClosing := text[m[8]:m[9]] == "/"
+
+		if closing {
+			// 弹出最近的同名标签, 没有匹配的开始标签时忽略
+			for i := len(stack) - 1; i > 0; i-- {
+				if stack[i].name == name {
+					stack = stack[:i]
+					break
+				}
+			}
+			continue
+		}
+		switch name {
+		case "break":
+			if d := parseBreak(attrs); d > 0 {
+				speech.Segments = append(speech.Segments, Segment{Break: d})
+			}
+		case "prosody":
+			top := current()
+			top.name = name
+			top.prosody = top.prosody.Apply(Prosody{
+				Rate:   parseRatio(attrs["rate"]),
+				Pitch:  parseRatio(attrs["pitch"]),
+				Volume: parseRatio(attrs["volume"]),
+			})
+			if !selfClosing {
+				stack = append(stack, top)
+			}
+		case "voice":
+			top := current()
+			top.name = name
+			if voice := attrs["name"]; voice != "" {
+				top.voice = voice
+			}
+			if !selfClosing {
+				stack = append(stack, top)
+			}
+		}
+	}
+	addText(text[last:])
+	return speech
+}
+
+func parseAttrs(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range attrPattern.FindAllStringSubmatch(s, -1) {
+		attrs[strings.ToLower(m[1])] = strings.TrimSpace(m[2])
+	}
+	return attrs
+}
+
+// parseRatio 支持 1.2、120%、+20%、-10% 和具名取值, 无法解析时返回 0
+func parseRatio(value string) float64 {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return 0
+	}
+	if named, ok := namedRatios[value]; ok {
+		return named
+	}
+	if strings.HasSuffix(value, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil {
+			return 0
+		}
+		if value[0] == '+' || value[0] == '-' {
+			return clampRatio(1 + percent/100)
+		}
+		return clampRatio(percent / 100)
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return clampRatio(v)
+}
+
+func clampRatio(v float64) float64 {
+	if v < 0.1 {
+		return 0.1
+	}
+	if v > 3 {
+		return 3
+	}
+	return v
+}
+
+func parseBreak(attrs map[string]string) time.Duration {
+	if value := strings.ToLower(attrs["time"]); value != "" {
+		var d time.Duration
+		if strings.HasSuffix(value, "ms") {
+			ms, _ := strconv.ParseFloat(strings.TrimSuffix(value, "ms"), 64)
+			d = time.Duration(ms * float64(time.Millisecond))
+		} else {
+			sec, _ := strconv.ParseFloat(strings.TrimSuffix(value, "s"), 64)
+			d = time.Duration(sec * float64(time.Second))
+		}
+		if d > maxBreak {
+			d = maxBreak
+		}
+		return d
+	}
+	if strength, ok := breakStrengths[strings.ToLower(attrs["strength"])]; ok {
+		return strength
+	}
+	return breakStrengths["medium"]
+}
+
+// ProsodyFromConfig 读取TTS配置中与提供者无关的 prosody 配置, 如 {"rate": 1.1, "pitch": "+5%"}
+func ProsodyFromConfig(config map[string]interface{}) Prosody {
+	m, _ := config["prosody"].(map[string]interface{})
+	value := func(key string) float64 {
+		switch v := m[key].(type) {
+		case float64:
+			return clampRatio(v)
+		case int:
+			return clampRatio(float64(v))
+		case string:
+			return parseRatio(v)
+		}
+		return 0
+	}
+	return Prosody{Rate: value("rate"), Pitch: value("pitch"), Volume: value("volume")}
+}
+
+// speech 解析文本中的语音标记, 没有标记且未配置 prosody 时返回 false
+// 故障转移链不解析, 由实际合成的提供者解析
+func (a *ContextTTSAdapter) speech(text string) (Speech, bool) {
+	if a.Name == constants.TtsTypeChain {
+		return Speech{}, false
+	}
+	base := ProsodyFromConfig(a.Config)
+	if !strings.Contains(text, "<") && base.IsDefault() {
+		return Speech{}, false
+	}
+	return ParseSpeech(text, base), true
+}
+
+// textToSpeech 合成可能带语音标记的文本, complete 为 false 时有语音片段合成失败, 结果不应缓存
+func (a *ContextTTSAdapter) textToSpeech(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (frames [][]byte, complete bool, err error) {
+	speech, ok := a.speech(text)
+	if !ok {
+		frames, err = a.Provider.TextToSpeech(ctx, text, sampleRate, channels, frameDuration)
+		return frames, err == nil, err
+	}
+	if !a.needSegments(speech) {
+		frames, err = a.Provider.TextToSpeech(ctx, speech.PlainText(), sampleRate, channels, frameDuration)
+		return frames, err == nil, err
+	}
+	outputChan, result, err := a.speak(ctx, speech, sampleRate, channels, frameDuration)
+	if err != nil {
+		return nil, false, err
+	}
+	for frame := range outputChan {
+		frames = append(frames, frame)
+	}
+	if ctx.Err() != nil {
+		return nil, false, ctx.Err()
+	}
+	return frames, <-result == nil, nil
+}
+
+// textToSpeechStream 流式合成可能带语音标记的文本, 无法得知是否完整合成时 result 为 nil
+func (a *ContextTTSAdapter) textToSpeechStream(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (chan []byte, <-chan error, error) {
+	speech, ok := a.speech(text)
+	if !ok {
+		return a.providerStream(ctx, text, sampleRate, channels, frameDuration)
+	}
+	if !a.needSegments(speech) {
+		return a.providerStream(ctx, speech.PlainText(), sampleRate, channels, frameDuration)
+	}
+	return a.speak(ctx, speech, sampleRate, channels, frameDuration)
+}
+
+// providerStream 流式合成一段文本, 提供者不报告结果时 result 为 nil
+func (a *ContextTTSAdapter) providerStream(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (chan []byte, <-chan error, error) {
+	if provider, ok := a.Provider.(StreamResultTTSProvider); ok {
+		return provider.TextToSpeechStreamWithResult(ctx, text, sampleRate, channels, frameDuration)
+	}
+	outputChan, err := a.Provider.TextToSpeechStream(ctx, text, sampleRate, channels, frameDuration)
+	return outputChan, nil, err
+}
+
+// needSegments 是否需要分段合成: 有停顿, 或提供者支持调整且有调整
+func (a *ContextTTSAdapter) needSegments(speech Speech) bool {
+	_, supported := a.Provider.(ProsodyTTSProvider)
+	for _, segment := range speech.Segments {
+		if segment.Break > 0 {
+			return true
+		}
+		if supported && (segment.Voice != "" || !segment.Prosody.IsDefault()) {
+			return true
+		}
+	}
+	return false
+}
+
+// speak 按段依次合成, 停顿输出静音帧
+// 片段合成失败时跳过继续合成, 结束后 result 报告是否每段都完整合成
+func (a *ContextTTSAdapter) speak(ctx context.Context, speech Speech, sampleRate int, channels int, frameDuration int) (chan []byte, <-chan error, error) {
+	silence, err := silenceFrame(sampleRate, channels, frameDuration)
+	if err != nil {
+		return nil, nil, err
+	}
+	outputChan := make(chan []byte, 100)
+	result := make(chan error, 1)
+	go func() {
+		var failed error
+		defer func() {
+			close(outputChan)
+			if ctx.Err() != nil {
+				failed = ctx.Err()
+			}
+			result <- failed
+		}()
+		send := func(frame []byte) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case outputChan <- frame:
+				return true
+			}
+		}
+		for _, segment := range speech.Segments {
+			if segment.Break > 0 {
+				for i := 0; i < int(segment.Break/(time.Duration(frameDuration)*time.Millisecond)); i++ {
+					if !send(silence) {
+						return
+					}
+				}
+				continue
+			}
+			segmentChan, segmentResult, err := a.segmentStream(ctx, segment, sampleRate, channels, frameDuration)
+			if err != nil {
+				log.Errorf("合成语音片段失败: %s, %v", segment.Text, err)
+				failed = err
+				continue
+			}
+			for frame := range segmentChan {
+				if !send(frame) {
+					return
+				}
+			}
+			if segmentResult == nil {
+				failed = fmt.Errorf("语音片段未报告合成结果: %s", segment.Text)
+			} else if err := <-segmentResult; err != nil {
+				log.Errorf("合成语音片段失败: %s, %v", segment.Text, err)
+				failed = err
+			}
+		}
+	}()
+	return outputChan, result, nil
+}
+
+func (a *ContextTTSAdapter) segmentStream(ctx context.Context, segment Segment, sampleRate int, channels int, frameDuration int) (chan []byte, <-chan error, error) {
+	if provider, ok := a.Provider.(ProsodyTTSProvider); ok && (segment.Voice != "" || !segment.Prosody.IsDefault()) {
+		return provider.TextToSpeechStreamWithProsody(ctx, segment.Text, segment.Prosody, segment.Voice, sampleRate, channels, frameDuration)
+	}
+	return a.providerStream(ctx, segment.Text, sampleRate, channels, frameDuration)
+}
+
+// silenceFrame 编码一帧静音
+func silenceFrame(sampleRate int, channels int, frameDuration int) ([]byte, error) {
+	processer, err := audio.GetAudioProcesser(sampleRate, channels, frameDuration)
+	if err != nil {
+		return nil, fmt.Errorf("创建编码器失败: %v", err)
+	}
+	pcm := make([]int16, sampleRate*channels*frameDuration/1000)
+	buf := make([]byte, len(pcm)*2)
+	n, err := processer.Encoder(pcm, buf)
+	if err != nil {
+		return nil, fmt.Errorf("编码静音帧失败: %v", err)
+	}
+	return buf[:n], nil
+}
@@ -0,0 +1,73 @@
+package tts
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSpeech(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		base Prosody
+		want []Segment
+	}{
+		{
+			name: "纯文本",
+			text: "你好，世界",
+			want: []Segment{{Text: "你好，世界"}},
+		},
+		{
+			name: "语速和停顿",
+			text: `<prosody rate="+20%">快一点</prosody><break time="500ms"/>正常`,
+			want: []Segment{
+				{Text: "快一点", Prosody: Prosody{Rate: 1.2, Pitch: 1, Volume: 1}},
+				{Break: 500 * time.Millisecond},
+				{Text: "正常"},
+			},
+		},
+		{
+			name: "嵌套叠加整体调整",
+			text: `<voice name="zh-CN-YunxiNeural"><prosody pitch="x-high">高</prosody>低</voice>`,
+			base: Prosody{Rate: 0.5},
+			want: []Segment{
+				{Text: "高", Prosody: Prosody{Rate: 0.5, Pitch: 1.2, Volume: 1}, Voice: "zh-CN-YunxiNeural"},
+				{Text: "低", Prosody: Prosody{Rate: 0.5}, Voice: "zh-CN-YunxiNeural"},
+			},
+		},
+		{
+			name: "未闭合标签作用到句末, 未知标签去除",
+			text: `<speak><prosody volume="150%">大声<emphasis>说</emphasis>`,
+			want: []Segment{{Text: "大声说", Prosody: Prosody{Rate: 1, Pitch: 1, Volume: 1.5}}},
+		},
+		{
+			name: "停顿上限和强度",
+			text: `<break time="10s"/>好<break strength="weak"/>`,
+			want: []Segment{{Break: maxBreak}, {Text: "好"}, {Break: 200 * time.Millisecond}},
+		},
+		{
+			name: "实体转义",
+			text: `a &lt; b`,
+			want: []Segment{{Text: "a < b"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseSpeech(tt.text, tt.base)
+			if !reflect.DeepEqual(got.Segments, tt.want) {
+				t.Fatalf("ParseSpeech(%q) = %+v, want %+v", tt.text, got.Segments, tt.want)
+			}
+		})
+	}
+}
+
+func TestProsodyFromConfig(t *testing.T) {
+	p := ProsodyFromConfig(map[string]interface{}{"prosody": map[string]interface{}{"rate": 1.1, "pitch": "-10%", "volume": "loud"}})
+	if p != (Prosody{Rate: 1.1, Pitch: 0.9, Volume: 1.25}) {
+		t.Fatalf("p = %+v", p)
+	}
+	if !ProsodyFromConfig(map[string]interface{}{}).IsDefault() {
+		t.Fatal("未配置时不应调整")
+	}
+}
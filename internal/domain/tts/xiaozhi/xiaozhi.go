@@ -8,11 +8,40 @@ import (
 	"sync"
 	"time"
 
+	"xiaozhi-esp32-server-golang/constants"
+	"xiaozhi-esp32-server-golang/internal/domain/audio"
+	config_types "xiaozhi-esp32-server-golang/internal/domain/config/types"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+	"xiaozhi-esp32-server-golang/internal/domain/tts"
 	log "xiaozhi-esp32-server-golang/logger"
 
 	"github.com/gorilla/websocket"
 )
 
+func init() {
+	tts.Register(constants.TtsTypeXiaozhi, func(config map[string]interface{}) (tts.BaseTTSProvider, error) {
+		return NewXiaozhiProvider(config), nil
+	}, registry.ConfigSchema{
+		Description: "小智服务端语音合成",
+		Fields: []registry.ConfigField{
+			{Name: "server_addr", Type: registry.FieldTypeString, Required: true, Description: "服务器地址"},
+			{Name: "device_id", Type: registry.FieldTypeString, Description: "设备ID"},
+			{Name: "client_id", Type: registry.FieldTypeString, Description: "客户端ID"},
+			{Name: "token", Type: registry.FieldTypeString, Description: "访问令牌"},
+			{Name: "pool_size", Type: registry.FieldTypeNumber, Description: "连接池大小"},
+			{Name: "idle_timeout", Type: registry.FieldTypeNumber, Description: "空闲超时（秒）"},
+			{Name: "output_sample_rate", Type: registry.FieldTypeNumber, Default: DefaultOutputSampleRate, Description: "服务端下发音频的采样率"},
+			{Name: "output_frame_duration", Type: registry.FieldTypeNumber, Default: DefaultOutputFrameDuration, Description: "服务端下发音频的帧长（毫秒），与设备一致时直接转发不转码"},
+		},
+	})
+}
+
+// 小智服务端下发的 opus 音频格式, 采样率与请求的格式无关, 帧长与 hello 中请求的 20ms 一致
+const (
+	DefaultOutputSampleRate    = 24000
+	DefaultOutputFrameDuration = 20
+)
+
 // WSConnWrapper WebSocket连接包装器，带有最后活跃时间
 // 与 doubao_ws.go 保持一致
 
@@ -52,6 +81,8 @@ type XiaozhiProvider struct {
 	DeviceID    string
 	AudioFormat map[string]interface{}
 	Header      http.Header
+	// OutputFormat 服务端下发音频的格式
+	OutputFormat audio.Format
 }
 
 var (
@@ -141,7 +172,7 @@ func NewXiaozhiProvider(config map[string]interface{}) *XiaozhiProvider {
 	}
 
 	// 可选配置连接池大小
-	if poolSize, ok := config["pool_size"].(int); ok && poolSize > 0 {
+	if poolSize := config_types.ConfigInt(config["pool_size"]); poolSize > 0 {
 		wsClientLock.Lock()
 		maxPoolSize = poolSize
 		wsClientLock.Unlock()
@@ -149,7 +180,7 @@ func NewXiaozhiProvider(config map[string]interface{}) *XiaozhiProvider {
 	}
 
 	// 设置连接超时时间（秒）
-	if idleTime, ok := config["idle_timeout"].(int); ok && idleTime > 0 {
+	if idleTime := config_types.ConfigInt(config["idle_timeout"]); idleTime > 0 {
 		wsClientLock.Lock()
 		maxIdleTime = time.Duration(idleTime) * time.Second
 		wsClientLock.Unlock()
@@ -163,14 +194,29 @@ func NewXiaozhiProvider(config map[string]interface{}) *XiaozhiProvider {
 	header.Set("Protocol-Version", "1")
 	header.Set("Client-Id", clientID)
 
+	outputFormat := audio.Format{SampleRate: DefaultOutputSampleRate, Channels: 1, FrameDuration: DefaultOutputFrameDuration}
+	// 管理后台和 json 配置中的数字为 float64
+	if v := config_types.ConfigInt(config["output_sample_rate"]); v > 0 {
+		outputFormat.SampleRate = v
+	}
+	if v := config_types.ConfigInt(config["output_frame_duration"]); v > 0 {
+		outputFormat.FrameDuration = v
+	}
+
 	return &XiaozhiProvider{
-		ServerAddr:  serverAddr,
-		DeviceID:    deviceID,
-		AudioFormat: format,
-		Header:      header,
+		ServerAddr:   serverAddr,
+		DeviceID:     deviceID,
+		AudioFormat:  format,
+		Header:       header,
+		OutputFormat: outputFormat,
 	}
 }
 
+// NativeFormat 直接转发服务端的 opus 帧, 与设备格式不一致时由调用方转码
+func (p *XiaozhiProvider) NativeFormat() audio.Format {
+	return p.OutputFormat
+}
+
 // getWSConnection 获取或复用全局WebSocket连接
 func (p *XiaozhiProvider) getWSConnection() (*websocket.Conn, error) {
 	wsClientLock.Lock()
@@ -545,15 +591,28 @@ func (p *XiaozhiProvider) handleTTSConnection(ctx context.Context, text string,
 
 // TextToSpeechStream 实现流式TTS，返回opus音频帧chan
 func (p *XiaozhiProvider) TextToSpeechStream(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (chan []byte, error) {
+	outputChan, _, err := p.TextToSpeechStreamWithResult(ctx, text, sampleRate, channels, frameDuration)
+	return outputChan, err
+}
+
+// TextToSpeechStreamWithResult 流式TTS, 结束后通过 result 报告是否完整合成
+func (p *XiaozhiProvider) TextToSpeechStreamWithResult(ctx context.Context, text string, sampleRate int, channels int, frameDuration int) (chan []byte, <-chan error, error) {
 	outputChan := make(chan []byte, 1000)
+	result := make(chan error, 1)
 
 	// 尝试处理TTS连接，支持重试
 	go func() {
-		defer close(outputChan)
+		var lastError error
+		defer func() {
+			close(outputChan)
+			if lastError == nil {
+				lastError = ctx.Err()
+			}
+			result <- lastError
+		}()
 
 		retryCount := 0
 		maxRetries := 2
-		var lastError error
 
 		// 最多尝试maxRetries次
 		for retryCount <= maxRetries {
@@ -564,6 +623,7 @@ func (p *XiaozhiProvider) TextToSpeechStream(ctx context.Context, text string, s
 				select {
 				case <-ctx.Done():
 					log.Debugf("上下文已取消，停止重试")
+					lastError = ctx.Err()
 					return
 				default:
 					// 继续重试
@@ -575,6 +635,7 @@ func (p *XiaozhiProvider) TextToSpeechStream(ctx context.Context, text string, s
 
 			if err == nil {
 				// 连接处理成功，无需重试
+				lastError = nil
 				return
 			}
 
@@ -589,7 +650,7 @@ func (p *XiaozhiProvider) TextToSpeechStream(ctx context.Context, text string, s
 		}
 	}()
 
-	return outputChan, nil
+	return outputChan, result, nil
 }
 
 // GetVoiceInfo 获取TTS配置信息
@@ -1,33 +1,44 @@
 package vad
 
 import (
-	"errors"
-	"xiaozhi-esp32-server-golang/constants"
+	"fmt"
+	"sync"
+
 	"xiaozhi-esp32-server-golang/internal/domain/vad/inter"
-	"xiaozhi-esp32-server-golang/internal/domain/vad/silero_vad"
-	"xiaozhi-esp32-server-golang/internal/domain/vad/webrtc_vad"
 )
 
+// acquired 记录VAD实例由哪个提供者分配，释放时归还给对应提供者
+var acquired sync.Map
+
 func AcquireVAD(provider string, config map[string]interface{}) (inter.VAD, error) {
-	switch provider {
-	case constants.VadTypeSileroVad:
-		return silero_vad.AcquireVAD(config)
-	case constants.VadTypeWebRTCVad:
-		return webrtc_vad.AcquireVAD(config)
-	default:
-		return nil, errors.New("invalid vad provider")
+	factory, ok := providers.Lookup(provider)
+	if !ok {
+		return nil, fmt.Errorf("invalid vad provider: %s", provider)
+	}
+	if err := providers.Validate(provider, config); err != nil {
+		return nil, err
 	}
+	vad, err := factory.Acquire(config)
+	if err != nil {
+		return nil, err
+	}
+	acquired.Store(vad, provider)
+	return vad, nil
 }
 
+// ReleaseVAD 归还 AcquireVAD 分配的实例, 未记录的实例(已释放或不是由 AcquireVAD 分配)不做处理
 func ReleaseVAD(vad inter.VAD) error {
-	//根据vad的类型，调用对应的ReleaseVAD方法
-	switch vad.(type) {
-	case *webrtc_vad.WebRTCVAD:
-		return webrtc_vad.ReleaseVAD(vad)
-	case *silero_vad.SileroVAD:
-		return silero_vad.ReleaseVAD(vad)
-	default:
-		return errors.New("invalid vad type")
+	if vad == nil {
+		return nil
+	}
+	//根据分配时记录的提供者，调用对应的Release方法
+	provider, ok := acquired.LoadAndDelete(vad)
+	if !ok {
+		return nil
+	}
+	factory, ok := providers.Lookup(provider.(string))
+	if !ok || factory.Release == nil {
+		return nil
 	}
-	return nil
+	return factory.Release(vad)
 }
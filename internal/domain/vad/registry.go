@@ -0,0 +1,46 @@
+package vad
+
+import (
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+	"xiaozhi-esp32-server-golang/internal/domain/vad/inter"
+)
+
+// Factory VAD提供者，VAD实例一般来自资源池，因此同时提供获取与归还方法
+type Factory struct {
+	Acquire func(config map[string]interface{}) (inter.VAD, error)
+	Release func(vad inter.VAD) error
+	// Stats 资源池统计信息, 资源池未初始化时返回 nil, 可为空
+	Stats func() map[string]interface{}
+}
+
+var providers = registry.New[Factory]("VAD")
+
+// Register 注册VAD提供者，由各提供者在 init() 中调用
+func Register(name string, factory Factory, schema registry.ConfigSchema) {
+	providers.Register(name, factory, schema)
+}
+
+// ListProviders 列出已注册的VAD提供者及其配置描述
+func ListProviders() []registry.ProviderInfo {
+	return providers.List()
+}
+
+// ValidateConfig 按提供者的配置描述校验VAD配置
+func ValidateConfig(name string, config map[string]interface{}) error {
+	return providers.Validate(name, config)
+}
+
+// PoolStats 获取各VAD提供者的资源池统计信息, key为提供者名称
+func PoolStats() map[string]map[string]interface{} {
+	result := make(map[string]map[string]interface{})
+	for _, name := range providers.Names() {
+		factory, ok := providers.Lookup(name)
+		if !ok || factory.Stats == nil {
+			continue
+		}
+		if stats := factory.Stats(); stats != nil {
+			result[name] = stats
+		}
+	}
+	return result
+}
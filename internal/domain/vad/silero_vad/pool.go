@@ -161,6 +161,18 @@ func (p *VADResourcePool) GetAvailableCount() int {
 	return len(p.availableVADs)
 }
 
+// Stats 获取资源池统计信息, 字段与 util.ResourcePool.Stats 保持一致
+func (p *VADResourcePool) Stats() map[string]interface{} {
+	inUseCount := p.GetActiveCount()
+	availableCount := p.GetAvailableCount()
+	return map[string]interface{}{
+		"total_resources":     inUseCount + availableCount,
+		"available_resources": availableCount,
+		"in_use_resources":    inUseCount,
+		"max_size":            p.maxSize,
+	}
+}
+
 // Resize 调整资源池大小
 func (p *VADResourcePool) Resize(newSize int) error {
 	if newSize <= 0 {
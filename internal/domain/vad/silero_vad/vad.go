@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"xiaozhi-esp32-server-golang/constants"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+	"xiaozhi-esp32-server-golang/internal/domain/vad"
 	log "xiaozhi-esp32-server-golang/logger"
 
 	. "xiaozhi-esp32-server-golang/internal/domain/vad/inter"
@@ -11,6 +14,25 @@ import (
 	"github.com/streamer45/silero-vad-go/speech"
 )
 
+func init() {
+	vad.Register(constants.VadTypeSileroVad, vad.Factory{
+		Acquire: AcquireVAD,
+		Release: ReleaseVAD,
+		Stats:   PoolStats,
+	}, registry.ConfigSchema{
+		Description: "Silero VAD（基于ONNX模型）",
+		Fields: []registry.ConfigField{
+			{Name: "model_path", Type: registry.FieldTypeString, Description: "模型文件路径"},
+			{Name: "threshold", Type: registry.FieldTypeNumber, Default: 0.5, Description: "检测阈值"},
+			{Name: "min_silence_duration_ms", Type: registry.FieldTypeNumber, Default: 100, Description: "最小静默时间（毫秒）"},
+			{Name: "sample_rate", Type: registry.FieldTypeNumber, Default: 16000, Description: "采样率"},
+			{Name: "channels", Type: registry.FieldTypeNumber, Default: 1, Description: "声道数"},
+			{Name: "pool_size", Type: registry.FieldTypeNumber, Default: 10, Description: "连接池大小"},
+			{Name: "acquire_timeout_ms", Type: registry.FieldTypeNumber, Default: 3000, Description: "获取连接超时时间（毫秒）"},
+		},
+	})
+}
+
 // VAD默认配置
 var defaultVADConfig = map[string]interface{}{
 	"threshold":               0.5,
@@ -275,6 +297,14 @@ func ReleaseVAD(vad VAD) error {
 	return nil
 }
 
+// PoolStats 获取资源池统计信息, 资源池未初始化时返回 nil
+func PoolStats() map[string]interface{} {
+	if globalVADResourcePool == nil || !globalVADResourcePool.initialized {
+		return nil
+	}
+	return globalVADResourcePool.Stats()
+}
+
 // Reset 重置VAD检测器状态
 func (s *SileroVAD) Reset() error {
 	s.mu.Lock()
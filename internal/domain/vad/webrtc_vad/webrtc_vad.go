@@ -6,11 +6,31 @@ import (
 	"sync"
 	"time"
 
+	"xiaozhi-esp32-server-golang/constants"
+	"xiaozhi-esp32-server-golang/internal/domain/registry"
+	"xiaozhi-esp32-server-golang/internal/domain/vad"
 	"xiaozhi-esp32-server-golang/internal/domain/vad/inter"
 
 	"github.com/hackers365/go-webrtcvad"
 )
 
+func init() {
+	vad.Register(constants.VadTypeWebRTCVad, vad.Factory{
+		Acquire: AcquireVAD,
+		Release: ReleaseVAD,
+		Stats:   PoolStats,
+	}, registry.ConfigSchema{
+		Description: "WebRTC VAD",
+		Fields: []registry.ConfigField{
+			{Name: "pool_min_size", Type: registry.FieldTypeNumber, Default: 5, Description: "连接池最小大小"},
+			{Name: "pool_max_size", Type: registry.FieldTypeNumber, Default: 1000, Description: "连接池最大大小"},
+			{Name: "pool_max_idle", Type: registry.FieldTypeNumber, Default: 100, Description: "连接池最大空闲连接数"},
+			{Name: "vad_sample_rate", Type: registry.FieldTypeNumber, Default: DefaultSampleRate, Description: "VAD采样率"},
+			{Name: "vad_mode", Type: registry.FieldTypeNumber, Default: DefaultMode, Description: "VAD模式（0-3，越高越敏感）"},
+		},
+	})
+}
+
 const (
 	// DefaultSampleRate WebRTC VAD 支持的采样率 (8000, 16000, 32000, 48000)
 	DefaultSampleRate = 16000
@@ -60,6 +80,14 @@ func ReleaseVAD(vad inter.VAD) error {
 	return nil
 }
 
+// PoolStats 获取资源池统计信息, 资源池未创建时返回 nil
+func PoolStats() map[string]interface{} {
+	if vadPool == nil {
+		return nil
+	}
+	return vadPool.Stats()
+}
+
 // NewWebRTCVAD 创建新的 WebRTC VAD 实例
 func NewWebRTCVAD() inter.VAD {
 	return &WebRTCVAD{
@@ -224,11 +224,12 @@ func (d *AudioDecoder) RunWavDecoder(startTs int64, isRaw bool) error {
 	var enc *opus.Encoder
 	var err error
 	if d.TargetAudioFormat == "opus" {
-		enc, err = opus.NewEncoder(int(sampleRate), outputChannels, opus.AppAudio)
+		enc, err = GetOpusEncoder(int(sampleRate), outputChannels)
 		if err != nil {
 			return fmt.Errorf("创建Opus编码器失败: %v", err)
 		}
 		d.enc = enc
+		defer PutOpusEncoder(int(sampleRate), outputChannels, enc)
 	}
 
 	//opus相关配置及缓冲区
@@ -352,11 +353,12 @@ func (d *AudioDecoder) RunMp3Decoder(startTs int64) error {
 	// 根据目标格式决定是否创建Opus编码器
 	var enc *opus.Encoder
 	if d.TargetAudioFormat == "opus" {
-		enc, err = opus.NewEncoder(opusSampleRate, outputChannels, opus.AppAudio)
+		enc, err = GetOpusEncoder(opusSampleRate, outputChannels)
 		if err != nil {
 			return fmt.Errorf("创建Opus编码器失败: %v", err)
 		}
 		d.enc = enc
+		defer PutOpusEncoder(opusSampleRate, outputChannels, enc)
 	}
 
 	//opus相关配置及缓冲区 创建缓冲区用于接收音频采样
@@ -0,0 +1,50 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+)
+
+// 以下基准测试对比 MP3 输出的TTS提供者每路流的CPU开销, 一次操作为把一段约3.5秒的 MP3 解码、重采样并编码为 opus
+// 走的是提供者实际使用的 AudioDecoder, RunParallel 模拟多路并发, 可用 -cpu 调整并发度, 需要链接真实的 libopus:
+// go test -run '^$' -bench Mp3ToOpus -benchmem -cpu 1,4,8 ./internal/util/
+
+const benchmarkMp3File = "../../test/websocket_client/test.mp3"
+
+// BenchmarkMp3ToOpusNewEncoder 优化前: 每句TTS新建编码器
+// 每次操作前清空编码器池, 其他协程刚归还的编码器仍可能被取到, 结果略微偏向优化前
+func BenchmarkMp3ToOpusNewEncoder(b *testing.B) {
+	benchmarkMp3ToOpus(b, func() {
+		opusEncoderPools.Delete(opusEncoderKey{sampleRate: 16000, channels: 1})
+	})
+}
+
+// BenchmarkMp3ToOpusPooledEncoder 优化后: 从编码器池获取
+func BenchmarkMp3ToOpusPooledEncoder(b *testing.B) {
+	benchmarkMp3ToOpus(b, func() {})
+}
+
+func benchmarkMp3ToOpus(b *testing.B, beforeStream func()) {
+	data, err := os.ReadFile(benchmarkMp3File)
+	if err != nil {
+		b.Skipf("读取测试音频失败: %v", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			beforeStream()
+			output := make(chan []byte, 1024)
+			decoder, _ := CreateAudioDecoderWithSampleRate(context.Background(), io.NopCloser(bytes.NewReader(data)), output, 60, "mp3", 16000)
+			if err := decoder.Run(0); err != nil {
+				b.Error(err)
+				return
+			}
+			for range output {
+			}
+		}
+	})
+}
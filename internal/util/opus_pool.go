@@ -0,0 +1,44 @@
+package util
+
+import (
+	"sync"
+
+	"gopkg.in/hraban/opus.v2"
+)
+
+type opusEncoderKey struct {
+	sampleRate int
+	channels   int
+}
+
+// opusEncoderPools 按采样率和声道数复用 opus 编码器, 避免每句TTS都创建编码器
+var opusEncoderPools sync.Map
+
+func opusEncoderPool(sampleRate int, channels int) *sync.Pool {
+	key := opusEncoderKey{sampleRate: sampleRate, channels: channels}
+	if pool, ok := opusEncoderPools.Load(key); ok {
+		return pool.(*sync.Pool)
+	}
+	pool, _ := opusEncoderPools.LoadOrStore(key, &sync.Pool{})
+	return pool.(*sync.Pool)
+}
+
+// GetOpusEncoder 从池中获取默认参数的 opus 编码器, 用完后调用 PutOpusEncoder 归还
+// 取出的编码器不要修改比特率等参数, 需要自定义参数时直接使用 opus.NewEncoder
+func GetOpusEncoder(sampleRate int, channels int) (*opus.Encoder, error) {
+	if enc, ok := opusEncoderPool(sampleRate, channels).Get().(*opus.Encoder); ok {
+		return enc, nil
+	}
+	return opus.NewEncoder(sampleRate, channels, opus.AppAudio)
+}
+
+// PutOpusEncoder 重置编码器状态后归还, 避免上一路音频的状态影响下一路
+func PutOpusEncoder(sampleRate int, channels int, enc *opus.Encoder) {
+	if enc == nil {
+		return
+	}
+	if err := enc.Reset(); err != nil {
+		return
+	}
+	opusEncoderPool(sampleRate, channels).Put(enc)
+}
@@ -61,6 +61,28 @@ func Int16SliceToBytes(samples []int16) []byte {
 	return buf.Bytes()
 }
 
+// PcmToWav 将单声道float32 PCM转换为16bit wav
+func PcmToWav(pcmData []float32, sampleRate int) []byte {
+	pcmBytes := Int16SliceToBytes(Float32SliceToInt16Slice(pcmData))
+
+	buf := bytes.NewBuffer(make([]byte, 0, 44+len(pcmBytes)))
+	buf.WriteString("RIFF")
+	binary.Write(buf, binary.LittleEndian, uint32(36+len(pcmBytes)))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	binary.Write(buf, binary.LittleEndian, uint32(16))           // fmt 块大小
+	binary.Write(buf, binary.LittleEndian, uint16(1))            // PCM
+	binary.Write(buf, binary.LittleEndian, uint16(1))            // 声道数
+	binary.Write(buf, binary.LittleEndian, uint32(sampleRate))   // 采样率
+	binary.Write(buf, binary.LittleEndian, uint32(sampleRate*2)) // 字节率
+	binary.Write(buf, binary.LittleEndian, uint16(2))            // 块对齐
+	binary.Write(buf, binary.LittleEndian, uint16(16))           // 位深
+	buf.WriteString("data")
+	binary.Write(buf, binary.LittleEndian, uint32(len(pcmBytes)))
+	buf.Write(pcmBytes)
+	return buf.Bytes()
+}
+
 func ResampleLinearFloat32(input []float32, inRate, outRate int) []float32 {
 	ratio := float64(outRate) / float64(inRate)
 	outLen := int(float64(len(input)) * ratio)
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -47,12 +48,14 @@ func (ac *AdminController) GetDeviceConfigs(c *gin.Context) {
 
 	// 构建配置响应
 	type ConfigResponse struct {
-		VAD     models.Config `json:"vad"`
-		ASR     models.Config `json:"asr"`
-		LLM     models.Config `json:"llm"`
-		TTS     models.Config `json:"tts"`
-		Prompt  string        `json:"prompt"`
-		AgentID string        `json:"agent_id"`
+		VAD         models.Config `json:"vad"`
+		ASR         models.Config `json:"asr"`
+		LLM         models.Config `json:"llm"`
+		TTS         models.Config `json:"tts"`
+		Prompt      string        `json:"prompt"`
+		AgentID     string        `json:"agent_id"`
+		RecordAudio bool          `json:"record_audio"`
+		EmotionMap  string        `json:"emotion_map"`
 	}
 
 	var response ConfigResponse
@@ -88,6 +91,8 @@ func (ac *AdminController) GetDeviceConfigs(c *gin.Context) {
 			}
 		} else {
 			response.Prompt = agent.CustomPrompt
+			response.RecordAudio = agent.RecordAudio
+			response.EmotionMap = agent.EmotionMap
 			log.Printf("智能体 %d 存在，使用自定义提示词", device.AgentID)
 		}
 	}
@@ -873,6 +878,7 @@ func (ac *AdminController) UpdateDevice(c *gin.Context) {
 		return
 	}
 
+	ac.notifyConfigChanged(false, nil, []string{device.DeviceName})
 	c.JSON(http.StatusOK, gin.H{"data": device})
 }
 
@@ -974,6 +980,37 @@ func (ac *AdminController) GetAgentMcpTools(c *gin.Context) {
 	GetAgentMcpToolsCommon(c, agentID, ac.WebSocketController, adminAgentValidator)
 }
 
+// GetConversations 分页查询全部对话记录, 可按 user_id、agent_id 过滤
+func (ac *AdminController) GetConversations(c *gin.Context) {
+	ListConversationsCommon(c, ac.conversationScope(c))
+}
+
+// ExportConversations 导出对话记录, 过滤条件与 GetConversations 相同
+func (ac *AdminController) ExportConversations(c *gin.Context) {
+	ExportConversationsCommon(c, ac.conversationScope(c), "conversations")
+}
+
+// GetAgentConversations 分页查询指定智能体的对话记录
+func (ac *AdminController) GetAgentConversations(c *gin.Context) {
+	var agent models.Agent
+	if err := ac.DB.Where("id = ?", c.Param("id")).First(&agent).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "智能体不存在"})
+		return
+	}
+	ListConversationsCommon(c, ac.DB.Where("agent_id = ?", agent.ID))
+}
+
+func (ac *AdminController) conversationScope(c *gin.Context) *gorm.DB {
+	query := ac.DB
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if agentID := c.Query("agent_id"); agentID != "" {
+		query = query.Where("agent_id = ?", agentID)
+	}
+	return query
+}
+
 func (ac *AdminController) CreateAgent(c *gin.Context) {
 	var agent models.Agent
 	if err := c.ShouldBindJSON(&agent); err != nil {
@@ -1008,6 +1045,7 @@ func (ac *AdminController) UpdateAgent(c *gin.Context) {
 		return
 	}
 
+	ac.notifyConfigChanged(false, []string{fmt.Sprintf("%d", agent.ID)}, nil)
 	c.JSON(http.StatusOK, gin.H{"data": agent})
 }
 
@@ -1382,6 +1420,12 @@ func (ac *AdminController) ToggleConfigEnable(c *gin.Context) {
 
 // 辅助方法
 func (ac *AdminController) createConfigWithType(c *gin.Context, config *models.Config) {
+	validated, err := ac.validateProviderConfig(config.Type, config.Provider, config.JsonData)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "配置校验失败: " + err.Error()})
+		return
+	}
+
 	// 如果没有提供config_id，自动生成一个
 	if config.ConfigID == "" {
 		// 使用类型_名称_时间戳的格式生成唯一ID
@@ -1400,7 +1444,7 @@ func (ac *AdminController) createConfigWithType(c *gin.Context, config *models.C
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"data": *config})
+	c.JSON(http.StatusCreated, configSavedResponse(*config, validated))
 }
 
 func (ac *AdminController) updateConfigWithType(c *gin.Context, configType string) {
@@ -1418,6 +1462,12 @@ func (ac *AdminController) updateConfigWithType(c *gin.Context, configType strin
 		return
 	}
 
+	validated, err := ac.validateProviderConfig(configType, updateData.Provider, updateData.JsonData)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "配置校验失败: " + err.Error()})
+		return
+	}
+
 	// 如果设置为默认配置，先取消其他同类型的默认配置
 	if updateData.IsDefault {
 		ac.DB.Model(&models.Config{}).Where("type = ? AND is_default = ? AND id != ?", configType, true, id).Update("is_default", false)
@@ -1440,7 +1490,97 @@ func (ac *AdminController) updateConfigWithType(c *gin.Context, configType strin
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": config})
+	// 提供者配置可能被多个智能体引用或作为默认配置, 通知全部在线设备重新加载
+	if providerConfigTypes[configType] {
+		ac.notifyConfigChanged(true, nil, nil)
+	}
+	c.JSON(http.StatusOK, configSavedResponse(config, validated))
+}
+
+// notifyConfigChanged 通知主服务器重新加载在线设备配置, 失败只记录日志, 不影响本次保存
+func (ac *AdminController) notifyConfigChanged(all bool, agentIDs, deviceIDs []string) {
+	if ac.WebSocketController == nil {
+		return
+	}
+	if err := ac.WebSocketController.NotifyConfigChanged(context.Background(), all, agentIDs, deviceIDs); err != nil {
+		log.Printf("通知配置变更失败: %v", err)
+	}
+}
+
+// providerConfigTypes 由主服务器提供者注册表管理的配置类型
+var providerConfigTypes = map[string]bool{"vad": true, "asr": true, "llm": true, "tts": true}
+
+// validateProviderConfig 通过主服务器按提供者配置描述校验配置
+// 不由提供者注册表管理的配置类型无需校验, validated 为 true; 主服务器不可用时 validated 为 false
+func (ac *AdminController) validateProviderConfig(configType, provider, jsonData string) (validated bool, err error) {
+	if !providerConfigTypes[configType] {
+		return true, nil
+	}
+	if provider == "" {
+		return false, fmt.Errorf("provider不能为空")
+	}
+
+	config := make(map[string]interface{})
+	if jsonData != "" {
+		if err := json.Unmarshal([]byte(jsonData), &config); err != nil {
+			return false, fmt.Errorf("json_data格式错误: %v", err)
+		}
+	}
+	if ac.WebSocketController == nil {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return ac.WebSocketController.ValidateProviderConfig(ctx, configType, provider, config)
+}
+
+// configSavedResponse 保存配置的响应, 未经主服务器校验时附带提示
+func configSavedResponse(config models.Config, validated bool) gin.H {
+	response := gin.H{"data": config, "validated": validated}
+	if !validated {
+		response["warning"] = "主服务器未连接，配置已保存但未经校验"
+	}
+	return response
+}
+
+// getProvidersWithType 获取主服务器已注册的提供者及配置描述
+func (ac *AdminController) getProvidersWithType(c *gin.Context, configType string) {
+	if ac.WebSocketController == nil {
+		c.JSON(http.StatusOK, gin.H{"data": []interface{}{}})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	providers, err := ac.WebSocketController.RequestProvidersFromClient(ctx, configType)
+	if err != nil {
+		log.Printf("获取%s提供者列表失败: %v", configType, err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "获取提供者列表失败: " + err.Error()})
+		return
+	}
+
+	data, ok := providers[configType]
+	if !ok {
+		data = []interface{}{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+func (ac *AdminController) GetVADProviders(c *gin.Context) {
+	ac.getProvidersWithType(c, "vad")
+}
+
+func (ac *AdminController) GetASRProviders(c *gin.Context) {
+	ac.getProvidersWithType(c, "asr")
+}
+
+func (ac *AdminController) GetLLMProviders(c *gin.Context) {
+	ac.getProvidersWithType(c, "llm")
+}
+
+func (ac *AdminController) GetTTSProviders(c *gin.Context) {
+	ac.getProvidersWithType(c, "tts")
 }
 
 func (ac *AdminController) deleteConfigWithType(c *gin.Context, configType string) {
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"xiaozhi/manager/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultConversationPageSize = 20
+	maxConversationPageSize     = 100
+	conversationExportBatchSize = 500
+)
+
+// conversationExportItem 导出的对话记录, 工具调用保持为JSON数组
+type conversationExportItem struct {
+	models.ConversationTurn
+	ToolCalls json.RawMessage `json:"tool_calls"`
+}
+
+// filterConversations 按请求参数过滤对话记录
+// 支持 device_id、session_id、keyword(匹配用户或助手文本)、start_time/end_time(RFC3339或2006-01-02)
+func filterConversations(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	if deviceID := c.Query("device_id"); deviceID != "" {
+		query = query.Where("device_id = ?", deviceID)
+	}
+	if sessionID := c.Query("session_id"); sessionID != "" {
+		query = query.Where("session_id = ?", sessionID)
+	}
+	if keyword := c.Query("keyword"); keyword != "" {
+		like := "%" + keyword + "%"
+		query = query.Where("user_text LIKE ? OR assistant_text LIKE ?", like, like)
+	}
+	if startTime := c.Query("start_time"); startTime != "" {
+		t, err := parseConversationTime(startTime)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("started_at >= ?", t)
+	}
+	if endTime := c.Query("end_time"); endTime != "" {
+		t, err := parseConversationTime(endTime)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("started_at <= ?", t)
+	}
+	return query, nil
+}
+
+func parseConversationTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("时间格式错误: %s", value)
+}
+
+// ListConversationsCommon 分页查询对话记录的公共函数, query 由调用方限定权限范围
+func ListConversationsCommon(c *gin.Context, query *gorm.DB) {
+	query, err := filterConversations(c, query.Model(&models.ConversationTurn{}))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 计数与分页查询共用过滤条件
+	query = query.Session(&gorm.Session{})
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultConversationPageSize)))
+	if pageSize < 1 {
+		pageSize = defaultConversationPageSize
+	}
+	if pageSize > maxConversationPageSize {
+		pageSize = maxConversationPageSize
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取对话记录失败"})
+		return
+	}
+
+	var turns []models.ConversationTurn
+	if err := query.Order("started_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&turns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取对话记录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      turns,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// csvCell 在以 = + - @ 或制表符、回车开头的文本前加单引号, 避免用户输入的对话内容在 Excel 中被当作公式执行
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// ExportConversationsCommon 导出对话记录的公共函数, format 支持 jsonl(默认) 和 csv
+func ExportConversationsCommon(c *gin.Context, query *gorm.DB, filename string) {
+	query, err := filterConversations(c, query.Model(&models.ConversationTurn{}))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format := c.DefaultQuery("format", "jsonl")
+	if format != "jsonl" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的导出格式: " + format})
+		return
+	}
+
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", filename, format))
+	c.Status(http.StatusOK)
+
+	var csvWriter *csv.Writer
+	encoder := json.NewEncoder(c.Writer)
+	if format == "csv" {
+		// 写入BOM便于Excel识别UTF-8
+		c.Writer.WriteString("\xEF\xBB\xBF")
+		csvWriter = csv.NewWriter(c.Writer)
+		csvWriter.Write([]string{"id", "turn_id", "started_at", "user_id", "agent_id", "device_id", "session_id", "user_text", "assistant_text", "tool_calls", "latency_ms", "duration_ms", "recorded"})
+	}
+
+	var turns []models.ConversationTurn
+	result := query.Order("started_at ASC, id ASC").FindInBatches(&turns, conversationExportBatchSize, func(tx *gorm.DB, batch int) error {
+		for _, turn := range turns {
+			if csvWriter != nil {
+				csvWriter.Write([]string{
+					strconv.FormatUint(uint64(turn.ID), 10),
+					csvCell(turn.TurnID),
+					turn.StartedAt.Format(time.RFC3339),
+					strconv.FormatUint(uint64(turn.UserID), 10),
+					strconv.FormatUint(uint64(turn.AgentID), 10),
+					csvCell(turn.DeviceID),
+					csvCell(turn.SessionID),
+					csvCell(turn.UserText),
+					csvCell(turn.AssistantText),
+					csvCell(turn.ToolCalls),
+					strconv.FormatInt(turn.LatencyMs, 10),
+					strconv.FormatInt(turn.DurationMs, 10),
+					strconv.FormatBool(turn.Recorded),
+				})
+				continue
+			}
+			item := conversationExportItem{ConversationTurn: turn, ToolCalls: json.RawMessage("[]")}
+			if json.Valid([]byte(turn.ToolCalls)) {
+				item.ToolCalls = json.RawMessage(turn.ToolCalls)
+			}
+			if err := encoder.Encode(item); err != nil {
+				return err
+			}
+		}
+		if csvWriter != nil {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+		return nil
+	})
+	if result.Error != nil {
+		// 响应头已发送, 只能记录日志
+		log.Printf("导出对话记录失败: %v", result.Error)
+	}
+}
@@ -91,6 +91,7 @@ func (sc *SetupController) InitializeDatabase(c *gin.Context) {
 		&models.Agent{},
 		&models.Config{},
 		&models.GlobalRole{},
+		&models.ConversationTurn{},
 	)
 	if err != nil {
 		tx.Rollback()
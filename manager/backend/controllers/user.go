@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
@@ -19,6 +20,7 @@ type UserController struct {
 	WebSocketController interface {
 		RequestMcpToolsFromClient(ctx context.Context, agentID string) ([]string, error)
 		InjectMessageToDevice(ctx context.Context, deviceID, message string, skipLlm bool) error
+		NotifyConfigChanged(ctx context.Context, all bool, agentIDs, deviceIDs []string) error
 	}
 }
 
@@ -235,12 +237,18 @@ func (uc *UserController) CreateAgent(c *gin.Context) {
 		LLMConfigID  *string `json:"llm_config_id"`
 		TTSConfigID  *string `json:"tts_config_id"`
 		ASRSpeed     string  `json:"asr_speed"`
+		RecordAudio  bool    `json:"record_audio"`
+		EmotionMap   string  `json:"emotion_map"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
 		return
 	}
+	if !validEmotionMap(req.EmotionMap) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "表情映射需为字符串到字符串的JSON对象"})
+		return
+	}
 
 	// 设置默认值
 	if req.ASRSpeed == "" {
@@ -254,6 +262,8 @@ func (uc *UserController) CreateAgent(c *gin.Context) {
 		LLMConfigID:  req.LLMConfigID,
 		TTSConfigID:  req.TTSConfigID,
 		ASRSpeed:     req.ASRSpeed,
+		RecordAudio:  req.RecordAudio,
+		EmotionMap:   req.EmotionMap,
 		Status:       "active",
 	}
 
@@ -319,18 +329,26 @@ func (uc *UserController) UpdateAgent(c *gin.Context) {
 		LLMConfigID  *string `json:"llm_config_id"`
 		TTSConfigID  *string `json:"tts_config_id"`
 		ASRSpeed     string  `json:"asr_speed"`
+		RecordAudio  bool    `json:"record_audio"`
+		EmotionMap   string  `json:"emotion_map"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
 		return
 	}
+	if !validEmotionMap(req.EmotionMap) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "表情映射需为字符串到字符串的JSON对象"})
+		return
+	}
 
 	// 更新字段
 	agent.Name = req.Name
 	agent.CustomPrompt = req.CustomPrompt
 	agent.LLMConfigID = req.LLMConfigID
 	agent.TTSConfigID = req.TTSConfigID
+	agent.RecordAudio = req.RecordAudio
+	agent.EmotionMap = req.EmotionMap
 
 	if req.ASRSpeed != "" {
 		agent.ASRSpeed = req.ASRSpeed
@@ -343,6 +361,12 @@ func (uc *UserController) UpdateAgent(c *gin.Context) {
 		return
 	}
 
+	// 通知主服务器, 使用该智能体的在线设备在下一轮对话前重新加载配置
+	if uc.WebSocketController != nil {
+		if err := uc.WebSocketController.NotifyConfigChanged(context.Background(), false, []string{fmt.Sprintf("%d", agent.ID)}, nil); err != nil {
+			log.Printf("通知配置变更失败: %v", err)
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"data": agent})
 }
 
@@ -557,6 +581,35 @@ func (uc *UserController) GetAgentMcpTools(c *gin.Context) {
 	GetAgentMcpToolsCommon(c, agentID, uc.WebSocketController, userAgentValidator)
 }
 
+// GetAgentConversations 分页查询智能体的对话记录
+func (uc *UserController) GetAgentConversations(c *gin.Context) {
+	agent, ok := uc.getOwnedAgent(c)
+	if !ok {
+		return
+	}
+	ListConversationsCommon(c, uc.DB.Where("agent_id = ? AND user_id = ?", agent.ID, agent.UserID))
+}
+
+// ExportAgentConversations 导出智能体的对话记录
+func (uc *UserController) ExportAgentConversations(c *gin.Context) {
+	agent, ok := uc.getOwnedAgent(c)
+	if !ok {
+		return
+	}
+	ExportConversationsCommon(c, uc.DB.Where("agent_id = ? AND user_id = ?", agent.ID, agent.UserID), fmt.Sprintf("agent_%d_conversations", agent.ID))
+}
+
+// getOwnedAgent 获取属于当前用户的智能体, 不存在时直接返回404
+func (uc *UserController) getOwnedAgent(c *gin.Context) (models.Agent, bool) {
+	userID, _ := c.Get("user_id")
+	var agent models.Agent
+	if err := uc.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&agent).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "智能体不存在"})
+		return agent, false
+	}
+	return agent, true
+}
+
 // 获取仪表板统计数据
 func (uc *UserController) GetDashboardStats(c *gin.Context) {
 	userID, _ := c.Get("user_id")
@@ -591,3 +644,12 @@ func (uc *UserController) GetDashboardStats(c *gin.Context) {
 
 	c.JSON(http.StatusOK, stats)
 }
+
+// validEmotionMap 表情映射为空或字符串到字符串的JSON对象
+func validEmotionMap(emotionMap string) bool {
+	if emotionMap == "" {
+		return true
+	}
+	var m map[string]string
+	return json.Unmarshal([]byte(emotionMap), &m) == nil
+}
@@ -301,6 +301,9 @@ func (client *WebSocketClient) processRequest(request *WebSocketRequest) {
 	case "/api/device/inactive":
 		client.handleDeviceInactiveRequest(request)
 
+	case "/api/device/conversation":
+		client.handleConversationRequest(request)
+
 	default:
 		log.Printf("未知的请求路径: %s", request.Path)
 		client.sendResponse(request.ID, 404, nil, "Unknown endpoint")
@@ -424,6 +427,69 @@ func (client *WebSocketClient) handleDeviceInactiveRequest(request *WebSocketReq
 	log.Printf("设备 %s 已设置为离线状态", deviceID)
 }
 
+// 处理主服务上报的一轮对话
+func (client *WebSocketClient) handleConversationRequest(request *WebSocketRequest) {
+	var req struct {
+		TurnID        string            `json:"turn_id"`
+		DeviceID      string            `json:"device_id"`
+		AgentID       string            `json:"agent_id"`
+		SessionID     string            `json:"session_id"`
+		UserText      string            `json:"user_text"`
+		AssistantText string            `json:"assistant_text"`
+		ToolCalls     []json.RawMessage `json:"tool_calls"`
+		LatencyMs     int64             `json:"latency_ms"`
+		DurationMs    int64             `json:"duration_ms"`
+		StartedAt     int64             `json:"started_at"` // 毫秒时间戳
+		Recorded      bool              `json:"recorded"`
+	}
+	data, _ := json.Marshal(request.Body)
+	if err := json.Unmarshal(data, &req); err != nil || req.DeviceID == "" {
+		log.Printf("收到对话记录上报，但参数错误: %v", err)
+		client.sendResponse(request.ID, 400, nil, "缺少device_id参数")
+		return
+	}
+
+	// 以设备当前归属为准确定用户和智能体，设备不存在时使用上报的agent_id
+	turn := models.ConversationTurn{
+		TurnID:        req.TurnID,
+		DeviceID:      req.DeviceID,
+		SessionID:     req.SessionID,
+		UserText:      req.UserText,
+		AssistantText: req.AssistantText,
+		LatencyMs:     req.LatencyMs,
+		DurationMs:    req.DurationMs,
+		Recorded:      req.Recorded,
+		StartedAt:     time.UnixMilli(req.StartedAt),
+	}
+	if req.StartedAt == 0 {
+		turn.StartedAt = time.Now()
+	}
+	var device models.Device
+	if err := client.controller.DB.Where("device_name = ?", req.DeviceID).First(&device).Error; err == nil {
+		turn.UserID = device.UserID
+		turn.AgentID = device.AgentID
+	} else {
+		var agent models.Agent
+		if err := client.controller.DB.Where("id = ?", req.AgentID).First(&agent).Error; err == nil {
+			turn.UserID = agent.UserID
+			turn.AgentID = agent.ID
+		}
+	}
+	if req.ToolCalls == nil {
+		req.ToolCalls = []json.RawMessage{}
+	}
+	toolCalls, _ := json.Marshal(req.ToolCalls)
+	turn.ToolCalls = string(toolCalls)
+
+	if err := client.controller.DB.Create(&turn).Error; err != nil {
+		log.Printf("保存对话记录失败: %v", err)
+		client.sendResponse(request.ID, 500, nil, fmt.Sprintf("保存对话记录失败: %v", err))
+		return
+	}
+
+	client.sendResponse(request.ID, 200, map[string]interface{}{"id": turn.ID}, "")
+}
+
 // 发送响应
 func (client *WebSocketClient) sendResponse(requestID string, status int, body map[string]interface{}, errorMsg string) {
 	response := WebSocketResponse{
@@ -702,6 +768,64 @@ func (ctrl *WebSocketController) RequestMcpToolsFromClient(ctx context.Context,
 	}
 }
 
+// firstConnectedClient 获取任意一个已连接的客户端，用于查询各主服务器一致的信息
+func (ctrl *WebSocketController) firstConnectedClient() *WebSocketClient {
+	for item := range ctrl.clientsMap.IterBuffered() {
+		if client := item.Val; client.isConnected {
+			return client
+		}
+	}
+	return nil
+}
+
+// RequestProvidersFromClient 请求主服务器已注册的提供者及配置描述，configType 为 vad/asr/llm/tts
+func (ctrl *WebSocketController) RequestProvidersFromClient(ctx context.Context, configType string) (map[string]interface{}, error) {
+	client := ctrl.firstConnectedClient()
+	if client == nil {
+		return nil, fmt.Errorf("没有连接的客户端")
+	}
+
+	response, err := client.SendRequestWithResponse(ctx, "GET", "/api/providers", map[string]interface{}{
+		"type": configType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.Status != http.StatusOK {
+		return nil, fmt.Errorf("获取提供者列表失败: %s", response.Error)
+	}
+	providers, _ := response.Body["providers"].(map[string]interface{})
+	return providers, nil
+}
+
+// ValidateProviderConfig 由主服务器按提供者配置描述校验配置
+// 没有连接的主服务器或请求失败时无法校验，validated 为 false 且不返回错误，由调用方提示配置未经校验
+func (ctrl *WebSocketController) ValidateProviderConfig(ctx context.Context, configType, provider string, config map[string]interface{}) (validated bool, err error) {
+	client := ctrl.firstConnectedClient()
+	if client == nil {
+		log.Printf("没有连接的客户端，%s配置未经校验", configType)
+		return false, nil
+	}
+
+	response, err := client.SendRequestWithResponse(ctx, "POST", "/api/providers/validate", map[string]interface{}{
+		"type":     configType,
+		"provider": provider,
+		"config":   config,
+	})
+	if err != nil {
+		log.Printf("请求配置校验失败，%s配置未经校验: %v", configType, err)
+		return false, nil
+	}
+	if response.Status != http.StatusOK {
+		return true, fmt.Errorf("%s", response.Error)
+	}
+	if valid, ok := response.Body["valid"].(bool); ok && !valid {
+		message, _ := response.Body["message"].(string)
+		return true, fmt.Errorf("%s", message)
+	}
+	return true, nil
+}
+
 // 请求客户端服务器信息
 func (ctrl *WebSocketController) RequestServerInfoFromClient(ctx context.Context, uuid string) (*WebSocketResponse, error) {
 	return ctrl.SendRequestToClient(ctx, uuid, "GET", "/api/server/info", nil)
@@ -758,6 +882,43 @@ func (ctrl *WebSocketController) InjectMessageToDevice(ctx context.Context, devi
 	return lastError
 }
 
+// NotifyConfigChanged 通知主服务器配置已变更（广播方式）
+// 主服务器按 agent_ids / device_ids 匹配在线设备, all 为 true 时重新加载全部在线设备, 新配置在下一轮对话前生效
+func (ctrl *WebSocketController) NotifyConfigChanged(ctx context.Context, all bool, agentIDs, deviceIDs []string) error {
+	body := map[string]interface{}{
+		"all":        all,
+		"agent_ids":  agentIDs,
+		"device_ids": deviceIDs,
+	}
+
+	request := WebSocketRequest{
+		ID:     uuid.New().String(),
+		Method: "POST",
+		Path:   "/api/device/config_changed",
+		Body:   body,
+	}
+
+	var lastError error
+	clientCount := 0
+
+	for item := range ctrl.clientsMap.IterBuffered() {
+		client := item.Val
+		if client.isConnected {
+			clientCount++
+			if err := client.conn.WriteJSON(request); err != nil {
+				log.Printf("向客户端 %s 广播配置变更失败: %v", client.ID, err)
+				lastError = err
+			}
+		}
+	}
+
+	if clientCount == 0 {
+		return fmt.Errorf("没有连接的客户端")
+	}
+
+	return lastError
+}
+
 // 异步发送请求到客户端（不等待响应）
 func (ctrl *WebSocketController) SendRequestToClientAsync(uuid string, method, path string, body map[string]interface{}) error {
 	if client, exists := ctrl.clientsMap.Get(uuid); exists && client.isConnected {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"xiaozhi/manager/backend/config"
+	"xiaozhi/manager/backend/models"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/sqlite"
@@ -37,6 +38,13 @@ func Init(cfg config.DatabaseConfig) *gorm.DB {
 	// 这些操作现在由引导页面通过API接口来处理
 	log.Println("数据库连接成功，等待引导页面初始化...")
 
+	// 已初始化的数据库补充后续版本新增的表和字段
+	if db.Migrator().HasTable(&models.User{}) {
+		if err := db.AutoMigrate(&models.Agent{}, &models.ConversationTurn{}); err != nil {
+			log.Println("更新数据库表结构失败:", err)
+		}
+	}
+
 	return db
 }
 
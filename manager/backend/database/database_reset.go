@@ -29,6 +29,7 @@ func InitWithReset(cfg config.DatabaseConfig) *gorm.DB {
 		&models.Agent{},
 		&models.Config{},
 		&models.GlobalRole{},
+		&models.ConversationTurn{},
 	)
 	if err != nil {
 		log.Printf("删除表时出现错误（可能表不存在）: %v", err)
@@ -40,6 +40,8 @@ type Agent struct {
 	TTSConfigID  *string   `json:"tts_config_id" gorm:"type:varchar(100)"`             // 音色配置ID
 	ASRSpeed     string    `json:"asr_speed" gorm:"type:varchar(20);default:'normal'"` // 语音识别速度: normal/patient/fast
 	Status       string    `json:"status" gorm:"type:varchar(20);default:'active'"`    // active, inactive
+	RecordAudio  bool      `json:"record_audio" gorm:"default:false"`                  // 是否保存对话录音, 用于排查识别问题
+	EmotionMap   string    `json:"emotion_map" gorm:"type:text"`                       // 表情映射JSON, 如 {"😊":"happy","开心":"happy"}
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -68,3 +70,21 @@ type GlobalRole struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
+
+// 对话记录模型，由主服务在每轮对话结束后上报
+type ConversationTurn struct {
+	ID            uint      `json:"id" gorm:"primarykey"`
+	UserID        uint      `json:"user_id" gorm:"index"`
+	AgentID       uint      `json:"agent_id" gorm:"index"`
+	DeviceID      string    `json:"device_id" gorm:"type:varchar(100);index"` // 设备名(MAC)
+	SessionID     string    `json:"session_id" gorm:"type:varchar(100);index"`
+	UserText      string    `json:"user_text" gorm:"type:longtext"`
+	AssistantText string    `json:"assistant_text" gorm:"type:longtext"`
+	ToolCalls     string    `json:"tool_calls" gorm:"type:longtext"`       // 工具调用及结果的JSON数组, 可能超过 text 的 64KB
+	LatencyMs     int64     `json:"latency_ms"`                            // 发起请求到首个响应的耗时
+	DurationMs    int64     `json:"duration_ms"`                           // 本轮对话总耗时
+	TurnID        string    `json:"turn_id" gorm:"type:varchar(64);index"` // 主服务生成的轮次ID，用于关联录音
+	Recorded      bool      `json:"recorded"`                              // 主服务是否保存了本轮录音
+	StartedAt     time.Time `json:"started_at" gorm:"index"`
+	CreatedAt     time.Time `json:"created_at"`
+}
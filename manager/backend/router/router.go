@@ -84,6 +84,10 @@ func Setup(db *gorm.DB) *gin.Engine {
 				user.GET("/agents/:id/mcp-endpoint", userController.GetAgentMCPEndpoint)
 				user.GET("/agents/:id/mcp-tools", userController.GetAgentMcpTools)
 
+				// 对话记录
+				user.GET("/agents/:id/conversations", userController.GetAgentConversations)
+				user.GET("/agents/:id/conversations/export", userController.ExportAgentConversations)
+
 				// 消息注入
 				user.POST("/devices/inject-message", userController.InjectMessage)
 			}
@@ -102,21 +106,25 @@ func Setup(db *gorm.DB) *gin.Engine {
 
 				// 具体配置类型路由（兼容前端）
 				admin.GET("/vad-configs", adminController.GetVADConfigs)
+				admin.GET("/vad-configs/providers", adminController.GetVADProviders)
 				admin.POST("/vad-configs", adminController.CreateVADConfig)
 				admin.PUT("/vad-configs/:id", adminController.UpdateVADConfig)
 				admin.DELETE("/vad-configs/:id", adminController.DeleteVADConfig)
 
 				admin.GET("/asr-configs", adminController.GetASRConfigs)
+				admin.GET("/asr-configs/providers", adminController.GetASRProviders)
 				admin.POST("/asr-configs", adminController.CreateASRConfig)
 				admin.PUT("/asr-configs/:id", adminController.UpdateASRConfig)
 				admin.DELETE("/asr-configs/:id", adminController.DeleteASRConfig)
 
 				admin.GET("/llm-configs", adminController.GetLLMConfigs)
+				admin.GET("/llm-configs/providers", adminController.GetLLMProviders)
 				admin.POST("/llm-configs", adminController.CreateLLMConfig)
 				admin.PUT("/llm-configs/:id", adminController.UpdateLLMConfig)
 				admin.DELETE("/llm-configs/:id", adminController.DeleteLLMConfig)
 
 				admin.GET("/tts-configs", adminController.GetTTSConfigs)
+				admin.GET("/tts-configs/providers", adminController.GetTTSProviders)
 				admin.POST("/tts-configs", adminController.CreateTTSConfig)
 				admin.PUT("/tts-configs/:id", adminController.UpdateTTSConfig)
 				admin.DELETE("/tts-configs/:id", adminController.DeleteTTSConfig)
@@ -175,6 +183,11 @@ func Setup(db *gorm.DB) *gin.Engine {
 				admin.DELETE("/agents/:id", adminController.DeleteAgent)
 				admin.GET("/agents/:id/mcp-endpoint", adminController.GetAgentMCPEndpoint)
 				admin.GET("/agents/:id/mcp-tools", adminController.GetAgentMcpTools)
+				admin.GET("/agents/:id/conversations", adminController.GetAgentConversations)
+
+				// 对话记录
+				admin.GET("/conversations", adminController.GetConversations)
+				admin.GET("/conversations/export", adminController.ExportConversations)
 
 				// 用户管理
 				admin.GET("/users", adminController.GetUsers)